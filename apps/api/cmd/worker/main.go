@@ -1,15 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
-	"strings"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+	"template-builder-api/internal/postprocess"
 	"template-builder-api/internal/queue"
+	"template-builder-api/internal/rendercache"
+	"template-builder-api/internal/renderer"
 	"template-builder-api/internal/repository"
 	"template-builder-api/internal/service"
+	"template-builder-api/internal/signing"
+	"template-builder-api/internal/storage"
 	"template-builder-api/pkg/db"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -22,53 +37,253 @@ func main() {
 	defer pool.Close()
 
 	repo := repository.NewPostgresRepository(pool)
+	// Background work across orgs bypasses row-level security explicitly;
+	// jobs run as their org.
+	system := db.AsSystem(context.Background())
+
+	// 2. Init Asset Service on the same storage as the API (STORAGE_BACKEND)
+	stores, err := storage.ProviderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	assetStore, err := stores.Bucket(service.AssetBucket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	assetService := service.NewAssetService(repo, assetStore)
 
-	// 2. Init Asset Service (Internally inits MinIO, we don't need separate client here if we use the constructor)
-	assetService, err := service.NewAssetService(repo, "localhost:9000", "minioadmin", "minioadmin")
+	// 3. Init Renderer Service, sharing the API's render cache
+	usageService := service.NewUsageService(repo)
+	cacheStore, err := stores.Bucket(rendercache.Bucket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	renderCache, err := rendercache.FromEnv(redis.NewClient(&redis.Options{Addr: "localhost:6380"}), cacheStore)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Large cached renders outlive their Redis entries; sweep them up
+	go func() {
+		for range time.Tick(time.Hour) {
+			if n, err := renderCache.Sweep(context.Background()); err != nil {
+				log.Printf("Failed to sweep render cache: %v", err)
+			} else if n > 0 {
+				log.Printf("Swept %d expired cached renders", n)
+			}
+		}
+	}()
+	rendererConfig, err := renderer.ConfigFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
+	rendererClient := renderer.New(rendererConfig)
+	go rendererClient.Run(context.Background())
+	renderService := service.NewRenderService(repo, rendererClient, renderCache, usageService, assetService)
 
-	// 3. Init Renderer Service
-	renderService := service.NewRenderService(repo, "http://localhost:3001")
+	// 3.1 PDF post-processing (watermarks, metadata, page numbers, protection, signatures)
+	signingKeys, err := signing.KeyBoxFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	signingService := service.NewSigningService(repo, signingKeys, signing.TimestamperFromEnv())
+	postProcessor := postprocess.Default(assetService, signingService)
 
 	// 4. Init Queue
 	q := queue.NewQueue("localhost:6380", "")
 
+	// 5. Webhooks: events are published from here and delivered in the background
+	webhookService := service.NewWebhookService(repo)
+	go webhookService.RunDispatcher(system)
+
+	// 6. Usage metering
+	go usageService.Run(system)
+
+	// 7. Scheduler: every worker runs it, the advisory lock picks one leader
+	generationService := service.NewGenerationService(repo, q, service.NewStatusEventHub(repo), usageService)
+	batchService := service.NewBatchService(repo, q, assetService, webhookService, usageService)
+	scheduleService := service.NewScheduleService(repo, generationService, batchService)
+	go scheduleService.Run(system)
+
 	log.Println("Worker started...")
 
-	// 5. Start Consumer
-	q.Consume(context.Background(), "workers-group", "worker-1", func(jobPayload queue.JobPayload) error {
+	// 8. Start Consumer
+	// Per-org concurrency: orgs.max_concurrent_jobs, else WORKER_ORG_CONCURRENCY
+	defaultLimit := 4
+	if v := os.Getenv("WORKER_ORG_CONCURRENCY"); v != "" {
+		if defaultLimit, err = strconv.Atoi(v); err != nil {
+			log.Fatalf("Invalid WORKER_ORG_CONCURRENCY: %v", err)
+		}
+	}
+	limits := &orgLimits{repo: repo, fallback: defaultLimit, cache: map[uuid.UUID]orgLimit{}}
+
+	q.Consume(context.Background(), "workers-group", consumerName(), queue.ConsumeOptions{OrgConcurrency: limits.get}, func(jobPayload queue.JobPayload) error {
 		log.Printf("Processing Job: %s", jobPayload.JobID)
 
-		ctx := context.Background()
+		ctx := db.WithOrgID(context.Background(), jobPayload.OrgID)
 
 		// 1. Update Status to Processing
 		repo.UpdateJobStatus(ctx, jobPayload.JobID, "processing", nil, "")
 
-		// 2. Call Renderer (Reusing Preview Logic but getting raw bytes)
-		// Ideally RenderService should support generating generic IO Reader without version sometimes,
-		// but here we use PreviewTemplate which fetches latest version by default if 0.
-		pdfBytes, err := renderService.PreviewTemplate(ctx, jobPayload.TemplateID, 1)
+		// 2. Call Renderer; the document is streamed from here on
+		// Jobs created before versions were carried in the payload render version 1.
+		version := jobPayload.Version
+		if version == 0 {
+			version = 1
+		}
+		doc, contentType, err := renderService.RenderTemplate(ctx, jobPayload.OrgID, jobPayload.TemplateID, version, jobPayload.Data, jobPayload.Output)
 		if err != nil {
 			repo.UpdateJobStatus(ctx, jobPayload.JobID, "failed", nil, err.Error())
+			finishJob(ctx, repo, webhookService, jobPayload, false)
 			return err
 		}
+		defer doc.Close()
+
+		// 3. Post-process PDFs with the template's settings and the request's
+		// overrides. Only those are read into memory; other documents go
+		// straight to storage, PDFs having their pages counted on the way.
+		isPDF := contentType == model.OutputContentTypes[model.OutputPDF]
+		var reader io.Reader = doc
+		size := int64(-1)
+		var countPages func() (int, error)
+		if isPDF {
+			job, err := postProcessJob(ctx, repo, jobPayload, version)
+			if err == nil && postProcessor.Applies(job) {
+				var data []byte
+				if data, err = io.ReadAll(doc); err == nil {
+					data, err = postProcessor.Run(ctx, data, job)
+				}
+				reader, size = bytes.NewReader(data), int64(len(data))
+				countPages = func() (int, error) { return pdf.PageCount(data) }
+			} else if err == nil {
+				scanner := &pdf.PageScanner{}
+				reader = io.TeeReader(doc, scanner)
+				countPages = scanner.Count
+			}
+			if err != nil {
+				repo.UpdateJobStatus(ctx, jobPayload.JobID, "failed", nil, err.Error())
+				finishJob(ctx, repo, webhookService, jobPayload, false)
+				return err
+			}
+		}
 
-		// 3. Upload to MinIO
-		reader := strings.NewReader(string(pdfBytes)) // inefficient cast but OK for MVP
-		filename := fmt.Sprintf("generated/%s.pdf", jobPayload.JobID)
+		// 4. Upload to MinIO
+		filename := fmt.Sprintf("generated/%s%s", jobPayload.JobID, model.OutputExtension(contentType))
 
-		asset, err := assetService.UploadAsset(ctx, jobPayload.OrgID, reader, filename, int64(len(pdfBytes)), "application/pdf")
+		asset, err := assetService.UploadAsset(ctx, jobPayload.OrgID, reader, filename, size, contentType)
 		if err != nil {
 			repo.UpdateJobStatus(ctx, jobPayload.JobID, "failed", nil, "Failed to upload asset: "+err.Error())
+			finishJob(ctx, repo, webhookService, jobPayload, false)
 			return err
 		}
 
-		// 4. Update Status to Completed
+		// 5. Update Status to Completed
 		repo.UpdateJobStatus(ctx, jobPayload.JobID, "completed", &asset.ID, "")
+		usageService.Record(jobPayload.OrgID, model.MetricDocuments, 1)
+		// Pages are only metered for PDFs; other formats have no page count to read
+		if isPDF {
+			if pages, err := countPages(); err == nil {
+				usageService.Record(jobPayload.OrgID, model.MetricPages, int64(pages))
+			} else {
+				log.Printf("Job %s: failed to count pages: %v", jobPayload.JobID, err)
+			}
+		}
+		finishJob(ctx, repo, webhookService, jobPayload, true)
 
 		log.Printf("Job Completed: %s", jobPayload.JobID)
 		return nil
 	})
 }
+
+// consumerName identifies this process in the queue's consumer group:
+// WORKER_NAME if set (and unique per replica), else host and pid.
+func consumerName() string {
+	if name := os.Getenv("WORKER_NAME"); name != "" {
+		return name
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// postProcessJob describes the post-processing of a job's PDF. Unpublished
+// versions count as drafts for the template's draft watermark.
+func postProcessJob(ctx context.Context, repo repository.Repository, jobPayload queue.JobPayload, version int) (*postprocess.Job, error) {
+	tmpl, err := repo.GetTemplate(ctx, jobPayload.OrgID, jobPayload.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	tmplVersion, err := repo.GetTemplateVersion(ctx, jobPayload.OrgID, jobPayload.TemplateID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var override *model.PostProcessing
+	if jobPayload.Output != nil {
+		override = jobPayload.Output.PostProcessing
+	}
+	return &postprocess.Job{
+		OrgID:   jobPayload.OrgID,
+		Data:    jobPayload.Data,
+		Draft:   tmplVersion.Status != "published",
+		Options: tmpl.PostProcessing.Merge(override),
+	}, nil
+}
+
+// orgLimits caches each org's job concurrency limit for a minute, since it
+// is looked up for every job the consumer considers.
+type orgLimits struct {
+	repo     repository.Repository
+	fallback int
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]orgLimit
+}
+
+type orgLimit struct {
+	limit   int
+	expires time.Time
+}
+
+func (l *orgLimits) get(orgID uuid.UUID) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c, ok := l.cache[orgID]; ok && time.Now().Before(c.expires) {
+		return c.limit
+	}
+	limit := l.fallback
+	if org, err := l.repo.GetOrg(db.WithOrgID(context.Background(), orgID), orgID); err == nil && org.MaxConcurrentJobs != nil {
+		limit = *org.MaxConcurrentJobs
+	}
+	l.cache[orgID] = orgLimit{limit: limit, expires: time.Now().Add(time.Minute)}
+	return limit
+}
+
+// finishJob publishes the job's outcome and counts it towards its batch,
+// if it has one.
+func finishJob(ctx context.Context, repo repository.Repository, events service.EventPublisher, jobPayload queue.JobPayload, succeeded bool) {
+	eventType := model.EventJobFailed
+	if succeeded {
+		eventType = model.EventJobCompleted
+	}
+	if job, err := repo.GetJob(ctx, jobPayload.OrgID, jobPayload.JobID); err == nil {
+		events.Publish(ctx, jobPayload.OrgID, eventType, job)
+	} else {
+		log.Printf("Failed to load job %s for %s event: %v", jobPayload.JobID, eventType, err)
+	}
+
+	if jobPayload.BatchID == nil {
+		return
+	}
+	batch, err := repo.RecordBatchJobResult(ctx, *jobPayload.BatchID, succeeded)
+	if err != nil {
+		log.Printf("Failed to record batch progress for job %s: %v", jobPayload.JobID, err)
+		return
+	}
+	if batch.Status == "completed" {
+		log.Printf("Batch Completed: %s (%d ok, %d failed)", batch.ID, batch.Completed, batch.Failed)
+		events.Publish(ctx, jobPayload.OrgID, model.EventBatchCompleted, batch)
+	}
+}
@@ -1,7 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
 	"template-builder-api/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -9,11 +13,12 @@ import (
 )
 
 type AssetHandler struct {
-	svc *service.AssetService
+	svc   *service.AssetService
+	usage *service.UsageService
 }
 
-func NewAssetHandler(svc *service.AssetService) *AssetHandler {
-	return &AssetHandler{svc: svc}
+func NewAssetHandler(svc *service.AssetService, usage *service.UsageService) *AssetHandler {
+	return &AssetHandler{svc: svc, usage: usage}
 }
 
 func (h *AssetHandler) UploadAsset(c *gin.Context) {
@@ -27,6 +32,23 @@ func (h *AssetHandler) UploadAsset(c *gin.Context) {
 	// 1. Get Org ID from Auth
 	orgID := c.MustGet("orgID").(uuid.UUID)
 
+	if err := h.usage.CheckUpload(c.Request.Context(), orgID, fileHeader.Size); err != nil {
+		if errors.Is(err, service.ErrAssetTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check upload limit"})
+		return
+	}
+	if err := h.usage.CheckStorage(c.Request.Context(), orgID, fileHeader.Size); err != nil {
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check storage quota"})
+		return
+	}
+
 	file, err := fileHeader.Open()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
@@ -34,11 +56,249 @@ func (h *AssetHandler) UploadAsset(c *gin.Context) {
 	}
 	defer file.Close()
 
-	asset, err := h.svc.UploadAsset(c.Request.Context(), orgID, file, fileHeader.Filename, fileHeader.Size, fileHeader.Header.Get("Content-Type"))
+	// The client's Content-Type is ignored; the type comes from the content.
+	asset, err := h.svc.UploadFile(c.Request.Context(), orgID, file, fileHeader.Filename, fileHeader.Size)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		uploadError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, asset)
 }
+
+type CreateUploadRequest struct {
+	Filename    string   `json:"filename" binding:"required"`
+	ContentType string   `json:"content_type" binding:"required"`
+	SizeBytes   int64    `json:"size_bytes" binding:"required,min=1"`
+	Folder      string   `json:"folder"`
+	Tags        []string `json:"tags"`
+}
+
+// CreateUpload starts an upload straight to storage, for files too large
+// to send through the API. The response says how to send the file; once
+// sent, the client calls CompleteUpload.
+func (h *AssetHandler) CreateUpload(c *gin.Context) {
+	var req CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	if err := h.usage.CheckUpload(c.Request.Context(), orgID, req.SizeBytes); err != nil {
+		if errors.Is(err, service.ErrAssetTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check upload limit"})
+		return
+	}
+	if err := h.usage.CheckStorage(c.Request.Context(), orgID, req.SizeBytes); err != nil {
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check storage quota"})
+		return
+	}
+
+	upload := &model.AssetUpload{
+		OrgID:       orgID,
+		CreatedBy:   &userID,
+		Filename:    req.Filename,
+		ContentType: req.ContentType,
+		SizeBytes:   req.SizeBytes,
+		Folder:      req.Folder,
+		Tags:        req.Tags,
+	}
+	presigned, err := h.svc.CreateUpload(c.Request.Context(), upload)
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"upload": upload, "request": presigned})
+}
+
+func (h *AssetHandler) CompleteUpload(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid upload id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	asset, err := h.svc.CompleteUpload(c.Request.Context(), orgID, id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return
+	case errors.Is(err, service.ErrUploadNotReceived):
+		c.JSON(http.StatusConflict, gin.H{"error": "file has not been uploaded"})
+		return
+	case err != nil:
+		uploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, asset)
+}
+
+// uploadError answers for a file the upload policy refused, or a failure.
+func uploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnsupportedAsset):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAssetTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidAsset):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store asset"})
+	}
+}
+
+const (
+	defaultAssetPageSize = 50
+	maxAssetPageSize     = 200
+)
+
+// ListAssets serves GET /assets?type=&content_type=&filename=&folder=&tag=,
+// newest first. filename matches any part of the name; folder matches one
+// folder exactly, with folder= (empty) for the top level.
+func (h *AssetHandler) ListAssets(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	filter := model.AssetFilter{
+		OrgID:       orgID,
+		Type:        c.Query("type"),
+		ContentType: c.Query("content_type"),
+		Filename:    c.Query("filename"),
+		Tag:         c.Query("tag"),
+		Limit:       defaultAssetPageSize,
+	}
+	if folder, ok := c.GetQuery("folder"); ok {
+		filter.Folder = &folder
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		filter.Limit = min(limit, maxAssetPageSize)
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return
+		}
+		filter.Offset = offset
+	}
+
+	assets, total, err := h.svc.ListAssets(c.Request.Context(), filter)
+	if errors.Is(err, service.ErrInvalidAsset) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list assets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  assets,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+func (h *AssetHandler) ListFolders(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	folders, err := h.svc.ListFolders(c.Request.Context(), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list folders"})
+		return
+	}
+	c.JSON(http.StatusOK, folders)
+}
+
+func (h *AssetHandler) GetAsset(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	asset, err := h.svc.GetAsset(c.Request.Context(), orgID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get asset"})
+		return
+	}
+	c.JSON(http.StatusOK, asset)
+}
+
+// UpdateAsset renames, moves or retags an asset; omitted fields are kept.
+func (h *AssetHandler) UpdateAsset(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	var req model.AssetUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	asset, err := h.svc.UpdateAsset(c.Request.Context(), orgID, id, req)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
+		return
+	case errors.Is(err, service.ErrInvalidAsset):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update asset"})
+		return
+	}
+	c.JSON(http.StatusOK, asset)
+}
+
+// DeleteAsset answers 409 with the references while anything published
+// still uses the asset.
+func (h *AssetHandler) DeleteAsset(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	err = h.svc.DeleteAsset(c.Request.Context(), orgID, id)
+	var inUse *repository.AssetInUseError
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
+		return
+	case errors.As(err, &inUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "asset is in use", "references": inUse.References})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete asset"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 
+	"template-builder-api/internal/ratelimit"
 	"template-builder-api/internal/service"
 
 	"template-builder-api/internal/utils"
@@ -12,10 +17,11 @@ import (
 
 type AuthHandler struct {
 	authService *service.AuthService
+	lockout     *ratelimit.Lockout
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService *service.AuthService, lockout *ratelimit.Lockout) *AuthHandler {
+	return &AuthHandler{authService: authService, lockout: lockout}
 }
 
 type RegisterRequest struct {
@@ -52,11 +58,35 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.authService.Login(c.Request.Context(), req.Email, req.Password)
+	ctx := c.Request.Context()
+	// Lockout is best-effort: if Redis is down, logins still work.
+	if wait, err := h.lockout.Locked(ctx, req.Email); err != nil {
+		log.Printf("Login lockout: %v", err)
+	} else if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+		return
+	}
+
+	token, err := h.authService.Login(ctx, req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			if err := h.lockout.Fail(ctx, req.Email); err != nil {
+				log.Printf("Login lockout: %v", err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		if errors.Is(err, service.ErrNoOrg) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User belongs to no organisation"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
 		return
 	}
+	if err := h.lockout.Succeed(ctx, req.Email); err != nil {
+		log.Printf("Login lockout: %v", err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"token": token})
 }
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/service"
+	"template-builder-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxBatchUploadBytes bounds the request body for inline batch files.
+// Larger inputs should be uploaded as an asset first and referenced by ID.
+const maxBatchUploadBytes = 64 << 20
+
+type BatchHandler struct {
+	svc *service.BatchService
+}
+
+func NewBatchHandler(svc *service.BatchService) *BatchHandler {
+	return &BatchHandler{svc: svc}
+}
+
+type CreateBatchRequest struct {
+	AssetID string            `json:"assetId" form:"assetId"`
+	Format  string            `json:"format" form:"format" binding:"omitempty,oneof=csv jsonl"`
+	Version int               `json:"version" form:"version" binding:"omitempty,min=1"`
+	Mapping map[string]string `json:"mapping" form:"-"`
+}
+
+// CreateBatch accepts either a multipart upload (field "file", plus optional
+// "format", "version" and a JSON-encoded "mapping") or a JSON body naming an
+// already uploaded asset.
+func (h *BatchHandler) CreateBatch(c *gin.Context) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return
+	}
+
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchUploadBytes)
+
+	var req CreateBatchRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.FormatValidationError(err)})
+		return
+	}
+
+	input := service.BatchInput{Format: req.Format, Version: req.Version, Mapping: req.Mapping}
+
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		if raw := c.PostForm("mapping"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &input.Mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON object of column to field path"})
+				return
+			}
+		}
+
+		if fileHeader, err := c.FormFile("file"); err == nil {
+			file, err := fileHeader.Open()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+				return
+			}
+			defer file.Close()
+
+			input.Reader = file
+			input.Filename = fileHeader.Filename
+			input.ContentType = fileHeader.Header.Get("Content-Type")
+		}
+	}
+
+	if input.Reader == nil {
+		if req.AssetID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file or assetId is required"})
+			return
+		}
+		assetID, err := uuid.Parse(req.AssetID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset id"})
+			return
+		}
+		input.SourceAssetID = &assetID
+	}
+
+	batch, err := h.svc.CreateBatch(c.Request.Context(), orgID, userID, templateID, input)
+	if errors.Is(err, service.ErrInvalidBatchInput) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template, version or asset not found"})
+		return
+	}
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create batch"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+func (h *BatchHandler) GetBatch(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	batch, err := h.svc.GetBatch(c.Request.Context(), orgID, batchID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// DownloadArchive streams a finished batch as a ZIP of its documents, or as
+// one merged PDF with format=pdf. Entry names come from the optional
+// filename pattern, e.g. "{{customer.name}}-{{invoice.number}}.pdf".
+func (h *BatchHandler) DownloadArchive(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	var stream func(io.Writer) error
+	var contentType, filename string
+	switch format := c.DefaultQuery("format", "zip"); format {
+	case "zip":
+		stream, err = h.svc.PrepareArchive(c.Request.Context(), orgID, batchID, c.Query("filename"))
+		contentType, filename = "application/zip", fmt.Sprintf("batch-%s.zip", batchID)
+	case "pdf":
+		stream, err = h.svc.PrepareMergedPDF(c.Request.Context(), orgID, batchID)
+		contentType, filename = "application/pdf", fmt.Sprintf("batch-%s.pdf", batchID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of: zip pdf"})
+		return
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+		return
+	case errors.Is(err, service.ErrBatchNotReady), errors.Is(err, service.ErrBatchEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare archive"})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	if err := stream(c.Writer); err != nil {
+		// Headers are already sent; all we can do is cut the response short
+		log.Printf("Batch %s archive failed: %v", batchID, err)
+		c.Abort()
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// The heartbeat keeps proxies from closing idle streams; on each beat the
+// current state is also re-read in case a notification was lost.
+const sseHeartbeat = 15 * time.Second
+
+// EventsHandler streams job and batch status changes as Server-Sent Events.
+type EventsHandler struct {
+	repo repository.Repository
+	hub  *service.StatusEventHub
+}
+
+func NewEventsHandler(repo repository.Repository, hub *service.StatusEventHub) *EventsHandler {
+	return &EventsHandler{repo: repo, hub: hub}
+}
+
+func (h *EventsHandler) JobEvents(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	h.stream(c, model.StatusEventJob, id, func(ctx context.Context) (model.StatusEvent, error) {
+		job, err := h.repo.GetJob(ctx, orgID, id)
+		if err != nil {
+			return model.StatusEvent{}, err
+		}
+		return model.JobStatusEvent(job), nil
+	})
+}
+
+func (h *EventsHandler) BatchEvents(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	h.stream(c, model.StatusEventBatch, id, func(ctx context.Context) (model.StatusEvent, error) {
+		batch, err := h.repo.GetBatch(ctx, orgID, id)
+		if err != nil {
+			return model.StatusEvent{}, err
+		}
+		return model.BatchStatusEvent(batch), nil
+	})
+}
+
+// stream sends the current state, then every change, until the resource
+// reaches a final state or the client goes away. Events carry their Seq as
+// ID, so a reconnecting client's Last-Event-ID suppresses what it has seen.
+func (h *EventsHandler) stream(c *gin.Context, kind string, id uuid.UUID, load func(context.Context) (model.StatusEvent, error)) {
+	ctx := c.Request.Context()
+
+	// Subscribe before the first read so no change can slip in between.
+	events, unsubscribe := h.hub.Subscribe(kind, id)
+	defer unsubscribe()
+
+	current, err := load(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": kind + " not found"})
+		return
+	}
+
+	lastSeq, _ := strconv.ParseInt(c.GetHeader("Last-Event-ID"), 10, 64)
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	// send writes ev unless the client has already seen it, and reports
+	// whether the stream should end.
+	send := func(ev model.StatusEvent) bool {
+		if ev.OrgID != current.OrgID || ev.Seq() <= lastSeq {
+			return false
+		}
+		data, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", ev.Seq(), data)
+		w.Flush()
+		lastSeq = ev.Seq()
+		return ev.Final()
+	}
+
+	if send(current) || current.Final() {
+		return
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			if send(ev) {
+				return
+			}
+		case <-heartbeat.C:
+			if ev, err := load(ctx); err == nil && send(ev) {
+				return
+			}
+			fmt.Fprint(w, ": heartbeat\n\n")
+			w.Flush()
+		}
+	}
+}
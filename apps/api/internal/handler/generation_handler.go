@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
 	"time"
 
 	"template-builder-api/internal/model"
 	"template-builder-api/internal/queue"
 	"template-builder-api/internal/repository"
 	"template-builder-api/internal/service"
+	"template-builder-api/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -16,54 +21,136 @@ import (
 
 type GenerationHandler struct {
 	repo         repository.Repository
-	queue        *queue.Queue
+	generation   *service.GenerationService
 	assetService *service.AssetService
+	syncTimeout  time.Duration
 }
 
-func NewGenerationHandler(repo repository.Repository, queue *queue.Queue, assetService *service.AssetService) *GenerationHandler {
-	return &GenerationHandler{repo: repo, queue: queue, assetService: assetService}
+// syncTimeout caps how long POST /templates/:id/render waits for its job.
+func NewGenerationHandler(repo repository.Repository, generation *service.GenerationService, assetService *service.AssetService, syncTimeout time.Duration) *GenerationHandler {
+	return &GenerationHandler{repo: repo, generation: generation, assetService: assetService, syncTimeout: syncTimeout}
 }
 
-func (h *GenerationHandler) GeneratePDF(c *gin.Context) {
-	idStr := c.Param("id")
-	templateID, err := uuid.Parse(idStr)
+type GenerateRequest struct {
+	Version int                  `json:"version"` // 0 means the latest version
+	Data    map[string]any       `json:"data"`
+	Output  *model.OutputOptions `json:"output"` // defaults to PDF
+}
+
+// createJob binds the optional request body and queues a job, writing the
+// error response itself when that fails.
+func (h *GenerationHandler) createJob(c *gin.Context, priority string) (*model.GenerationJob, bool) {
+	templateID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
-		return
+		return nil, false
+	}
+
+	var req GenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.FormatValidationError(err)})
+		return nil, false
 	}
 
 	// Org ID from Auth
 	orgID := c.MustGet("orgID").(uuid.UUID)
+	userID := c.MustGet("userID").(uuid.UUID)
 
-	jobID := uuid.New()
-	job := &model.GenerationJob{
-		ID:         jobID,
-		OrgID:      orgID,
-		TemplateID: templateID,
-		Status:     "pending",
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	job, err := h.generation.CreateJob(c.Request.Context(), orgID, userID, templateID, req.Version, req.Data, req.Output, priority)
+	switch {
+	case errors.Is(err, service.ErrInvalidMergeData), errors.Is(err, service.ErrInvalidOutput), errors.Is(err, service.ErrInvalidPostProcessing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return nil, false
+	case errors.Is(err, service.ErrQuotaExceeded):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create job"})
+		return nil, false
 	}
+	return job, true
+}
 
-	if err := h.repo.CreateJob(c.Request.Context(), job); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create job"})
+func (h *GenerationHandler) GeneratePDF(c *gin.Context) {
+	job, ok := h.createJob(c, queue.PriorityDefault)
+	if !ok {
 		return
 	}
 
-	// Enqueue
-	err = h.queue.EnqueueJob(c.Request.Context(), queue.JobPayload{
-		JobID:      jobID,
-		OrgID:      orgID,
-		TemplateID: templateID,
-		Data:       nil, // Can pass merge data here
-	})
+	c.JSON(http.StatusAccepted, gin.H{"jobId": job.ID})
+}
+
+// RenderSync queues a normal job and waits for it, returning the document
+// itself (or, with response=redirect, a redirect to it). Jobs that outlast
+// the wait get the same 202 as GeneratePDF and can be polled.
+//
+// Query: wait (seconds, capped at the server's sync timeout), response
+// (body or redirect).
+func (h *GenerationHandler) RenderSync(c *gin.Context) {
+	wait := h.syncTimeout
+	if v := c.Query("wait"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "wait must be a non-negative number of seconds"})
+			return
+		}
+		wait = min(time.Duration(secs)*time.Second, h.syncTimeout)
+	}
+	mode := c.DefaultQuery("response", "body")
+	if mode != "body" && mode != "redirect" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "response must be body or redirect"})
+		return
+	}
+
+	// Someone is waiting on this one, so it jumps ahead of queued batches.
+	job, ok := h.createJob(c, queue.PriorityInteractive)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	job, err := h.generation.WaitForJob(ctx, job.OrgID, job.ID, wait)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read job status"})
+		}
+		return
+	}
+
+	switch {
+	case job.Status == "failed":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"jobId": job.ID, "status": job.Status, "error": job.ErrorMessage})
+		return
+	case job.Status != "completed" || job.OutputAssetID == nil:
+		c.Header("Location", "/v1/jobs/"+job.ID.String())
+		c.JSON(http.StatusAccepted, gin.H{"jobId": job.ID, "status": job.Status})
+		return
+	}
+
+	c.Header("X-Job-Id", job.ID.String())
+	if mode == "redirect" {
+		url, err := h.assetService.GetDownloadURL(ctx, job.OrgID, *job.OutputAssetID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign download url"})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, url)
+		return
+	}
+
+	obj, asset, err := h.assetService.OpenAsset(ctx, job.OrgID, *job.OutputAssetID)
 	if err != nil {
-		h.repo.UpdateJobStatus(c.Request.Context(), jobID, "failed", nil, err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enqueue job"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open output"})
 		return
 	}
+	defer obj.Close()
 
-	c.JSON(http.StatusAccepted, gin.H{"jobId": jobID})
+	c.DataFromReader(http.StatusOK, asset.SizeBytes, asset.ContentType, obj, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`inline; filename="%s"`, path.Base(asset.Filename)),
+	})
 }
 
 func (h *GenerationHandler) GetJobStatus(c *gin.Context) {
@@ -74,7 +161,8 @@ func (h *GenerationHandler) GetJobStatus(c *gin.Context) {
 		return
 	}
 
-	job, err := h.repo.GetJob(c.Request.Context(), jobID)
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	job, err := h.repo.GetJob(c.Request.Context(), orgID, jobID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
 		return
@@ -89,12 +177,13 @@ func (h *GenerationHandler) GetJobStatus(c *gin.Context) {
 		"status":        job.Status,
 		"outputAssetId": job.OutputAssetID,
 		"errorMessage":  job.ErrorMessage,
+		"output":        job.Output,
 		"createdAt":     job.CreatedAt,
 		"updatedAt":     job.UpdatedAt,
 	}
 
 	if job.Status == "completed" && job.OutputAssetID != nil {
-		url, err := h.assetService.GetDownloadURL(c.Request.Context(), *job.OutputAssetID)
+		url, err := h.assetService.GetDownloadURL(c.Request.Context(), orgID, *job.OutputAssetID)
 		if err == nil {
 			response["downloadUrl"] = url
 		} else {
@@ -104,3 +193,88 @@ func (h *GenerationHandler) GetJobStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+const (
+	defaultJobPageSize = 50
+	maxJobPageSize     = 200
+)
+
+func (h *GenerationHandler) ListJobs(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	filter := model.JobFilter{
+		OrgID:  orgID,
+		Status: c.Query("status"),
+		Limit:  defaultJobPageSize,
+	}
+
+	if filter.Status != "" {
+		switch filter.Status {
+		case "pending", "processing", "completed", "failed":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of: pending processing completed failed"})
+			return
+		}
+	}
+
+	uuidParams := map[string]**uuid.UUID{
+		"templateId": &filter.TemplateID,
+		"batchId":    &filter.BatchID,
+		"createdBy":  &filter.CreatedBy,
+	}
+	for name, dst := range uuidParams {
+		if v := c.Query(name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s", name)})
+				return
+			}
+			*dst = &id
+		}
+	}
+
+	timeParams := map[string]**time.Time{
+		"from": &filter.CreatedAfter,
+		"to":   &filter.CreatedBefore,
+	}
+	for name, dst := range timeParams {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be an RFC3339 timestamp", name)})
+				return
+			}
+			*dst = &t
+		}
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		filter.Limit = min(limit, maxJobPageSize)
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return
+		}
+		filter.Offset = offset
+	}
+
+	jobs, total, err := h.repo.ListJobs(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  jobs,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
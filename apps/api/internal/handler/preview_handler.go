@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"template-builder-api/internal/renderer"
+	"template-builder-api/internal/repository"
 	"template-builder-api/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -31,13 +34,30 @@ func (h *PreviewHandler) PreviewTemplate(c *gin.Context) {
 		version = 1 // Default to 1 for MVP if not provided
 	}
 
-	pdfBytes, err := h.svc.PreviewTemplate(c.Request.Context(), id, version)
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	doc, err := h.svc.PreviewTemplate(c.Request.Context(), orgID, id, version)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidAsset) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, renderer.ErrUnavailable) {
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "renderer is unavailable, try again later"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Header("Content-Type", "application/pdf")
-	c.Header("Content-Disposition", `attachment; filename="preview.pdf"`)
-	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+	defer doc.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "application/pdf", doc, map[string]string{
+		"Content-Disposition": `attachment; filename="preview.pdf"`,
+	})
 }
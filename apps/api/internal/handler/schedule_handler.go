@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/service"
+	"template-builder-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ScheduleHandler struct {
+	svc *service.ScheduleService
+}
+
+func NewScheduleHandler(svc *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{svc: svc}
+}
+
+type ScheduleRequest struct {
+	TemplateID    uuid.UUID       `json:"templateId" binding:"required"`
+	Name          string          `json:"name" binding:"required"`
+	Cron          string          `json:"cron" binding:"required"`
+	Timezone      string          `json:"timezone"`
+	VersionPolicy string          `json:"versionPolicy" binding:"required,oneof=latest published pinned"`
+	Version       *int            `json:"version"`
+	DataSource    string          `json:"dataSource" binding:"required,oneof=payload url asset"`
+	Payload       json.RawMessage `json:"payload"`
+	SourceURL     string          `json:"sourceUrl"`
+	SourceAssetID *uuid.UUID      `json:"sourceAssetId"`
+	Active        *bool           `json:"active"` // defaults to true
+}
+
+func (r ScheduleRequest) input() service.ScheduleInput {
+	active := r.Active == nil || *r.Active
+	return service.ScheduleInput{
+		TemplateID:    r.TemplateID,
+		Name:          r.Name,
+		CronExpr:      r.Cron,
+		Timezone:      r.Timezone,
+		VersionPolicy: r.VersionPolicy,
+		Version:       r.Version,
+		DataSource:    r.DataSource,
+		Payload:       r.Payload,
+		SourceURL:     r.SourceURL,
+		SourceAssetID: r.SourceAssetID,
+		Active:        active,
+	}
+}
+
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.FormatValidationError(err)})
+		return
+	}
+
+	schedule, err := h.svc.CreateSchedule(c.Request.Context(), orgID, userID, req.input())
+	if errors.Is(err, service.ErrInvalidSchedule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	schedules, err := h.svc.ListSchedules(c.Request.Context(), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	schedule, err := h.svc.GetSchedule(c.Request.Context(), orgID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.FormatValidationError(err)})
+		return
+	}
+
+	schedule, err := h.svc.UpdateSchedule(c.Request.Context(), orgID, id, req.input())
+	switch {
+	case errors.Is(err, service.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	err = h.svc.DeleteSchedule(c.Request.Context(), orgID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete schedule"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ScheduleHandler) ListRuns(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	runs, err := h.svc.ListRuns(c.Request.Context(), orgID, id, limit, offset)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
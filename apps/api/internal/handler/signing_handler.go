@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxCertificateUploadBytes bounds PKCS#12 uploads; real bundles are a
+	// few kilobytes.
+	maxCertificateUploadBytes = 1 << 20
+	// maxVerifyUploadBytes bounds documents submitted for verification.
+	maxVerifyUploadBytes = 50 << 20
+)
+
+type SigningHandler struct {
+	svc *service.SigningService
+}
+
+func NewSigningHandler(svc *service.SigningService) *SigningHandler {
+	return &SigningHandler{svc: svc}
+}
+
+// CreateCertificate takes a multipart upload with the PKCS#12 bundle in
+// "file", its "password" and an optional display "name".
+func (h *SigningHandler) CreateCertificate(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCertificateUploadBytes)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+		return
+	}
+	defer file.Close()
+
+	bundle, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+
+	cert, err := h.svc.CreateCertificate(c.Request.Context(), orgID, userID, c.PostForm("name"), bundle, c.PostForm("password"))
+	if errors.Is(err, service.ErrInvalidCertificate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store certificate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cert)
+}
+
+func (h *SigningHandler) ListCertificates(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	certs, err := h.svc.ListCertificates(c.Request.Context(), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list certificates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, certs)
+}
+
+func (h *SigningHandler) GetCertificate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid certificate id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	cert, err := h.svc.GetCertificate(c.Request.Context(), orgID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "certificate not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cert)
+}
+
+func (h *SigningHandler) DeleteCertificate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid certificate id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	err = h.svc.DeleteCertificate(c.Request.Context(), orgID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "certificate not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete certificate"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Verify checks the signatures in a PDF sent either as a multipart "file"
+// or as the raw request body.
+func (h *SigningHandler) Verify(c *gin.Context) {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxVerifyUploadBytes)
+
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read document"})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document is required"})
+		return
+	}
+
+	report, err := h.svc.Verify(c.Request.Context(), orgID, data)
+	if errors.Is(err, service.ErrInvalidPDF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -1,7 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
 	"template-builder-api/internal/service"
 	"template-builder-api/internal/utils"
 
@@ -55,8 +59,9 @@ func (h *TemplateHandler) ListTemplates(c *gin.Context) {
 }
 
 type CreateVersionRequest struct {
-	TemplateJSON map[string]any `json:"templateJson"`
-	SchemaJSON   map[string]any `json:"schemaJson"`
+	TemplateJSON map[string]any   `json:"templateJson"`
+	SchemaJSON   map[string]any   `json:"schemaJson"`
+	PageSetup    *model.PageSetup `json:"pageSetup"` // A4 portrait when omitted
 }
 
 func (h *TemplateHandler) CreateVersion(c *gin.Context) {
@@ -69,6 +74,7 @@ func (h *TemplateHandler) CreateVersion(c *gin.Context) {
 
 	// User ID from Auth
 	userID := c.MustGet("userID").(uuid.UUID)
+	orgID := c.MustGet("orgID").(uuid.UUID)
 
 	var req CreateVersionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -76,7 +82,15 @@ func (h *TemplateHandler) CreateVersion(c *gin.Context) {
 		return
 	}
 
-	version, err := h.svc.CreateVersion(c.Request.Context(), templateID, userID, req.TemplateJSON, req.SchemaJSON)
+	version, err := h.svc.CreateVersion(c.Request.Context(), orgID, templateID, userID, req.TemplateJSON, req.SchemaJSON, req.PageSetup)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidPageSetup) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -85,23 +99,57 @@ func (h *TemplateHandler) CreateVersion(c *gin.Context) {
 	c.JSON(http.StatusCreated, version)
 }
 
-func (h *TemplateHandler) GetTemplate(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
+// UpdateVersion replaces a draft version's content; published versions
+// are immutable.
+func (h *TemplateHandler) UpdateVersion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	var req CreateVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	t, err := h.svc.GetTemplate(c.Request.Context(), id)
+	v, err := h.svc.UpdateVersion(c.Request.Context(), orgID, id, version, req.TemplateJSON, req.SchemaJSON, req.PageSetup)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return
+	case errors.Is(err, service.ErrInvalidPageSetup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrVersionPublished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
 
-	// RBAC Check
 	orgID := c.MustGet("orgID").(uuid.UUID)
-	if t.OrgID != orgID {
+	t, err := h.svc.GetTemplate(c.Request.Context(), orgID, id)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
 	}
@@ -117,25 +165,75 @@ func (h *TemplateHandler) ListVersions(c *gin.Context) {
 		return
 	}
 
-	// TODO: Verify access to template (check OrgID) first.
-	// For now assuming if they know ID they can list versions, or we fetch Template first.
-	// Ideally Service handles this or we repeat the check.
-	t, err := h.svc.GetTemplate(c.Request.Context(), id)
-	if err != nil {
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	if _, err := h.svc.GetTemplate(c.Request.Context(), orgID, id); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
 		return
 	}
+
+	versions, err := h.svc.ListVersions(c.Request.Context(), orgID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func (h *TemplateHandler) PublishVersion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+
 	orgID := c.MustGet("orgID").(uuid.UUID)
-	if t.OrgID != orgID {
-		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+	v, err := h.svc.PublishVersion(c.Request.Context(), orgID, id, version)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	versions, err := h.svc.ListVersions(c.Request.Context(), id)
+	c.JSON(http.StatusOK, v)
+}
+
+// UpdatePostProcessing replaces the template's PDF post-processing
+// settings. A null body clears them.
+func (h *TemplateHandler) UpdatePostProcessing(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+
+	var req *model.PostProcessing
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.FormatValidationError(err)})
+		return
+	}
+
+	t, err := h.svc.UpdatePostProcessing(c.Request.Context(), orgID, id, req)
+	switch {
+	case errors.Is(err, service.ErrInvalidPostProcessing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, versions)
+	c.JSON(http.StatusOK, t)
 }
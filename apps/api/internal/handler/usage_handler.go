@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"template-builder-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxUsageRangeDays = 366
+
+type UsageHandler struct {
+	svc *service.UsageService
+}
+
+func NewUsageHandler(svc *service.UsageService) *UsageHandler {
+	return &UsageHandler{svc: svc}
+}
+
+// GetUsage reports an org's usage per day. Query: from, to (YYYY-MM-DD,
+// inclusive; default the current month so far).
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid org id"})
+		return
+	}
+	orgID := c.MustGet("orgID").(uuid.UUID)
+	if id != orgID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "org not found"})
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.DateOnly, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.DateOnly, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a YYYY-MM-DD date"})
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) > maxUsageRangeDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to, and the range is limited to a year"})
+		return
+	}
+
+	report, err := h.svc.GetUsage(c.Request.Context(), orgID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,149 @@
+// Package imaging decodes uploaded images and makes smaller renditions of
+// them, in pure Go.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	// ErrUnsupported is returned for data no decoder recognises.
+	ErrUnsupported = errors.New("imaging: unsupported image")
+	// ErrTooLarge is returned for images over MaxPixels.
+	ErrTooLarge = errors.New("imaging: image too large")
+)
+
+// MaxPixels bounds what Decode will decode, so a small file can't claim
+// dimensions that take gigabytes to decode.
+const MaxPixels = 50_000_000
+
+// jpegQuality is for renditions of opaque images.
+const jpegQuality = 82
+
+// Size is a rendition size: the longer side is at most MaxDim pixels.
+type Size struct {
+	Name   string
+	MaxDim int
+}
+
+// Sizes are the renditions made for each image, largest first.
+var Sizes = []Size{
+	{"large", 2560},
+	{"medium", 1280},
+	{"small", 640},
+	{"thumbnail", 256},
+}
+
+// Info describes a decoded image. Width and Height are as displayed, after
+// any EXIF orientation. DPI is 0 if the file doesn't say.
+type Info struct {
+	Format string
+	Width  int
+	Height int
+	DPI    float64
+}
+
+// Decode decodes a PNG, JPEG, GIF (its first frame) or WebP image,
+// applying a JPEG's EXIF orientation.
+func Decode(data []byte) (image.Image, Info, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, Info{}, fmt.Errorf("%w: %dx%d is over %d pixels", ErrTooLarge, cfg.Width, cfg.Height, MaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	info := Info{Format: format}
+	switch format {
+	case "png":
+		info.DPI = pngDPI(data)
+	case "jpeg":
+		info.DPI = jpegDPI(data)
+		img = orient(img, jpegOrientation(data))
+	}
+	b := img.Bounds()
+	info.Width, info.Height = b.Dx(), b.Dy()
+	return img, info, nil
+}
+
+// Rendition is an encoded, resized copy of an image.
+type Rendition struct {
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// Renditions makes a rendition in each of Sizes smaller than img. Each is
+// scaled from the one before, which is much faster than scaling each from
+// a large original and looks the same. Opaque images are encoded as JPEG,
+// others as PNG.
+func Renditions(img image.Image) ([]Rendition, error) {
+	opaque := isOpaque(img)
+	var out []Rendition
+	src := img
+	for _, size := range Sizes {
+		b := src.Bounds()
+		if max(b.Dx(), b.Dy()) <= size.MaxDim {
+			continue
+		}
+		src = Resize(src, size.MaxDim)
+		r := Rendition{Name: size.Name, Width: src.Bounds().Dx(), Height: src.Bounds().Dy()}
+		var buf bytes.Buffer
+		var err error
+		if opaque {
+			r.ContentType = "image/jpeg"
+			err = jpeg.Encode(&buf, src, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			r.ContentType = "image/png"
+			err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, src)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("imaging: failed to encode %s rendition: %w", size.Name, err)
+		}
+		r.Data = buf.Bytes()
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Resize scales img so its longer side is maxDim, keeping its aspect
+// ratio. Images already that small are returned as they are.
+func Resize(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if max(w, h) <= maxDim {
+		return img
+	}
+	if w >= h {
+		w, h = maxDim, max(1, (h*maxDim+w/2)/w)
+	} else {
+		w, h = max(1, (w*maxDim+h/2)/h), maxDim
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// pngDPI reads the pHYs chunk, which comes before the image data.
+func pngDPI(data []byte) float64 {
+	const sig = 8
+	for i := sig; i+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		typ := string(data[i+4 : i+8])
+		body := i + 8
+		if typ == "IDAT" || n < 0 || body+n > len(data) {
+			return 0
+		}
+		// Pixels per unit on x and y, then the unit: 1 is the metre
+		if typ == "pHYs" && n >= 9 && data[body+8] == 1 {
+			return float64(binary.BigEndian.Uint32(data[body:])) * 0.0254
+		}
+		i = body + n + 4 // and the CRC
+	}
+	return 0
+}
+
+// jpegSegments calls fn with each marker segment before the image data
+// until fn returns false.
+func jpegSegments(data []byte, fn func(marker byte, body []byte) bool) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return
+		}
+		marker := data[i+1]
+		if marker == 0xDA { // start of scan
+			return
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return
+		}
+		if !fn(marker, data[i+4:i+2+n]) {
+			return
+		}
+		i += 2 + n
+	}
+}
+
+// jpegDPI reads the JFIF header's density.
+func jpegDPI(data []byte) float64 {
+	var dpi float64
+	jpegSegments(data, func(marker byte, body []byte) bool {
+		if marker != 0xE0 || len(body) < 12 || !bytes.HasPrefix(body, []byte("JFIF\x00")) {
+			return true
+		}
+		x := float64(binary.BigEndian.Uint16(body[8:]))
+		switch body[7] {
+		case 1: // dots per inch
+			dpi = x
+		case 2: // dots per centimetre
+			dpi = x * 2.54
+		}
+		return false
+	})
+	return dpi
+}
+
+// jpegOrientation reads the EXIF orientation, 1 to 8; 1 is upright.
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	jpegSegments(data, func(marker byte, body []byte) bool {
+		if marker != 0xE1 || !bytes.HasPrefix(body, []byte("Exif\x00\x00")) {
+			return true
+		}
+		tiff := body[6:]
+		if len(tiff) < 8 {
+			return false
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return false
+		}
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd < 8 || ifd+2 > len(tiff) {
+			return false
+		}
+		entries := int(order.Uint16(tiff[ifd:]))
+		for e := 0; e < entries; e++ {
+			at := ifd + 2 + 12*e
+			if at+12 > len(tiff) {
+				break
+			}
+			if order.Uint16(tiff[at:]) == 0x0112 {
+				if v := int(order.Uint16(tiff[at+8:])); v >= 1 && v <= 8 {
+					orientation = v
+				}
+				break
+			}
+		}
+		return false
+	})
+	return orientation
+}
+
+// orient turns an image stored in EXIF orientation o upright.
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored and rotated 270 clockwise
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // mirrored and rotated 90 clockwise
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 270 clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
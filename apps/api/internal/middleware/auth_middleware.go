@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"template-builder-api/internal/service"
+	"template-builder-api/pkg/db"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -50,14 +51,18 @@ func AuthMiddleware(authService *service.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		// Extract Org ID
-		var orgID uuid.UUID
-		if orgStr, ok := claims["org"].(string); ok {
-			orgID, _ = uuid.Parse(orgStr)
+		// Every request runs as one org; row-level security shows nothing
+		// to a request without one.
+		orgStr, _ := claims["org"].(string)
+		orgID, err := uuid.Parse(orgStr)
+		if err != nil || orgID == uuid.Nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token org id"})
+			return
 		}
 
 		c.Set("userID", userID)
 		c.Set("orgID", orgID)
+		c.Request = c.Request.WithContext(db.WithOrgID(c.Request.Context(), orgID))
 		c.Next()
 	}
 }
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	idempotencyTTL = 24 * time.Hour
+	// An in-progress key older than this belongs to a request that died.
+	idempotencyStaleAfter = 5 * time.Minute
+	maxIdempotencyKeyLen  = 255
+	// Bodies are spooled to disk to be hashed; this matches the largest
+	// upload any idempotent endpoint accepts.
+	maxIdempotentBody = 64 << 20
+)
+
+// Idempotency honours the Idempotency-Key header: the first response for a
+// key is stored for 24 hours and replayed for retries of the same request.
+// Reusing a key for a different request is rejected with 422. Only
+// successes and validation errors are stored (see replayable); after any
+// other response, such as a quota or rate limit rejection or a server
+// error, the retry runs again. Must run after AuthMiddleware.
+func Idempotency(repo repository.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+		orgID := c.MustGet("orgID").(uuid.UUID)
+		ctx := c.Request.Context()
+
+		requestHash, cleanup, err := spoolAndHash(c)
+		defer cleanup()
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+
+		now := time.Now()
+		rec := &model.IdempotencyRecord{
+			OrgID:       orgID,
+			Key:         key,
+			RequestHash: requestHash,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(idempotencyTTL),
+		}
+		existing, err := repo.ClaimIdempotencyKey(ctx, rec, idempotencyStaleAfter)
+		if err != nil {
+			log.Printf("Idempotency: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check Idempotency-Key"})
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case existing.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// The client may be gone by now; the outcome must still be recorded.
+		ctx = context.WithoutCancel(ctx)
+		if !replayable(w.Status()) {
+			if err := repo.ReleaseIdempotencyKey(ctx, orgID, key); err != nil {
+				log.Printf("Idempotency: %v", err)
+			}
+			return
+		}
+		rec.StatusCode = w.Status()
+		rec.ContentType = w.Header().Get("Content-Type")
+		rec.Body = w.body.Bytes()
+		if err := repo.SaveIdempotencyResponse(ctx, rec); err != nil {
+			log.Printf("Idempotency: %v", err)
+		}
+	}
+}
+
+// replayable reports whether a response with status is stored for retries:
+// successes, and rejections that the same request will always get.
+func replayable(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity:
+		return true
+	}
+	return status >= 200 && status < 300
+}
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// spoolAndHash copies the request body to a temporary file, which replaces
+// it for the handler, and returns a hash of method, URL and body. Multipart
+// bodies are hashed part by part so that a retry with a fresh boundary
+// still matches.
+func spoolAndHash(c *gin.Context) (string, func(), error) {
+	f, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return "", func() {}, err
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBody)
+	if _, err := io.Copy(f, body); err != nil {
+		return "", cleanup, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", cleanup, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", c.Request.Method, c.Request.URL.RequestURI())
+	mediaType, params, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType == "multipart/form-data" && params["boundary"] != "" {
+		err = hashMultipart(h, f, params["boundary"])
+	} else {
+		_, err = io.Copy(h, f)
+	}
+	if err != nil {
+		return "", cleanup, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", cleanup, err
+	}
+
+	c.Request.Body = f
+	return hex.EncodeToString(h.Sum(nil)), cleanup, nil
+}
+
+func hashMultipart(h hash.Hash, r io.Reader, boundary string) error {
+	mr := multipart.NewReader(r, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "part %q %q\n", part.FormName(), part.FileName())
+		n, err := io.Copy(h, part)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "\n%d\n", n)
+	}
+}
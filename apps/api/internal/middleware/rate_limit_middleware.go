@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"template-builder-api/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PlanResolver returns the plan ID of an org, used to pick its rules.
+type PlanResolver func(ctx context.Context, orgID uuid.UUID) (string, error)
+
+// RateLimit applies the group's rule to each caller: authenticated requests
+// are limited per user under their org's plan, anonymous ones per client
+// IP. It sets RateLimit-* headers on every response and rejects with 429
+// and Retry-After once the limit is hit. Redis failures let requests
+// through.
+func RateLimit(limiter *ratelimit.Limiter, config ratelimit.Config, group string, plans PlanResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plan := ratelimit.DefaultPlan
+		key := group + ":ip:" + c.ClientIP()
+		if userID, ok := c.Get("userID"); ok {
+			key = group + ":user:" + userID.(uuid.UUID).String()
+			if orgID, ok := c.Get("orgID"); ok && plans != nil {
+				if p, err := plans(c.Request.Context(), orgID.(uuid.UUID)); err == nil {
+					plan = p
+				}
+			}
+		}
+
+		rule, ok := config.Rule(group, plan)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), key, rule)
+		if err != nil {
+			log.Printf("Rate limit: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", rule.Policy())
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UsageMiddleware counts API calls per org and rejects them with 429 once
+// the plan's daily allowance is used up. Calls rejected with 429, by it or
+// by a rate limit, aren't counted. Must run after AuthMiddleware, and
+// after the route group's rate limit so requests it rejects aren't looked
+// at.
+func UsageMiddleware(usage *service.UsageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.MustGet("orgID").(uuid.UUID)
+
+		err := usage.CheckAPICall(c.Request.Context(), orgID)
+		if errors.Is(err, service.ErrDailyQuotaExceeded) {
+			// The allowance resets at midnight UTC.
+			now := time.Now().UTC()
+			reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+			c.Header("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			// Metering problems must not take the API down.
+			log.Printf("Usage: failed to check API quota for %s: %v", orgID, err)
+		}
+
+		c.Next()
+		if c.Writer.Status() != http.StatusTooManyRequests {
+			usage.Record(orgID, model.MetricAPICalls, 1)
+		}
+	}
+}
@@ -6,14 +6,111 @@ import (
 	"github.com/google/uuid"
 )
 
+// Asset types
+const (
+	AssetTypeImage = "image"
+	AssetTypeFont  = "font"
+	AssetTypeDocx  = "docx"
+	AssetTypePDF   = "pdf"
+	AssetTypeCSV   = "csv"
+	// AssetTypeDocument is a generated document in any other format.
+	AssetTypeDocument = "document"
+)
+
 type Asset struct {
 	ID          uuid.UUID `json:"id"`
 	OrgID       uuid.UUID `json:"org_id"`
-	Type        string    `json:"type"` // image, font, docx
+	Type        string    `json:"type"` // One of the AssetType constants
 	Filename    string    `json:"filename"`
 	ContentType string    `json:"content_type"`
 	SizeBytes   int64     `json:"size_bytes"`
-	S3Key       string    `json:"-"`
-	URL         string    `json:"url,omitempty"` // Presigned URL for display
-	CreatedAt   time.Time `json:"created_at"`
+	// ChecksumSHA256 is the hex SHA-256 of the stored object; empty for
+	// assets uploaded before checksums were recorded.
+	ChecksumSHA256 string `json:"checksum_sha256,omitempty"`
+	// Folder is a slash-separated path, "" for the top level.
+	Folder string   `json:"folder"`
+	Tags   []string `json:"tags"`
+	// Width, Height and DPI describe decoded images; DPI is 0 if the file
+	// doesn't say.
+	Width      int              `json:"width,omitempty"`
+	Height     int              `json:"height,omitempty"`
+	DPI        float64          `json:"dpi,omitempty"`
+	Renditions []AssetRendition `json:"renditions,omitempty"`
+	S3Key      string           `json:"-"`
+	URL        string           `json:"url,omitempty"` // Presigned URL for display
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// AssetRendition is a smaller copy of an image asset, made on upload.
+type AssetRendition struct {
+	Name        string `json:"name"` // large, medium, small or thumbnail
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	URL         string `json:"url,omitempty"`
+}
+
+// Rendition returns the named rendition, or nil.
+func (a *Asset) Rendition(name string) *AssetRendition {
+	for i := range a.Renditions {
+		if a.Renditions[i].Name == name {
+			return &a.Renditions[i]
+		}
+	}
+	return nil
+}
+
+// AssetFilter narrows ListAssets. Zero values mean "no filter".
+type AssetFilter struct {
+	OrgID       uuid.UUID
+	Type        string
+	ContentType string
+	// Filename matches case-insensitively anywhere in the name.
+	Filename string
+	// Folder matches one folder exactly; "" is the top level.
+	Folder *string
+	Tag    string
+	Limit  int
+	Offset int
+}
+
+// AssetUpdate renames or reorganises an asset. Nil fields are unchanged.
+type AssetUpdate struct {
+	Filename *string   `json:"filename"`
+	Folder   *string   `json:"folder"`
+	Tags     *[]string `json:"tags"`
+}
+
+// AssetFolder is a folder in an org's library and how many assets it holds
+// directly.
+type AssetFolder struct {
+	Folder string `json:"folder"`
+	Count  int    `json:"count"`
+}
+
+// AssetReference is something that still uses an asset.
+type AssetReference struct {
+	Kind       string     `json:"kind"` // template_version, template, schedule
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	Version    int        `json:"version,omitempty"`
+	ScheduleID *uuid.UUID `json:"schedule_id,omitempty"`
+}
+
+// AssetUpload is a presigned upload straight to storage, waiting for the
+// client to report it complete.
+type AssetUpload struct {
+	ID          uuid.UUID  `json:"id"`
+	OrgID       uuid.UUID  `json:"org_id"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty"`
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"content_type"`
+	// SizeBytes is the declared size, the most the upload may store.
+	SizeBytes   int64      `json:"size_bytes"`
+	Folder      string     `json:"folder"`
+	Tags        []string   `json:"tags"`
+	S3Key       string     `json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Batch struct {
+	ID            uuid.UUID         `json:"id"`
+	OrgID         uuid.UUID         `json:"orgId"`
+	TemplateID    uuid.UUID         `json:"templateId"`
+	Version       int               `json:"version"`
+	Status        string            `json:"status"`       // processing, completed
+	SourceFormat  string            `json:"sourceFormat"` // csv, jsonl
+	SourceAssetID *uuid.UUID        `json:"sourceAssetId,omitempty"`
+	ColumnMapping map[string]string `json:"columnMapping,omitempty"`
+	Total         int               `json:"total"`
+	Completed     int               `json:"completed"`
+	Failed        int               `json:"failed"`
+	RowErrors     []BatchRowError   `json:"rowErrors"`
+	CreatedBy     *uuid.UUID        `json:"createdBy,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+}
+
+// BatchRowError records why a single input row did not produce a job.
+// Row is 1-based and counts data rows only (a CSV header is not a row).
+type BatchRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord is the stored outcome of the first request made with an
+// Idempotency-Key. StatusCode is 0 while that request is still running.
+type IdempotencyRecord struct {
+	OrgID       uuid.UUID
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
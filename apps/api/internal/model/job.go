@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,9 +11,41 @@ type GenerationJob struct {
 	ID            uuid.UUID  `json:"id"`
 	OrgID         uuid.UUID  `json:"orgId"`
 	TemplateID    uuid.UUID  `json:"templateId"`
+	BatchID       *uuid.UUID `json:"batchId,omitempty"`
+	BatchRow      int        `json:"batchRow,omitempty"`
+	CreatedBy     *uuid.UUID `json:"createdBy,omitempty"`
 	Status        string     `json:"status"` // pending, processing, completed, failed
 	OutputAssetID *uuid.UUID `json:"outputAssetId,omitempty"`
 	ErrorMessage  string     `json:"errorMessage,omitempty"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	UpdatedAt     time.Time  `json:"updatedAt"`
+	// Output is nil for a PDF with the template's own layout.
+	Output    *OutputOptions `json:"output,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+
+	// Data is the merge data the job was created with. It is stored but not
+	// loaded by GetJob/ListJobs.
+	Data json.RawMessage `json:"-"`
+}
+
+// JobOutput is a completed job's output asset along with the merge data
+// used to produce it.
+type JobOutput struct {
+	JobID    uuid.UUID
+	BatchRow int
+	Data     json.RawMessage
+	AssetID  uuid.UUID
+	S3Key    string
+}
+
+// JobFilter narrows ListJobs. Zero values mean "no filter".
+type JobFilter struct {
+	OrgID         uuid.UUID
+	Status        string
+	TemplateID    *uuid.UUID
+	BatchID       *uuid.UUID
+	CreatedBy     *uuid.UUID
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
+	Offset        int
 }
@@ -17,9 +17,10 @@ type User struct {
 }
 
 type Org struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                uuid.UUID `json:"id"`
+	Name              string    `json:"name"`
+	MaxConcurrentJobs *int      `json:"max_concurrent_jobs,omitempty"` // nil uses the worker default
+	CreatedAt         time.Time `json:"created_at"`
 }
 
 type Membership struct {
@@ -31,12 +32,14 @@ type Membership struct {
 }
 
 type Template struct {
-	ID        uuid.UUID `json:"id"`
-	OrgID     uuid.UUID `json:"org_id"`
-	Name      string    `json:"name"`
-	Type      string    `json:"type"`   // layout, docx
-	Status    string    `json:"status"` // active, archived
-	CreatedAt time.Time `json:"created_at"`
+	ID     uuid.UUID `json:"id"`
+	OrgID  uuid.UUID `json:"org_id"`
+	Name   string    `json:"name"`
+	Type   string    `json:"type"`   // layout, docx
+	Status string    `json:"status"` // active, archived
+	// PostProcessing is applied to every PDF generated from the template.
+	PostProcessing *PostProcessing `json:"post_processing,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
 }
 
 type TemplateVersion struct {
@@ -46,6 +49,7 @@ type TemplateVersion struct {
 	Status       string         `json:"status"` // draft, published, archived
 	TemplateJSON map[string]any `json:"template_json,omitempty"`
 	SchemaJSON   map[string]any `json:"schema_json,omitempty"`
+	PageSetup    *PageSetup     `json:"page_setup,omitempty"`
 	DocxAssetID  *uuid.UUID     `json:"docx_asset_id,omitempty"`
 	CreatedBy    *uuid.UUID     `json:"created_by,omitempty"`
 	CreatedAt    time.Time      `json:"created_at"`
@@ -0,0 +1,113 @@
+package model
+
+// Output formats a document can be generated in.
+const (
+	OutputPDF  = "pdf"
+	OutputPNG  = "png"
+	OutputJPEG = "jpeg"
+	OutputHTML = "html"
+	OutputDOCX = "docx"
+)
+
+var OutputFormats = []string{OutputPDF, OutputPNG, OutputJPEG, OutputHTML, OutputDOCX}
+
+// PageSizeCustom takes its dimensions from PageSetup.Width and Height.
+const PageSizeCustom = "custom"
+
+// Page sizes and orientations the renderer understands.
+var (
+	PageSizes    = []string{"A3", "A4", "A5", "Letter", "Legal", PageSizeCustom}
+	Orientations = []string{"portrait", "landscape"}
+)
+
+const ContentTypeZIP = "application/zip"
+
+// OutputContentTypes is the content type of a single-file output of each
+// format. Multi-page image outputs are packaged as ContentTypeZIP instead.
+var OutputContentTypes = map[string]string{
+	OutputPDF:  "application/pdf",
+	OutputPNG:  "image/png",
+	OutputJPEG: "image/jpeg",
+	OutputHTML: "text/html; charset=utf-8",
+	OutputDOCX: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// PageSetup describes the physical page. Template versions carry one, and
+// generation requests may override any part of it. Dimensions are in
+// millimetres; zero values leave the choice to the renderer (A4 portrait).
+type PageSetup struct {
+	PageSize string `json:"pageSize,omitempty"`
+	// Width and Height are the trim size of a custom page.
+	Width       float64  `json:"pageWidth,omitempty"`
+	Height      float64  `json:"pageHeight,omitempty"`
+	Orientation string   `json:"orientation,omitempty"`
+	Margins     *Margins `json:"margins,omitempty"`
+	// Bleed extends the page beyond the trim size on every side, for print.
+	Bleed *float64 `json:"bleed,omitempty"`
+}
+
+// Merge returns p with the parts set in override replacing its own. A page
+// size in override replaces the dimensions as a whole.
+func (p *PageSetup) Merge(override *PageSetup) *PageSetup {
+	var out PageSetup
+	if p != nil {
+		out = *p
+	}
+	if override == nil {
+		return &out
+	}
+	if override.PageSize != "" {
+		out.PageSize, out.Width, out.Height = override.PageSize, override.Width, override.Height
+	}
+	if override.Orientation != "" {
+		out.Orientation = override.Orientation
+	}
+	if override.Margins != nil {
+		out.Margins = override.Margins
+	}
+	if override.Bleed != nil {
+		out.Bleed = override.Bleed
+	}
+	return &out
+}
+
+// OutputOptions controls the format and layout of a generated document.
+// Zero values leave the choice to the renderer. The embedded PageSetup
+// overrides the template version's.
+type OutputOptions struct {
+	Format string `json:"format,omitempty"` // defaults to pdf
+	PageSetup
+	// DPI and Quality (1-100, JPEG only) apply to image formats.
+	DPI     int `json:"dpi,omitempty"`
+	Quality int `json:"quality,omitempty"`
+	// Pages selects pages by number, e.g. "1-3,5". Empty means all pages.
+	Pages string `json:"pages,omitempty"`
+	// PostProcessing replaces sections of the template's settings (PDF only).
+	PostProcessing *PostProcessing `json:"postProcessing,omitempty"`
+}
+
+// Margins are in millimetres.
+type Margins struct {
+	Top    float64 `json:"top"`
+	Right  float64 `json:"right"`
+	Bottom float64 `json:"bottom"`
+	Left   float64 `json:"left"`
+}
+
+// OutputExtension returns the file extension for an output content type.
+func OutputExtension(contentType string) string {
+	switch contentType {
+	case OutputContentTypes[OutputPNG]:
+		return ".png"
+	case OutputContentTypes[OutputJPEG]:
+		return ".jpg"
+	case OutputContentTypes[OutputHTML]:
+		return ".html"
+	case OutputContentTypes[OutputDOCX]:
+		return ".docx"
+	case ContentTypeZIP:
+		return ".zip"
+	default:
+		return ".pdf"
+	}
+}
@@ -0,0 +1,166 @@
+package model
+
+import "github.com/google/uuid"
+
+// PostProcessing configures the changes made to a rendered PDF before it
+// is stored. Templates carry defaults; a generation request may replace any
+// section.
+type PostProcessing struct {
+	Watermark *Watermark `json:"watermark,omitempty"`
+	// DraftWatermark stamps "DRAFT" on documents rendered from versions that
+	// are not published, unless a watermark is set.
+	DraftWatermark bool              `json:"draftWatermark,omitempty"`
+	Metadata       *DocumentMetadata `json:"metadata,omitempty"`
+	PageNumbers    *PageNumbers      `json:"pageNumbers,omitempty"`
+	Protection     *Protection       `json:"protection,omitempty"`
+	Signature      *Signature        `json:"signature,omitempty"`
+	// Profile is a standard the output is prepared for, such as
+	// ProfilePDFA2B. Empty means none.
+	Profile string `json:"profile,omitempty"`
+}
+
+// ProfilePDFA2B is PDF/A-2b (ISO 19005-2, level B) for archiving. Output
+// passes a partial pre-flight check, not a full conformance validation.
+// It can't be combined with Protection, as PDF/A forbids encryption.
+const ProfilePDFA2B = "pdfa-2b"
+
+var Profiles = []string{ProfilePDFA2B}
+
+// Watermark is drawn over every page: text, or an image asset.
+type Watermark struct {
+	Text         string     `json:"text,omitempty"`
+	ImageAssetID *uuid.UUID `json:"imageAssetId,omitempty"`
+	Opacity      float64    `json:"opacity,omitempty"`  // 0-1, default 0.15
+	Rotation     *float64   `json:"rotation,omitempty"` // degrees, default 45 for text and 0 for images
+	FontSize     float64    `json:"fontSize,omitempty"` // default 72
+	Color        string     `json:"color,omitempty"`    // #rrggbb, default #808080
+	// Scale is the image's width as a fraction of the page width, default 0.5.
+	Scale float64 `json:"scale,omitempty"`
+}
+
+// DocumentMetadata fills the PDF document information. Values may contain
+// {{ path }} placeholders, expanded from the merge data.
+type DocumentMetadata struct {
+	Title    string   `json:"title,omitempty"`
+	Author   string   `json:"author,omitempty"`
+	Subject  string   `json:"subject,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Page number positions.
+var PageNumberPositions = []string{"bottom-center", "bottom-left", "bottom-right", "top-center", "top-left", "top-right"}
+
+// PageNumbers stamps a page number on every page.
+type PageNumbers struct {
+	// Format may use {page} and {pages}; default "Page {page} of {pages}".
+	Format    string  `json:"format,omitempty"`
+	Position  string  `json:"position,omitempty"` // default bottom-center
+	FontSize  float64 `json:"fontSize,omitempty"` // default 9
+	Margin    float64 `json:"margin,omitempty"`   // mm from the page edge, default 10
+	SkipFirst bool    `json:"skipFirst,omitempty"`
+}
+
+// Permission names for Protection.Permissions.
+const (
+	PermissionPrint        = "print"
+	PermissionPrintHighRes = "printHighRes"
+	PermissionCopy         = "copy"
+	PermissionModify       = "modify"
+	PermissionAnnotate     = "annotate"
+	PermissionFillForms    = "fillForms"
+	PermissionExtract      = "extract"
+	PermissionAssemble     = "assemble"
+)
+
+var Permissions = []string{
+	PermissionPrint, PermissionPrintHighRes, PermissionCopy, PermissionModify,
+	PermissionAnnotate, PermissionFillForms, PermissionExtract, PermissionAssemble,
+}
+
+// Protection encrypts the document. Without a user password it opens
+// freely but the permissions still apply.
+type Protection struct {
+	UserPassword  string `json:"userPassword,omitempty"`
+	OwnerPassword string `json:"ownerPassword,omitempty"`
+	// Permissions granted to users; nil means print, printHighRes and
+	// extract.
+	Permissions []string `json:"permissions"`
+	// PasswordSet is reported instead of the passwords when reading a
+	// template's settings back.
+	PasswordSet bool `json:"passwordSet,omitempty"`
+}
+
+// Signature signs the document with one of the org's signing
+// certificates (PAdES). It is applied last and can't be combined with
+// Protection.
+type Signature struct {
+	CertificateID uuid.UUID `json:"certificateId"`
+	// Reason, Location and ContactInfo may contain {{ path }} placeholders.
+	Reason      string `json:"reason,omitempty"`
+	Location    string `json:"location,omitempty"`
+	ContactInfo string `json:"contactInfo,omitempty"`
+	// Timestamp adds an RFC 3161 timestamp from the configured authority.
+	Timestamp bool `json:"timestamp,omitempty"`
+	// Appearance makes the signature visible; without it the signature is
+	// invisible.
+	Appearance *SignatureAppearance `json:"appearance,omitempty"`
+}
+
+// SignatureAppearance places a visible signature box. Positions are in mm
+// from the page's bottom-left corner.
+type SignatureAppearance struct {
+	Page   int     `json:"page,omitempty"` // 1-based, default the last page
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width,omitempty"`  // default 60
+	Height float64 `json:"height,omitempty"` // default 20
+	// Text may use {signer}, {date}, {reason} and {location}; the default
+	// names the signer and date, and the reason when there is one.
+	Text     string  `json:"text,omitempty"`
+	FontSize float64 `json:"fontSize,omitempty"` // default 8
+}
+
+// Merge returns p with the sections set in override replacing its own.
+func (p *PostProcessing) Merge(override *PostProcessing) *PostProcessing {
+	var out PostProcessing
+	if p != nil {
+		out = *p
+	}
+	if override == nil {
+		return &out
+	}
+	if override.Watermark != nil {
+		out.Watermark = override.Watermark
+	}
+	out.DraftWatermark = out.DraftWatermark || override.DraftWatermark
+	if override.Metadata != nil {
+		out.Metadata = override.Metadata
+	}
+	if override.PageNumbers != nil {
+		out.PageNumbers = override.PageNumbers
+	}
+	if override.Protection != nil {
+		out.Protection = override.Protection
+	}
+	if override.Signature != nil {
+		out.Signature = override.Signature
+	}
+	if override.Profile != "" {
+		out.Profile = override.Profile
+	}
+	return &out
+}
+
+// Redacted returns a copy safe to show: passwords are replaced by
+// PasswordSet.
+func (p *PostProcessing) Redacted() *PostProcessing {
+	if p == nil || p.Protection == nil {
+		return p
+	}
+	out := *p
+	prot := *p.Protection
+	prot.PasswordSet = prot.UserPassword != "" || prot.OwnerPassword != ""
+	prot.UserPassword, prot.OwnerPassword = "", ""
+	out.Protection = &prot
+	return &out
+}
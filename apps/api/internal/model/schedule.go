@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	VersionPolicyLatest    = "latest"
+	VersionPolicyPublished = "published"
+	VersionPolicyPinned    = "pinned"
+
+	DataSourcePayload = "payload"
+	DataSourceURL     = "url"
+	DataSourceAsset   = "asset"
+)
+
+// Schedule generates documents from a template on a cron schedule. A run
+// with a payload, or a URL returning a JSON object, creates one job; a CSV
+// or JSONL source creates a batch.
+type Schedule struct {
+	ID            uuid.UUID       `json:"id"`
+	OrgID         uuid.UUID       `json:"orgId"`
+	TemplateID    uuid.UUID       `json:"templateId"`
+	Name          string          `json:"name"`
+	CronExpr      string          `json:"cron"`
+	Timezone      string          `json:"timezone"`
+	VersionPolicy string          `json:"versionPolicy"` // latest, published, pinned
+	Version       *int            `json:"version,omitempty"`
+	DataSource    string          `json:"dataSource"` // payload, url, asset
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	SourceURL     string          `json:"sourceUrl,omitempty"`
+	SourceAssetID *uuid.UUID      `json:"sourceAssetId,omitempty"`
+	Active        bool            `json:"active"`
+	NextRunAt     *time.Time      `json:"nextRunAt,omitempty"`
+	LastRunAt     *time.Time      `json:"lastRunAt,omitempty"`
+	CreatedBy     uuid.UUID       `json:"createdBy"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+type ScheduleRun struct {
+	ID           uuid.UUID  `json:"id"`
+	OrgID        uuid.UUID  `json:"orgId"`
+	ScheduleID   uuid.UUID  `json:"scheduleId"`
+	ScheduledFor time.Time  `json:"scheduledFor"`
+	Status       string     `json:"status"` // pending, enqueued, failed
+	JobID        *uuid.UUID `json:"jobId,omitempty"`
+	BatchID      *uuid.UUID `json:"batchId,omitempty"`
+	ErrorMessage string     `json:"errorMessage,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SigningCertificate is an org's certificate for signing generated PDFs.
+// The private key is stored sealed under a server secret and is never
+// returned.
+type SigningCertificate struct {
+	ID           uuid.UUID `json:"id"`
+	OrgID        uuid.UUID `json:"orgId"`
+	Name         string    `json:"name"`
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	// Fingerprint is the SHA-256 of the certificate, hex encoded.
+	Fingerprint string     `json:"fingerprint"`
+	NotBefore   time.Time  `json:"notBefore"`
+	NotAfter    time.Time  `json:"notAfter"`
+	CreatedBy   *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+
+	// Chain is the certificate followed by its issuers, DER encoded.
+	Chain [][]byte `json:"-"`
+	// SealedKey is the PKCS#8 private key, encrypted.
+	SealedKey []byte `json:"-"`
+}
+
+// SignatureVerification is the result of checking one signature in a PDF.
+type SignatureVerification struct {
+	Field    string `json:"field"`
+	Signer   string `json:"signer,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Location string `json:"location,omitempty"`
+	// SignedAt is the time claimed by the signer; Timestamp the time
+	// asserted by a timestamp authority, when the signature has one.
+	SignedAt  *time.Time `json:"signedAt,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Intact means the signed bytes are unchanged and the signature value
+	// verifies with the embedded certificate.
+	Intact bool `json:"intact"`
+	// CoversWholeDocument is false when content was appended after signing.
+	CoversWholeDocument bool `json:"coversWholeDocument"`
+	// Certificate is the org's certificate that made the signature, if it
+	// is one of the org's.
+	Certificate *SigningCertificate `json:"certificate,omitempty"`
+	Errors      []string            `json:"errors"`
+}
+
+// VerificationReport covers every signature in a PDF. Valid requires at
+// least one signature, and every signature intact, covering the whole
+// document and made with one of the org's certificates.
+type VerificationReport struct {
+	Valid      bool                    `json:"valid"`
+	Signatures []SignatureVerification `json:"signatures"`
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusEventJob   = "job"
+	StatusEventBatch = "batch"
+)
+
+// StatusEvent is a job or batch state change as streamed to clients. It is
+// a snapshot, so a client that misses events only needs the latest one.
+type StatusEvent struct {
+	Kind          string     `json:"kind"` // job, batch
+	ID            uuid.UUID  `json:"id"`
+	OrgID         uuid.UUID  `json:"orgId"`
+	BatchID       *uuid.UUID `json:"batchId,omitempty"`
+	Status        string     `json:"status"`
+	OutputAssetID *uuid.UUID `json:"outputAssetId,omitempty"`
+	ErrorMessage  string     `json:"errorMessage,omitempty"`
+	Total         int        `json:"total,omitempty"`
+	Completed     int        `json:"completed,omitempty"`
+	Failed        int        `json:"failed,omitempty"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// Seq orders events for the same resource and doubles as the SSE event ID.
+func (e StatusEvent) Seq() int64 {
+	return e.UpdatedAt.UnixMicro()
+}
+
+// Final reports whether no further events will follow.
+func (e StatusEvent) Final() bool {
+	switch e.Kind {
+	case StatusEventJob:
+		return e.Status == "completed" || e.Status == "failed"
+	case StatusEventBatch:
+		return e.Status == "completed"
+	}
+	return false
+}
+
+func JobStatusEvent(j *GenerationJob) StatusEvent {
+	return StatusEvent{
+		Kind:          StatusEventJob,
+		ID:            j.ID,
+		OrgID:         j.OrgID,
+		BatchID:       j.BatchID,
+		Status:        j.Status,
+		OutputAssetID: j.OutputAssetID,
+		ErrorMessage:  j.ErrorMessage,
+		UpdatedAt:     j.UpdatedAt,
+	}
+}
+
+func BatchStatusEvent(b *Batch) StatusEvent {
+	return StatusEvent{
+		Kind:      StatusEventBatch,
+		ID:        b.ID,
+		OrgID:     b.OrgID,
+		Status:    b.Status,
+		Total:     b.Total,
+		Completed: b.Completed,
+		Failed:    b.Failed,
+		UpdatedAt: b.UpdatedAt,
+	}
+}
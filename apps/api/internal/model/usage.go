@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Usage metrics counted per org and UTC day.
+const (
+	MetricDocuments = "documents"
+	MetricPages     = "pages"
+	MetricRenderMS  = "render_ms"
+	MetricAPICalls  = "api_calls"
+
+	MetricRenderCacheHits   = "render_cache_hits"
+	MetricRenderCacheMisses = "render_cache_misses"
+)
+
+// Plan holds an org's limits. A nil limit is unlimited.
+type Plan struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	MaxDocuments      *int64 `json:"maxDocuments"`
+	MaxPages          *int64 `json:"maxPages"`
+	MaxRenderSeconds  *int64 `json:"maxRenderSeconds"`
+	MaxStorageBytes   *int64 `json:"maxStorageBytes"`
+	MaxAPICallsPerDay *int64 `json:"maxApiCallsPerDay"`
+	MaxUploadBytes    *int64 `json:"maxUploadBytes"`
+}
+
+// UsageDelta is an amount to add to one daily counter.
+type UsageDelta struct {
+	OrgID  uuid.UUID
+	Day    time.Time
+	Metric string
+	Value  int64
+}
+
+// UsageDay is one row of the daily rollup.
+type UsageDay struct {
+	Day    time.Time
+	Metric string
+	Value  int64
+}
+
+// UsageTotals is usage over a period in reporting units.
+type UsageTotals struct {
+	Documents     int64   `json:"documents"`
+	Pages         int64   `json:"pages"`
+	RenderSeconds float64 `json:"renderSeconds"`
+	APICalls      int64   `json:"apiCalls"`
+	// Renders served from the render cache, and renders that weren't.
+	RenderCacheHits   int64 `json:"renderCacheHits"`
+	RenderCacheMisses int64 `json:"renderCacheMisses"`
+}
+
+type DailyUsage struct {
+	Day string `json:"day"` // YYYY-MM-DD
+	UsageTotals
+}
+
+type UsageReport struct {
+	OrgID        uuid.UUID    `json:"orgId"`
+	Plan         *Plan        `json:"plan"`
+	From         string       `json:"from"`
+	To           string       `json:"to"`
+	Totals       UsageTotals  `json:"totals"`
+	StorageBytes int64        `json:"storageBytes"`
+	Daily        []DailyUsage `json:"daily"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types
+const (
+	EventJobCompleted             = "job.completed"
+	EventJobFailed                = "job.failed"
+	EventBatchCompleted           = "batch.completed"
+	EventTemplateVersionPublished = "template.version.published"
+)
+
+// WebhookEvents lists every event an endpoint may subscribe to.
+var WebhookEvents = []string{
+	EventJobCompleted,
+	EventJobFailed,
+	EventBatchCompleted,
+	EventTemplateVersionPublished,
+}
+
+type WebhookEndpoint struct {
+	ID          uuid.UUID `json:"id"`
+	OrgID       uuid.UUID `json:"orgId"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Secret      string    `json:"secret,omitempty"` // only returned on create
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID       `json:"id"`
+	OrgID         uuid.UUID       `json:"orgId"`
+	EndpointID    uuid.UUID       `json:"endpointId"`
+	EventID       uuid.UUID       `json:"eventId"`
+	EventType     string          `json:"eventType"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"` // pending, succeeded, failed
+	Attempts      int             `json:"attempts"`
+	ResponseCode  *int            `json:"responseCode,omitempty"`
+	ResponseBody  string          `json:"responseBody,omitempty"`
+	ErrorMessage  string          `json:"errorMessage,omitempty"`
+	NextAttemptAt *time.Time      `json:"nextAttemptAt,omitempty"`
+	DeliveredAt   *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+
+	// Set when claimed for sending; never serialised.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
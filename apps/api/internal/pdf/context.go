@@ -0,0 +1,112 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// ErrEncrypted is returned for documents that are password protected.
+var ErrEncrypted = errors.New("pdf: encrypted documents are not supported")
+
+// Fonts for stamps and signature appearances. Helvetica is a standard
+// font every viewer has, so nothing is embedded; PDF/A forbids fonts that
+// aren't embedded, so archival output uses Go Regular instead.
+const (
+	StandardFont = "Helvetica"
+	EmbeddedFont = "GoRegular"
+)
+
+var (
+	configOnce sync.Once
+	fontsOnce  sync.Once
+	fontsErr   error
+)
+
+// configuration returns the pdfcpu configuration for a call. pdfcpu's
+// config directory is disabled, as it would otherwise write a config.yml
+// under the user's home on first use.
+func configuration() *model.Configuration {
+	configOnce.Do(api.DisableConfigDir)
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	return conf
+}
+
+// loadFonts installs EmbeddedFont for pdfcpu, which only reads user fonts
+// from a directory, once per process.
+func loadFonts() error {
+	fontsOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "pdf-fonts-")
+		if err != nil {
+			fontsErr = err
+			return
+		}
+		if err := font.InstallFontFromBytes(dir, EmbeddedFont, goregular.TTF); err != nil {
+			fontsErr = fmt.Errorf("pdf: install %s: %w", EmbeddedFont, err)
+			return
+		}
+		font.UserFontDir = dir
+		fontsErr = font.LoadUserFonts()
+	})
+	return fontsErr
+}
+
+// readContext reads and validates data. Encrypted documents are refused
+// with ErrEncrypted, even those that open without a password.
+func readContext(data []byte) (*model.Context, error) {
+	ctx, err := api.ReadAndValidate(bytes.NewReader(data), configuration())
+	if errors.Is(err, pdfcpu.ErrWrongPassword) || (err == nil && ctx.Encrypt != nil) {
+		return nil, ErrEncrypted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %w", err)
+	}
+	return ctx, nil
+}
+
+// textString encodes s as a PDF text string: a literal when it is
+// printable ASCII, UTF-16 otherwise.
+func textString(s string) types.Object {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			return types.NewHexLiteral([]byte(types.EncodeUTF16String(s)))
+		}
+	}
+	escaped, _ := types.Escape(s)
+	return types.StringLiteral(*escaped)
+}
+
+// text decodes a text string entry; anything else is "".
+func text(ctx *model.Context, o types.Object) string {
+	o, err := ctx.Dereference(o)
+	if err != nil || o == nil {
+		return ""
+	}
+	s, err := types.StringOrHexLiteral(o)
+	if err != nil || s == nil {
+		return ""
+	}
+	return *s
+}
+
+// usedObjects returns the numbers of the objects in use, to find the
+// ones added since by comparing with a later call.
+func usedObjects(ctx *model.Context) map[int]bool {
+	used := make(map[int]bool, len(ctx.Table))
+	for n, e := range ctx.Table {
+		if e != nil && !e.Free {
+			used[n] = true
+		}
+	}
+	return used
+}
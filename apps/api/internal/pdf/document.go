@@ -0,0 +1,113 @@
+// Package pdf covers what the generation pipeline does to PDFs (stamping,
+// metadata, encryption, concatenation, PDF/A preparation and signing) on
+// top of pdfcpu, which parses and writes the files.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Document is a PDF being post-processed: a pdfcpu context that steps
+// change in place and Bytes writes out in full.
+type Document struct {
+	ctx *model.Context
+	// archive is set by PreparePDFA2B. pdfcpu sets the information dates
+	// as it writes, so Bytes then brings the XMP metadata in line.
+	archive bool
+}
+
+// Parse reads a complete PDF file.
+func Parse(data []byte) (*Document, error) {
+	ctx, err := readContext(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{ctx: ctx}, nil
+}
+
+// PageCount returns the number of pages.
+func (d *Document) PageCount() int {
+	return d.ctx.PageCount
+}
+
+// SetInfo merges entries into the document information dictionary; keys
+// are entry names such as "Title".
+func (d *Document) SetInfo(entries map[string]string) error {
+	info, err := d.info()
+	if err != nil {
+		return err
+	}
+	for k, v := range entries {
+		info[k] = textString(v)
+	}
+	return nil
+}
+
+// info returns the document information dictionary, adding one if there
+// is none.
+func (d *Document) info() (types.Dict, error) {
+	if d.ctx.Info != nil {
+		info, err := d.ctx.DereferenceDict(*d.ctx.Info)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			return info, nil
+		}
+	}
+	info := types.Dict{}
+	ref, err := d.ctx.IndRefForNewObject(info)
+	if err != nil {
+		return nil, err
+	}
+	d.ctx.Info = ref
+	return info, nil
+}
+
+// DisplayDocTitle asks viewers to show the title instead of the file name.
+func (d *Document) DisplayDocTitle() error {
+	prefs, err := d.ctx.DereferenceDict(d.ctx.RootDict["ViewerPreferences"])
+	if err != nil {
+		return err
+	}
+	if prefs == nil {
+		prefs = types.Dict{}
+		d.ctx.RootDict["ViewerPreferences"] = prefs
+	}
+	prefs["DisplayDocTitle"] = types.Boolean(true)
+	return nil
+}
+
+// Bytes writes the whole document, encrypting it if Encrypt was called.
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := api.WriteContext(d.ctx, &buf); err != nil {
+		return nil, fmt.Errorf("pdf: write: %w", err)
+	}
+	if !d.archive {
+		return buf.Bytes(), nil
+	}
+	return syncPDFAMetadata(buf.Bytes())
+}
+
+// PageCount parses data and returns its number of pages.
+func PageCount(data []byte) (int, error) {
+	n, err := api.PageCount(bytes.NewReader(data), configuration())
+	if err != nil {
+		return 0, fmt.Errorf("pdf: %w", err)
+	}
+	return n, nil
+}
+
+// Rect is a rectangle in default user space units (points).
+type Rect struct {
+	LLX, LLY, URX, URY float64
+}
+
+func (r Rect) Width() float64  { return r.URX - r.LLX }
+func (r Rect) Height() float64 { return r.URY - r.LLY }
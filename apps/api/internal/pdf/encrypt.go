@@ -0,0 +1,48 @@
+package pdf
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Permissions are the user access flags of the standard security handler
+// (PDF 32000-1, table 22). Owners always have full access.
+type Permissions uint32
+
+const (
+	PermPrint        Permissions = 1 << 2
+	PermModify       Permissions = 1 << 3
+	PermCopy         Permissions = 1 << 4
+	PermAnnotate     Permissions = 1 << 5
+	PermFillForms    Permissions = 1 << 8
+	PermExtract      Permissions = 1 << 9 // text extraction for accessibility
+	PermAssemble     Permissions = 1 << 10
+	PermPrintHighRes Permissions = 1 << 11
+)
+
+// Encrypt makes Bytes encrypt the document with AES-256, so it must be the
+// last change. An empty owner password is replaced by a random one, which
+// keeps the permissions enforceable.
+//
+// pdfcpu uses the deprecated revision 5 of the security handler for PDF
+// 1.7 files and revision 6 only for PDF 2.0, so encrypted documents are
+// written as PDF 2.0.
+func (d *Document) Encrypt(userPassword, ownerPassword string, perms Permissions) error {
+	if ownerPassword == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("pdf: encrypt: %w", err)
+		}
+		ownerPassword = hex.EncodeToString(b)
+	}
+	v := model.V20
+	d.ctx.RootVersion = &v
+	d.ctx.Cmd = model.ENCRYPT
+	d.ctx.UserPW, d.ctx.OwnerPW = userPassword, ownerPassword
+	d.ctx.EncryptUsingAES, d.ctx.EncryptKeyLength = true, 256
+	d.ctx.Permissions = model.PermissionsNone | model.PermissionFlags(perms)
+	return nil
+}
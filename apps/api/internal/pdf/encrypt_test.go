@@ -0,0 +1,88 @@
+package pdf
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// encryptedPDF returns a two-page document encrypted with Encrypt.
+func encryptedPDF(t *testing.T, user, owner string, perms Permissions) []byte {
+	t.Helper()
+	doc, err := Parse(buildPDF(t, testPage{600, 1}, testPage{601, -1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Encrypt(user, owner, perms); err != nil {
+		t.Fatal(err)
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// decrypt opens data with pdfcpu, as an independent reader, and returns
+// the decrypted document.
+func decrypt(data []byte, user, owner string) ([]byte, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW, conf.OwnerPW = user, owner
+	var out bytes.Buffer
+	if err := api.Decrypt(bytes.NewReader(data), &out, conf); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	data := encryptedPDF(t, "user-secret", "owner-secret", PermPrint|PermCopy)
+	if bytes.Contains(data, []byte("Page 1")) {
+		t.Fatal("encrypted document contains plaintext")
+	}
+
+	for name, pw := range map[string][2]string{
+		"user password":  {"user-secret", ""},
+		"owner password": {"", "owner-secret"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			plain, err := decrypt(data, pw[0], pw[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageWidths(t, plain); !slices.Equal(got, []float64{600, 601}) {
+				t.Errorf("page widths = %v, want [600 601]", got)
+			}
+			text := pageContent(t, testContext(t, plain), 1)
+			if !bytes.Contains(text, []byte("(Page 1) Tj")) {
+				t.Errorf("page 1 content = %q", text)
+			}
+		})
+	}
+
+	if _, err := decrypt(data, "wrong", "wrong"); err == nil {
+		t.Error("decrypted with the wrong password")
+	}
+}
+
+func TestEncryptPermissions(t *testing.T) {
+	data := encryptedPDF(t, "", "owner-secret", PermPrint|PermExtract)
+	p, err := api.Permissions(bytes.NewReader(data), model.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatal(err)
+	}
+	perms := Permissions(uint32(p))
+	for _, perm := range []Permissions{PermPrint, PermExtract} {
+		if perms&perm == 0 {
+			t.Errorf("permission %#x not granted (P = %#x)", perm, uint32(p))
+		}
+	}
+	for _, perm := range []Permissions{PermModify, PermCopy, PermAnnotate, PermAssemble} {
+		if perms&perm != 0 {
+			t.Errorf("permission %#x granted (P = %#x)", perm, uint32(p))
+		}
+	}
+}
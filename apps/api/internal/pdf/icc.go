@@ -0,0 +1,95 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// SRGBDescription identifies the profile returned by SRGBProfile.
+const SRGBDescription = "sRGB IEC61966-2.1"
+
+// SRGBProfile returns an ICC (v2) display profile for sRGB: the standard
+// primaries adapted to D50 and the sRGB transfer curve. It is built rather
+// than shipped as a file; callers must not modify the slice.
+var SRGBProfile = sync.OnceValue(func() []byte {
+	s15 := func(v float64) uint32 { return uint32(int32(math.Round(v * 65536))) }
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		return binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(b, s15(x)), s15(y)), s15(z))
+	}
+
+	desc := []byte("desc\x00\x00\x00\x00")
+	desc = binary.BigEndian.AppendUint32(desc, uint32(len(SRGBDescription)+1))
+	desc = append(desc, SRGBDescription+"\x00"...)
+	// Empty Unicode and ScriptCode descriptions; the latter has a fixed
+	// 67-byte field.
+	desc = append(desc, make([]byte, 4+4+2+1+67)...)
+
+	cprt := append([]byte("text\x00\x00\x00\x00"), "No copyright, use freely\x00"...)
+
+	curve := []byte("curv\x00\x00\x00\x00")
+	const samples = 1024
+	curve = binary.BigEndian.AppendUint32(curve, samples)
+	for i := range samples {
+		v := float64(i) / (samples - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = binary.BigEndian.AppendUint16(curve, uint16(math.Round(v*65535)))
+	}
+
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	tags := []tag{
+		{"desc", desc},
+		{"cprt", cprt},
+		{"wtpt", xyz(0.9505, 1, 1.0891)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	// Tag data follows the header and tag table, 4-byte aligned; the three
+	// curves share one copy.
+	var table, body bytes.Buffer
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	start := 128 + 4 + 12*len(tags)
+	offsets := map[*byte]int{}
+	for _, t := range tags {
+		off, ok := offsets[&t.data[0]]
+		if !ok {
+			off = start + body.Len()
+			offsets[&t.data[0]] = off
+			body.Write(t.data)
+			for body.Len()%4 != 0 {
+				body.WriteByte(0)
+			}
+		}
+		table.WriteString(t.sig)
+		binary.Write(&table, binary.BigEndian, [2]uint32{uint32(off), uint32(len(t.data))})
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(128+table.Len()+body.Len()))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntrRGB XYZ ")
+	binary.BigEndian.PutUint16(header[24:], 2024) // creation date, 2024-01-01
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	// Rendering intent 0 (perceptual); the PCS illuminant is D50.
+	binary.BigEndian.PutUint32(header[68:], s15(0.9642))
+	binary.BigEndian.PutUint32(header[72:], s15(1))
+	binary.BigEndian.PutUint32(header[76:], s15(0.8249))
+
+	return append(append(header, table.Bytes()...), body.Bytes()...)
+})
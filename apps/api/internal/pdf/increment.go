@@ -0,0 +1,96 @@
+package pdf
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// increment collects changes to a document read with readContext and
+// appends them to the original bytes as an incremental update, leaving
+// those bytes untouched.
+type increment struct {
+	ctx    *model.Context
+	before map[int]bool
+	objs   []int
+}
+
+func newIncrement(ctx *model.Context) *increment {
+	return &increment{ctx: ctx, before: usedObjects(ctx)}
+}
+
+// changed adds an existing object to the update. Objects added to the
+// document since newIncrement are included without this.
+func (inc *increment) changed(num int) {
+	inc.objs = append(inc.objs, num)
+}
+
+// dict returns the dictionary d[key] for changing, adding an empty one
+// if there is none. A dictionary that is an object of its own is added to
+// the update; a direct one is written with d, which must be in it.
+func (inc *increment) dict(d types.Dict, key string) (types.Dict, error) {
+	if ref, ok := d[key].(types.IndirectRef); ok {
+		v, err := inc.ctx.DereferenceDict(ref)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			inc.changed(ref.ObjectNumber.Value())
+			return v, nil
+		}
+	}
+	if v, ok := d[key].(types.Dict); ok {
+		return v, nil
+	}
+	v := types.Dict{}
+	d[key] = v
+	return v, nil
+}
+
+// appendRef appends ref to the array d[key], like dict.
+func (inc *increment) appendRef(d types.Dict, key string, ref types.IndirectRef) error {
+	if ir, ok := d[key].(types.IndirectRef); ok {
+		a, err := inc.ctx.DereferenceArray(ir)
+		if err != nil {
+			return err
+		}
+		if entry, found := inc.ctx.FindTableEntryForIndRef(&ir); found && a != nil {
+			entry.Object = append(append(types.Array{}, a...), ref)
+			inc.changed(ir.ObjectNumber.Value())
+			return nil
+		}
+	}
+	a, _ := d[key].(types.Array)
+	d[key] = append(append(types.Array{}, a...), ref)
+	return nil
+}
+
+// write returns data with the update appended.
+func (inc *increment) write(data []byte) ([]byte, error) {
+	ctx := inc.ctx
+	for n := range usedObjects(ctx) {
+		if !inc.before[n] {
+			inc.objs = append(inc.objs, n)
+		}
+	}
+	slices.Sort(inc.objs)
+	ctx.Write.Increment = true
+	ctx.Write.Offset = ctx.Read.FileSize
+	ctx.Write.ObjNrs = nil
+	for _, n := range slices.Compact(inc.objs) {
+		ctx.Write.IncrementWithObjNr(n)
+	}
+	// The update keeps the file's kind of cross-reference section
+	ctx.WriteXRefStream = ctx.Read.UsingXRefStreams
+
+	var out bytes.Buffer
+	out.Grow(len(data) + 64<<10)
+	out.Write(data)
+	if err := api.WriteIncrement(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,65 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Merger concatenates PDFs page by page into w. pdfcpu merges the inputs
+// into the first one's context, so the whole merged document is held in
+// memory until Close writes it.
+type Merger struct {
+	w   io.Writer
+	ctx *model.Context
+}
+
+// NewMerger starts a merged document on w.
+func NewMerger(w io.Writer) (*Merger, error) {
+	return &Merger{w: w}, nil
+}
+
+// Pages returns the number of pages added so far.
+func (m *Merger) Pages() int {
+	if m.ctx == nil {
+		return 0
+	}
+	return m.ctx.PageCount
+}
+
+// Add appends every page of the PDF in data.
+func (m *Merger) Add(data []byte) error {
+	ctx, err := readContext(data)
+	if err != nil {
+		return err
+	}
+	if m.ctx == nil {
+		ctx.Configuration.CreateBookmarks = false
+		ctx.EnsureVersionForWriting()
+		m.ctx = ctx
+		return nil
+	}
+	if err := pdfcpu.MergeXRefTables("", ctx, m.ctx, false, false); err != nil {
+		return fmt.Errorf("pdf: merge: %w", err)
+	}
+	return nil
+}
+
+// Close writes the merged document.
+func (m *Merger) Close() error {
+	if m.ctx == nil {
+		return fmt.Errorf("pdf: merge: no documents added")
+	}
+	// Inputs rendered alike share fonts and images, which pdfcpu writes
+	// once.
+	if err := api.OptimizeContext(m.ctx); err != nil {
+		return fmt.Errorf("pdf: merge: %w", err)
+	}
+	if err := api.WriteContext(m.ctx, m.w); err != nil {
+		return fmt.Errorf("pdf: merge: %w", err)
+	}
+	return nil
+}
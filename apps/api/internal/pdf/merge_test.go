@@ -0,0 +1,75 @@
+package pdf
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestMergerKeepsPageOrder(t *testing.T) {
+	// The first page links to the last, so walking the object graph from
+	// it reaches the last page before the second.
+	first := buildPDF(t, testPage{600, 2}, testPage{601, -1}, testPage{602, 0})
+	second := buildPDF(t, testPage{700, -1}, testPage{701, 0})
+
+	var out bytes.Buffer
+	m, err := NewMerger(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{first, second} {
+		if err := m.Add(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Pages() != 5 {
+		t.Errorf("Pages() = %d, want 5", m.Pages())
+	}
+
+	want := []float64{600, 601, 602, 700, 701}
+	if got := pageWidths(t, out.Bytes()); !slices.Equal(got, want) {
+		t.Errorf("merged page widths = %v, want %v", got, want)
+	}
+
+	// Links still point at the same pages
+	ctx := testContext(t, out.Bytes())
+	for from, to := range map[int]int{1: 3, 3: 1, 5: 4} {
+		page, _, _, err := ctx.PageDict(from, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, target, _, err := ctx.PageDict(to, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		annots, _ := ctx.DereferenceArray(page["Annots"])
+		if len(annots) != 1 {
+			t.Fatalf("page %d has %d annotations, want 1", from, len(annots))
+		}
+		annot, _ := ctx.DereferenceDict(annots[0])
+		dest, _ := ctx.DereferenceArray(annot["Dest"])
+		if len(dest) == 0 || dest[0] != *target {
+			t.Errorf("page %d links to %v, want page %d (%v)", from, dest, to, *target)
+		}
+	}
+}
+
+func TestMergerSinglePage(t *testing.T) {
+	var out bytes.Buffer
+	m, err := NewMerger(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(buildPDF(t, testPage{612, -1})); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := PageCount(out.Bytes()); err != nil || n != 1 {
+		t.Errorf("PageCount = %d, %v; want 1", n, err)
+	}
+}
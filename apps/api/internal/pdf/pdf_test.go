@@ -0,0 +1,125 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// testPage describes a page of a test document. Pages are told apart by
+// their width; linkTo, if not -1, adds a link annotation to that page.
+type testPage struct {
+	width  float64
+	linkTo int
+}
+
+// buildPDF writes a document with a page per entry of pages, each showing
+// its number in Helvetica. The file is written out by hand so the tests
+// don't depend on the code they check to produce their input.
+func buildPDF(t *testing.T, pages ...testPage) []byte {
+	t.Helper()
+	return writeTestPDF(true, pages)
+}
+
+// buildBlankPDF is buildPDF without the text, and so without a font.
+func buildBlankPDF(t *testing.T, pages ...testPage) []byte {
+	t.Helper()
+	return writeTestPDF(false, pages)
+}
+
+func writeTestPDF(text bool, pages []testPage) []byte {
+	// Objects 1 to 3 are the catalog, page tree and font (null without
+	// text); page i is object 4+2i and its content the one after.
+	pageRef := func(i int) string { return fmt.Sprintf("%d 0 R", 4+2*i) }
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"null",
+	}
+	if text {
+		objs[2] = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+	}
+	var kids []string
+	for i, p := range pages {
+		var annots, resources, content string
+		if p.linkTo >= 0 {
+			annots = fmt.Sprintf(" /Annots [<< /Type /Annot /Subtype /Link /Rect [72 700 200 740] /Dest [%s /Fit] >>]", pageRef(p.linkTo))
+		}
+		if text {
+			resources = " /Resources << /Font << /F1 3 0 R >> >>"
+			content = fmt.Sprintf("BT /F1 24 Tf 72 720 Td (Page %d) Tj ET", i+1)
+		}
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s 842] /Contents %d 0 R%s%s >>", num(p.width), 5+2*i, resources, annots),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content)+1, content))
+		kids = append(kids, pageRef(i))
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return b.Bytes()
+}
+
+// testContext reads data with pdfcpu, failing the test if it can't.
+func testContext(t *testing.T, data []byte) *model.Context {
+	t.Helper()
+	ctx, err := readContext(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+// pageWidths parses data and returns the width of each page in order.
+func pageWidths(t *testing.T, data []byte) []float64 {
+	t.Helper()
+	ctx := testContext(t, data)
+	widths := make([]float64, ctx.PageCount)
+	for i := range widths {
+		_, _, inherited, err := ctx.PageDict(i+1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		widths[i] = inherited.MediaBox.Width()
+	}
+	return widths
+}
+
+// pageContent returns the decoded content of page n of ctx, its streams
+// joined.
+func pageContent(t *testing.T, ctx *model.Context, n int) []byte {
+	t.Helper()
+	page, _, _, err := ctx.PageDict(n, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ctx.PageContent(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func writeDoc(t *testing.T, doc *Document) []byte {
+	t.Helper()
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
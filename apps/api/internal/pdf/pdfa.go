@@ -0,0 +1,281 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// PDF/A-2 implementation limits (ISO 19005-2, 6.1.13).
+const (
+	MaxStringPDFA = 32767
+	maxNamePDFA   = 127
+)
+
+// Annotation flags (ISO 32000-1, 12.5.3).
+const (
+	annotInvisible    = 1 << 0
+	annotHidden       = 1 << 1
+	annotPrint        = 1 << 2
+	annotNoView       = 1 << 5
+	annotLocked       = 1 << 7
+	annotToggleNoView = 1 << 8
+)
+
+// infoXMP lists the document information entries PDF/A requires to be
+// mirrored in the XMP metadata, and where.
+var infoXMP = []struct {
+	key  string
+	prop string
+}{
+	{"Title", "dc:title"},
+	{"Author", "dc:creator"},
+	{"Subject", "dc:description"},
+	{"Keywords", "pdf:Keywords"},
+	{"Creator", "xmp:CreatorTool"},
+	{"Producer", "pdf:Producer"},
+	{"CreationDate", "xmp:CreateDate"},
+	{"ModDate", "xmp:ModifyDate"},
+}
+
+// PreparePDFA2B adds what a PDF/A-2b file needs beyond its content: XMP
+// metadata matching the document information, an sRGB output intent
+// unless the document has one, printable annotations and named optional
+// content configurations. It doesn't make every document compliant
+// (fonts must already be embedded, for one); pre-flight the written file
+// with PreflightPDFA2B.
+func (d *Document) PreparePDFA2B() error {
+	ctx := d.ctx
+
+	// The information dictionary and XMP must agree, so anything
+	// unreadable is dropped. pdfcpu sets the dates and producer as it
+	// writes, which Bytes then copies to the XMP.
+	info, err := d.info()
+	if err != nil {
+		return err
+	}
+	delete(info, "Trapped")
+	for _, e := range infoXMP {
+		if _, ok := textEntry(ctx, info, e.key); !ok {
+			delete(info, e.key)
+		}
+	}
+	xmp, err := ctx.IndRefForNewObject(metadataStream(pdfaXMP(ctx, info)))
+	if err != nil {
+		return err
+	}
+	ctx.RootDict["Metadata"] = *xmp
+
+	if !d.hasPDFAOutputIntent() {
+		sd, err := ctx.NewStreamDictForBuf(SRGBProfile())
+		if err != nil {
+			return err
+		}
+		sd.InsertInt("N", 3)
+		if err := sd.Encode(); err != nil {
+			return err
+		}
+		profile, err := ctx.IndRefForNewObject(*sd)
+		if err != nil {
+			return err
+		}
+		intents, _ := ctx.DereferenceArray(ctx.RootDict["OutputIntents"])
+		ctx.RootDict["OutputIntents"] = append(append(types.Array{}, intents...), types.Dict{
+			"Type":                      types.Name("OutputIntent"),
+			"S":                         types.Name("GTS_PDFA1"),
+			"OutputConditionIdentifier": textString(SRGBDescription),
+			"RegistryName":              textString("http://www.color.org"),
+			"Info":                      textString(SRGBDescription),
+			"DestOutputProfile":         *profile,
+		})
+	}
+
+	for n := 1; n <= ctx.PageCount; n++ {
+		page, _, _, err := ctx.PageDict(n, false)
+		if err != nil {
+			return err
+		}
+		annots, _ := ctx.DereferenceArray(page["Annots"])
+		for _, a := range annots {
+			annot, _ := ctx.DereferenceDict(a)
+			if annot == nil || dictName(ctx, annot, "Subtype") == "Popup" {
+				continue
+			}
+			flags, _ := ctx.DereferenceInteger(annot["F"])
+			f := 0
+			if flags != nil {
+				f = flags.Value()
+			}
+			annot["F"] = types.Integer(f&^(annotInvisible|annotHidden|annotNoView|annotToggleNoView) | annotPrint)
+		}
+	}
+
+	// Viewers may not smooth images in archival files.
+	for _, e := range ctx.Table {
+		if e == nil || e.Free {
+			continue
+		}
+		if sd, ok := e.Object.(types.StreamDict); ok && dictName(ctx, sd.Dict, "Subtype") == "Image" {
+			delete(sd.Dict, "Interpolate")
+		}
+	}
+
+	// pdfcpu's stamps are optional content whose default configuration
+	// has usage events (AS) and no name, both of which PDF/A rules out.
+	oc, _ := ctx.DereferenceDict(ctx.RootDict["OCProperties"])
+	for _, c := range ocConfigs(ctx, oc) {
+		delete(c, "AS")
+		if c["Name"] == nil {
+			c["Name"] = textString("Default")
+		}
+	}
+
+	d.archive = true
+	return nil
+}
+
+// ocConfigs returns the optional content configurations of oc, the
+// default one first.
+func ocConfigs(ctx *model.Context, oc types.Dict) []types.Dict {
+	if oc == nil {
+		return nil
+	}
+	var configs []types.Dict
+	others, _ := ctx.DereferenceArray(oc["Configs"])
+	for _, o := range append(types.Array{oc["D"]}, others...) {
+		if c, _ := ctx.DereferenceDict(o); c != nil {
+			configs = append(configs, c)
+		}
+	}
+	return configs
+}
+
+func (d *Document) hasPDFAOutputIntent() bool {
+	intents, _ := d.ctx.DereferenceArray(d.ctx.RootDict["OutputIntents"])
+	for _, o := range intents {
+		intent, _ := d.ctx.DereferenceDict(o)
+		if intent != nil && dictName(d.ctx, intent, "S") == "GTS_PDFA1" {
+			if sd, _, _ := d.ctx.DereferenceStreamDict(intent["DestOutputProfile"]); sd != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncPDFAMetadata rewrites the XMP metadata of a file PreparePDFA2B was
+// called on from the document information pdfcpu wrote, as an
+// incremental update.
+func syncPDFAMetadata(data []byte) ([]byte, error) {
+	ctx, err := readContext(data)
+	if err != nil {
+		return nil, err
+	}
+	inc := newIncrement(ctx)
+	// Without metadata there is nothing to bring in line; the pre-flight
+	// check reports it.
+	ref, ok := ctx.RootDict["Metadata"].(types.IndirectRef)
+	if !ok {
+		return data, nil
+	}
+	entry, found := ctx.FindTableEntryForIndRef(&ref)
+	if !found {
+		return data, nil
+	}
+	var info types.Dict
+	if ctx.Info != nil {
+		if info, err = ctx.DereferenceDict(*ctx.Info); err != nil {
+			return nil, err
+		}
+	}
+	entry.Object = metadataStream(pdfaXMP(ctx, info))
+	inc.changed(ref.ObjectNumber.Value())
+	out, err := inc.write(data)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: write metadata: %w", err)
+	}
+	return out, nil
+}
+
+// metadataStream is an XMP metadata stream. It is left unfiltered, as
+// PDF/A requires.
+func metadataStream(xmp []byte) types.StreamDict {
+	sd := types.StreamDict{
+		Dict:    types.Dict{"Type": types.Name("Metadata"), "Subtype": types.Name("XML")},
+		Content: xmp,
+	}
+	// With no filters Encode only sets the length
+	_ = sd.Encode()
+	return sd
+}
+
+// textEntry returns the text string d[key], and whether there is one.
+func textEntry(ctx *model.Context, d types.Dict, key string) (string, bool) {
+	o, err := ctx.Dereference(d[key])
+	if err != nil || o == nil {
+		return "", false
+	}
+	s, err := types.StringOrHexLiteral(o)
+	if err != nil || s == nil {
+		return "", false
+	}
+	return *s, true
+}
+
+// dictName returns the name d[key], or "".
+func dictName(ctx *model.Context, d types.Dict, key string) string {
+	n, _ := ctx.DereferenceName(d[key], model.V10, nil)
+	return n.Value()
+}
+
+// pdfaXMP writes an XMP packet identifying the file as PDF/A-2b, with the
+// information dictionary's entries.
+func pdfaXMP(ctx *model.Context, info types.Dict) []byte {
+	esc := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about=""
+ xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/"
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+ xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+<pdfaid:part>2</pdfaid:part>
+<pdfaid:conformance>B</pdfaid:conformance>
+<dc:format>application/pdf</dc:format>
+`)
+	for _, e := range infoXMP {
+		v, ok := textEntry(ctx, info, e.key)
+		if !ok {
+			continue
+		}
+		switch e.prop {
+		case "dc:title", "dc:description":
+			fmt.Fprintf(&b, "<%s><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></%[1]s>\n", e.prop, esc(v))
+		case "dc:creator":
+			fmt.Fprintf(&b, "<%s><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></%[1]s>\n", e.prop, esc(v))
+		case "xmp:CreateDate", "xmp:ModifyDate":
+			if t, err := ParseDate(v); err == nil {
+				fmt.Fprintf(&b, "<%s>%s</%[1]s>\n", e.prop, t.Format(time.RFC3339))
+			}
+		default:
+			fmt.Fprintf(&b, "<%s>%s</%[1]s>\n", e.prop, esc(v))
+		}
+	}
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	// Padding lets editors update the packet in place.
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.Bytes()
+}
@@ -0,0 +1,751 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Issue is a PDF/A requirement that the pre-flight check found a file
+// failing.
+type Issue struct {
+	// Clause is the ISO 19005-2 clause with the requirement.
+	Clause  string `json:"clause"`
+	Message string `json:"message"`
+	// Objects are the first few objects with the problem; empty for
+	// problems with the file as a whole.
+	Objects []int `json:"objects,omitempty"`
+}
+
+func (i Issue) String() string {
+	if len(i.Objects) == 0 {
+		return fmt.Sprintf("%s: %s", i.Clause, i.Message)
+	}
+	nums := make([]string, len(i.Objects))
+	for j, n := range i.Objects {
+		nums[j] = fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%s: %s (objects %s)", i.Clause, i.Message, strings.Join(nums, ", "))
+}
+
+// maxIssueObjects bounds Issue.Objects; a missing font is reported once,
+// not for every page that uses it.
+const maxIssueObjects = 5
+
+const (
+	nsRDF    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsPDFAID = "http://www.aiim.org/pdfa/ns/id/"
+)
+
+// xmpNamespaces resolves the prefixes used in infoXMP.
+var xmpNamespaces = map[string]string{
+	"dc":  "http://purl.org/dc/elements/1.1/",
+	"pdf": "http://ns.adobe.com/pdf/1.3/",
+	"xmp": "http://ns.adobe.com/xap/1.0/",
+}
+
+var (
+	annotationTypes = []string{
+		"Text", "Link", "FreeText", "Line", "Square", "Circle", "Polygon", "PolyLine",
+		"Highlight", "Underline", "Squiggly", "StrikeOut", "Stamp", "Caret", "Ink",
+		"Popup", "FileAttachment", "Widget", "PrinterMark", "TrapNet", "Watermark", "Redact",
+	}
+	forbiddenActions = []string{
+		"Launch", "Sound", "Movie", "ResetForm", "ImportData", "Hide",
+		"SetOCGState", "Rendition", "Trans", "GoTo3DView", "JavaScript",
+	}
+	blendModes = []string{
+		"Normal", "Compatible", "Multiply", "Screen", "Overlay", "Darken", "Lighten",
+		"ColorDodge", "ColorBurn", "HardLight", "SoftLight", "Difference", "Exclusion",
+		"Hue", "Saturation", "Color", "Luminosity",
+	}
+)
+
+// PreflightPDFA2B is a partial pre-flight check of a file against the
+// PDF/A-2b (ISO 19005-2, level B) requirements that can be decided from
+// its structure: file layout and limits, output intent and device colour,
+// embedded fonts, annotations, actions, optional content, metadata and
+// signatures. It is not a conformance validator: it doesn't look inside
+// font programs, ICC profiles or images, among much else a validator such
+// as veraPDF checks. A nil result means the check found no problems, not
+// that the file conforms.
+func PreflightPDFA2B(data []byte) []Issue {
+	c := &pdfaChecker{index: map[string]int{}, colors: map[string][]int{}}
+
+	if len(data) < 8 || !bytes.HasPrefix(data, []byte("%PDF-1.")) || data[7] < '0' || data[7] > '7' {
+		c.add("6.1.2", 0, "the file must start with a %%PDF-1.n header, n at most 7")
+	} else if !binaryComment(data[8:]) {
+		c.add("6.1.2", 0, "the header must be followed by a comment of at least four binary characters")
+	}
+	if !bytes.HasSuffix(bytes.TrimRight(data, "\r\n"), []byte("%%EOF")) {
+		c.add("6.1.3", 0, "no data may follow the last %%%%EOF marker")
+	}
+
+	ctx, err := readContext(data)
+	if errors.Is(err, ErrEncrypted) {
+		c.add("6.1.3", 0, "the file must not be encrypted")
+		return c.issues
+	}
+	if err != nil {
+		c.add("6.1.1", 0, "the file can't be read: %v", err)
+		return c.issues
+	}
+	c.ctx = ctx
+	for n, e := range ctx.Table {
+		if n > 0 && e != nil && !e.Free && e.Object != nil {
+			c.nums = append(c.nums, n)
+		}
+	}
+	sort.Ints(c.nums)
+
+	if len(ctx.ID) != 2 {
+		c.add("6.1.3", 0, "the trailer must have a file ID")
+	}
+	if len(c.nums) > 8388607 {
+		c.add("6.1.13", 0, "the file has more than 8388607 objects")
+	}
+
+	for _, n := range c.nums {
+		c.walk(n, ctx.Table[n].Object)
+	}
+	c.checkContents()
+	c.checkCatalog()
+	c.checkOutputIntent()
+	c.checkMetadata()
+	sigs, _ := Signatures(data)
+	for _, sig := range sigs {
+		if _, whole, err := sig.SignedBytes(data); err != nil || !whole {
+			c.add("6.4.3", 0, "signature %s must cover the whole file apart from its value", sig.Field)
+		}
+	}
+	return c.issues
+}
+
+// binaryComment reports whether the line after the header is a comment
+// starting with four bytes above 127.
+func binaryComment(rest []byte) bool {
+	line, _, _ := bytes.Cut(bytes.TrimLeft(rest, "\r\n"), []byte("\n"))
+	if len(line) < 5 || line[0] != '%' {
+		return false
+	}
+	for _, b := range line[1:5] {
+		if b < 128 {
+			return false
+		}
+	}
+	return true
+}
+
+type pdfaChecker struct {
+	ctx    *model.Context
+	nums   []int // object numbers in order
+	issues []Issue
+	index  map[string]int
+	// colors maps device colour families (RGB, CMYK, Gray) to the objects
+	// using them.
+	colors map[string][]int
+}
+
+// add records a problem at object num (0 for the file), merging repeats
+// of the same message.
+func (c *pdfaChecker) add(clause string, num int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	key := clause + "\x00" + msg
+	i, ok := c.index[key]
+	if !ok {
+		i = len(c.issues)
+		c.index[key] = i
+		c.issues = append(c.issues, Issue{Clause: clause, Message: msg})
+	}
+	if num > 0 && len(c.issues[i].Objects) < maxIssueObjects && !slices.Contains(c.issues[i].Objects, num) {
+		c.issues[i].Objects = append(c.issues[i].Objects, num)
+	}
+}
+
+func (c *pdfaChecker) useColor(family string, num int) {
+	if len(c.colors[family]) < maxIssueObjects && !slices.Contains(c.colors[family], num) {
+		c.colors[family] = append(c.colors[family], num)
+	}
+}
+
+func (c *pdfaChecker) resolve(o types.Object) types.Object {
+	o, _ = c.ctx.Dereference(o)
+	return o
+}
+
+// dict resolves o to a dictionary, or a stream's dictionary.
+func (c *pdfaChecker) dict(o types.Object) types.Dict {
+	switch v := c.resolve(o).(type) {
+	case types.Dict:
+		return v
+	case types.StreamDict:
+		return v.Dict
+	}
+	return nil
+}
+
+func (c *pdfaChecker) array(o types.Object) types.Array {
+	a, _ := c.resolve(o).(types.Array)
+	return a
+}
+
+func (c *pdfaChecker) name(d types.Dict, key string) string {
+	return dictName(c.ctx, d, key)
+}
+
+// stream resolves o to a stream and the number of its object.
+func (c *pdfaChecker) stream(o types.Object) (types.StreamDict, int, bool) {
+	ref, ok := o.(types.IndirectRef)
+	if !ok {
+		return types.StreamDict{}, 0, false
+	}
+	sd, ok := c.resolve(ref).(types.StreamDict)
+	return sd, ref.ObjectNumber.Value(), ok
+}
+
+// walk checks the value of object num and everything directly inside it.
+func (c *pdfaChecker) walk(num int, o types.Object) {
+	switch v := o.(type) {
+	case types.StringLiteral:
+		if b, err := types.Unescape(string(v)); err == nil && len(b) > MaxStringPDFA {
+			c.add("6.1.13", num, "strings must be at most %d bytes", MaxStringPDFA)
+		}
+	case types.HexLiteral:
+		if len(v)/2 > MaxStringPDFA {
+			c.add("6.1.13", num, "strings must be at most %d bytes", MaxStringPDFA)
+		}
+	case types.Name:
+		if len(v) > maxNamePDFA {
+			c.add("6.1.13", num, "names must be at most %d bytes", maxNamePDFA)
+		}
+	case types.Integer:
+		if v > math.MaxInt32 || v < math.MinInt32 {
+			c.add("6.1.13", num, "integers must fit in 32 bits")
+		}
+	case types.Array:
+		for _, e := range v {
+			c.walk(num, e)
+		}
+	case types.Dict:
+		c.checkDict(num, v)
+		for _, e := range v {
+			c.walk(num, e)
+		}
+	case types.StreamDict:
+		c.checkStream(num, v)
+		c.checkDict(num, v.Dict)
+		for _, e := range v.Dict {
+			c.walk(num, e)
+		}
+	}
+}
+
+func (c *pdfaChecker) checkDict(num int, d types.Dict) {
+	typ, subtype := c.name(d, "Type"), c.name(d, "Subtype")
+
+	if s := c.name(d, "S"); slices.Contains(forbiddenActions, s) && (typ == "" || typ == "Action") {
+		c.add("6.5.1", num, "%s actions are not permitted", s)
+	}
+	if _, ok := d["AA"]; ok && (typ == "Catalog" || typ == "Page" || subtype == "Widget" || d["FT"] != nil) {
+		c.add("6.5.2", num, "additional actions (AA) are not permitted on the catalog, pages, widgets or form fields")
+	}
+	if _, ok := d["TR"]; ok {
+		c.add("6.2.5", num, "graphics states must not have a transfer function (TR)")
+	}
+	if _, ok := d["TR2"]; ok && c.name(d, "TR2") != "Default" {
+		c.add("6.2.5", num, "graphics states may only use the Default transfer function (TR2)")
+	}
+	if bm := c.resolve(d["BM"]); bm != nil {
+		modes, _ := bm.(types.Array)
+		if n, ok := bm.(types.Name); ok {
+			modes = types.Array{n}
+		}
+		for _, m := range modes {
+			if n, _ := c.resolve(m).(types.Name); !slices.Contains(blendModes, n.Value()) {
+				c.add("6.2.10", num, "blend mode %v is not permitted", m)
+			}
+		}
+	}
+	for _, key := range []string{"ColorSpace", "CS"} {
+		cs := c.resolve(d[key])
+		if named, ok := cs.(types.Dict); ok {
+			// A resource dictionary's named colour spaces
+			for _, v := range named {
+				c.colorSpace(num, v)
+			}
+		} else if cs != nil {
+			c.colorSpace(num, cs)
+		}
+	}
+
+	if typ == "Font" {
+		c.checkFont(num, d)
+	}
+	if typ == "Annot" || (subtype != "" && d["Rect"] != nil && typ == "") {
+		c.checkAnnot(num, d)
+	}
+}
+
+// colorSpace records the device colour families a colour space uses.
+func (c *pdfaChecker) colorSpace(num int, o types.Object) {
+	switch v := c.resolve(o).(type) {
+	case types.Name:
+		switch v {
+		case "DeviceRGB", "RGB":
+			c.useColor("RGB", num)
+		case "DeviceCMYK", "CMYK":
+			c.useColor("CMYK", num)
+		case "DeviceGray", "G":
+			c.useColor("Gray", num)
+		}
+	case types.Array:
+		if len(v) == 0 {
+			return
+		}
+		switch n, _ := c.resolve(v[0]).(types.Name); n {
+		case "Indexed", "I", "Pattern":
+			if len(v) > 1 {
+				c.colorSpace(num, v[1])
+			}
+		case "Separation", "DeviceN":
+			if len(v) > 2 {
+				c.colorSpace(num, v[2])
+			}
+		}
+	}
+}
+
+func (c *pdfaChecker) checkStream(num int, s types.StreamDict) {
+	for _, key := range []string{"F", "FFilter", "FDecodeParms"} {
+		if _, ok := s.Dict[key]; ok {
+			c.add("6.1.7.1", num, "streams must not refer to external files (%s)", key)
+		}
+	}
+	for _, f := range s.FilterPipeline {
+		if f.Name == "LZWDecode" || f.Name == "LZW" {
+			c.add("6.1.7.2", num, "LZWDecode is not permitted")
+		}
+	}
+
+	switch c.name(s.Dict, "Subtype") {
+	case "Image":
+		if _, ok := s.Dict["Alternates"]; ok {
+			c.add("6.2.8", num, "images must not have Alternates")
+		}
+		if _, ok := s.Dict["OPI"]; ok {
+			c.add("6.2.8", num, "images must not have OPI")
+		}
+		if v, _ := c.resolve(s.Dict["Interpolate"]).(types.Boolean); v {
+			c.add("6.2.8", num, "images must not be interpolated")
+		}
+	case "Form":
+		if _, ok := s.Dict["OPI"]; ok {
+			c.add("6.2.9", num, "form XObjects must not have OPI")
+		}
+		if _, ok := s.Dict["Ref"]; ok {
+			c.add("6.2.9", num, "reference XObjects are not permitted")
+		}
+		if c.name(s.Dict, "Subtype2") == "PS" {
+			c.add("6.2.9", num, "PostScript XObjects are not permitted")
+		}
+	case "PS":
+		c.add("6.2.9", num, "PostScript XObjects are not permitted")
+	}
+}
+
+func (c *pdfaChecker) checkFont(num int, font types.Dict) {
+	subtype := c.name(font, "Subtype")
+	if subtype == "Type0" || subtype == "Type3" {
+		// Type0 fonts are checked through their descendant, and Type3
+		// glyphs are content streams
+		return
+	}
+	name := c.name(font, "BaseFont")
+	desc := c.dict(font["FontDescriptor"])
+	embedded := false
+	switch subtype {
+	case "Type1", "MMType1":
+		embedded = desc["FontFile"] != nil || desc["FontFile3"] != nil
+	case "TrueType", "CIDFontType2":
+		embedded = desc["FontFile2"] != nil || c.name(c.dict(desc["FontFile3"]), "Subtype") == "OpenType"
+	case "CIDFontType0":
+		embedded = desc["FontFile3"] != nil
+	default:
+		c.add("6.2.11.1", num, "font %s has unknown type %s", name, subtype)
+		return
+	}
+	if !embedded {
+		c.add("6.2.11.4", num, "font %s is not embedded", name)
+	}
+
+	if subtype == "TrueType" && desc != nil {
+		flags, _ := c.resolve(desc["Flags"]).(types.Integer)
+		enc := c.name(font, "Encoding")
+		if ed := c.dict(font["Encoding"]); ed != nil {
+			enc = c.name(ed, "BaseEncoding")
+		}
+		switch {
+		case flags&4 != 0 && font["Encoding"] != nil:
+			c.add("6.2.11.6", num, "symbolic TrueType font %s must not have an Encoding", name)
+		case flags&4 == 0 && enc != "WinAnsiEncoding" && enc != "MacRomanEncoding":
+			c.add("6.2.11.6", num, "non-symbolic TrueType font %s must use WinAnsiEncoding or MacRomanEncoding", name)
+		}
+	}
+}
+
+func (c *pdfaChecker) checkAnnot(num int, annot types.Dict) {
+	subtype := c.name(annot, "Subtype")
+	if !slices.Contains(annotationTypes, subtype) {
+		c.add("6.3.1", num, "%s annotations are not permitted", subtype)
+		return
+	}
+	if subtype == "FileAttachment" {
+		c.add("6.8", num, "file attachments can't be checked for conformance")
+	}
+	if subtype == "Popup" {
+		return
+	}
+
+	flags, _ := c.resolve(annot["F"]).(types.Integer)
+	if flags&annotPrint == 0 || flags&(annotInvisible|annotHidden|annotNoView|annotToggleNoView) != 0 {
+		c.add("6.3.2", num, "annotations must be printable and not hidden")
+	}
+
+	ap := c.dict(annot["AP"])
+	if ap == nil {
+		rect, err := c.ctx.RectForArray(c.array(annot["Rect"]))
+		if subtype != "Link" && err == nil && rect.Width() != 0 && rect.Height() != 0 {
+			c.add("6.3.3", num, "%s annotations must have an appearance", subtype)
+		}
+		return
+	}
+	for key := range ap {
+		if key != "N" {
+			c.add("6.3.3", num, "appearance dictionaries may only have a normal (N) appearance")
+			break
+		}
+	}
+	if _, ok := c.resolve(ap["N"]).(types.Dict); ok && (subtype != "Widget" || c.fieldType(annot) != "Btn") {
+		c.add("6.3.3", num, "only button widgets may have appearance sub-dictionaries")
+	}
+}
+
+// fieldType returns a form field's type, which may be inherited.
+func (c *pdfaChecker) fieldType(field types.Dict) string {
+	for i := 0; field != nil && i < 32; i++ {
+		if ft := c.name(field, "FT"); ft != "" {
+			return ft
+		}
+		field = c.dict(field["Parent"])
+	}
+	return ""
+}
+
+func (c *pdfaChecker) checkCatalog() {
+	catalog := c.ctx.RootDict
+	names := c.dict(catalog["Names"])
+	if names["JavaScript"] != nil {
+		c.add("6.5.1", 0, "document-level JavaScript is not permitted")
+	}
+	if names["EmbeddedFiles"] != nil {
+		c.add("6.8", 0, "embedded files can't be checked for conformance")
+	}
+	form := c.dict(catalog["AcroForm"])
+	if v, _ := c.resolve(form["NeedAppearances"]).(types.Boolean); v {
+		c.add("6.4.1", 0, "forms must not ask viewers to generate appearances (NeedAppearances)")
+	}
+	if form["XFA"] != nil {
+		c.add("6.4.2", 0, "XFA forms are not permitted")
+	}
+	if v, _ := c.resolve(catalog["NeedsRendering"]).(types.Boolean); v {
+		c.add("6.4.2", 0, "NeedsRendering is not permitted")
+	}
+	for _, config := range ocConfigs(c.ctx, c.dict(catalog["OCProperties"])) {
+		if _, ok := textEntry(c.ctx, config, "Name"); !ok {
+			c.add("6.9", 0, "optional content configurations must have a Name")
+		}
+		if config["AS"] != nil {
+			c.add("6.9", 0, "optional content configurations must not have an AS entry")
+		}
+	}
+}
+
+// checkOutputIntent checks the PDF/A output intent and that device colour
+// matches it.
+func (c *pdfaChecker) checkOutputIntent() {
+	var profile types.Object
+	for _, o := range c.array(c.ctx.RootDict["OutputIntents"]) {
+		intent := c.dict(o)
+		if c.name(intent, "S") != "GTS_PDFA1" {
+			continue
+		}
+		// Streams are always indirect, so the same profile is the same ref
+		dest := intent["DestOutputProfile"]
+		if ref, ok := dest.(types.IndirectRef); profile != nil && (!ok || profile != types.Object(ref)) {
+			c.add("6.2.2", 0, "all PDF/A output intents must use the same profile")
+		}
+		profile = dest
+	}
+
+	family := ""
+	if profile == nil {
+		c.add("6.2.2", 0, "the file needs a GTS_PDFA1 output intent with a destination profile")
+	} else if s, _, ok := c.stream(profile); !ok {
+		c.add("6.2.2", 0, "the output intent's destination profile is missing")
+	} else if err := s.Decode(); err != nil || len(s.Content) < 128 {
+		c.add("6.2.2", 0, "the output intent's ICC profile can't be read")
+	} else {
+		data := s.Content
+		n, _ := c.resolve(s.Dict["N"]).(types.Integer)
+		spaces := map[string]types.Integer{"RGB ": 3, "CMYK": 4, "GRAY": 1}
+		space := string(data[16:20])
+		switch {
+		case data[8] > 4:
+			c.add("6.2.2", 0, "the output intent's ICC profile version must be 4 or older")
+		case spaces[space] == 0 || spaces[space] != n:
+			c.add("6.2.2", 0, "the output intent's ICC profile must be RGB, CMYK or Gray and match its N entry")
+		default:
+			family = map[string]string{"RGB ": "RGB", "CMYK": "CMYK", "GRAY": "Gray"}[space]
+		}
+	}
+
+	for _, f := range []string{"RGB", "CMYK", "Gray"} {
+		objs := c.colors[f]
+		if len(objs) == 0 || f == family || (f == "Gray" && family != "") {
+			continue
+		}
+		for _, num := range objs {
+			c.add("6.2.4.3", num, "Device%s colour needs a matching output intent", f)
+		}
+	}
+}
+
+func (c *pdfaChecker) checkMetadata() {
+	meta, _, ok := c.stream(c.ctx.RootDict["Metadata"])
+	if !ok {
+		c.add("6.6.2", 0, "the catalog must have an XMP metadata stream")
+		return
+	}
+	if len(meta.FilterPipeline) > 0 {
+		c.add("6.6.2", 0, "the XMP metadata stream must not be filtered")
+	}
+	if err := meta.Decode(); err != nil {
+		c.add("6.6.2", 0, "the XMP metadata can't be read: %v", err)
+		return
+	}
+	props, err := parseXMP(meta.Content)
+	if err != nil {
+		c.add("6.6.2", 0, "the XMP metadata is not well-formed: %v", err)
+		return
+	}
+	if props[nsPDFAID+"part"] != "2" || !slices.Contains([]string{"A", "B", "U"}, props[nsPDFAID+"conformance"]) {
+		c.add("6.6.4", 0, "the XMP metadata must identify the file as PDF/A-2")
+	}
+
+	var info types.Dict
+	if c.ctx.Info != nil {
+		info = c.dict(*c.ctx.Info)
+	}
+	for _, e := range infoXMP {
+		want, ok := textEntry(c.ctx, info, e.key)
+		if !ok {
+			continue
+		}
+		prefix, local, _ := strings.Cut(e.prop, ":")
+		have := props[xmpNamespaces[prefix]+local]
+		match := want == have
+		if e.key == "CreationDate" || e.key == "ModDate" {
+			t1, err1 := ParseDate(want)
+			t2, err2 := time.Parse(time.RFC3339, have)
+			match = err1 == nil && err2 == nil && t1.Equal(t2)
+		}
+		if !match {
+			c.add("6.6.3", 0, "document information %s doesn't match XMP %s", e.key, e.prop)
+		}
+	}
+}
+
+// parseXMP returns the simple properties of an XMP packet keyed by
+// namespace URI and name. For arrays the first item is taken.
+func parseXMP(data []byte) (map[string]string, error) {
+	props := map[string]string{}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth, desc := 0, 0
+	var prop string
+	var text strings.Builder
+	var done bool
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return props, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case t.Name.Space == nsRDF && t.Name.Local == "Description":
+				desc = depth
+				// Properties may also be written as attributes
+				for _, a := range t.Attr {
+					if a.Name.Space != "" && a.Name.Space != nsRDF && a.Name.Space != "xmlns" {
+						props[a.Name.Space+a.Name.Local] = a.Value
+					}
+				}
+			case desc > 0 && depth == desc+1:
+				prop, done = t.Name.Space+t.Name.Local, false
+				text.Reset()
+			}
+		case xml.CharData:
+			if prop != "" && !done {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case prop != "" && depth == desc+1:
+				props[prop] = strings.TrimSpace(text.String())
+				prop = ""
+			case prop != "" && t.Name.Space == nsRDF && t.Name.Local == "li":
+				done = true
+			case depth == desc:
+				desc = 0
+			}
+			depth--
+		}
+	}
+}
+
+// checkContents scans page, form and glyph content streams for device
+// colour operators.
+func (c *pdfaChecker) checkContents() {
+	for _, num := range c.nums {
+		obj := c.ctx.Table[num].Object
+		if s, ok := obj.(types.StreamDict); ok && c.name(s.Dict, "Subtype") == "Form" {
+			c.scanContent(num, s)
+		}
+		d, _ := obj.(types.Dict)
+		switch {
+		case c.name(d, "Type") == "Page":
+			contents := d["Contents"]
+			if a := c.array(contents); a != nil {
+				for _, e := range a {
+					if s, n, ok := c.stream(e); ok {
+						c.scanContent(n, s)
+					}
+				}
+			} else if s, n, ok := c.stream(contents); ok {
+				c.scanContent(n, s)
+			}
+		case c.name(d, "Subtype") == "Type3":
+			for _, p := range c.dict(d["CharProcs"]) {
+				if s, n, ok := c.stream(p); ok {
+					c.scanContent(n, s)
+				}
+			}
+		}
+	}
+}
+
+func (c *pdfaChecker) scanContent(num int, s types.StreamDict) {
+	if err := s.Decode(); err != nil {
+		return
+	}
+	content := string(s.Content)
+	var last types.Object
+	for {
+		operand, op, ok := nextContentToken(&content)
+		if !ok {
+			return
+		}
+		if op == "" {
+			last = operand
+			continue
+		}
+		switch op {
+		case "rg", "RG":
+			c.useColor("RGB", num)
+		case "k", "K":
+			c.useColor("CMYK", num)
+		case "g", "G":
+			c.useColor("Gray", num)
+		case "cs", "CS":
+			c.colorSpace(num, last)
+		case "BI":
+			// Inline image: a dictionary up to ID, then binary data up to
+			// EI
+			var key types.Object
+			for {
+				o, op, ok := nextContentToken(&content)
+				if !ok {
+					return
+				}
+				if op == "ID" {
+					break
+				}
+				if key == types.Name("CS") || key == types.Name("ColorSpace") {
+					c.colorSpace(num, o)
+				}
+				key = o
+			}
+			for i := 0; ; {
+				j := strings.Index(content[i:], "EI")
+				if j < 0 {
+					return
+				}
+				if i += j; i > 0 && isSpace(content[i-1]) {
+					content = content[i+2:]
+					break
+				}
+				i += 2
+			}
+		}
+		last = nil
+	}
+}
+
+// nextContentToken reads the next operand or operator from a content
+// stream, advancing s past it. ok is false at the end or on bad syntax.
+func nextContentToken(s *string) (operand types.Object, op string, ok bool) {
+	for {
+		*s = strings.TrimLeftFunc(*s, func(r rune) bool { return r < 0x80 && isSpace(byte(r)) })
+		if !strings.HasPrefix(*s, "%") {
+			break
+		}
+		// A comment runs to the end of the line
+		end := strings.IndexAny(*s, "\r\n")
+		if end < 0 {
+			end = len(*s)
+		}
+		*s = (*s)[end:]
+	}
+	if *s == "" {
+		return nil, "", false
+	}
+	// Operators are the bare words that aren't numbers; pdfcpu parses
+	// everything else.
+	if b := (*s)[0]; !isDelim(b) && !strings.ContainsRune("0123456789+-.", rune(b)) {
+		end := strings.IndexFunc(*s, func(r rune) bool { return r < 0x80 && (isSpace(byte(r)) || isDelim(byte(r))) })
+		if end < 0 {
+			end = len(*s)
+		}
+		op, *s = (*s)[:end], (*s)[end:]
+		return nil, op, true
+	}
+	o, err := model.ParseObject(s)
+	if err != nil || o == nil {
+		return nil, "", false
+	}
+	return o, "", true
+}
@@ -0,0 +1,209 @@
+package pdf
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// archivalDoc returns a test document with its text in an embedded font,
+// the way the stamp steps draw it, and information to mirror in the
+// metadata.
+func archivalDoc(t *testing.T) *Document {
+	t.Helper()
+	doc, err := Parse(buildBlankPDF(t, testPage{612, 1}, testPage{612, -1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := &TextStamp{Text: "Page {page}", Font: EmbeddedFont, FontSize: 24, Color: "#000000", Opacity: 1, Position: PositionTopLeft, Margin: 72}
+	if err := doc.AddTextStamp(stamp); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetInfo(map[string]string{"Title": "Invoice 1001", "Author": "Accounts"}); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// firstAnnot returns the link annotation on the first page of doc.
+func firstAnnot(t *testing.T, ctx *model.Context) types.Dict {
+	t.Helper()
+	page, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annots, _ := ctx.DereferenceArray(page["Annots"])
+	if len(annots) == 0 {
+		t.Fatal("page 1 has no annotations")
+	}
+	annot, _ := ctx.DereferenceDict(annots[0])
+	return annot
+}
+
+func TestPreparedDocumentPassesPreflight(t *testing.T) {
+	doc := archivalDoc(t)
+	if err := doc.PreparePDFA2B(); err != nil {
+		t.Fatal(err)
+	}
+	data := writeDoc(t, doc)
+	if issues := PreflightPDFA2B(data); issues != nil {
+		t.Errorf("issues = %v, want none", issues)
+	}
+
+	// Signing appends an update, which must still pass the check
+	var given []byte
+	field := &SignatureField{Rect: Rect{20, 20, 220, 70}, Text: "Signed", Font: EmbeddedFont, Size: MaxStringPDFA}
+	signed, err := Sign(data, field, digestSigner(&given))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := PreflightPDFA2B(signed); issues != nil {
+		t.Errorf("signed: issues = %v, want none", issues)
+	}
+}
+
+func TestPreflightPDFA2BFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		clause string
+		// change is made after PreparePDFA2B
+		change func(ctx *model.Context)
+	}{
+		{"font not embedded", "6.2.11.4", func(ctx *model.Context) {
+			addResource(t, ctx, "Font", types.Dict{"Type": types.Name("Font"), "Subtype": types.Name("Type1"), "BaseFont": types.Name("Helvetica")})
+		}},
+		{"hidden annotation", "6.3.2", func(ctx *model.Context) {
+			firstAnnot(t, ctx)["F"] = types.Integer(annotHidden)
+		}},
+		{"JavaScript action", "6.5.1", func(ctx *model.Context) {
+			ctx.RootDict["OpenAction"] = types.Dict{"S": types.Name("JavaScript"), "JS": textString("app.alert(1)")}
+		}},
+		{"interpolated image", "6.2.8", func(ctx *model.Context) {
+			sd := types.StreamDict{Dict: types.Dict{
+				"Type": types.Name("XObject"), "Subtype": types.Name("Image"), "Width": types.Integer(1), "Height": types.Integer(1),
+				"ColorSpace": types.Name("DeviceRGB"), "BitsPerComponent": types.Integer(8), "Interpolate": types.Boolean(true),
+			}, Content: []byte{0, 0, 0}}
+			sd.Encode()
+			addResource(t, ctx, "XObject", sd)
+		}},
+		{"LZW stream", "6.1.7.2", func(ctx *model.Context) {
+			sd := types.StreamDict{
+				Dict:           types.Dict{"Type": types.Name("XObject"), "Subtype": types.Name("Form"), "BBox": types.NewNumberArray(0, 0, 1, 1), "Filter": types.Name("LZWDecode")},
+				FilterPipeline: []types.PDFFilter{{Name: "LZWDecode"}},
+				Content:        []byte("q Q"),
+			}
+			if err := sd.Encode(); err != nil {
+				t.Fatal(err)
+			}
+			addResource(t, ctx, "XObject", sd)
+		}},
+		{"no output intent", "6.2.2", func(ctx *model.Context) {
+			delete(ctx.RootDict, "OutputIntents")
+		}},
+		{"no metadata", "6.6.2", func(ctx *model.Context) {
+			delete(ctx.RootDict, "Metadata")
+		}},
+		{"optional content usage", "6.9", func(ctx *model.Context) {
+			oc, _ := ctx.DereferenceDict(ctx.RootDict["OCProperties"])
+			ocConfigs(ctx, oc)[0]["AS"] = types.Array{}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := archivalDoc(t)
+			if err := doc.PreparePDFA2B(); err != nil {
+				t.Fatal(err)
+			}
+			tt.change(doc.ctx)
+			issues := PreflightPDFA2B(writeDoc(t, doc))
+			if !slices.ContainsFunc(issues, func(i Issue) bool { return i.Clause == tt.clause }) {
+				t.Errorf("issues = %v, want one for clause %s", issues, tt.clause)
+			}
+		})
+	}
+}
+
+func TestPreflightPDFA2BWrittenFile(t *testing.T) {
+	doc := archivalDoc(t)
+	if err := doc.PreparePDFA2B(); err != nil {
+		t.Fatal(err)
+	}
+	data := writeDoc(t, doc)
+	if !bytes.Contains(data, []byte(">Invoice 1001<")) {
+		t.Fatal("title not found in the XMP metadata")
+	}
+
+	tests := []struct {
+		name   string
+		clause string
+		data   []byte
+	}{
+		{"data after EOF", "6.1.3", append(slices.Clone(data), "\nappended"...)},
+		{"no binary comment", "6.1.2", append([]byte("%PDF-1.7\n%plain\n"), data[len("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"):]...)},
+		{"PDF 2.0 header", "6.1.2", append([]byte("%PDF-2.0"), data[len("%PDF-1.7"):]...)},
+		{"not a PDF", "6.1.1", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\nnothing here\n%%EOF\n")},
+		{"information differs from metadata", "6.6.3", retitleXMP(data)},
+		{"encrypted", "6.1.3", encryptedPDF(t, "", "owner", PermPrint)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := PreflightPDFA2B(tt.data)
+			if !slices.ContainsFunc(issues, func(i Issue) bool { return i.Clause == tt.clause }) {
+				t.Errorf("issues = %v, want one for clause %s", issues, tt.clause)
+			}
+		})
+	}
+}
+
+// addResource adds o to the resources of the first page, so that it is
+// written.
+func addResource(t *testing.T, ctx *model.Context, kind string, o types.Object) {
+	t.Helper()
+	ref, err := ctx.IndRefForNewObject(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := ctx.DereferenceDict(page["Resources"])
+	if res == nil {
+		res = types.Dict{}
+		page["Resources"] = res
+	}
+	names, _ := ctx.DereferenceDict(res[kind])
+	if names == nil {
+		names = types.Dict{}
+		res[kind] = names
+	}
+	names["Test"] = *ref
+}
+
+// retitleXMP changes the title in the current XMP metadata of data, the
+// last copy in the file, keeping its length so the offsets still hold.
+func retitleXMP(data []byte) []byte {
+	data = slices.Clone(data)
+	if i := bytes.LastIndex(data, []byte(">Invoice 1001<")); i >= 0 {
+		copy(data[i:], ">Invoice 1002<")
+	}
+	return data
+}
+
+func TestPreparePDFA2BFixesAnnotations(t *testing.T) {
+	doc := archivalDoc(t)
+	annot := firstAnnot(t, doc.ctx)
+	annot["F"] = types.Integer(annotHidden | annotNoView)
+	if err := doc.PreparePDFA2B(); err != nil {
+		t.Fatal(err)
+	}
+	if got := annot["F"]; got != types.Integer(annotPrint) {
+		t.Errorf("annotation flags = %v, want %d", got, annotPrint)
+	}
+	if issues := PreflightPDFA2B(writeDoc(t, doc)); issues != nil {
+		t.Errorf("issues = %v, want none", issues)
+	}
+}
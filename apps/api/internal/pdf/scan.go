@@ -0,0 +1,82 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var (
+	pageTypes = [][]byte{[]byte("/Type /Page"), []byte("/Type/Page")}
+	objStm    = []byte("/ObjStm")
+)
+
+// pageScanTail is how much of each write is kept to find matches that
+// span writes: the longest page type and the byte after it, less one.
+const pageScanTail = 11
+
+// PageScanner counts the pages of a PDF written to it without holding the
+// document, by counting page objects. That only works for files whose
+// objects aren't packed into object streams, such as Chromium's; Count
+// reports an error for the others.
+type PageScanner struct {
+	tail   []byte
+	pages  int
+	packed bool
+}
+
+func (s *PageScanner) Write(p []byte) (int, error) {
+	buf := append(s.tail, p...)
+	s.scan(buf, len(buf)-pageScanTail)
+	s.tail = append(s.tail[:0], buf[max(0, len(buf)-pageScanTail):]...)
+	return len(p), nil
+}
+
+// scan counts page objects starting before limit in buf.
+func (s *PageScanner) scan(buf []byte, limit int) {
+	if bytes.Contains(buf, objStm) {
+		s.packed = true
+	}
+	for _, pt := range pageTypes {
+		for i := 0; i < limit; {
+			j := bytes.Index(buf[i:], pt)
+			if j < 0 || i+j >= limit {
+				break
+			}
+			end := i + j + len(pt)
+			// "/Type /Pages" is a page tree node
+			if end == len(buf) || isSpace(buf[end]) || isDelim(buf[end]) {
+				s.pages++
+			}
+			i = end
+		}
+	}
+}
+
+// Count returns the number of pages once the whole file has been written.
+func (s *PageScanner) Count() (int, error) {
+	s.scan(s.tail, len(s.tail))
+	s.tail = nil
+	if s.packed {
+		return 0, fmt.Errorf("pdf: can't count pages in object streams")
+	}
+	if s.pages == 0 {
+		return 0, fmt.Errorf("pdf: no pages found")
+	}
+	return s.pages, nil
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,417 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// DefaultSignatureSize is the space reserved for the CMS signature when a
+// SignatureField doesn't say. It fits a certificate chain of a few
+// certificates plus a timestamp token.
+const DefaultSignatureSize = 32 * 1024
+
+// byteRangePlaceholder is written in place of the real byte range, which is
+// only known once the file is laid out. The numbers are wide enough for
+// any offset, so patching never moves anything.
+const byteRangePlaceholder = "[0 9999999999 9999999999 9999999999]"
+
+// SignatureField describes a signature added by Sign.
+type SignatureField struct {
+	// Name is the form field name; default "Signature1".
+	Name string
+	// Signer, Reason, Location and ContactInfo are shown by viewers.
+	Signer      string
+	Reason      string
+	Location    string
+	ContactInfo string
+	Time        time.Time
+
+	// Page is the 1-based page showing the signature, default the first;
+	// negative numbers count from the end, so -1 is the last page.
+	// Rect places it relative to the lower left corner of the page's
+	// media box; a zero Rect makes the signature invisible.
+	Page int
+	Rect Rect
+	// Text is drawn in Rect inside a thin frame, a line per "\n", in Font
+	// (StandardFont or EmbeddedFont) at FontSize.
+	Text     string
+	Font     string
+	FontSize float64
+
+	// Size is the number of bytes reserved for the signature.
+	Size int
+}
+
+// Sign adds a signature field to data as an incremental update and signs
+// it. The original bytes are kept as they are, so earlier signatures stay
+// valid. sign gets the file minus the space reserved for the signature
+// and returns a DER CMS SignedData (detached), which is embedded as the
+// signature value. Nothing may change the file afterwards.
+func Sign(data []byte, f *SignatureField, sign func(signed []byte) ([]byte, error)) ([]byte, error) {
+	ctx, err := readContext(data)
+	if err != nil {
+		return nil, err
+	}
+	size := f.Size
+	if size <= 0 {
+		size = DefaultSignatureSize
+	}
+	page := f.Page
+	switch {
+	case page == 0:
+		page = 1
+	case page < 0:
+		page += ctx.PageCount + 1
+	}
+	if page < 1 || page > ctx.PageCount {
+		return nil, fmt.Errorf("pdf: signature page %d is out of range (document has %d pages)", f.Page, ctx.PageCount)
+	}
+	pageDict, pageRef, inherited, err := ctx.PageDict(page, false)
+	if err != nil {
+		return nil, err
+	}
+	if pageDict == nil || pageRef == nil || inherited == nil || inherited.MediaBox == nil {
+		return nil, fmt.Errorf("pdf: page %d missing", page)
+	}
+	box := inherited.MediaBox
+
+	inc := newIncrement(ctx)
+	inc.changed(pageRef.ObjectNumber.Value())
+	inc.changed(ctx.Root.ObjectNumber.Value())
+
+	t := f.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	sig := types.Dict{
+		"Type":      types.Name("Sig"),
+		"Filter":    types.Name("Adobe.PPKLite"),
+		"SubFilter": types.Name("ETSI.CAdES.detached"),
+		"M":         types.StringLiteral(Date(t)),
+		// Both are patched once the increment is written
+		"ByteRange": types.Array{types.Integer(0), types.Integer(9999999999), types.Integer(9999999999), types.Integer(9999999999)},
+		"Contents":  types.HexLiteral(strings.Repeat("0", 2*size)),
+	}
+	for key, v := range map[string]string{"Name": f.Signer, "Reason": f.Reason, "Location": f.Location, "ContactInfo": f.ContactInfo} {
+		if v != "" {
+			sig[key] = textString(v)
+		}
+	}
+	sigRef, err := ctx.IndRefForNewObject(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	acroForm, err := inc.dict(ctx.RootDict, "AcroForm")
+	if err != nil {
+		return nil, err
+	}
+	fields, _ := ctx.DereferenceArray(acroForm["Fields"])
+
+	rect := types.NewRectangle(box.LL.X+f.Rect.LLX, box.LL.Y+f.Rect.LLY, box.LL.X+f.Rect.URX, box.LL.Y+f.Rect.URY)
+	widget := types.Dict{
+		"Type":    types.Name("Annot"),
+		"Subtype": types.Name("Widget"),
+		"FT":      types.Name("Sig"),
+		"T":       textString(uniqueFieldName(ctx, fields, f.Name)),
+		"V":       *sigRef,
+		"F":       types.Integer(annotPrint | annotLocked),
+		"Rect":    rect.Array(),
+		"P":       *pageRef,
+	}
+	if w, h := f.Rect.Width(), f.Rect.Height(); w > 0 && h > 0 && f.Text != "" {
+		ap, err := signatureAppearance(ctx, w, h, f)
+		if err != nil {
+			return nil, err
+		}
+		widget["AP"] = types.Dict{"N": *ap}
+	}
+	widgetRef, err := ctx.IndRefForNewObject(widget)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := inc.appendRef(acroForm, "Fields", *widgetRef); err != nil {
+		return nil, err
+	}
+	acroForm["SigFlags"] = types.Integer(3) // signatures exist, append only
+	if err := inc.appendRef(pageDict, "Annots", *widgetRef); err != nil {
+		return nil, err
+	}
+
+	// ETSI.CAdES.detached is a PDF 2.0 feature, declared as an extension
+	// for 1.7 readers. The header can't change in an increment, so older
+	// files get the catalog's Version instead.
+	if ctx.XRefTable.Version() < model.V17 {
+		ctx.RootDict["Version"] = types.Name("1.7")
+	}
+	ext, err := inc.dict(ctx.RootDict, "Extensions")
+	if err != nil {
+		return nil, err
+	}
+	ext["ESIC"] = types.Dict{"BaseVersion": types.Name("1.7"), "ExtensionLevel": types.Integer(2)}
+
+	out, err := inc.write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The placeholders are only in the increment, each exactly once
+	update := out[len(data):]
+	at := bytes.Index(update, []byte("/ByteRange"+byteRangePlaceholder))
+	contents := []byte("<" + strings.Repeat("0", 2*size) + ">")
+	rel := bytes.Index(update, contents)
+	if at < 0 || rel < 0 || bytes.Count(update, []byte(byteRangePlaceholder)) != 1 {
+		return nil, fmt.Errorf("pdf: signature placeholder not found")
+	}
+	start := len(data) + rel
+	end := start + len(contents)
+
+	// Patch the byte range in place, padding to the placeholder's width
+	rangeAt := len(data) + at + len("/ByteRange")
+	actual := fmt.Sprintf("[0 %d %d %d", start, end, len(out)-end)
+	copy(out[rangeAt:], actual+strings.Repeat(" ", len(byteRangePlaceholder)-len(actual)-1)+"]")
+
+	signed := make([]byte, 0, len(out)-(end-start))
+	signed = append(append(signed, out[:start]...), out[end:]...)
+	value, err := sign(signed)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) > size {
+		return nil, fmt.Errorf("pdf: signature is %d bytes, only %d reserved", len(value), size)
+	}
+	hex.Encode(out[start+1:], value)
+	return out, nil
+}
+
+// signatureAppearance adds the form XObject drawn in a visible signature:
+// a thin frame with text lines from the top left.
+func signatureAppearance(ctx *model.Context, w, h float64, f *SignatureField) (*types.IndirectRef, error) {
+	name := f.Font
+	if name == "" {
+		name = StandardFont
+	}
+	if name == EmbeddedFont {
+		if err := loadFonts(); err != nil {
+			return nil, err
+		}
+	}
+	size := f.FontSize
+	if size == 0 {
+		size = 8
+	}
+
+	pad := 2 * 72 / 25.4 // 2mm
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "q 0.5 G 0.5 w 0.25 0.25 %s %s re S Q\n", num(w-0.5), num(h-0.5))
+	fmt.Fprintf(&b, "q 0.1 g BT /F0 %s Tf %s TL %s %s Td\n", num(size), num(size*1.25), num(pad), num(h-pad-size))
+	for i, line := range strings.Split(f.Text, "\n") {
+		if i > 0 {
+			b.WriteString("T* ")
+		}
+		if font.IsCoreFont(name) {
+			line = model.DecodeUTF8ToByte(line)
+		}
+		// Records the glyphs used, so an embedded font is subset to them
+		fmt.Fprintf(&b, "(%s) Tj\n", model.PrepBytes(ctx.XRefTable, line, name, true, false, false))
+	}
+	b.WriteString("ET Q\n")
+
+	fontRef, err := pdffont.EnsureFontDict(ctx.XRefTable, name, "", "", false, nil)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := ctx.NewStreamDictForBuf(b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	sd.InsertName("Type", "XObject")
+	sd.InsertName("Subtype", "Form")
+	sd.Insert("BBox", types.NewRectangle(0, 0, w, h).Array())
+	sd.Insert("Resources", types.Dict{"Font": types.Dict{"F0": *fontRef}})
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	return ctx.IndRefForNewObject(*sd)
+}
+
+// uniqueFieldName returns name, or "Signature1", made unique among the
+// top-level fields.
+func uniqueFieldName(ctx *model.Context, fields types.Array, name string) string {
+	taken := map[string]bool{}
+	for _, ref := range fields {
+		if field, _ := ctx.DereferenceDict(ref); field != nil {
+			taken[text(ctx, field["T"])] = true
+		}
+	}
+	if name != "" && !taken[name] {
+		return name
+	}
+	base := name
+	if base == "" {
+		base = "Signature"
+	}
+	for i := 1; ; i++ {
+		if n := fmt.Sprintf("%s%d", base, i); !taken[n] {
+			return n
+		}
+	}
+}
+
+// SignatureInfo is a signature found in a document.
+type SignatureInfo struct {
+	Field     string
+	SubFilter string
+	Signer    string
+	Reason    string
+	Location  string
+	// Time is the signing time claimed by the signer (/M).
+	Time      *time.Time
+	ByteRange []int64
+	// Contents is the signature value, usually a DER CMS SignedData
+	// followed by zero padding.
+	Contents []byte
+}
+
+// Signatures lists the signed signature fields of data.
+func Signatures(data []byte) ([]SignatureInfo, error) {
+	ctx, err := readContext(data)
+	if err != nil {
+		return nil, err
+	}
+	acroForm, _ := ctx.DereferenceDict(ctx.RootDict["AcroForm"])
+	fields, _ := ctx.DereferenceArray(acroForm["Fields"])
+
+	var out []SignatureInfo
+	seen := map[int]bool{}
+	var walk func(o types.Object, parentName, parentFT string, depth int)
+	walk = func(o types.Object, parentName, parentFT string, depth int) {
+		if ref, ok := o.(types.IndirectRef); ok {
+			if seen[ref.ObjectNumber.Value()] {
+				return
+			}
+			seen[ref.ObjectNumber.Value()] = true
+		}
+		field, _ := ctx.DereferenceDict(o)
+		if field == nil || depth > 32 {
+			return
+		}
+		name := parentName
+		if t := text(ctx, field["T"]); t != "" {
+			if name != "" {
+				name += "."
+			}
+			name += t
+		}
+		ft := parentFT
+		if v := field.NameEntry("FT"); v != nil {
+			ft = *v
+		}
+		if kids, _ := ctx.DereferenceArray(field["Kids"]); kids != nil {
+			for _, k := range kids {
+				walk(k, name, ft, depth+1)
+			}
+		}
+		if ft != "Sig" {
+			return
+		}
+		v, _ := ctx.DereferenceDict(field["V"])
+		if v == nil {
+			return
+		}
+
+		info := SignatureInfo{Field: name}
+		if sf := v.NameEntry("SubFilter"); sf != nil {
+			info.SubFilter = *sf
+		}
+		info.Signer, info.Reason, info.Location = text(ctx, v["Name"]), text(ctx, v["Reason"]), text(ctx, v["Location"])
+		if t, err := ParseDate(text(ctx, v["M"])); err == nil {
+			info.Time = &t
+		}
+		if br, _ := ctx.DereferenceArray(v["ByteRange"]); br != nil {
+			for _, n := range br {
+				i, _ := ctx.DereferenceInteger(n)
+				if i == nil {
+					break
+				}
+				info.ByteRange = append(info.ByteRange, int64(*i))
+			}
+		}
+		if c, _ := ctx.Dereference(v["Contents"]); c != nil {
+			switch c := c.(type) {
+			case types.HexLiteral:
+				info.Contents, _ = c.Bytes()
+			case types.StringLiteral:
+				info.Contents, _ = types.Unescape(c.Value())
+			}
+		}
+		out = append(out, info)
+	}
+	for _, f := range fields {
+		walk(f, "", "", 0)
+	}
+	return out, nil
+}
+
+// SignedBytes returns the part of raw covered by the signature. whole is
+// set when it reaches the end of the file, i.e. nothing was appended
+// after signing.
+func (s *SignatureInfo) SignedBytes(raw []byte) (signed []byte, whole bool, err error) {
+	br := s.ByteRange
+	if len(br) != 4 || br[0] != 0 || br[1] <= 0 || br[2] < br[1] || br[3] < 0 || br[2]+br[3] > int64(len(raw)) {
+		return nil, false, fmt.Errorf("pdf: invalid signature byte range %v", br)
+	}
+	// The gap must be exactly the hex string holding the signature
+	if raw[br[1]] != '<' || raw[br[2]-1] != '>' {
+		return nil, false, fmt.Errorf("pdf: signature byte range does not exclude exactly the signature")
+	}
+	signed = make([]byte, 0, br[1]+br[3])
+	signed = append(append(signed, raw[:br[1]]...), raw[br[2]:br[2]+br[3]]...)
+	return signed, br[2]+br[3] == int64(len(raw)), nil
+}
+
+// Date formats t as a PDF date string.
+func Date(t time.Time) string {
+	_, offset := t.Zone()
+	if offset == 0 {
+		return t.Format("D:20060102150405Z")
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%s%c%02d'%02d'", t.Format("D:20060102150405"), sign, offset/3600, offset/60%60)
+}
+
+// ParseDate reads a PDF date string; fields after the year are optional.
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimPrefix(s, "D:")
+	s = strings.ReplaceAll(strings.TrimSuffix(s, "'"), "'", "")
+	digits := len(s)
+	for i, c := range s {
+		if c < '0' || c > '9' {
+			digits = i
+			break
+		}
+	}
+	layouts := map[int]string{4: "2006", 6: "200601", 8: "20060102", 10: "2006010215", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[digits]
+	if !ok {
+		return time.Time{}, fmt.Errorf("pdf: bad date %q", s)
+	}
+	switch zone := s[digits:]; {
+	case zone == "" || zone == "Z" || zone == "Z0000":
+		return time.ParseInLocation(layout, s[:digits], time.UTC)
+	default:
+		return time.Parse(layout+"-0700", s[:digits]+zone)
+	}
+}
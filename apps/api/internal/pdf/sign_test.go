@@ -0,0 +1,313 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// digestSigner stands in for a CMS signature: the digest of what it was
+// given, which it keeps in given.
+func digestSigner(given *[]byte) func([]byte) ([]byte, error) {
+	return func(signed []byte) ([]byte, error) {
+		*given = bytes.Clone(signed)
+		sum := sha256.Sum256(signed)
+		return sum[:], nil
+	}
+}
+
+// validContext reads data with pdfcpu and validates it, cross-reference
+// sections and all, failing the test if it doesn't pass.
+func validContext(t *testing.T, data []byte) *model.Context {
+	t.Helper()
+	ctx, err := api.ReadAndValidate(bytes.NewReader(data), configuration())
+	if err != nil {
+		t.Fatalf("pdfcpu rejected the signed file: %v", err)
+	}
+	return ctx
+}
+
+func TestSignCoversWholeFile(t *testing.T) {
+	data := buildPDF(t, testPage{612, -1}, testPage{612, -1})
+	var given []byte
+	field := &SignatureField{Signer: "Test Signer", Reason: "Approval", Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Size: 256}
+	out, err := Sign(data, field, digestSigner(&given))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, data) {
+		t.Fatal("signing rewrote the original bytes instead of appending an update")
+	}
+	if ctx := validContext(t, out); ctx.PageCount != 2 {
+		t.Errorf("signed file has %d pages, want 2", ctx.PageCount)
+	}
+
+	sigs, err := Signatures(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 1 {
+		t.Fatalf("found %d signatures, want 1", len(sigs))
+	}
+	sig := sigs[0]
+	if sig.Field != "Signature1" || sig.SubFilter != "ETSI.CAdES.detached" || sig.Signer != "Test Signer" || sig.Reason != "Approval" || sig.Time == nil || !sig.Time.Equal(field.Time) {
+		t.Errorf("signature = %+v", sig)
+	}
+
+	covered, whole, err := sig.SignedBytes(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !whole || !bytes.Equal(covered, given) {
+		t.Error("byte range does not cover exactly what was signed")
+	}
+	sum := sha256.Sum256(given)
+	if !bytes.HasPrefix(sig.Contents, sum[:]) || len(sig.Contents) != field.Size {
+		t.Errorf("contents = %x, want the signature padded to %d bytes", sig.Contents, field.Size)
+	}
+
+	// Anything appended afterwards is outside the signature
+	if _, whole, _ := sig.SignedBytes(append(bytes.Clone(out), "\n% appended\n"...)); whole {
+		t.Error("appended content reported as covered")
+	}
+	if _, err := Sign(data, field, func([]byte) ([]byte, error) { return make([]byte, 257), nil }); err == nil {
+		t.Error("signature larger than the reserved space accepted")
+	}
+}
+
+func TestSignTwiceKeepsFirstSignature(t *testing.T) {
+	var first, second []byte
+	once, err := Sign(buildPDF(t, testPage{612, -1}), &SignatureField{Size: 64}, digestSigner(&first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, err := Sign(once, &SignatureField{Size: 64}, digestSigner(&second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	validContext(t, twice)
+
+	sigs, err := Signatures(twice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 2 || sigs[0].Field != "Signature1" || sigs[1].Field != "Signature2" {
+		t.Fatalf("signatures = %+v", sigs)
+	}
+	covered, whole, err := sigs[0].SignedBytes(twice)
+	if err != nil || whole || !bytes.Equal(covered, first) {
+		t.Errorf("first signature: whole = %v, err = %v; want it to cover the bytes it signed", whole, err)
+	}
+	covered, whole, err = sigs[1].SignedBytes(twice)
+	if err != nil || !whole || !bytes.Equal(covered, second) {
+		t.Errorf("second signature: whole = %v, err = %v; want it to cover the whole file", whole, err)
+	}
+}
+
+func TestSignPage(t *testing.T) {
+	data := buildPDF(t, testPage{500, -1}, testPage{600, -1}, testPage{700, -1})
+	tests := []struct {
+		page    int
+		want    float64
+		wantErr bool
+	}{
+		{0, 500, false},
+		{2, 600, false},
+		{-1, 700, false},
+		{-3, 500, false},
+		{4, 0, true},
+		{-4, 0, true},
+	}
+	for _, tt := range tests {
+		var given []byte
+		field := &SignatureField{Page: tt.page, Rect: Rect{10, 10, 110, 40}, Text: "Signed", Size: 64}
+		out, err := Sign(data, field, digestSigner(&given))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("page %d: Sign() accepted a page out of range", tt.page)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("page %d: %v", tt.page, err)
+		}
+
+		// The widget is in the Annots of the page asked for
+		ctx := validContext(t, out)
+		var got float64
+		for i := 1; i <= ctx.PageCount; i++ {
+			d, _, inherited, err := ctx.PageDict(i, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if annots, _ := ctx.DereferenceArray(d["Annots"]); len(annots) > 0 {
+				got = inherited.MediaBox.Width()
+			}
+		}
+		if got != tt.want {
+			t.Errorf("page %d: widget on the page %v wide, want %v", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestSignAppearanceEmbedsFont(t *testing.T) {
+	tests := []struct {
+		font     string
+		embedded bool
+	}{
+		{StandardFont, false},
+		{EmbeddedFont, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.font, func(t *testing.T) {
+			var given []byte
+			field := &SignatureField{Rect: Rect{20, 20, 220, 70}, Text: "Signed by Zoë\nÉcole", Font: tt.font, FontSize: 9, Size: 64}
+			out, err := Sign(buildPDF(t, testPage{612, -1}), field, digestSigner(&given))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := validContext(t, out)
+
+			var fontFile bool
+			for _, e := range ctx.Table {
+				if e == nil || e.Object == nil {
+					continue
+				}
+				if d, ok := e.Object.(types.Dict); ok && d.Type() != nil && *d.Type() == "FontDescriptor" && d["FontFile2"] != nil {
+					fontFile = true
+				}
+			}
+			if fontFile != tt.embedded {
+				t.Errorf("font program embedded = %v, want %v", fontFile, tt.embedded)
+			}
+		})
+	}
+}
+
+// testSigner is a self-signed certificate and its key, written as PEM
+// files to dir for openssl.
+func testSigner(t *testing.T, dir string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "cert.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeTestFile(t, dir, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func writeTestFile(t *testing.T, dir, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSignVerifiedByOpenSSL signs with openssl and checks the result
+// without the package's own reader: pdfcpu finds the signature dictionary
+// and openssl verifies its value over the bytes its ByteRange names.
+func TestSignVerifiedByOpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) ([]byte, error) {
+		cmd := exec.Command(openssl, args...)
+		cmd.Dir = dir
+		return cmd.CombinedOutput()
+	}
+	testSigner(t, dir)
+
+	field := &SignatureField{Signer: "Test Signer", Rect: Rect{20, 20, 220, 70}, Text: "Signed", Size: 4096}
+	out, err := Sign(buildPDF(t, testPage{612, -1}), field, func(signed []byte) ([]byte, error) {
+		writeTestFile(t, dir, "signed.bin", signed)
+		out, err := run("cms", "-sign", "-binary", "-in", "signed.bin", "-signer", "cert.pem", "-inkey", "key.pem", "-outform", "DER", "-out", "sig.der")
+		if err != nil {
+			t.Fatalf("openssl cms -sign: %v\n%s", err, out)
+		}
+		return os.ReadFile(filepath.Join(dir, "sig.der"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := validContext(t, out)
+	var sig types.Dict
+	for _, e := range ctx.Table {
+		if e == nil || e.Object == nil {
+			continue
+		}
+		if d, ok := e.Object.(types.Dict); ok && d.Type() != nil && *d.Type() == "Sig" {
+			sig = d
+		}
+	}
+	if sig == nil {
+		t.Fatal("no signature dictionary in the signed file")
+	}
+	var br []int
+	for _, o := range sig.ArrayEntry("ByteRange") {
+		n, ok := o.(types.Integer)
+		if !ok {
+			t.Fatalf("ByteRange = %v", sig["ByteRange"])
+		}
+		br = append(br, n.Value())
+	}
+	if len(br) != 4 || br[2]+br[3] != len(out) {
+		t.Fatalf("ByteRange = %v for a file of %d bytes", br, len(out))
+	}
+	value, err := sig["Contents"].(types.HexLiteral).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(content []byte) error {
+		writeTestFile(t, dir, "content.bin", content)
+		writeTestFile(t, dir, "value.der", value)
+		out, err := run("cms", "-verify", "-binary", "-inform", "DER", "-in", "value.der",
+			"-content", "content.bin", "-CAfile", "cert.pem", "-purpose", "any", "-out", os.DevNull)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return nil
+	}
+	content := append(bytes.Clone(out[:br[1]]), out[br[2]:br[2]+br[3]]...)
+	if err := verify(content); err != nil {
+		t.Errorf("openssl rejected the signature over its byte range: %v", err)
+	}
+	content[len(content)/2] ^= 1
+	if err := verify(content); err == nil {
+		t.Error("openssl accepted the signature over changed bytes")
+	}
+}
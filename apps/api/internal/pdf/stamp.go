@@ -0,0 +1,204 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Stamp positions. Stamps anywhere but the centre keep Margin from the
+// page edges they are next to.
+const (
+	PositionCenter       = "center"
+	PositionBottomLeft   = "bottom-left"
+	PositionBottomCenter = "bottom-center"
+	PositionBottomRight  = "bottom-right"
+	PositionTopLeft      = "top-left"
+	PositionTopCenter    = "top-center"
+	PositionTopRight     = "top-right"
+)
+
+// anchors maps positions to pdfcpu's anchors.
+var anchors = map[string]string{
+	PositionCenter:       "c",
+	PositionBottomLeft:   "bl",
+	PositionBottomCenter: "bc",
+	PositionBottomRight:  "br",
+	PositionTopLeft:      "tl",
+	PositionTopCenter:    "tc",
+	PositionTopRight:     "tr",
+}
+
+// TextStamp is a line of text drawn over pages.
+type TextStamp struct {
+	// Text may use {page} and {pages}, the page number and count.
+	Text string
+	// Font is StandardFont or EmbeddedFont.
+	Font string
+	// FontSize is rounded to whole points, the sizes pdfcpu draws.
+	FontSize float64
+	// Color is #rrggbb.
+	Color   string
+	Opacity float64
+	// Rotation is in degrees, counterclockwise about the text's centre.
+	Rotation float64
+	Position string
+	Margin   float64
+	// Pages are the 1-based pages to stamp; nil means all of them.
+	Pages []int
+}
+
+// AddTextStamp draws s on top of the pages' content.
+func (d *Document) AddTextStamp(s *TextStamp) error {
+	if s.Pages != nil && len(s.Pages) == 0 {
+		return nil
+	}
+	anchor, ok := anchors[s.Position]
+	if !ok {
+		return fmt.Errorf("pdf: unknown stamp position %q", s.Position)
+	}
+	if s.Font == EmbeddedFont {
+		if err := loadFonts(); err != nil {
+			return err
+		}
+	}
+	// An absolute scale of 1 keeps the size asked for; pdfcpu otherwise
+	// scales text to the page.
+	points := max(1, int(math.Round(s.FontSize)))
+	dx, dy := offset(anchor, s.Margin)
+	desc := fmt.Sprintf("fontname:%s, points:%d, scalefactor:1 abs, rotation:%s, fillcolor:%s, opacity:%s, position:%s, offset:%s %s",
+		s.Font, points, num(rotation(s.Rotation)), s.Color, num(s.Opacity), anchor, num(dx), num(dy))
+	text := strings.NewReplacer("%", "%%", "{page}", "%p", "{pages}", "%P").Replace(s.Text)
+	wm, err := api.TextWatermark(text, desc, true, false, types.POINTS)
+	if err != nil {
+		return fmt.Errorf("pdf: stamp: %w", err)
+	}
+	return d.stamp(pageSet(s.Pages), wm)
+}
+
+// ImageStamp is an image drawn across the middle of every page.
+type ImageStamp struct {
+	// Image is a PNG, JPEG or GIF file.
+	Image []byte
+	// Width is the image's width as a fraction of the page width.
+	Width    float64
+	Opacity  float64
+	Rotation float64
+}
+
+// AddImageStamp draws s on top of every page's content.
+func (d *Document) AddImageStamp(s *ImageStamp) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(s.Image))
+	if err != nil {
+		return fmt.Errorf("pdf: image: %w", err)
+	}
+	if cfg.Width == 0 || cfg.Height == 0 {
+		return fmt.Errorf("pdf: image is empty")
+	}
+
+	// pdfcpu scales an image relative to the page height when it is
+	// taller than wide, so pages are stamped in groups of the same width
+	// with an absolute scale instead.
+	widths := map[float64]types.IntSet{}
+	for n := 1; n <= d.ctx.PageCount; n++ {
+		w, err := d.pageWidth(n)
+		if err != nil {
+			return err
+		}
+		if widths[w] == nil {
+			widths[w] = types.IntSet{}
+		}
+		widths[w][n] = true
+	}
+	for _, w := range slices.Sorted(maps.Keys(widths)) {
+		desc := fmt.Sprintf("scalefactor:%s abs, rotation:%s, opacity:%s, position:c",
+			num(w*s.Width/float64(cfg.Width)), num(rotation(s.Rotation)), num(s.Opacity))
+		wm, err := api.ImageWatermarkForReader(bytes.NewReader(s.Image), desc, true, false, types.POINTS)
+		if err != nil {
+			return fmt.Errorf("pdf: stamp: %w", err)
+		}
+		if err := d.stamp(widths[w], wm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Document) stamp(pages types.IntSet, wm *model.Watermark) error {
+	if err := api.WatermarkContext(d.ctx, pages, wm); err != nil {
+		return fmt.Errorf("pdf: stamp: %w", err)
+	}
+	return nil
+}
+
+// pageWidth is the width of a page as pdfcpu lays stamps out on it: its
+// crop box, turned with the page.
+func (d *Document) pageWidth(n int) (float64, error) {
+	_, _, inherited, err := d.ctx.PageDict(n, false)
+	if err != nil {
+		return 0, err
+	}
+	if inherited == nil || inherited.MediaBox == nil {
+		return 0, fmt.Errorf("pdf: page %d has no media box", n)
+	}
+	box := inherited.MediaBox
+	if inherited.CropBox != nil {
+		box = inherited.CropBox
+	}
+	if inherited.Rotate%180 != 0 {
+		return box.Height(), nil
+	}
+	return box.Width(), nil
+}
+
+// offset returns the shift from a pdfcpu anchor that keeps margin from the
+// page edges next to it.
+func offset(anchor string, margin float64) (dx, dy float64) {
+	switch anchor[len(anchor)-1] {
+	case 'l':
+		dx = margin
+	case 'r':
+		dx = -margin
+	}
+	switch anchor[0] {
+	case 'b':
+		dy = margin
+	case 't':
+		dy = -margin
+	}
+	return dx, dy
+}
+
+// rotation brings degrees into the -180 to 180 range pdfcpu accepts.
+func rotation(degrees float64) float64 {
+	return math.Remainder(degrees, 360)
+}
+
+func pageSet(pages []int) types.IntSet {
+	if pages == nil {
+		return nil
+	}
+	set := types.IntSet{}
+	for _, n := range pages {
+		set[n] = true
+	}
+	return set
+}
+
+// num formats a number for a content stream or a pdfcpu description.
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
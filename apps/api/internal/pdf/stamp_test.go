@@ -0,0 +1,120 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// stampForm returns the bounding box and content of the form pdfcpu draws
+// a stamp with on page n, or false if the page has none.
+func stampForm(t *testing.T, ctx *model.Context, n int) (*[4]float64, []byte, bool) {
+	t.Helper()
+	page, _, _, err := ctx.PageDict(n, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := ctx.DereferenceDict(page["Resources"])
+	xobjects, _ := ctx.DereferenceDict(res["XObject"])
+	for _, o := range xobjects {
+		sd, _, err := ctx.DereferenceStreamDict(o)
+		if err != nil || sd == nil || dictName(ctx, sd.Dict, "Subtype") != "Form" {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		box, err := ctx.RectForArray(sd.ArrayEntry("BBox"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &[4]float64{box.LL.X, box.LL.Y, box.UR.X, box.UR.Y}, sd.Content, true
+	}
+	return nil, nil, false
+}
+
+func TestAddTextStamp(t *testing.T) {
+	doc, err := Parse(buildPDF(t, testPage{600, -1}, testPage{400, -1}, testPage{500, -1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := &TextStamp{
+		Text: "{page} of {pages}, 100%", Font: StandardFont, FontSize: 9, Color: "#333333", Opacity: 1,
+		Position: PositionBottomRight, Margin: 20, Pages: []int{2, 3},
+	}
+	if err := doc.AddTextStamp(stamp); err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t, writeDoc(t, doc))
+
+	if _, _, ok := stampForm(t, ctx, 1); ok {
+		t.Error("page 1 stamped, want only pages 2 and 3")
+	}
+	for n, width := range map[int]float64{2: 400, 3: 500} {
+		box, content, ok := stampForm(t, ctx, n)
+		if !ok {
+			t.Fatalf("page %d not stamped", n)
+		}
+		if want := []byte(fmt.Sprintf("(%d of 3, 100%%) Tj", n)); !bytes.Contains(content, want) {
+			t.Errorf("page %d stamp = %q, want it to show %q", n, content, want)
+		}
+		if !bytes.Contains(content, []byte("/F1 9.00 Tf")) || !bytes.Contains(content, []byte("0.20 0.20 0.20 rg")) {
+			t.Errorf("page %d stamp = %q, want 9 point text in #333333", n, content)
+		}
+		// The stamp is placed Margin in from the bottom right corner
+		place := []byte(fmt.Sprintf("%.5f 20.00000 cm", width-20-box[2]))
+		if page := pageContent(t, ctx, n); !bytes.Contains(page, place) {
+			t.Errorf("page %d content = %q, want the stamp placed with %q", n, page, place)
+		}
+	}
+
+	if err := doc.AddTextStamp(&TextStamp{Text: "x", Font: StandardFont, FontSize: 9, Position: "middle"}); err == nil {
+		t.Error("unknown position accepted")
+	}
+}
+
+func TestAddTextStampNoPages(t *testing.T) {
+	doc, err := Parse(buildPDF(t, testPage{600, -1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := &TextStamp{Text: "x", Font: StandardFont, FontSize: 9, Color: "#000000", Opacity: 1, Position: PositionBottomCenter, Pages: []int{}}
+	if err := doc.AddTextStamp(stamp); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := stampForm(t, testContext(t, writeDoc(t, doc)), 1); ok {
+		t.Error("page stamped for an empty page list, want none")
+	}
+}
+
+func TestAddImageStampScalesToPageWidth(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Parse(buildPDF(t, testPage{600, -1}, testPage{400, -1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.AddImageStamp(&ImageStamp{Image: img.Bytes(), Width: 0.5, Opacity: 0.3}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t, writeDoc(t, doc))
+	for n, want := range map[int][4]float64{1: {0, 0, 300, 150}, 2: {0, 0, 200, 100}} {
+		box, _, ok := stampForm(t, ctx, n)
+		if !ok {
+			t.Fatalf("page %d not stamped", n)
+		}
+		if *box != want {
+			t.Errorf("page %d stamp box = %v, want %v", n, *box, want)
+		}
+	}
+
+	if err := doc.AddImageStamp(&ImageStamp{Image: []byte("not an image"), Width: 0.5}); err == nil {
+		t.Error("image that doesn't decode accepted")
+	}
+}
@@ -0,0 +1,51 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+)
+
+// PreflightError lists the problems the pre-flight check of a profile
+// found in a document.
+type PreflightError struct {
+	Profile string
+	Issues  []pdf.Issue
+}
+
+func (e *PreflightError) Error() string {
+	lines := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		lines[i] = issue.String()
+	}
+	return fmt.Sprintf("document failed the %s pre-flight check: %s", e.Profile, strings.Join(lines, "; "))
+}
+
+// ArchiveStep prepares documents for PDF/A-2b: XMP metadata, an output
+// intent and printable annotations. Stamps are drawn in an embedded font
+// when the profile is set. The written file then goes through
+// pdf.PreflightPDFA2B, so a job fails with the problems it finds rather
+// than storing a document that plainly isn't PDF/A. Passing is no proof
+// of conformance; that takes a full validator such as veraPDF.
+type ArchiveStep struct{}
+
+func (ArchiveStep) Name() string { return "pdf/a" }
+
+func (ArchiveStep) Applies(job *Job) bool { return job.Options.Profile == model.ProfilePDFA2B }
+
+func (ArchiveStep) Apply(_ context.Context, doc *pdf.Document, job *Job) error {
+	if job.Options.Protection != nil {
+		return fmt.Errorf("%s can't be combined with protection", job.Options.Profile)
+	}
+	return doc.PreparePDFA2B()
+}
+
+func (ArchiveStep) Check(_ context.Context, out []byte, job *Job) error {
+	if issues := pdf.PreflightPDFA2B(out); len(issues) > 0 {
+		return &PreflightError{Profile: job.Options.Profile, Issues: issues}
+	}
+	return nil
+}
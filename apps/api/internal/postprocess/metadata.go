@@ -0,0 +1,54 @@
+package postprocess
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"template-builder-api/internal/pdf"
+)
+
+// MetadataStep fills the document information dictionary. pdfcpu sets
+// the modification date as it writes the file.
+type MetadataStep struct{}
+
+func (MetadataStep) Name() string { return "metadata" }
+
+func (MetadataStep) Applies(job *Job) bool { return job.Options.Metadata != nil }
+
+func (MetadataStep) Apply(_ context.Context, doc *pdf.Document, job *Job) error {
+	var data map[string]any
+	if len(job.Data) > 0 {
+		if err := json.Unmarshal(job.Data, &data); err != nil {
+			return err
+		}
+	}
+
+	m := job.Options.Metadata
+	info := map[string]string{}
+	set := func(key, value string) {
+		if v := strings.TrimSpace(expand(value, data)); v != "" {
+			info[key] = v
+		}
+	}
+	set("Title", m.Title)
+	set("Author", m.Author)
+	set("Subject", m.Subject)
+
+	var keywords []string
+	for _, k := range m.Keywords {
+		if v := strings.TrimSpace(expand(k, data)); v != "" {
+			keywords = append(keywords, v)
+		}
+	}
+	set("Keywords", strings.Join(keywords, ", "))
+	if err := doc.SetInfo(info); err != nil {
+		return err
+	}
+
+	// Viewers show the title instead of the file name when asked to.
+	if _, ok := info["Title"]; ok {
+		return doc.DisplayDocTitle()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package postprocess
+
+import (
+	"context"
+
+	"template-builder-api/internal/pdf"
+)
+
+const defaultPageNumberFormat = "Page {page} of {pages}"
+
+// PageNumberStep stamps "Page n of m" (or a custom format) on each page.
+type PageNumberStep struct{}
+
+func (PageNumberStep) Name() string { return "page numbers" }
+
+func (PageNumberStep) Applies(job *Job) bool { return job.Options.PageNumbers != nil }
+
+func (PageNumberStep) Apply(_ context.Context, doc *pdf.Document, job *Job) error {
+	pn := job.Options.PageNumbers
+	format := pn.Format
+	if format == "" {
+		format = defaultPageNumberFormat
+	}
+	position := pn.Position
+	if position == "" {
+		position = pdf.PositionBottomCenter
+	}
+	size := pn.FontSize
+	if size == 0 {
+		size = 9
+	}
+	margin := pn.Margin
+	if margin == 0 {
+		margin = 10
+	}
+
+	stamp := &pdf.TextStamp{
+		Text:     format,
+		Font:     job.fontName(),
+		FontSize: size,
+		Color:    "#333333",
+		Opacity:  1,
+		Position: position,
+		Margin:   margin * mmToPt,
+	}
+	if pn.SkipFirst {
+		stamp.Pages = []int{}
+		for n := 2; n <= doc.PageCount(); n++ {
+			stamp.Pages = append(stamp.Pages, n)
+		}
+	}
+	return doc.AddTextStamp(stamp)
+}
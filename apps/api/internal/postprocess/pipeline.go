@@ -0,0 +1,136 @@
+// Package postprocess applies the post-processing steps configured on a
+// template or generation request (metadata, watermarks, page numbers,
+// archive profiles, protection and signatures) to rendered PDFs.
+package postprocess
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+
+	"github.com/google/uuid"
+)
+
+// Assets loads the images referenced by watermarks.
+type Assets interface {
+	OpenAsset(ctx context.Context, orgID, assetID uuid.UUID) (io.ReadCloser, *model.Asset, error)
+}
+
+// Job is what the steps know about the document being processed.
+type Job struct {
+	OrgID uuid.UUID
+	// Data is the merge data the document was rendered with.
+	Data json.RawMessage
+	// Draft is set when the rendered version is not published.
+	Draft   bool
+	Options *model.PostProcessing
+}
+
+// Step is one stage of the pipeline.
+type Step interface {
+	Name() string
+	// Applies reports whether the job asks for this step at all.
+	Applies(job *Job) bool
+	Apply(ctx context.Context, doc *pdf.Document, job *Job) error
+}
+
+// Finisher is a step that changes the written document, such as signing,
+// which must cover the final bytes. Finish gets the file once every step
+// has been applied; only one finisher may apply to a job.
+type Finisher interface {
+	Step
+	Finish(ctx context.Context, out []byte, job *Job) ([]byte, error)
+}
+
+// Checker is a step that inspects the written document, such as a
+// pre-flight check, which must see the final bytes. Check runs after
+// every step and the finisher.
+type Checker interface {
+	Step
+	Check(ctx context.Context, out []byte, job *Job) error
+}
+
+// Pipeline runs steps in order over a parsed document.
+type Pipeline struct {
+	steps []Step
+}
+
+func NewPipeline(steps ...Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Default is metadata, watermark, page numbers, archive profile,
+// protection and signature. The archive profile covers everything added
+// before it; protection encrypts everything before it and the signature
+// covers the final file, so those two come last.
+func Default(assets Assets, signers Signers) *Pipeline {
+	return NewPipeline(MetadataStep{}, WatermarkStep{Assets: assets}, PageNumberStep{}, ArchiveStep{}, ProtectionStep{}, SignatureStep{Signers: signers})
+}
+
+// Applies reports whether any step applies to job. Callers can stream
+// documents it doesn't apply to rather than reading them for Run.
+func (p *Pipeline) Applies(job *Job) bool {
+	return len(p.applicable(job)) > 0
+}
+
+func (p *Pipeline) applicable(job *Job) []Step {
+	if job.Options == nil {
+		job.Options = &model.PostProcessing{}
+	}
+	var steps []Step
+	for _, s := range p.steps {
+		if s.Applies(job) {
+			steps = append(steps, s)
+		}
+	}
+	return steps
+}
+
+// Run applies every step the job asks for. Documents that need no changes
+// are returned as they are, without a parse and rewrite.
+func (p *Pipeline) Run(ctx context.Context, data []byte, job *Job) ([]byte, error) {
+	steps := p.applicable(job)
+	var finisher Finisher
+	for _, s := range steps {
+		if f, ok := s.(Finisher); ok {
+			if finisher != nil {
+				return nil, fmt.Errorf("post-processing: %s and %s both write the document", finisher.Name(), f.Name())
+			}
+			finisher = f
+		}
+	}
+	if len(steps) == 0 {
+		return data, nil
+	}
+
+	doc, err := pdf.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("post-processing: %w", err)
+	}
+	for _, s := range steps {
+		if err := s.Apply(ctx, doc, job); err != nil {
+			return nil, fmt.Errorf("post-processing %s: %w", s.Name(), err)
+		}
+	}
+	out, err := doc.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if finisher != nil {
+		if out, err = finisher.Finish(ctx, out, job); err != nil {
+			return nil, fmt.Errorf("post-processing %s: %w", finisher.Name(), err)
+		}
+	}
+	for _, s := range steps {
+		if c, ok := s.(Checker); ok {
+			if err := c.Check(ctx, out, job); err != nil {
+				return nil, fmt.Errorf("post-processing %s: %w", c.Name(), err)
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package postprocess
+
+import (
+	"context"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+)
+
+var permissionFlags = map[string]pdf.Permissions{
+	model.PermissionPrint:        pdf.PermPrint,
+	model.PermissionPrintHighRes: pdf.PermPrintHighRes,
+	model.PermissionCopy:         pdf.PermCopy,
+	model.PermissionModify:       pdf.PermModify,
+	model.PermissionAnnotate:     pdf.PermAnnotate,
+	model.PermissionFillForms:    pdf.PermFillForms,
+	model.PermissionExtract:      pdf.PermExtract,
+	model.PermissionAssemble:     pdf.PermAssemble,
+}
+
+// ProtectionStep encrypts the document with passwords and permissions.
+type ProtectionStep struct{}
+
+func (ProtectionStep) Name() string { return "protection" }
+
+func (ProtectionStep) Applies(job *Job) bool { return job.Options.Protection != nil }
+
+func (ProtectionStep) Apply(_ context.Context, doc *pdf.Document, job *Job) error {
+	p := job.Options.Protection
+	names := p.Permissions
+	if names == nil {
+		names = []string{model.PermissionPrint, model.PermissionPrintHighRes, model.PermissionExtract}
+	}
+	var perms pdf.Permissions
+	for _, n := range names {
+		perms |= permissionFlags[n]
+	}
+	return doc.Encrypt(p.UserPassword, p.OwnerPassword, perms)
+}
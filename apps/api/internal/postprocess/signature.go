@@ -0,0 +1,112 @@
+package postprocess
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+	"template-builder-api/internal/signing"
+
+	"github.com/google/uuid"
+)
+
+// Signers loads an org's signing credentials.
+type Signers interface {
+	Signer(ctx context.Context, orgID, certificateID uuid.UUID, timestamp bool) (*signing.Signer, error)
+}
+
+// SignatureStep signs the finished document. The signature has to cover
+// the final bytes, so it writes the file itself as the pipeline's finisher.
+type SignatureStep struct {
+	Signers Signers
+}
+
+func (SignatureStep) Name() string { return "signature" }
+
+func (SignatureStep) Applies(job *Job) bool { return job.Options.Signature != nil }
+
+// Apply only checks the job; signing happens in Finish.
+func (SignatureStep) Apply(_ context.Context, _ *pdf.Document, job *Job) error {
+	if job.Options.Protection != nil {
+		return errors.New("a signed document can't also be password protected")
+	}
+	return nil
+}
+
+func (s SignatureStep) Finish(ctx context.Context, out []byte, job *Job) ([]byte, error) {
+	if s.Signers == nil {
+		return nil, errors.New("signing is not configured")
+	}
+	opts := job.Options.Signature
+	signer, err := s.Signers.Signer(ctx, job.OrgID, opts.CertificateID, opts.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]any
+	if len(job.Data) > 0 {
+		if err := json.Unmarshal(job.Data, &data); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now().UTC()
+	field := &pdf.SignatureField{
+		Signer:      signerName(signer.Chain[0]),
+		Reason:      strings.TrimSpace(expand(opts.Reason, data)),
+		Location:    strings.TrimSpace(expand(opts.Location, data)),
+		ContactInfo: strings.TrimSpace(expand(opts.ContactInfo, data)),
+		Time:        now,
+	}
+
+	if a := opts.Appearance; a != nil {
+		field.Page = a.Page
+		if field.Page == 0 {
+			field.Page = -1 // the last page
+		}
+		width, height := a.Width, a.Height
+		if width == 0 {
+			width = 60
+		}
+		if height == 0 {
+			height = 20
+		}
+		x, y := a.X*mmToPt, a.Y*mmToPt
+		field.Rect = pdf.Rect{LLX: x, LLY: y, URX: x + width*mmToPt, URY: y + height*mmToPt}
+
+		text := a.Text
+		if text == "" {
+			text = "Digitally signed by {signer}\nDate: {date}"
+			if field.Reason != "" {
+				text += "\nReason: {reason}"
+			}
+		}
+		field.Text = strings.NewReplacer(
+			"{signer}", field.Signer,
+			"{date}", now.Format("2006-01-02 15:04:05 MST"),
+			"{reason}", field.Reason,
+			"{location}", field.Location,
+		).Replace(expand(text, data))
+		field.FontSize = a.FontSize
+		field.Font = job.fontName()
+	}
+	if job.Options.Profile == model.ProfilePDFA2B {
+		// The signature is a string, which PDF/A limits in length
+		field.Size = pdf.MaxStringPDFA
+	}
+
+	return pdf.Sign(out, field, func(signed []byte) ([]byte, error) {
+		return signer.Sign(ctx, signed)
+	})
+}
+
+func signerName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	return cert.Subject.String()
+}
@@ -0,0 +1,49 @@
+package postprocess
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+)
+
+// fontName is the pdf font stamps and signature appearances are drawn in:
+// the standard Helvetica font, which every viewer has, so nothing needs
+// embedding, or for archival output, which forbids fonts that aren't
+// embedded, an embedded copy of Go Regular.
+func (j *Job) fontName() string {
+	if j.Options.Profile == model.ProfilePDFA2B {
+		return pdf.EmbeddedFont
+	}
+	return pdf.StandardFont
+}
+
+// color returns a validated #rrggbb or rrggbb colour as pdfcpu takes it.
+func color(s string) string {
+	return "#" + strings.TrimPrefix(s, "#")
+}
+
+const mmToPt = 72 / 25.4
+
+var placeholder = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// expand replaces {{ path }} placeholders from merge data; missing values
+// become empty.
+func expand(s string, data map[string]any) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		var cur any = data
+		for _, part := range strings.Split(placeholder.FindStringSubmatch(m)[1], ".") {
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return ""
+			}
+			cur = obj[part]
+		}
+		if cur == nil {
+			return ""
+		}
+		return fmt.Sprint(cur)
+	})
+}
@@ -0,0 +1,94 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+)
+
+// Watermark images larger than this are refused rather than embedded.
+const maxWatermarkImageBytes = 10 << 20
+
+// WatermarkStep draws a text or image watermark across the middle of every
+// page, or "DRAFT" for unpublished versions when the template asks for it.
+type WatermarkStep struct {
+	Assets Assets
+}
+
+func (WatermarkStep) Name() string { return "watermark" }
+
+func (WatermarkStep) Applies(job *Job) bool {
+	return job.Options.Watermark != nil || (job.Options.DraftWatermark && job.Draft)
+}
+
+func (s WatermarkStep) Apply(ctx context.Context, doc *pdf.Document, job *Job) error {
+	wm := job.Options.Watermark
+	if wm == nil {
+		wm = &model.Watermark{Text: "DRAFT"}
+	}
+
+	opacity := wm.Opacity
+	if opacity == 0 {
+		opacity = 0.15
+	}
+	if wm.ImageAssetID != nil {
+		return s.applyImage(ctx, doc, job, wm, opacity)
+	}
+
+	size := wm.FontSize
+	if size == 0 {
+		size = 72
+	}
+	rotation := 45.0
+	if wm.Rotation != nil {
+		rotation = *wm.Rotation
+	}
+	c := wm.Color
+	if c == "" {
+		c = "#808080"
+	}
+	return doc.AddTextStamp(&pdf.TextStamp{
+		Text:     wm.Text,
+		Font:     job.fontName(),
+		FontSize: size,
+		Color:    color(c),
+		Opacity:  opacity,
+		Rotation: rotation,
+		Position: pdf.PositionCenter,
+	})
+}
+
+func (s WatermarkStep) applyImage(ctx context.Context, doc *pdf.Document, job *Job, wm *model.Watermark, opacity float64) error {
+	if s.Assets == nil {
+		return fmt.Errorf("image watermarks are not available")
+	}
+	obj, asset, err := s.Assets.OpenAsset(ctx, job.OrgID, *wm.ImageAssetID)
+	if err != nil {
+		return fmt.Errorf("load watermark image: %w", err)
+	}
+	defer obj.Close()
+	if !strings.HasPrefix(asset.ContentType, "image/") {
+		return fmt.Errorf("watermark asset is %s, not an image", asset.ContentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(obj, maxWatermarkImageBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxWatermarkImageBytes {
+		return fmt.Errorf("watermark image is larger than %d bytes", maxWatermarkImageBytes)
+	}
+
+	scale := wm.Scale
+	if scale == 0 {
+		scale = 0.5
+	}
+	var rotation float64
+	if wm.Rotation != nil {
+		rotation = *wm.Rotation
+	}
+	return doc.AddImageStamp(&pdf.ImageStamp{Image: data, Width: scale, Opacity: opacity, Rotation: rotation})
+}
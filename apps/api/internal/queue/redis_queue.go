@@ -3,23 +3,68 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
+	"template-builder-api/internal/model"
+
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// Priority classes. Interactive work (sync renders) is picked most often,
+// batches least, but every class keeps being served.
+const (
+	PriorityInteractive = "interactive"
+	PriorityDefault     = "default"
+	PriorityBatch       = "batch"
+)
+
+// priorityCycle is the weighted round-robin order consumers visit the
+// classes in: 4 interactive, 2 default and 1 batch pick per cycle.
+var priorityCycle = []string{
+	PriorityInteractive, PriorityDefault, PriorityInteractive, PriorityBatch,
+	PriorityInteractive, PriorityDefault, PriorityInteractive,
+}
+
+const (
+	// legacyStream is the single stream jobs were queued on before per-org
+	// streams; consumers still drain it.
+	legacyStream = "generation_jobs"
+	wakeKey      = "generation_jobs:wake"
+	// A consumer that finds no work waits this long for a wake-up.
+	idleWait = time.Second
+	// Concurrency slots are leases so a crashed worker's slots free up.
+	// Consumers renew them while a job runs.
+	slotLease  = 15 * time.Minute
+	renewEvery = slotLease / 3
+	// A delivered message idle this long belongs to a consumer that died
+	// (running jobs are kept fresh), and is claimed by another.
+	claimIdle = slotLease
+	// deadLetterStream keeps the last deadLetterLen failed jobs with their
+	// error, for inspection; they are not retried.
+	deadLetterStream = legacyStream + ":dead"
+	deadLetterLen    = 10000
+)
+
 type JobPayload struct {
-	JobID      uuid.UUID       `json:"jobId"`
-	OrgID      uuid.UUID       `json:"orgId"`
-	TemplateID uuid.UUID       `json:"templateId"`
-	Data       json.RawMessage `json:"data"`
+	JobID      uuid.UUID            `json:"jobId"`
+	OrgID      uuid.UUID            `json:"orgId"`
+	TemplateID uuid.UUID            `json:"templateId"`
+	Version    int                  `json:"version,omitempty"`
+	BatchID    *uuid.UUID           `json:"batchId,omitempty"`
+	Priority   string               `json:"priority,omitempty"`
+	Output     *model.OutputOptions `json:"output,omitempty"`
+	Data       json.RawMessage      `json:"data"`
 }
 
 type Queue struct {
 	client *redis.Client
-	stream string
+	prefix string
 }
 
 func NewQueue(redisAddr string, password string) *Queue {
@@ -31,62 +76,372 @@ func NewQueue(redisAddr string, password string) *Queue {
 
 	return &Queue{
 		client: rdb,
-		stream: "generation_jobs",
+		prefix: legacyStream,
 	}
 }
 
+// Each org has one stream per priority class; a set per class lists the
+// orgs whose streams may hold work.
+func (q *Queue) streamKey(priority string, orgID uuid.UUID) string {
+	return q.prefix + ":" + priority + ":" + orgID.String()
+}
+
+func (q *Queue) orgsKey(priority string) string {
+	return q.prefix + ":" + priority + ":orgs"
+}
+
+func (q *Queue) slotsKey(orgID uuid.UUID) string {
+	return q.prefix + ":inflight:" + orgID.String()
+}
+
 func (q *Queue) EnqueueJob(ctx context.Context, job JobPayload) error {
+	if job.Priority == "" {
+		job.Priority = PriorityDefault
+	}
+	if !slices.Contains(priorityCycle, job.Priority) {
+		return fmt.Errorf("unknown priority %q", job.Priority)
+	}
 	bytes, err := json.Marshal(job)
 	if err != nil {
 		return err
 	}
 
-	return q.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: q.stream,
+	pipe := q.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.streamKey(job.Priority, job.OrgID),
 		Values: map[string]interface{}{
 			"payload": bytes,
 		},
-	}).Err()
+	})
+	pipe.SAdd(ctx, q.orgsKey(job.Priority), job.OrgID.String())
+	pipe.RPush(ctx, wakeKey, 1)
+	pipe.LTrim(ctx, wakeKey, 0, 99)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ConsumeOptions tunes fairness for Consume.
+type ConsumeOptions struct {
+	// OrgConcurrency returns how many of an org's jobs may run at once
+	// across all consumers. Values < 1 mean unlimited.
+	OrgConcurrency func(orgID uuid.UUID) int
+}
+
+// acquireSlot takes a concurrency lease for an org if one is free. Expired
+// leases of dead consumers are dropped first.
+var acquireSlot = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if tonumber(ARGV[3]) > 0 and redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+// forgetIdleOrg removes an org from a class's set and deletes its stream
+// once the stream is empty, returning 1 if it did. Acked messages are
+// deleted, so an empty stream has nothing pending either. Done in a script
+// so an XADD racing with the check can't be lost.
+var forgetIdleOrg = redis.NewScript(`
+if redis.call('XLEN', KEYS[1]) == 0 then
+	redis.call('SREM', KEYS[2], ARGV[1])
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+return 0
+`)
+
+// slot is a lease on one of an org's concurrency slots.
+type slot struct {
+	key   string
+	lease string
+}
+
+type consumer struct {
+	q        *Queue
+	group    string
+	name     string
+	opts     ConsumeOptions
+	groups   map[string]bool
+	cursors  map[string]int
+	nextSlot int
 }
 
 // For Worker
-func (q *Queue) Consume(ctx context.Context, group string, consumer string, handler func(JobPayload) error) {
-	// Create group if not exists
-	q.client.XGroupCreateMkStream(ctx, q.stream, group, "0")
-
-	for {
-		streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-			Group:    group,
-			Consumer: consumer,
-			Streams:  []string{q.stream, ">"},
-			Count:    1,
-			Block:    2 * time.Second,
-		}).Result()
+//
+// Consume runs handler for one job at a time. Classes are visited in
+// weighted round-robin order, and orgs within a class in round-robin order,
+// so one org's large batch cannot starve everyone else. An org already at
+// its concurrency limit is skipped until one of its jobs finishes.
+//
+// consumerName must be unique to the process: messages delivered to a
+// consumer that stops renewing them are claimed by the others.
+func (q *Queue) Consume(ctx context.Context, group string, consumerName string, opts ConsumeOptions, handler func(JobPayload) error) {
+	c := &consumer{
+		q:       q,
+		group:   group,
+		name:    consumerName,
+		opts:    opts,
+		groups:  map[string]bool{},
+		cursors: map[string]int{},
+	}
 
+	for ctx.Err() == nil {
+		msg, stream, s, err := c.next(ctx)
 		if err != nil {
-			if err != redis.Nil {
-				fmt.Printf("Redis Read Error: %v\n", err)
-			}
+			log.Printf("Queue: read failed: %v", err)
+			time.Sleep(idleWait)
+			continue
+		}
+		if msg == nil {
+			// Nothing runnable; wait for an enqueue or poll again shortly.
+			q.client.BLPop(ctx, idleWait, wakeKey)
 			continue
 		}
 
-		for _, stream := range streams {
-			for _, message := range stream.Messages {
-				payloadStr := message.Values["payload"].(string)
-				var job JobPayload
-				if err := json.Unmarshal([]byte(payloadStr), &job); err != nil {
-					fmt.Println("Invalid payload", err)
-					q.client.XAck(ctx, q.stream, group, message.ID)
-					continue
-				}
+		stop := c.keepAlive(ctx, stream, msg.ID, s)
+		c.handle(ctx, stream, msg, handler)
+		stop()
+		c.release(ctx, s)
+	}
+}
+
+// handle runs a message's job. Messages are deleted once acked so that an
+// empty stream means an idle org. A job that fails is recorded as failed by
+// the handler and is not redelivered: it is moved to deadLetterStream
+// instead.
+func (c *consumer) handle(ctx context.Context, stream string, message *redis.XMessage, handler func(JobPayload) error) {
+	payloadStr, _ := message.Values["payload"].(string)
+	var job JobPayload
+	err := json.Unmarshal([]byte(payloadStr), &job)
+	if err != nil {
+		log.Printf("Queue: invalid payload in %s: %v", message.ID, err)
+	} else if err = handler(job); err != nil {
+		log.Printf("Job Failed: %s: %v", job.JobID, err)
+	}
+
+	// The outcome is recorded even if the consumer is stopping
+	ctx = context.WithoutCancel(ctx)
+	pipe := c.q.client.TxPipeline()
+	if err != nil {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: deadLetterStream,
+			MaxLen: deadLetterLen,
+			Approx: true,
+			Values: map[string]interface{}{
+				"payload":   payloadStr,
+				"error":     err.Error(),
+				"stream":    stream,
+				"id":        message.ID,
+				"consumer":  c.name,
+				"failed_at": time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+	}
+	pipe.XAck(ctx, stream, c.group, message.ID)
+	pipe.XDel(ctx, stream, message.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Queue: failed to ack %s on %s: %v", message.ID, stream, err)
+	}
+}
 
-				if err := handler(job); err == nil {
-					q.client.XAck(ctx, q.stream, group, message.ID)
-				} else {
-					fmt.Printf("Job Failed: %v\n", err)
-					// Handle DLQ or retry later
+// keepAlive renews the job's concurrency slot and resets its message's idle
+// time until stop is called, so a long job keeps its slot and isn't claimed
+// by another consumer.
+func (c *consumer) keepAlive(ctx context.Context, stream, id string, s *slot) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(renewEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if s != nil {
+				pipe := c.q.client.Pipeline()
+				// ZADD rather than XX: a lease that lapsed is taken back
+				pipe.ZAdd(ctx, s.key, redis.Z{Score: float64(time.Now().Add(slotLease).UnixMilli()), Member: s.lease})
+				pipe.PExpire(ctx, s.key, slotLease)
+				if _, err := pipe.Exec(ctx); err != nil {
+					log.Printf("Queue: failed to renew slot %s: %v", s.key, err)
 				}
 			}
+			err := c.q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+				Stream: stream, Group: c.group, Consumer: c.name, Messages: []string{id},
+			}).Err()
+			if err != nil {
+				log.Printf("Queue: failed to renew %s on %s: %v", id, stream, err)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// next finds the next runnable message, and the org's concurrency slot it
+// took, which must be released once the job is done. Jobs on the legacy
+// stream take no slot.
+func (c *consumer) next(ctx context.Context) (*redis.XMessage, string, *slot, error) {
+	// Start at the next slot of the cycle, then fall through the others so
+	// an idle class never leaves the consumer waiting.
+	for i := range priorityCycle {
+		priority := priorityCycle[(c.nextSlot+i)%len(priorityCycle)]
+		msg, stream, s, err := c.fromClass(ctx, priority)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if msg != nil {
+			c.nextSlot = (c.nextSlot + i + 1) % len(priorityCycle)
+			return msg, stream, s, nil
+		}
+	}
+
+	msg, err := c.read(ctx, legacyStream)
+	if err != nil || msg == nil {
+		return nil, "", nil, err
+	}
+	return msg, legacyStream, nil, nil
+}
+
+// fromClass takes one message from the next org in the class that has work
+// and a free concurrency slot.
+func (c *consumer) fromClass(ctx context.Context, priority string) (*redis.XMessage, string, *slot, error) {
+	client := c.q.client
+	orgs, err := client.SMembers(ctx, c.q.orgsKey(priority)).Result()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(orgs) == 0 {
+		return nil, "", nil, nil
+	}
+	slices.Sort(orgs)
+
+	start := c.cursors[priority]
+	for i := range orgs {
+		idx := (start + i) % len(orgs)
+		orgID, err := uuid.Parse(orgs[idx])
+		if err != nil {
+			client.SRem(ctx, c.q.orgsKey(priority), orgs[idx])
+			continue
+		}
+
+		s, err := c.acquire(ctx, orgID)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if s == nil {
+			continue
+		}
+
+		stream := c.q.streamKey(priority, orgID)
+		msg, err := c.read(ctx, stream)
+		if err != nil {
+			c.release(ctx, s)
+			return nil, "", nil, err
+		}
+		if msg == nil {
+			c.release(ctx, s)
+			if gone, _ := forgetIdleOrg.Run(ctx, client, []string{stream, c.q.orgsKey(priority)}, orgs[idx]).Int(); gone == 1 {
+				delete(c.groups, stream)
+			}
+			continue
+		}
+
+		c.cursors[priority] = idx + 1
+		return msg, stream, s, nil
+	}
+	return nil, "", nil, nil
+}
+
+// acquire takes one of the org's concurrency slots, or returns nil if
+// they are all taken.
+func (c *consumer) acquire(ctx context.Context, orgID uuid.UUID) (*slot, error) {
+	limit := 0
+	if c.opts.OrgConcurrency != nil {
+		limit = c.opts.OrgConcurrency(orgID)
+	}
+
+	s := &slot{key: c.q.slotsKey(orgID), lease: c.name + ":" + uuid.NewString()}
+	now := time.Now()
+	ok, err := acquireSlot.Run(ctx, c.q.client, []string{s.key},
+		now.UnixMilli(), now.Add(slotLease).UnixMilli(), limit, s.lease, slotLease.Milliseconds()).Int()
+	if err != nil {
+		return nil, err
+	}
+	if ok == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (c *consumer) release(ctx context.Context, s *slot) {
+	if s != nil {
+		c.q.client.ZRem(context.WithoutCancel(ctx), s.key, s.lease)
+	}
+}
+
+// read returns, without blocking, a message of the stream abandoned by a
+// consumer that died, or else its next undelivered message for the group,
+// or nil.
+func (c *consumer) read(ctx context.Context, stream string) (*redis.XMessage, error) {
+	if !c.groups[stream] {
+		err := c.q.client.XGroupCreateMkStream(ctx, stream, c.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, err
+		}
+		c.groups[stream] = true
+	}
+
+	claimed, _, err := c.q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    c.group,
+		Consumer: c.name,
+		MinIdle:  claimIdle,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		delete(c.groups, stream)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		return &claimed[0], nil
+	}
+
+	streams, err := c.q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.name,
+		Streams:  []string{stream, ">"},
+		Count:    1,
+		Block:    -1,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		// The stream was removed since we created the group; recreate next time.
+		delete(c.groups, stream)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range streams {
+		if len(s.Messages) > 0 {
+			return &s.Messages[0], nil
 		}
 	}
+	return nil, nil
 }
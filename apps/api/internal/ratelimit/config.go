@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultPlan is the rule key used for unauthenticated callers and for
+// plans without a rule of their own.
+const DefaultPlan = "default"
+
+// Config maps a route group to its rules per plan ID.
+type Config map[string]map[string]Rule
+
+// Rule picks the group's rule for plan, falling back to DefaultPlan.
+func (c Config) Rule(group, plan string) (Rule, bool) {
+	rules := c[group]
+	if r, ok := rules[plan]; ok {
+		return r, true
+	}
+	r, ok := rules[DefaultPlan]
+	return r, ok
+}
+
+// DefaultConfig limits login and registration per IP, general API use per
+// user, and document generation more tightly.
+func DefaultConfig() Config {
+	return Config{
+		"auth": {
+			DefaultPlan: {Limit: 20, Period: time.Minute},
+		},
+		"api": {
+			DefaultPlan:  {Limit: 120, Period: time.Minute},
+			"pro":        {Limit: 600, Period: time.Minute},
+			"enterprise": {Limit: 3000, Period: time.Minute},
+		},
+		"generate": {
+			DefaultPlan:  {Limit: 10, Period: time.Minute},
+			"pro":        {Limit: 120, Period: time.Minute},
+			"enterprise": {Limit: 600, Period: time.Minute},
+		},
+	}
+}
+
+// ParseConfig overlays JSON such as
+//
+//	{"generate": {"default": {"limit": 5, "period": "1m"}}}
+//
+// onto base; each group given replaces the base group entirely.
+func ParseConfig(base Config, data []byte) (Config, error) {
+	var overlay Config
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return nil, err
+	}
+	out := Config{}
+	for group, rules := range base {
+		out[group] = rules
+	}
+	for group, rules := range overlay {
+		for plan, r := range rules {
+			if r.Limit < 1 || r.Period <= 0 {
+				return nil, fmt.Errorf("rule %s/%s needs a positive limit and period", group, plan)
+			}
+		}
+		out[group] = rules
+	}
+	return out, nil
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Limit  int    `json:"limit"`
+		Period string `json:"period"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	period, err := time.ParseDuration(raw.Period)
+	if err != nil {
+		return fmt.Errorf("invalid period %q: %w", raw.Period, err)
+	}
+	r.Limit, r.Period = raw.Limit, period
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package ratelimit implements Redis-backed request rate limits and login
+// lockout, shared by every API instance.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rule allows Limit requests per Period, spread evenly; bursts of up to
+// Limit are allowed after a quiet period.
+type Rule struct {
+	Limit  int           `json:"limit"`
+	Period time.Duration `json:"period"`
+}
+
+// Policy renders the rule for the RateLimit-Policy header.
+func (r Rule) Policy() string {
+	return fmt.Sprintf("%d;w=%d", r.Limit, int(r.Period.Seconds()))
+}
+
+// Result is the outcome of one Allow call.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when Allowed.
+	RetryAfter time.Duration
+}
+
+// gcra is the generic cell rate algorithm: the key stores the theoretical
+// arrival time (TAT) of the next request, and a request is allowed if
+// pushing the TAT forward by one emission interval keeps it within one
+// period of now. It behaves like a token bucket with a single key.
+var gcra = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+
+if new_tat - now > period then
+	return {0, 0, tat - now, new_tat - period - now}
+end
+
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil(new_tat - now))
+return {1, math.floor((period - (new_tat - now)) / interval), new_tat - now, 0}
+`)
+
+type Limiter struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewLimiter(client *redis.Client) *Limiter {
+	return &Limiter{client: client, prefix: "ratelimit:"}
+}
+
+// Allow counts one request against key under rule.
+func (l *Limiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	period := rule.Period.Milliseconds()
+	interval := period / int64(rule.Limit)
+	if interval < 1 {
+		interval = 1
+	}
+
+	res, err := gcra.Run(ctx, l.client, []string{l.prefix + key}, time.Now().UnixMilli(), interval, period).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      rule.Limit,
+		Remaining:  int(res[1]),
+		Reset:      time.Duration(res[2]) * time.Millisecond,
+		RetryAfter: time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Lockout blocks logins to an account after repeated failures, no matter
+// which IP they come from.
+type Lockout struct {
+	client *redis.Client
+	// MaxFailures within Window locks the account for Duration.
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+}
+
+func NewLockout(client *redis.Client) *Lockout {
+	return &Lockout{client: client, MaxFailures: 5, Window: 15 * time.Minute, Duration: 15 * time.Minute}
+}
+
+// registerFailure counts a failure and converts the count into a lock once
+// it reaches the maximum.
+var registerFailure = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if n >= tonumber(ARGV[2]) then
+	redis.call('SET', KEYS[2], 1, 'PX', ARGV[3])
+	redis.call('DEL', KEYS[1])
+end
+return n
+`)
+
+// keys are derived from a hash so email addresses don't sit in Redis.
+func (l *Lockout) keys(account string) (failures, locked string) {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(account))))
+	id := hex.EncodeToString(sum[:16])
+	return "login:failures:" + id, "login:locked:" + id
+}
+
+// Locked returns how long the account remains locked, or zero.
+func (l *Lockout) Locked(ctx context.Context, account string) (time.Duration, error) {
+	_, locked := l.keys(account)
+	ttl, err := l.client.PTTL(ctx, locked).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+func (l *Lockout) Fail(ctx context.Context, account string) error {
+	failures, locked := l.keys(account)
+	return registerFailure.Run(ctx, l.client, []string{failures, locked},
+		l.Window.Milliseconds(), l.MaxFailures, l.Duration.Milliseconds()).Err()
+}
+
+// Succeed clears the failure count after a good login.
+func (l *Lockout) Succeed(ctx context.Context, account string) error {
+	failures, _ := l.keys(account)
+	return l.client.Del(ctx, failures).Err()
+}
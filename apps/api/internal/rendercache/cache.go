@@ -0,0 +1,287 @@
+// Package rendercache keeps rendered documents under a hash of everything
+// that determines them, so repeated renders skip the renderer. Small
+// documents are stored in Redis; larger ones in object storage, with a
+// Redis entry pointing at them.
+package rendercache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"template-builder-api/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type Options struct {
+	// MaxInline is the largest document kept in Redis itself.
+	MaxInline int
+	// MaxSize is the largest document cached at all. Renders are buffered
+	// up to this size on their way through Tee.
+	MaxSize int
+	// TTL applies to renders of published versions, which never change.
+	// DraftTTL applies to drafts, whose entries are also dropped when the
+	// draft is edited.
+	TTL      time.Duration
+	DraftTTL time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxInline: 512 << 10,
+		MaxSize:   16 << 20,
+		TTL:       24 * time.Hour,
+		DraftTTL:  15 * time.Minute,
+	}
+}
+
+// Entry is a cached document. The caller must close Body.
+type Entry struct {
+	ContentType string
+	Body        io.ReadCloser
+}
+
+// Version identifies the template version a render came from, so its
+// entries can be found again when the version changes.
+type Version struct {
+	OrgID      uuid.UUID
+	TemplateID uuid.UUID
+	Version    int
+	Draft      bool
+}
+
+// Bucket is the bucket for documents larger than MaxInline.
+const Bucket = "render-cache"
+
+// Cache is safe for concurrent use. A nil *Cache caches nothing.
+type Cache struct {
+	rdb     *redis.Client
+	objects storage.Blob
+	opts    Options
+	prefix  string
+}
+
+// New returns a cache in rdb, with large documents in objects. Without
+// objects, only documents up to MaxInline are cached.
+func New(rdb *redis.Client, objects storage.Blob, opts Options) *Cache {
+	return &Cache{rdb: rdb, objects: objects, opts: opts, prefix: "rendercache:"}
+}
+
+// Key hashes the inputs of a render. Inputs are hashed as JSON, which
+// sorts map keys; json.RawMessage inputs are decoded and re-encoded first
+// so that formatting and key order don't matter.
+func Key(inputs ...any) (string, error) {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, in := range inputs {
+		if raw, ok := in.(json.RawMessage); ok && len(raw) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return "", fmt.Errorf("rendercache: %w", err)
+			}
+			in = v
+		}
+		if err := enc.Encode(in); err != nil {
+			return "", fmt.Errorf("rendercache: %w", err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// EnsureBucket creates the bucket for large documents.
+func (c *Cache) EnsureBucket(ctx context.Context) error {
+	if c == nil || c.objects == nil {
+		return nil
+	}
+	return storage.Ensure(ctx, c.objects)
+}
+
+// Sweep deletes large documents older than the longest TTL, whose Redis
+// entries have expired without them being deleted.
+func (c *Cache) Sweep(ctx context.Context) (int, error) {
+	if c == nil || c.objects == nil {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-max(c.opts.TTL, c.opts.DraftTTL))
+	var stale []string
+	err := c.objects.List(ctx, "", func(o storage.ObjectInfo) error {
+		if o.LastModified.Before(cutoff) {
+			stale = append(stale, o.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i, key := range stale {
+		if err := c.objects.Delete(ctx, key); err != nil {
+			return i, err
+		}
+	}
+	return len(stale), nil
+}
+
+// Get returns the document cached under key, or nil if there is none.
+// Large documents are streamed from object storage.
+func (c *Cache) Get(ctx context.Context, key string) (*Entry, error) {
+	if c == nil {
+		return nil, nil
+	}
+	fields, err := c.rdb.HGetAll(ctx, c.entryKey(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	entry := &Entry{ContentType: fields["ct"]}
+	object, ok := fields["object"]
+	if !ok {
+		entry.Body = io.NopCloser(strings.NewReader(fields["data"]))
+		return entry, nil
+	}
+	if c.objects == nil {
+		return nil, nil
+	}
+	obj, _, err := c.objects.Get(ctx, object)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Swept; drop the pointer too.
+		c.rdb.Del(ctx, c.entryKey(key))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry.Body = obj
+	return entry, nil
+}
+
+// Put caches a document rendered from v under key. Documents larger than
+// MaxSize are not cached.
+func (c *Cache) Put(ctx context.Context, key string, v Version, contentType string, data []byte) error {
+	if c == nil || len(data) > c.opts.MaxSize {
+		return nil
+	}
+	ttl := c.opts.TTL
+	if v.Draft {
+		ttl = c.opts.DraftTTL
+	}
+
+	fields := map[string]any{"ct": contentType}
+	if len(data) <= c.opts.MaxInline {
+		fields["data"] = data
+	} else if c.objects != nil {
+		if _, err := c.objects.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+			return fmt.Errorf("rendercache: failed to store document: %w", err)
+		}
+		fields["object"] = key
+	} else {
+		return nil
+	}
+
+	index := c.versionKey(v)
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, c.entryKey(key), fields)
+		pipe.Expire(ctx, c.entryKey(key), ttl)
+		pipe.SAdd(ctx, index, key)
+		pipe.Expire(ctx, index, max(c.opts.TTL, c.opts.DraftTTL))
+		return nil
+	})
+	return err
+}
+
+// Tee passes body through, caching the document under key once it has been
+// read to the end. Documents over MaxSize pass through uncached.
+func (c *Cache) Tee(ctx context.Context, key string, v Version, contentType string, body io.ReadCloser) io.ReadCloser {
+	if c == nil {
+		return body
+	}
+	return &teeBody{ReadCloser: body, ctx: ctx, cache: c, key: key, version: v, contentType: contentType}
+}
+
+type teeBody struct {
+	io.ReadCloser
+	ctx         context.Context
+	cache       *Cache
+	key         string
+	version     Version
+	contentType string
+
+	buf bytes.Buffer
+	// skip is set once the document is known to be too large, or stored.
+	skip bool
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if t.skip {
+		return n, err
+	}
+	if t.buf.Len()+n > t.cache.opts.MaxSize {
+		t.skip = true
+		t.buf = bytes.Buffer{}
+		return n, err
+	}
+	t.buf.Write(p[:n])
+	if err == io.EOF {
+		t.skip = true
+		if err := t.cache.Put(t.ctx, t.key, t.version, t.contentType, t.buf.Bytes()); err != nil {
+			log.Printf("Render cache store failed: %v", err)
+		}
+		t.buf = bytes.Buffer{}
+	}
+	return n, err
+}
+
+// InvalidateVersion drops every document cached for v, e.g. when a draft
+// is edited.
+func (c *Cache) InvalidateVersion(ctx context.Context, v Version) error {
+	if c == nil {
+		return nil
+	}
+	index := c.versionKey(v)
+	keys, err := c.rdb.SMembers(ctx, index).Result()
+	if err != nil {
+		return err
+	}
+
+	del := []string{index}
+	for _, key := range keys {
+		del = append(del, c.entryKey(key))
+		if c.objects == nil {
+			continue
+		}
+		object, err := c.rdb.HGet(ctx, c.entryKey(key), "object").Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.objects.Delete(ctx, object); err != nil {
+			return fmt.Errorf("rendercache: failed to remove document: %w", err)
+		}
+	}
+	return c.rdb.Del(ctx, del...).Err()
+}
+
+func (c *Cache) entryKey(key string) string {
+	return c.prefix + "doc:" + key
+}
+
+func (c *Cache) versionKey(v Version) string {
+	return fmt.Sprintf("%sversion:%s:%s:%d", c.prefix, v.OrgID, v.TemplateID, v.Version)
+}
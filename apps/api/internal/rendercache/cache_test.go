@@ -0,0 +1,309 @@
+package rendercache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"template-builder-api/internal/storage"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestKey(t *testing.T) {
+	templateID := uuid.MustParse("11111111-2222-3333-4444-555555555555")
+	key := func(inputs ...any) string {
+		t.Helper()
+		k, err := Key(inputs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	same := []struct {
+		name string
+		a, b []any
+	}{
+		{"formatting",
+			[]any{templateID, 1, json.RawMessage(`{"a":1,"b":[1,2]}`)},
+			[]any{templateID, 1, json.RawMessage("{\n  \"a\": 1,\n  \"b\": [ 1, 2 ]\n}")}},
+		{"key order",
+			[]any{json.RawMessage(`{"a":1,"b":{"x":true,"y":null}}`)},
+			[]any{json.RawMessage(`{"b":{"y":null,"x":true},"a":1}`)}},
+		{"maps", []any{map[string]int{"a": 1, "b": 2}}, []any{map[string]int{"b": 2, "a": 1}}},
+		{"escapes", []any{json.RawMessage(`{"name":"é"}`)}, []any{json.RawMessage(`{"name":"\u00e9"}`)}},
+	}
+	for _, tt := range same {
+		t.Run(tt.name, func(t *testing.T) {
+			if a, b := key(tt.a...), key(tt.b...); a != b {
+				t.Errorf("keys differ: %s, %s", a, b)
+			}
+		})
+	}
+
+	different := []struct {
+		name string
+		a, b []any
+	}{
+		{"values", []any{json.RawMessage(`{"a":1}`)}, []any{json.RawMessage(`{"a":2}`)}},
+		{"version", []any{templateID, 1}, []any{templateID, 2}},
+		{"template", []any{templateID, 1}, []any{uuid.New(), 1}},
+		{"array order", []any{json.RawMessage(`[1,2]`)}, []any{json.RawMessage(`[2,1]`)}},
+		// Decoding as float64 would round both to the same value
+		{"large numbers", []any{json.RawMessage(`{"id":12345678901234567890}`)}, []any{json.RawMessage(`{"id":12345678901234567891}`)}},
+		{"input boundaries", []any{"ab", "c"}, []any{"a", "bc"}},
+		{"input count", []any{"a"}, []any{"a", nil}},
+	}
+	for _, tt := range different {
+		t.Run(tt.name, func(t *testing.T) {
+			if a, b := key(tt.a...), key(tt.b...); a == b {
+				t.Errorf("keys are equal: %s", a)
+			}
+		})
+	}
+
+	if _, err := Key(json.RawMessage(`{"a":`)); err == nil {
+		t.Error("Key() accepted invalid JSON")
+	}
+}
+
+type testCache struct {
+	*Cache
+	redis   *miniredis.Miniredis
+	objects *storage.Memory
+}
+
+func newTestCache(t *testing.T, opts Options) *testCache {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	objects := storage.NewMemory(Bucket, nil)
+	return &testCache{Cache: New(rdb, objects, opts), redis: mr, objects: objects}
+}
+
+// read returns the document cached under key, or nil.
+func (c *testCache) read(t *testing.T, key string) []byte {
+	t.Helper()
+	entry, err := c.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil {
+		return nil
+	}
+	defer entry.Body.Close()
+	data, err := io.ReadAll(entry.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// trackedBody is a render response body that records being closed.
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// smallReads hands out at most 7 bytes per Read, so documents take several.
+type smallReads struct{ r io.Reader }
+
+func (s smallReads) Read(p []byte) (int, error) {
+	if len(p) > 7 {
+		p = p[:7]
+	}
+	return s.r.Read(p)
+}
+
+func TestTee(t *testing.T) {
+	ctx := context.Background()
+	v := Version{OrgID: uuid.New(), TemplateID: uuid.New(), Version: 1}
+	c := newTestCache(t, Options{MaxInline: 16, MaxSize: 64, TTL: time.Hour, DraftTTL: time.Minute})
+
+	tests := []struct {
+		name   string
+		size   int
+		cached bool
+	}{
+		{"inline", 10, true},
+		{"object", 40, true},
+		{"at MaxSize", 64, true},
+		{"over MaxSize", 65, false},
+		{"far over MaxSize", 1000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := bytes.Repeat([]byte("x"), tt.size)
+			key := "tee-" + tt.name
+			body := &trackedBody{Reader: smallReads{bytes.NewReader(doc)}}
+			r := c.Tee(ctx, key, v, "application/pdf", body)
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, doc) {
+				t.Fatalf("passed through %d bytes, want %d", len(got), len(doc))
+			}
+			r.Close()
+			if !body.closed {
+				t.Error("Close() didn't close the render body")
+			}
+
+			cached := c.read(t, key)
+			if tt.cached && !bytes.Equal(cached, doc) {
+				t.Errorf("cached %d bytes, want %d", len(cached), len(doc))
+			}
+			if !tt.cached && cached != nil {
+				t.Errorf("cached %d bytes, want nothing", len(cached))
+			}
+		})
+	}
+
+	t.Run("stops buffering past MaxSize", func(t *testing.T) {
+		r := c.Tee(ctx, "buffer", v, "application/pdf", io.NopCloser(bytes.NewReader(make([]byte, 1000))))
+		if _, err := io.CopyN(io.Discard, r, 100); err != nil {
+			t.Fatal(err)
+		}
+		if tee := r.(*teeBody); !tee.skip || tee.buf.Len() != 0 {
+			t.Errorf("still buffering %d bytes after MaxSize", tee.buf.Len())
+		}
+	})
+
+	t.Run("closed early", func(t *testing.T) {
+		r := c.Tee(ctx, "partial", v, "application/pdf", io.NopCloser(strings.NewReader("hello world")))
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		if cached := c.read(t, "partial"); cached != nil {
+			t.Errorf("a partly read document was cached: %q", cached)
+		}
+	})
+}
+
+func TestPutStorage(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, Options{MaxInline: 16, MaxSize: 64, TTL: time.Hour, DraftTTL: time.Minute})
+	published := Version{OrgID: uuid.New(), TemplateID: uuid.New(), Version: 1}
+	draft := published
+	draft.Version, draft.Draft = 2, true
+
+	small, large := []byte("small"), bytes.Repeat([]byte("L"), 40)
+	if err := c.Put(ctx, "small", published, "text/html", small); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(ctx, "large", draft, "application/pdf", large); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.objects.Stat(ctx, "small"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("small document was put in object storage: %v", err)
+	}
+	if _, err := c.objects.Stat(ctx, "large"); err != nil {
+		t.Errorf("large document is not in object storage: %v", err)
+	}
+	entry, err := c.Get(ctx, "large")
+	if err != nil || entry == nil {
+		t.Fatalf("Get() = %v, %v", entry, err)
+	}
+	entry.Body.Close()
+	if entry.ContentType != "application/pdf" {
+		t.Errorf("ContentType = %q", entry.ContentType)
+	}
+
+	if ttl := c.redis.TTL(c.entryKey("small")); ttl != time.Hour {
+		t.Errorf("published TTL = %v, want %v", ttl, time.Hour)
+	}
+	if ttl := c.redis.TTL(c.entryKey("large")); ttl != time.Minute {
+		t.Errorf("draft TTL = %v, want %v", ttl, time.Minute)
+	}
+
+	// A swept object drops its pointer
+	if err := c.objects.Delete(ctx, "large"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.read(t, "large"); got != nil {
+		t.Errorf("Get() of a swept document = %q", got)
+	}
+	if c.redis.Exists(c.entryKey("large")) {
+		t.Error("pointer to a swept document was kept")
+	}
+}
+
+func TestInvalidateVersion(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, Options{MaxInline: 16, MaxSize: 64, TTL: time.Hour, DraftTTL: time.Minute})
+	draft := Version{OrgID: uuid.New(), TemplateID: uuid.New(), Version: 3, Draft: true}
+	other := draft
+	other.Version = 2
+
+	for key, size := range map[string]int{"draft-small": 5, "draft-large": 40} {
+		if err := c.Put(ctx, key, draft, "application/pdf", bytes.Repeat([]byte("d"), size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Put(ctx, "other-large", other, "application/pdf", bytes.Repeat([]byte("o"), 40)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.InvalidateVersion(ctx, draft); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"draft-small", "draft-large"} {
+		if got := c.read(t, key); got != nil {
+			t.Errorf("%s is still cached", key)
+		}
+	}
+	if _, err := c.objects.Stat(ctx, "draft-large"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("draft-large is still in object storage: %v", err)
+	}
+	if c.redis.Exists(c.versionKey(draft)) {
+		t.Error("the version's index was kept")
+	}
+
+	if got := c.read(t, "other-large"); len(got) != 40 {
+		t.Errorf("another version's document was dropped")
+	}
+	if !c.redis.Exists(c.versionKey(other)) {
+		t.Error("another version's index was dropped")
+	}
+
+	// Nothing cached for the version is fine too
+	if err := c.InvalidateVersion(ctx, draft); err != nil {
+		t.Errorf("InvalidateVersion() of an empty version = %v", err)
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	ctx := context.Background()
+	var c *Cache
+	body := io.NopCloser(strings.NewReader("doc"))
+	if r := c.Tee(ctx, "k", Version{}, "application/pdf", body); r != body {
+		t.Error("Tee() on a nil cache wrapped the body")
+	}
+	if err := c.Put(ctx, "k", Version{}, "application/pdf", []byte("doc")); err != nil {
+		t.Error(err)
+	}
+	if entry, err := c.Get(ctx, "k"); entry != nil || err != nil {
+		t.Errorf("Get() = %v, %v", entry, err)
+	}
+	if err := c.InvalidateVersion(ctx, Version{}); err != nil {
+		t.Error(err)
+	}
+}
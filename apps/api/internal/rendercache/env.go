@@ -0,0 +1,36 @@
+package rendercache
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"template-builder-api/internal/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FromEnv builds the cache with the default options, overridden by
+// RENDER_CACHE_TTL and RENDER_CACHE_DRAFT_TTL (Go durations). It returns
+// nil, which caches nothing, when RENDER_CACHE is "off".
+func FromEnv(rdb *redis.Client, objects storage.Blob) (*Cache, error) {
+	if os.Getenv("RENDER_CACHE") == "off" {
+		return nil, nil
+	}
+	opts := DefaultOptions()
+	for name, ttl := range map[string]*time.Duration{
+		"RENDER_CACHE_TTL":       &opts.TTL,
+		"RENDER_CACHE_DRAFT_TTL": &opts.DraftTTL,
+	} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s: %q", name, v)
+		}
+		*ttl = d
+	}
+	return New(rdb, objects, opts), nil
+}
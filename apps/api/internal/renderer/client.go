@@ -0,0 +1,312 @@
+// Package renderer is the client for the renderer service. It spreads
+// requests over a pool of instances, preferring the least loaded healthy
+// one, retries failed requests with jittered backoff, and stops sending
+// to an instance that keeps failing until it recovers.
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned without calling the renderer when no
+// instance is healthy with its circuit breaker closed.
+var ErrUnavailable = errors.New("renderer unavailable")
+
+// StatusError is a non-2xx response from the renderer.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("renderer error (%d): %s", e.Code, e.Body)
+}
+
+// Status describes one instance, for health reporting.
+type Status struct {
+	URL      string `json:"url"`
+	Healthy  bool   `json:"healthy"`
+	Open     bool   `json:"circuitOpen"`
+	InFlight int    `json:"inFlight"`
+	Version  string `json:"version,omitempty"`
+}
+
+type Client struct {
+	cfg       Config
+	http      *http.Client
+	endpoints []*endpoint
+}
+
+func New(cfg Config) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = cfg.ConnectTimeout
+
+	c := &Client{cfg: cfg, http: &http.Client{Transport: transport, Timeout: cfg.Timeout}}
+	for _, u := range cfg.Endpoints {
+		c.endpoints = append(c.endpoints, &endpoint{url: u, healthy: true})
+	}
+	return c
+}
+
+// Post sends a JSON body to path, retrying on connection errors and 5xx
+// responses. Only 2xx responses are returned; the caller must close the
+// body.
+func (c *Client) Post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	var lastErr error
+	tried := map[*endpoint]bool{}
+	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			// Full jitter: anywhere up to the doubled backoff
+			wait := time.Duration(rand.Int64N(int64(c.cfg.RetryBackoff<<(attempt-1)) + 1))
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		e := c.pick(tried)
+		if e == nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+			}
+			return nil, ErrUnavailable
+		}
+		tried[e] = true
+
+		resp, err := c.send(ctx, e, path, body)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("Renderer %s failed (attempt %d): %v", e.url, attempt+1, err)
+	}
+	return nil, lastErr
+}
+
+func (c *Client) send(ctx context.Context, e *endpoint, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url+path, bytes.NewReader(body))
+	if err != nil {
+		e.release()
+		e.abandon()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		e.release()
+		// A cancelled request says nothing about the renderer.
+		if ctx.Err() != nil {
+			e.abandon()
+		} else {
+			e.record(false, time.Now(), &c.cfg)
+		}
+		return nil, fmt.Errorf("failed to call renderer: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		resp.Body.Close()
+		e.release()
+		e.record(resp.StatusCode < 500, time.Now(), &c.cfg)
+		return nil, &StatusError{Code: resp.StatusCode, Body: string(msg)}
+	}
+	e.record(true, time.Now(), &c.cfg)
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: e.release}
+	return resp, nil
+}
+
+// pick reserves the least loaded available endpoint, preferring ones not
+// yet tried for this request. Ties are broken at random so instances share
+// the work when idle.
+func (c *Client) pick(tried map[*endpoint]bool) *endpoint {
+	now := time.Now()
+	for _, fresh := range []bool{true, false} {
+		candidates := make([]*endpoint, 0, len(c.endpoints))
+		for _, e := range c.endpoints {
+			if tried[e] == !fresh {
+				candidates = append(candidates, e)
+			}
+		}
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		// Sort by load; acquiring may still fail if the endpoint's state
+		// changed, so fall through to the next.
+		for len(candidates) > 0 {
+			best := 0
+			for i, e := range candidates {
+				if e.load() < candidates[best].load() {
+					best = i
+				}
+			}
+			e := candidates[best]
+			if e.acquire(now, c.cfg.FailureThreshold) {
+				return e
+			}
+			candidates = append(candidates[:best], candidates[best+1:]...)
+		}
+	}
+	return nil
+}
+
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code >= 500
+	}
+	return true
+}
+
+// releaseBody releases the endpoint once the response is consumed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
+
+// Run checks every instance's health until ctx is done.
+func (c *Client) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, e := range c.endpoints {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.check(ctx, e)
+			}()
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check asks an instance for its version; answering at all makes it
+// healthy.
+func (c *Client) check(ctx context.Context, e *endpoint) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.HealthInterval)
+	defer cancel()
+
+	version, err := c.fetchVersion(ctx, e.url)
+	if err != nil {
+		if e.status().Healthy {
+			log.Printf("Renderer %s is unhealthy: %v", e.url, err)
+		}
+		e.setHealth(false, "")
+		return
+	}
+	if !e.status().Healthy {
+		log.Printf("Renderer %s is healthy again", e.url)
+	}
+	e.setHealth(true, version)
+}
+
+func (c *Client) fetchVersion(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"/version", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var v struct {
+		Version  string `json:"version"`
+		Build    string `json:"build"`
+		Chromium string `json:"chromium"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", err
+	}
+	if v.Version == "" {
+		return "", fmt.Errorf("no version reported")
+	}
+	if v.Build != "" {
+		v.Version += "+" + v.Build
+	}
+	return v.Version + "/chromium " + v.Chromium, nil
+}
+
+// Version identifies the renderer build and browser when every healthy
+// instance runs the same one, and is "" otherwise, e.g. mid-deploy or
+// before the first health check.
+func (c *Client) Version() string {
+	version := ""
+	for _, e := range c.endpoints {
+		s := e.status()
+		if !s.Healthy {
+			continue
+		}
+		if s.Version == "" || (version != "" && s.Version != version) {
+			return ""
+		}
+		version = s.Version
+	}
+	return version
+}
+
+// Statuses reports on every instance.
+func (c *Client) Statuses() []Status {
+	out := make([]Status, len(c.endpoints))
+	for i, e := range c.endpoints {
+		out[i] = e.status()
+	}
+	return out
+}
+
+// RenderTime reads the time the renderer spent on a request from its
+// Server-Timing header ("render;dur=<ms>").
+func RenderTime(h http.Header) (time.Duration, bool) {
+	for _, v := range h.Values("Server-Timing") {
+		for _, metric := range strings.Split(v, ",") {
+			params := strings.Split(metric, ";")
+			if strings.TrimSpace(params[0]) != "render" {
+				continue
+			}
+			for _, p := range params[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if name != "dur" {
+					continue
+				}
+				ms, err := strconv.ParseFloat(value, 64)
+				if err != nil || ms < 0 {
+					return 0, false
+				}
+				return time.Duration(ms * float64(time.Millisecond)), true
+			}
+		}
+	}
+	return 0, false
+}
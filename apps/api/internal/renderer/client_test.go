@@ -0,0 +1,361 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRenderer is a renderer instance answering every request with status.
+type testRenderer struct {
+	*httptest.Server
+	status atomic.Int32
+	hits   atomic.Int32
+	// block, if set, holds requests until it is closed.
+	block atomic.Pointer[chan struct{}]
+}
+
+// hold makes requests wait until the returned channel is closed.
+func (r *testRenderer) hold() chan struct{} {
+	block := make(chan struct{})
+	r.block.Store(&block)
+	return block
+}
+
+func newTestRenderer(t *testing.T, status int) *testRenderer {
+	t.Helper()
+	r := &testRenderer{}
+	r.status.Store(int32(status))
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.hits.Add(1)
+		if block := r.block.Load(); block != nil {
+			<-*block
+		}
+		w.WriteHeader(int(r.status.Load()))
+		io.WriteString(w, "document")
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func testConfig(urls ...string) Config {
+	cfg := DefaultConfig()
+	cfg.Endpoints = urls
+	cfg.Retries = 0
+	cfg.RetryBackoff = time.Millisecond
+	cfg.FailureThreshold = 3
+	cfg.OpenTimeout = 50 * time.Millisecond
+	return cfg
+}
+
+func post(c *Client) error {
+	resp, err := c.Post(context.Background(), "/render", []byte(`{}`))
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return err
+}
+
+func statusCode(err error) int {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code
+	}
+	return 0
+}
+
+func TestBreakerOpensAtThreshold(t *testing.T) {
+	r := newTestRenderer(t, http.StatusInternalServerError)
+	c := New(testConfig(r.URL))
+
+	for i := 0; i < 3; i++ {
+		if err := post(c); statusCode(err) != 500 {
+			t.Fatalf("request %d: %v, want a 500", i+1, err)
+		}
+		if open := c.Statuses()[0].Open; open != (i == 2) {
+			t.Errorf("after %d failures the breaker is open: %v", i+1, open)
+		}
+	}
+	if err := post(c); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("open breaker: %v, want ErrUnavailable", err)
+	}
+	if n := r.hits.Load(); n != 3 {
+		t.Errorf("renderer got %d requests, want 3", n)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	r := newTestRenderer(t, http.StatusInternalServerError)
+	c := New(testConfig(r.URL))
+
+	for _, status := range []int{500, 500, 200, 500, 500} {
+		r.status.Store(int32(status))
+		post(c)
+	}
+	if c.Statuses()[0].Open {
+		t.Error("breaker opened on failures that weren't consecutive")
+	}
+}
+
+func TestBreakerProbesOnceAfterOpenTimeout(t *testing.T) {
+	r := newTestRenderer(t, http.StatusInternalServerError)
+	c := New(testConfig(r.URL))
+	for i := 0; i < 3; i++ {
+		post(c)
+	}
+	if err := post(c); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("open breaker: %v, want ErrUnavailable", err)
+	}
+
+	// After OpenTimeout one request goes through while the others are
+	// refused
+	time.Sleep(c.cfg.OpenTimeout)
+	r.status.Store(http.StatusOK)
+	release := r.hold()
+	probe := make(chan error)
+	go func() { probe <- post(c) }()
+	for r.hits.Load() < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		if err := post(c); !errors.Is(err, ErrUnavailable) {
+			t.Errorf("request during the probe: %v, want ErrUnavailable", err)
+		}
+	}
+	close(release)
+	if err := <-probe; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	// The probe succeeded, so the breaker is closed again
+	if err := post(c); err != nil {
+		t.Errorf("after a successful probe: %v", err)
+	}
+	if n := r.hits.Load(); n != 5 {
+		t.Errorf("renderer got %d requests, want 5", n)
+	}
+}
+
+func TestBreakerReopensAfterFailedProbe(t *testing.T) {
+	r := newTestRenderer(t, http.StatusBadGateway)
+	c := New(testConfig(r.URL))
+	for i := 0; i < 3; i++ {
+		post(c)
+	}
+
+	time.Sleep(c.cfg.OpenTimeout)
+	if err := post(c); statusCode(err) != 502 {
+		t.Fatalf("probe: %v, want a 502", err)
+	}
+	if err := post(c); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("after a failed probe: %v, want ErrUnavailable", err)
+	}
+	if !c.Statuses()[0].Open {
+		t.Error("breaker is closed after a failed probe")
+	}
+}
+
+func TestEndpointBreaker(t *testing.T) {
+	cfg := testConfig()
+	now := time.Now()
+	e := &endpoint{healthy: true}
+	for i := 0; i < cfg.FailureThreshold; i++ {
+		if !e.acquire(now, cfg.FailureThreshold) {
+			t.Fatalf("closed breaker refused request %d", i+1)
+		}
+		e.release()
+		e.record(false, now, &cfg)
+	}
+
+	if e.acquire(now.Add(cfg.OpenTimeout-time.Millisecond), cfg.FailureThreshold) {
+		t.Error("open breaker let a request through before OpenTimeout")
+	}
+	later := now.Add(cfg.OpenTimeout)
+	if !e.acquire(later, cfg.FailureThreshold) {
+		t.Fatal("half-open breaker refused the probe")
+	}
+	if e.acquire(later, cfg.FailureThreshold) {
+		t.Error("half-open breaker let a second request through")
+	}
+
+	// A cancelled probe proves nothing; the next request probes instead
+	e.release()
+	e.abandon()
+	if !e.acquire(later, cfg.FailureThreshold) {
+		t.Fatal("breaker refused a probe after one was abandoned")
+	}
+	e.release()
+	e.record(true, later, &cfg)
+	if !e.acquire(later, cfg.FailureThreshold) || !e.acquire(later, cfg.FailureThreshold) {
+		t.Error("breaker isn't closed after a successful probe")
+	}
+
+	e.setHealth(false, "")
+	if e.acquire(later, cfg.FailureThreshold) {
+		t.Error("unhealthy endpoint accepted a request")
+	}
+}
+
+func TestClientErrorNotRetried(t *testing.T) {
+	a := newTestRenderer(t, http.StatusUnprocessableEntity)
+	b := newTestRenderer(t, http.StatusUnprocessableEntity)
+	cfg := testConfig(a.URL, b.URL)
+	cfg.Retries = 2
+	c := New(cfg)
+
+	for i := 0; i < 5; i++ {
+		if err := post(c); statusCode(err) != 422 {
+			t.Fatalf("request %d: %v, want a 422", i+1, err)
+		}
+	}
+	if n := a.hits.Load() + b.hits.Load(); n != 5 {
+		t.Errorf("renderers got %d requests for 5 calls, want 5", n)
+	}
+	// Bad requests aren't the renderer's fault
+	for _, s := range c.Statuses() {
+		if s.Open {
+			t.Errorf("%s: breaker opened on 4xx responses", s.URL)
+		}
+	}
+}
+
+func TestServerErrorRetriedOnAnotherEndpoint(t *testing.T) {
+	failing := newTestRenderer(t, http.StatusServiceUnavailable)
+	working := newTestRenderer(t, http.StatusOK)
+	cfg := testConfig(failing.URL, working.URL)
+	cfg.Retries = 1
+	cfg.FailureThreshold = 100
+	c := New(cfg)
+
+	const calls = 20
+	for i := 0; i < calls; i++ {
+		before := failing.hits.Load()
+		if err := post(c); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		if failing.hits.Load()-before > 1 {
+			t.Fatal("retry went back to the endpoint that failed")
+		}
+	}
+	if n := working.hits.Load(); n != calls {
+		t.Errorf("working renderer got %d requests, want %d", n, calls)
+	}
+	if failing.hits.Load() == 0 {
+		t.Error("failing renderer was never picked; the retry path went untested")
+	}
+}
+
+func TestConnectionErrorRetried(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	working := newTestRenderer(t, http.StatusOK)
+	cfg := testConfig(down.URL, working.URL)
+	cfg.Retries = 1
+	c := New(cfg)
+
+	for i := 0; i < 10; i++ {
+		if err := post(c); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	r := newTestRenderer(t, http.StatusInternalServerError)
+	cfg := testConfig(r.URL)
+	cfg.Retries = 2
+	cfg.FailureThreshold = 100
+	c := New(cfg)
+
+	// With one endpoint, retries go back to it
+	if err := post(c); statusCode(err) != 500 {
+		t.Fatalf("got %v, want a 500", err)
+	}
+	if n := r.hits.Load(); n != 3 {
+		t.Errorf("renderer got %d requests, want 3", n)
+	}
+}
+
+func TestInFlightReleasedOnClose(t *testing.T) {
+	a := newTestRenderer(t, http.StatusOK)
+	b := newTestRenderer(t, http.StatusOK)
+	c := New(testConfig(a.URL, b.URL))
+	inFlight := func() (n int) {
+		for _, s := range c.Statuses() {
+			n += s.InFlight
+		}
+		return n
+	}
+
+	resp, err := c.Post(context.Background(), "/render", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := inFlight(); n != 1 {
+		t.Fatalf("inFlight = %d with a response open, want 1", n)
+	}
+
+	// The open response makes its endpoint the busier one
+	busy := a
+	if c.Statuses()[1].InFlight == 1 {
+		busy = b
+	}
+	before := busy.hits.Load()
+	if err := post(c); err != nil {
+		t.Fatal(err)
+	}
+	if busy.hits.Load() != before {
+		t.Error("request went to the busier endpoint")
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	resp.Body.Close()
+	if n := inFlight(); n != 0 {
+		t.Errorf("inFlight = %d after Close, want 0", n)
+	}
+
+	// Failed requests release their endpoint too
+	a.status.Store(http.StatusInternalServerError)
+	b.status.Store(http.StatusBadRequest)
+	for i := 0; i < 4; i++ {
+		post(c)
+	}
+	if n := inFlight(); n != 0 {
+		t.Errorf("inFlight = %d after failed requests, want 0", n)
+	}
+}
+
+func TestCancelledRequestDoesNotCount(t *testing.T) {
+	r := newTestRenderer(t, http.StatusOK)
+	defer close(r.hold())
+	cfg := testConfig(r.URL)
+	cfg.FailureThreshold = 1
+	c := New(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := c.Post(ctx, "/render", nil); err == nil {
+			t.Error("cancelled request succeeded")
+		}
+	}()
+	for r.hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	if s := c.Statuses()[0]; s.Open || s.InFlight != 0 {
+		t.Errorf("after a cancelled request: %+v", s)
+	}
+}
@@ -0,0 +1,94 @@
+package renderer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Endpoints are the base URLs of the renderer instances.
+	Endpoints []string
+	// ConnectTimeout bounds establishing a connection; Timeout bounds a
+	// whole attempt, including reading the document.
+	ConnectTimeout time.Duration
+	Timeout        time.Duration
+	// Retries is how many times a request is retried, on another instance
+	// where there is one, after a connection error or 5xx. Retries wait a
+	// random time up to RetryBackoff, doubling each attempt.
+	Retries      int
+	RetryBackoff time.Duration
+	// FailureThreshold consecutive failures open an instance's circuit
+	// breaker for OpenTimeout, after which one request is let through to
+	// test it.
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	// HealthInterval is how often instances are checked.
+	HealthInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Endpoints:        []string{"http://localhost:3001"},
+		ConnectTimeout:   2 * time.Second,
+		Timeout:          2 * time.Minute,
+		Retries:          2,
+		RetryBackoff:     250 * time.Millisecond,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HealthInterval:   10 * time.Second,
+	}
+}
+
+// ConfigFromEnv overrides the defaults with RENDERER_URLS (comma
+// separated), RENDERER_CONNECT_TIMEOUT, RENDERER_TIMEOUT,
+// RENDERER_RETRIES, RENDERER_RETRY_BACKOFF, RENDERER_FAILURE_THRESHOLD,
+// RENDERER_OPEN_TIMEOUT and RENDERER_HEALTH_INTERVAL.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	if v := os.Getenv("RENDERER_URLS"); v != "" {
+		cfg.Endpoints = nil
+		for _, u := range strings.Split(v, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				cfg.Endpoints = append(cfg.Endpoints, strings.TrimSuffix(u, "/"))
+			}
+		}
+		if len(cfg.Endpoints) == 0 {
+			return cfg, fmt.Errorf("invalid RENDERER_URLS: %q", v)
+		}
+	}
+	for name, d := range map[string]*time.Duration{
+		"RENDERER_CONNECT_TIMEOUT": &cfg.ConnectTimeout,
+		"RENDERER_TIMEOUT":         &cfg.Timeout,
+		"RENDERER_RETRY_BACKOFF":   &cfg.RetryBackoff,
+		"RENDERER_OPEN_TIMEOUT":    &cfg.OpenTimeout,
+		"RENDERER_HEALTH_INTERVAL": &cfg.HealthInterval,
+	} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid %s: %q", name, v)
+		}
+		*d = parsed
+	}
+	if v := os.Getenv("RENDERER_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid RENDERER_RETRIES: %q", v)
+		}
+		cfg.Retries = n
+	}
+	if v := os.Getenv("RENDERER_FAILURE_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid RENDERER_FAILURE_THRESHOLD: %q", v)
+		}
+		cfg.FailureThreshold = n
+	}
+	return cfg, nil
+}
@@ -0,0 +1,102 @@
+package renderer
+
+import (
+	"sync"
+	"time"
+)
+
+// endpoint is one renderer instance with its health and circuit breaker.
+type endpoint struct {
+	url string
+
+	mu sync.Mutex
+	// healthy is the result of the last health check; instances start
+	// healthy so requests aren't refused before the first check.
+	healthy bool
+	version string
+	// inFlight counts requests whose response hasn't been closed.
+	inFlight int
+	// failures counts consecutive failed requests. At the threshold the
+	// breaker is open until openUntil, then half-open: probing is set
+	// while the single trial request runs.
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// acquire reserves the endpoint for a request if it is healthy and its
+// breaker lets the request through.
+func (e *endpoint) acquire(now time.Time, threshold int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.healthy {
+		return false
+	}
+	if e.failures >= threshold {
+		if now.Before(e.openUntil) || e.probing {
+			return false
+		}
+		e.probing = true
+	}
+	e.inFlight++
+	return true
+}
+
+func (e *endpoint) release() {
+	e.mu.Lock()
+	e.inFlight--
+	e.mu.Unlock()
+}
+
+// record counts a request's outcome towards the breaker.
+func (e *endpoint) record(ok bool, now time.Time, cfg *Config) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.probing = false
+	if ok {
+		e.failures = 0
+		e.openUntil = time.Time{}
+		return
+	}
+	e.failures++
+	if e.failures >= cfg.FailureThreshold {
+		e.openUntil = now.Add(cfg.OpenTimeout)
+	}
+}
+
+// abandon ends a request that neither succeeded nor failed, such as a
+// cancelled one.
+func (e *endpoint) abandon() {
+	e.mu.Lock()
+	e.probing = false
+	e.mu.Unlock()
+}
+
+func (e *endpoint) load() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.inFlight
+}
+
+func (e *endpoint) setHealth(healthy bool, version string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.healthy = healthy
+	if healthy {
+		e.version = version
+	}
+}
+
+func (e *endpoint) status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return Status{
+		URL:      e.url,
+		Healthy:  e.healthy,
+		Open:     time.Now().Before(e.openUntil),
+		InFlight: e.inFlight,
+		Version:  e.version,
+	}
+}
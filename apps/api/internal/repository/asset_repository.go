@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"template-builder-api/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrAssetInUse is returned when deleting an asset something still uses.
+	ErrAssetInUse = errors.New("asset in use")
+	// ErrUploadCompleted is returned when completing an upload twice.
+	ErrUploadCompleted = errors.New("upload already completed")
+)
+
+const assetColumns = `id, org_id, type, filename, content_type, size_bytes, COALESCE(checksum_sha256, ''), folder, tags,
+	COALESCE(width, 0), COALESCE(height, 0), COALESCE(dpi, 0)::float8, renditions, s3_key, created_at`
+
+func scanAsset(row pgx.Row) (*model.Asset, error) {
+	var a model.Asset
+	if err := row.Scan(&a.ID, &a.OrgID, &a.Type, &a.Filename, &a.ContentType, &a.SizeBytes, &a.ChecksumSHA256, &a.Folder, &a.Tags,
+		&a.Width, &a.Height, &a.DPI, &a.Renditions, &a.S3Key, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+	return &a, nil
+}
+
+const insertAssetQuery = `INSERT INTO assets (id, org_id, type, filename, content_type, size_bytes, checksum_sha256, folder, tags,
+			  width, height, dpi, renditions, s3_key, created_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, NULLIF($10::int, 0), NULLIF($11::int, 0), NULLIF($12::real, 0), $13, $14, $15)`
+
+func insertAssetArgs(a *model.Asset) []any {
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+	if a.Renditions == nil {
+		a.Renditions = []model.AssetRendition{}
+	}
+	return []any{a.ID, a.OrgID, a.Type, a.Filename, a.ContentType, a.SizeBytes, a.ChecksumSHA256, a.Folder, a.Tags,
+		a.Width, a.Height, a.DPI, a.Renditions, a.S3Key, a.CreatedAt}
+}
+
+func (r *PostgresRepository) CreateAsset(ctx context.Context, a *model.Asset) error {
+	if _, err := r.db.Exec(ctx, insertAssetQuery, insertAssetArgs(a)...); err != nil {
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetAsset(ctx context.Context, orgID, id uuid.UUID) (*model.Asset, error) {
+	query := `SELECT ` + assetColumns + ` FROM assets WHERE id = $1 AND org_id = $2`
+	a, err := scanAsset(r.db.QueryRow(ctx, query, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset: %w", notFound(err))
+	}
+	return a, nil
+}
+
+func (r *PostgresRepository) ListAssets(ctx context.Context, f model.AssetFilter) ([]model.Asset, int, error) {
+	where := []string{"org_id = $1"}
+	args := []any{f.OrgID}
+	add := func(clause string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+
+	if f.Type != "" {
+		add("type = $%d", f.Type)
+	}
+	if f.ContentType != "" {
+		add("content_type = $%d", f.ContentType)
+	}
+	if f.Filename != "" {
+		add(`filename ILIKE '%%' || $%d || '%%' ESCAPE '\'`, escapeLike(f.Filename))
+	}
+	if f.Folder != nil {
+		add("folder = $%d", *f.Folder)
+	}
+	if f.Tag != "" {
+		add("tags @> ARRAY[$%d]::text[]", f.Tag)
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM assets WHERE `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count assets: %w", err)
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM assets WHERE %s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`,
+		assetColumns, cond, len(args)+1, len(args)+2)
+	rows, err := r.db.Query(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list assets: %w", err)
+	}
+	defer rows.Close()
+
+	assets := []model.Asset{}
+	for rows.Next() {
+		a, err := scanAsset(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan asset: %w", err)
+		}
+		assets = append(assets, *a)
+	}
+	return assets, total, rows.Err()
+}
+
+// escapeLike escapes LIKE's wildcards so s matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (r *PostgresRepository) ListAssetFolders(ctx context.Context, orgID uuid.UUID) ([]model.AssetFolder, error) {
+	rows, err := r.db.Query(ctx, `SELECT folder, COUNT(*) FROM assets WHERE org_id = $1 GROUP BY folder ORDER BY folder`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list asset folders: %w", err)
+	}
+	defer rows.Close()
+
+	folders := []model.AssetFolder{}
+	for rows.Next() {
+		var f model.AssetFolder
+		if err := rows.Scan(&f.Folder, &f.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan asset folder: %w", err)
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateAsset(ctx context.Context, orgID, id uuid.UUID, u model.AssetUpdate) (*model.Asset, error) {
+	var tags []string
+	if u.Tags != nil {
+		tags = *u.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+	}
+	query := `UPDATE assets SET filename = COALESCE($3, filename), folder = COALESCE($4, folder), tags = COALESCE($5, tags)
+			  WHERE id = $1 AND org_id = $2 RETURNING ` + assetColumns
+	a, err := scanAsset(r.db.QueryRow(ctx, query, id, orgID, u.Filename, u.Folder, tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update asset: %w", notFound(err))
+	}
+	return a, nil
+}
+
+// assetReferencesQuery finds what uses asset $2: published template
+// versions built from it (a DOCX import) or pointing at it from an element,
+// templates whose post-processing stamps it while they have a published
+// version, and schedules that read their data from it. Drafts don't count;
+// they fail to render once the asset is gone, and can be fixed.
+const assetReferencesQuery = `
+	SELECT 'template_version', v.template_id, v.version, NULL::uuid
+	FROM template_versions v JOIN templates t ON t.id = v.template_id
+	WHERE t.org_id = $1 AND v.status = 'published'
+	  AND (v.docx_asset_id = $2
+	       OR jsonb_path_exists(v.template_json, '$.** ? (@.assetId == $id)', jsonb_build_object('id', $2::uuid::text)))
+	UNION ALL
+	SELECT 'template', t.id, 0, NULL::uuid
+	FROM templates t
+	WHERE t.org_id = $1
+	  AND jsonb_path_exists(COALESCE(t.post_processing, '{}'), '$.** ? (@.imageAssetId == $id)', jsonb_build_object('id', $2::uuid::text))
+	  AND EXISTS (SELECT 1 FROM template_versions v WHERE v.template_id = t.id AND v.status = 'published')
+	UNION ALL
+	SELECT 'schedule', NULL::uuid, 0, s.id
+	FROM generation_schedules s
+	WHERE s.org_id = $1 AND s.source_asset_id = $2
+	ORDER BY 1, 2, 3`
+
+// lockTemplateAssetsQuery locks FOR SHARE the org's ($3) assets that
+// version $2 of template $1 uses, and those the template's post-processing
+// stamps: what assetReferencesQuery finds once the version is published.
+// DeleteAsset locks the asset FOR UPDATE before looking for references, so
+// a delete and a publish of a version using the same asset take turns and
+// whichever comes second sees the other.
+const lockTemplateAssetsQuery = `
+	SELECT a.id FROM assets a
+	WHERE a.org_id = $3 AND a.id::text IN (
+		SELECT v.docx_asset_id::text FROM template_versions v WHERE v.template_id = $1 AND v.version = $2
+		UNION ALL
+		SELECT jsonb_path_query(v.template_json, '$.**.assetId') #>> '{}'
+		FROM template_versions v WHERE v.template_id = $1 AND v.version = $2
+		UNION ALL
+		SELECT jsonb_path_query(COALESCE(t.post_processing, '{}'), '$.**.imageAssetId') #>> '{}'
+		FROM templates t WHERE t.id = $1)
+	ORDER BY a.id
+	FOR SHARE OF a`
+
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func findAssetReferences(ctx context.Context, q queryer, orgID, id uuid.UUID) ([]model.AssetReference, error) {
+	rows, err := q.Query(ctx, assetReferencesQuery, orgID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find asset references: %w", err)
+	}
+	defer rows.Close()
+
+	refs := []model.AssetReference{}
+	for rows.Next() {
+		var ref model.AssetReference
+		if err := rows.Scan(&ref.Kind, &ref.TemplateID, &ref.Version, &ref.ScheduleID); err != nil {
+			return nil, fmt.Errorf("failed to scan asset reference: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+func (r *PostgresRepository) FindAssetReferences(ctx context.Context, orgID, id uuid.UUID) ([]model.AssetReference, error) {
+	return findAssetReferences(ctx, r.db, orgID, id)
+}
+
+// DeleteAsset checks references and deletes in one transaction, and returns
+// the deleted asset so the caller can remove its object.
+func (r *PostgresRepository) DeleteAsset(ctx context.Context, orgID, id uuid.UUID) (*model.Asset, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	a, err := scanAsset(tx.QueryRow(ctx, `SELECT `+assetColumns+` FROM assets WHERE id = $1 AND org_id = $2 FOR UPDATE`, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete asset: %w", notFound(err))
+	}
+	refs, err := findAssetReferences(ctx, tx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) > 0 {
+		return nil, &AssetInUseError{References: refs}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM assets WHERE id = $1 AND org_id = $2`, id, orgID); err != nil {
+		return nil, fmt.Errorf("failed to delete asset: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return a, nil
+}
+
+// AssetInUseError lists what stopped DeleteAsset. It matches ErrAssetInUse.
+type AssetInUseError struct {
+	References []model.AssetReference
+}
+
+func (e *AssetInUseError) Error() string {
+	return fmt.Sprintf("asset in use by %d reference(s)", len(e.References))
+}
+
+func (e *AssetInUseError) Is(target error) bool { return target == ErrAssetInUse }
+
+const assetUploadColumns = `id, org_id, created_by, filename, content_type, size_bytes, folder, tags, s3_key, expires_at, completed_at, created_at`
+
+func scanAssetUpload(row pgx.Row) (*model.AssetUpload, error) {
+	var u model.AssetUpload
+	if err := row.Scan(&u.ID, &u.OrgID, &u.CreatedBy, &u.Filename, &u.ContentType, &u.SizeBytes, &u.Folder, &u.Tags, &u.S3Key, &u.ExpiresAt, &u.CompletedAt, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *PostgresRepository) CreateAssetUpload(ctx context.Context, u *model.AssetUpload) error {
+	if u.Tags == nil {
+		u.Tags = []string{}
+	}
+	query := `INSERT INTO asset_uploads (id, org_id, created_by, filename, content_type, size_bytes, folder, tags, s3_key, expires_at, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.Exec(ctx, query, u.ID, u.OrgID, u.CreatedBy, u.Filename, u.ContentType, u.SizeBytes, u.Folder, u.Tags, u.S3Key, u.ExpiresAt, u.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create asset upload: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetAssetUpload(ctx context.Context, orgID, id uuid.UUID) (*model.AssetUpload, error) {
+	query := `SELECT ` + assetUploadColumns + ` FROM asset_uploads WHERE id = $1 AND org_id = $2`
+	u, err := scanAssetUpload(r.db.QueryRow(ctx, query, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset upload: %w", notFound(err))
+	}
+	return u, nil
+}
+
+// CompleteAssetUpload marks upload a.ID complete and creates the asset in
+// one transaction. It fails with ErrUploadCompleted if that already
+// happened.
+func (r *PostgresRepository) CompleteAssetUpload(ctx context.Context, a *model.Asset) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE asset_uploads SET completed_at = NOW() WHERE id = $1 AND org_id = $2 AND completed_at IS NULL`, a.ID, a.OrgID)
+	if err != nil {
+		return fmt.Errorf("failed to complete asset upload: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUploadCompleted
+	}
+	if _, err := tx.Exec(ctx, insertAssetQuery, insertAssetArgs(a)...); err != nil {
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// PurgeAssetUploads deletes uploads that expired before cutoff and returns
+// them, so the caller can delete the objects of those never completed.
+func (r *PostgresRepository) PurgeAssetUploads(ctx context.Context, cutoff time.Time) ([]model.AssetUpload, error) {
+	rows, err := r.db.Query(ctx, `DELETE FROM asset_uploads WHERE expires_at < $1 RETURNING `+assetUploadColumns, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge asset uploads: %w", err)
+	}
+	defer rows.Close()
+
+	uploads := []model.AssetUpload{}
+	for rows.Next() {
+		u, err := scanAssetUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan asset upload: %w", err)
+		}
+		uploads = append(uploads, *u)
+	}
+	return uploads, rows.Err()
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"template-builder-api/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const batchColumns = `id, org_id, template_id, version, status, source_format, source_asset_id, column_mapping,
+	total, completed, failed, row_errors, created_by, created_at, updated_at`
+
+func scanBatch(row pgx.Row) (*model.Batch, error) {
+	var b model.Batch
+	if err := row.Scan(&b.ID, &b.OrgID, &b.TemplateID, &b.Version, &b.Status, &b.SourceFormat, &b.SourceAssetID, &b.ColumnMapping,
+		&b.Total, &b.Completed, &b.Failed, &b.RowErrors, &b.CreatedBy, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// CreateBatch inserts the batch and all of its jobs in one transaction so a
+// batch is never visible with only some of its rows.
+func (r *PostgresRepository) CreateBatch(ctx context.Context, b *model.Batch, jobs []model.GenerationJob) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin batch tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO generation_batches (id, org_id, template_id, version, status, source_format, source_asset_id, column_mapping,
+			  total, completed, failed, row_errors, created_by, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+	_, err = tx.Exec(ctx, query, b.ID, b.OrgID, b.TemplateID, b.Version, b.Status, b.SourceFormat, b.SourceAssetID, b.ColumnMapping,
+		b.Total, b.Completed, b.Failed, b.RowErrors, b.CreatedBy, b.CreatedAt, b.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"generation_jobs"},
+		[]string{"id", "org_id", "template_id", "batch_id", "batch_row", "created_by", "status", "merge_data", "created_at", "updated_at"},
+		pgx.CopyFromSlice(len(jobs), func(i int) ([]any, error) {
+			j := jobs[i]
+			return []any{j.ID, j.OrgID, j.TemplateID, j.BatchID, j.BatchRow, j.CreatedBy, j.Status, j.Data, j.CreatedAt, j.UpdatedAt}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create batch jobs: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetBatch(ctx context.Context, orgID, id uuid.UUID) (*model.Batch, error) {
+	query := `SELECT ` + batchColumns + ` FROM generation_batches WHERE id = $1 AND org_id = $2`
+	b, err := scanBatch(r.db.QueryRow(ctx, query, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", notFound(err))
+	}
+	return b, nil
+}
+
+// RecordBatchJobResult counts one finished job towards the batch and flips it
+// to completed once every row is accounted for. It returns the updated batch
+// and notifies StatusEventsChannel of the new progress.
+func (r *PostgresRepository) RecordBatchJobResult(ctx context.Context, id uuid.UUID, succeeded bool) (*model.Batch, error) {
+	completed, failed := 0, 1
+	if succeeded {
+		completed, failed = 1, 0
+	}
+
+	query := `UPDATE generation_batches
+			  SET completed = completed + $2,
+			      failed = failed + $3,
+			      status = CASE WHEN completed + $2 + failed + $3 >= total THEN 'completed' ELSE status END,
+			      updated_at = clock_timestamp() -- taken after the row lock, so it orders concurrent updates
+			  WHERE id = $1
+			  RETURNING ` + batchColumns
+	b, err := scanBatch(r.db.QueryRow(ctx, query, id, completed, failed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update batch progress: %w", notFound(err))
+	}
+	r.notifyStatus(ctx, model.BatchStatusEvent(b))
+	return b, nil
+}
+
+// ListBatchOutputs returns the completed jobs of a batch in input row order.
+func (r *PostgresRepository) ListBatchOutputs(ctx context.Context, orgID, batchID uuid.UUID) ([]model.JobOutput, error) {
+	query := `SELECT j.id, COALESCE(j.batch_row, 0), j.merge_data, a.id, a.s3_key
+			  FROM generation_jobs j JOIN assets a ON a.id = j.output_asset_id
+			  WHERE j.batch_id = $1 AND j.org_id = $2 AND j.status = 'completed'
+			  ORDER BY j.batch_row, j.id`
+	rows, err := r.db.Query(ctx, query, batchID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch outputs: %w", err)
+	}
+	defer rows.Close()
+
+	var outputs []model.JobOutput
+	for rows.Next() {
+		var o model.JobOutput
+		if err := rows.Scan(&o.JobID, &o.BatchRow, &o.Data, &o.AssetID, &o.S3Key); err != nil {
+			return nil, fmt.Errorf("failed to scan batch output: %w", err)
+		}
+		outputs = append(outputs, o)
+	}
+	return outputs, rows.Err()
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"template-builder-api/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ClaimIdempotencyKey stores rec as in progress unless the key is already
+// held. It returns the existing record when it is, and nil when the caller
+// now owns the key. Expired keys, and in-progress keys older than
+// staleAfter (their request died), are taken over.
+func (r *PostgresRepository) ClaimIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord, staleAfter time.Duration) (*model.IdempotencyRecord, error) {
+	query := `INSERT INTO idempotency_keys (org_id, key, request_hash, created_at, expires_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (org_id, key) DO UPDATE
+			  SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL, response_body = NULL,
+			      created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+			  WHERE idempotency_keys.expires_at < NOW()
+			     OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < NOW() - make_interval(secs => $6))
+			  RETURNING key`
+	var key string
+	err := r.db.QueryRow(ctx, query, rec.OrgID, rec.Key, rec.RequestHash, rec.CreatedAt, rec.ExpiresAt, staleAfter.Seconds()).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	existing := model.IdempotencyRecord{OrgID: rec.OrgID, Key: rec.Key}
+	var status *int
+	var contentType *string
+	err = r.db.QueryRow(ctx, `SELECT request_hash, status_code, content_type, response_body, created_at, expires_at
+							  FROM idempotency_keys WHERE org_id = $1 AND key = $2`, rec.OrgID, rec.Key).
+		Scan(&existing.RequestHash, &status, &contentType, &existing.Body, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if status != nil {
+		existing.StatusCode = *status
+	}
+	if contentType != nil {
+		existing.ContentType = *contentType
+	}
+	return &existing, nil
+}
+
+func (r *PostgresRepository) SaveIdempotencyResponse(ctx context.Context, rec *model.IdempotencyRecord) error {
+	query := `UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3
+			  WHERE org_id = $4 AND key = $5 AND request_hash = $6`
+	_, err := r.db.Exec(ctx, query, rec.StatusCode, rec.ContentType, rec.Body, rec.OrgID, rec.Key, rec.RequestHash)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops an in-progress key so the request can be
+// retried with it.
+func (r *PostgresRepository) ReleaseIdempotencyKey(ctx context.Context, orgID uuid.UUID, key string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE org_id = $1 AND key = $2 AND status_code IS NULL`, orgID, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,10 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"template-builder-api/internal/model"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -16,26 +20,108 @@ type Repository interface {
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 
 	CreateTemplate(ctx context.Context, template *model.Template) error
-	GetTemplate(ctx context.Context, id uuid.UUID) (*model.Template, error)
+	// Lookups by ID are always scoped to the caller's org; a row owned by
+	// another org is reported as ErrNotFound.
+	GetTemplate(ctx context.Context, orgID, id uuid.UUID) (*model.Template, error)
 	ListTemplates(ctx context.Context, orgID uuid.UUID) ([]model.Template, error)
+	UpdateTemplatePostProcessing(ctx context.Context, orgID, id uuid.UUID, pp *model.PostProcessing) (*model.Template, error)
 	CreateTemplateVersion(ctx context.Context, version *model.TemplateVersion) error
-	ListTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]model.TemplateVersion, error)
-	GetTemplateVersion(ctx context.Context, templateID uuid.UUID, version int) (*model.TemplateVersion, error)
+	ListTemplateVersions(ctx context.Context, orgID, templateID uuid.UUID) ([]model.TemplateVersion, error)
+	GetTemplateVersion(ctx context.Context, orgID, templateID uuid.UUID, version int) (*model.TemplateVersion, error)
 	GetMaxVersion(ctx context.Context, templateID uuid.UUID) (int, error)
+	PublishTemplateVersion(ctx context.Context, orgID, templateID uuid.UUID, version int) (*model.TemplateVersion, error)
+	UpdateTemplateVersion(ctx context.Context, orgID uuid.UUID, v *model.TemplateVersion) error
 
 	CreateAsset(ctx context.Context, asset *model.Asset) error
-	GetAsset(ctx context.Context, id uuid.UUID) (*model.Asset, error)
+	GetAsset(ctx context.Context, orgID, id uuid.UUID) (*model.Asset, error)
+	ListAssets(ctx context.Context, filter model.AssetFilter) ([]model.Asset, int, error)
+	ListAssetFolders(ctx context.Context, orgID uuid.UUID) ([]model.AssetFolder, error)
+	UpdateAsset(ctx context.Context, orgID, id uuid.UUID, update model.AssetUpdate) (*model.Asset, error)
+	// DeleteAsset refuses, with ErrAssetInUse, to delete an asset that
+	// FindAssetReferences would report.
+	DeleteAsset(ctx context.Context, orgID, id uuid.UUID) (*model.Asset, error)
+	FindAssetReferences(ctx context.Context, orgID, id uuid.UUID) ([]model.AssetReference, error)
+	CreateAssetUpload(ctx context.Context, upload *model.AssetUpload) error
+	GetAssetUpload(ctx context.Context, orgID, id uuid.UUID) (*model.AssetUpload, error)
+	CompleteAssetUpload(ctx context.Context, asset *model.Asset) error
+	PurgeAssetUploads(ctx context.Context, cutoff time.Time) ([]model.AssetUpload, error)
 
 	// Jobs
 	CreateJob(ctx context.Context, job *model.GenerationJob) error
-	GetJob(ctx context.Context, id uuid.UUID) (*model.GenerationJob, error)
+	GetJob(ctx context.Context, orgID, id uuid.UUID) (*model.GenerationJob, error)
+	ListJobs(ctx context.Context, filter model.JobFilter) ([]model.GenerationJob, int, error)
 	UpdateJobStatus(ctx context.Context, id uuid.UUID, status string, outputAssetID *uuid.UUID, errMsg string) error
 
+	// Batches
+	CreateBatch(ctx context.Context, batch *model.Batch, jobs []model.GenerationJob) error
+	GetBatch(ctx context.Context, orgID, id uuid.UUID) (*model.Batch, error)
+	RecordBatchJobResult(ctx context.Context, id uuid.UUID, succeeded bool) (*model.Batch, error)
+	ListBatchOutputs(ctx context.Context, orgID, batchID uuid.UUID) ([]model.JobOutput, error)
+
+	// Webhooks
+	CreateWebhookEndpoint(ctx context.Context, endpoint *model.WebhookEndpoint) error
+	GetWebhookEndpoint(ctx context.Context, orgID, id uuid.UUID) (*model.WebhookEndpoint, error)
+	ListWebhookEndpoints(ctx context.Context, orgID uuid.UUID) ([]model.WebhookEndpoint, error)
+	UpdateWebhookEndpoint(ctx context.Context, endpoint *model.WebhookEndpoint) error
+	DeleteWebhookEndpoint(ctx context.Context, orgID, id uuid.UUID) error
+	ListWebhookEndpointsForEvent(ctx context.Context, orgID uuid.UUID, eventType string) ([]model.WebhookEndpoint, error)
+	CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, orgID, id uuid.UUID) (*model.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, orgID, endpointID uuid.UUID, limit, offset int) ([]model.WebhookDelivery, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, delivery *model.WebhookDelivery) error
+
+	// Signing certificates
+	CreateSigningCertificate(ctx context.Context, cert *model.SigningCertificate) error
+	GetSigningCertificate(ctx context.Context, orgID, id uuid.UUID) (*model.SigningCertificate, error)
+	ListSigningCertificates(ctx context.Context, orgID uuid.UUID) ([]model.SigningCertificate, error)
+	FindSigningCertificates(ctx context.Context, orgID uuid.UUID, fingerprint string) ([]model.SigningCertificate, error)
+	DeleteSigningCertificate(ctx context.Context, orgID, id uuid.UUID) error
+
+	// Schedules
+	CreateSchedule(ctx context.Context, schedule *model.Schedule) error
+	GetSchedule(ctx context.Context, orgID, id uuid.UUID) (*model.Schedule, error)
+	ListSchedules(ctx context.Context, orgID uuid.UUID) ([]model.Schedule, error)
+	UpdateSchedule(ctx context.Context, schedule *model.Schedule) error
+	DeleteSchedule(ctx context.Context, orgID, id uuid.UUID) error
+	StartDueScheduleRuns(ctx context.Context, limit int, next func(*model.Schedule) *time.Time) ([]model.Schedule, []model.ScheduleRun, error)
+	FinishScheduleRun(ctx context.Context, run *model.ScheduleRun) error
+	ListScheduleRuns(ctx context.Context, orgID, scheduleID uuid.UUID, limit, offset int) ([]model.ScheduleRun, error)
+	GetLatestPublishedVersion(ctx context.Context, orgID, templateID uuid.UUID) (int, error)
+	LeaderLock(key int64) *LeaderLock
+
+	// Usage and plans
+	AddUsage(ctx context.Context, deltas []model.UsageDelta) error
+	ListDailyUsage(ctx context.Context, orgID uuid.UUID, from, to time.Time) ([]model.UsageDay, error)
+	SumUsage(ctx context.Context, orgID uuid.UUID, from, to time.Time) (map[string]int64, error)
+	GetOrgPlan(ctx context.Context, orgID uuid.UUID) (*model.Plan, error)
+	GetStorageBytes(ctx context.Context, orgID uuid.UUID) (int64, error)
+
+	// Idempotency keys
+	ClaimIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord, staleAfter time.Duration) (*model.IdempotencyRecord, error)
+	SaveIdempotencyResponse(ctx context.Context, rec *model.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, orgID uuid.UUID, key string) error
+	PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+
+	// Status events
+	Listen(ctx context.Context, channel string, ready func(), handle func(payload string)) error
+
 	// Auth
 	ListMemberships(ctx context.Context, userID uuid.UUID) ([]model.Membership, error)
 	CreateMembership(ctx context.Context, userID, orgID uuid.UUID, role string) error
 }
 
+// ErrNotFound is returned when a row does not exist or belongs to another org.
+var ErrNotFound = errors.New("not found")
+
+// notFound maps pgx.ErrNoRows to ErrNotFound so callers don't depend on pgx.
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
 type PostgresRepository struct {
 	db *pgxpool.Pool
 }
@@ -45,22 +131,22 @@ func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
 }
 
 func (r *PostgresRepository) CreateOrg(ctx context.Context, name string) (*model.Org, error) {
-	query := `INSERT INTO orgs (name) VALUES ($1) RETURNING id, name, created_at`
+	query := `INSERT INTO orgs (name) VALUES ($1) RETURNING id, name, max_concurrent_jobs, created_at`
 	row := r.db.QueryRow(ctx, query, name)
 
 	var org model.Org
-	if err := row.Scan(&org.ID, &org.Name, &org.CreatedAt); err != nil {
+	if err := row.Scan(&org.ID, &org.Name, &org.MaxConcurrentJobs, &org.CreatedAt); err != nil {
 		return nil, fmt.Errorf("failed to create org: %w", err)
 	}
 	return &org, nil
 }
 
 func (r *PostgresRepository) GetOrg(ctx context.Context, id uuid.UUID) (*model.Org, error) {
-	query := `SELECT id, name, created_at FROM orgs WHERE id = $1`
+	query := `SELECT id, name, max_concurrent_jobs, created_at FROM orgs WHERE id = $1`
 	row := r.db.QueryRow(ctx, query, id)
 
 	var org model.Org
-	if err := row.Scan(&org.ID, &org.Name, &org.CreatedAt); err != nil {
+	if err := row.Scan(&org.ID, &org.Name, &org.MaxConcurrentJobs, &org.CreatedAt); err != nil {
 		return nil, fmt.Errorf("failed to get org: %w", err)
 	}
 	return &org, nil
@@ -97,13 +183,43 @@ func (r *PostgresRepository) CreateTemplate(ctx context.Context, t *model.Templa
 	return nil
 }
 
-func (r *PostgresRepository) GetTemplate(ctx context.Context, id uuid.UUID) (*model.Template, error) {
-	query := `SELECT id, org_id, name, type, status, created_at FROM templates WHERE id = $1`
-	row := r.db.QueryRow(ctx, query, id)
+func (r *PostgresRepository) GetTemplate(ctx context.Context, orgID, id uuid.UUID) (*model.Template, error) {
+	query := `SELECT id, org_id, name, type, status, post_processing, created_at FROM templates WHERE id = $1 AND org_id = $2`
+	row := r.db.QueryRow(ctx, query, id, orgID)
+
+	var t model.Template
+	if err := row.Scan(&t.ID, &t.OrgID, &t.Name, &t.Type, &t.Status, &t.PostProcessing, &t.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", notFound(err))
+	}
+	return &t, nil
+}
+
+// UpdateTemplatePostProcessing replaces the template's post-processing
+// settings; nil clears them. A watermark image is held like the assets of
+// a version being published, so it can't be deleted meanwhile.
+func (r *PostgresRepository) UpdateTemplatePostProcessing(ctx context.Context, orgID, id uuid.UUID, pp *model.PostProcessing) (*model.Template, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if pp != nil && pp.Watermark != nil && pp.Watermark.ImageAssetID != nil {
+		_, err := tx.Exec(ctx, `SELECT id FROM assets WHERE id = $1 AND org_id = $2 FOR SHARE`, *pp.Watermark.ImageAssetID, orgID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock watermark asset: %w", err)
+		}
+	}
+	query := `UPDATE templates SET post_processing = $3 WHERE id = $1 AND org_id = $2
+			  RETURNING id, org_id, name, type, status, post_processing, created_at`
+	row := tx.QueryRow(ctx, query, id, orgID, pp)
 
 	var t model.Template
-	if err := row.Scan(&t.ID, &t.OrgID, &t.Name, &t.Type, &t.Status, &t.CreatedAt); err != nil {
-		return nil, fmt.Errorf("failed to get template: %w", err)
+	if err := row.Scan(&t.ID, &t.OrgID, &t.Name, &t.Type, &t.Status, &t.PostProcessing, &t.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to update template: %w", notFound(err))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	return &t, nil
 }
@@ -128,19 +244,20 @@ func (r *PostgresRepository) ListTemplates(ctx context.Context, orgID uuid.UUID)
 }
 
 func (r *PostgresRepository) CreateTemplateVersion(ctx context.Context, v *model.TemplateVersion) error {
-	query := `INSERT INTO template_versions (id, template_id, version, status, template_json, schema_json, created_by, created_at) 
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := r.db.Exec(ctx, query, v.ID, v.TemplateID, v.Version, v.Status, v.TemplateJSON, v.SchemaJSON, v.CreatedBy, v.CreatedAt)
+	query := `INSERT INTO template_versions (id, template_id, version, status, template_json, schema_json, page_setup, created_by, created_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := r.db.Exec(ctx, query, v.ID, v.TemplateID, v.Version, v.Status, v.TemplateJSON, v.SchemaJSON, v.PageSetup, v.CreatedBy, v.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create template version: %w", err)
 	}
 	return nil
 }
 
-func (r *PostgresRepository) ListTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]model.TemplateVersion, error) {
-	query := `SELECT id, template_id, version, status, created_by, created_at 
-			  FROM template_versions WHERE template_id = $1 ORDER BY version DESC`
-	rows, err := r.db.Query(ctx, query, templateID)
+func (r *PostgresRepository) ListTemplateVersions(ctx context.Context, orgID, templateID uuid.UUID) ([]model.TemplateVersion, error) {
+	query := `SELECT v.id, v.template_id, v.version, v.status, v.created_by, v.created_at 
+			  FROM template_versions v JOIN templates t ON t.id = v.template_id
+			  WHERE v.template_id = $1 AND t.org_id = $2 ORDER BY v.version DESC`
+	rows, err := r.db.Query(ctx, query, templateID, orgID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list versions: %w", err)
 	}
@@ -158,16 +275,17 @@ func (r *PostgresRepository) ListTemplateVersions(ctx context.Context, templateI
 	return versions, nil
 }
 
-func (r *PostgresRepository) GetTemplateVersion(ctx context.Context, templateID uuid.UUID, version int) (*model.TemplateVersion, error) {
-	query := `SELECT id, template_id, version, status, template_json, schema_json, created_at 
-			  FROM template_versions WHERE template_id = $1 AND version = $2`
-	row := r.db.QueryRow(ctx, query, templateID, version)
+func (r *PostgresRepository) GetTemplateVersion(ctx context.Context, orgID, templateID uuid.UUID, version int) (*model.TemplateVersion, error) {
+	query := `SELECT v.id, v.template_id, v.version, v.status, v.template_json, v.schema_json, v.page_setup, v.created_by, v.created_at, v.published_at 
+			  FROM template_versions v JOIN templates t ON t.id = v.template_id
+			  WHERE v.template_id = $1 AND v.version = $2 AND t.org_id = $3`
+	row := r.db.QueryRow(ctx, query, templateID, version, orgID)
 
 	var v model.TemplateVersion
 	// Note: We might need to handle NULLs for docx_asset_id etc if we query them.
 	// For MVP simplified query above ignores partial fields.
-	if err := row.Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.TemplateJSON, &v.SchemaJSON, &v.CreatedAt); err != nil {
-		return nil, fmt.Errorf("failed to get template version: %w", err)
+	if err := row.Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.TemplateJSON, &v.SchemaJSON, &v.PageSetup, &v.CreatedBy, &v.CreatedAt, &v.PublishedAt); err != nil {
+		return nil, fmt.Errorf("failed to get template version: %w", notFound(err))
 	}
 	return &v, nil
 }
@@ -182,70 +300,151 @@ func (r *PostgresRepository) GetMaxVersion(ctx context.Context, templateID uuid.
 	return maxVersion, nil
 }
 
-func (r *PostgresRepository) CreateAsset(ctx context.Context, a *model.Asset) error {
-	query := `INSERT INTO assets (id, org_id, type, filename, content_type, size_bytes, s3_key, created_at) 
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := r.db.Exec(ctx, query, a.ID, a.OrgID, a.Type, a.Filename, a.ContentType, a.SizeBytes, a.S3Key, a.CreatedAt)
+// PublishTemplateVersion publishes a version, holding the assets it uses
+// so they can't be deleted while it does.
+func (r *PostgresRepository) PublishTemplateVersion(ctx context.Context, orgID, templateID uuid.UUID, version int) (*model.TemplateVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockTemplateAssetsQuery, templateID, version, orgID); err != nil {
+		return nil, fmt.Errorf("failed to lock template assets: %w", err)
+	}
+	query := `UPDATE template_versions v SET status = 'published', published_at = COALESCE(v.published_at, NOW())
+			  FROM templates t
+			  WHERE t.id = v.template_id AND v.template_id = $1 AND v.version = $2 AND t.org_id = $3
+			  RETURNING v.id, v.template_id, v.version, v.status, v.created_by, v.created_at, v.published_at`
+	row := tx.QueryRow(ctx, query, templateID, version, orgID)
+
+	var v model.TemplateVersion
+	if err := row.Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.CreatedBy, &v.CreatedAt, &v.PublishedAt); err != nil {
+		return nil, fmt.Errorf("failed to publish template version: %w", notFound(err))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &v, nil
+}
+
+// UpdateTemplateVersion replaces a draft version's content. Published
+// versions are immutable and report ErrNotFound.
+func (r *PostgresRepository) UpdateTemplateVersion(ctx context.Context, orgID uuid.UUID, v *model.TemplateVersion) error {
+	query := `UPDATE template_versions tv SET template_json = $1, schema_json = $2, page_setup = $3
+			  FROM templates t
+			  WHERE t.id = tv.template_id AND tv.template_id = $4 AND tv.version = $5 AND t.org_id = $6 AND tv.status = 'draft'`
+	tag, err := r.db.Exec(ctx, query, v.TemplateJSON, v.SchemaJSON, v.PageSetup, v.TemplateID, v.Version, orgID)
 	if err != nil {
-		return fmt.Errorf("failed to create asset: %w", err)
+		return fmt.Errorf("failed to update template version: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to update template version: %w", ErrNotFound)
 	}
 	return nil
 }
 
 func (r *PostgresRepository) CreateJob(ctx context.Context, job *model.GenerationJob) error {
-	query := `INSERT INTO generation_jobs (id, org_id, template_id, status, error_message, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := r.db.Exec(ctx, query, job.ID, job.OrgID, job.TemplateID, job.Status, job.ErrorMessage, job.CreatedAt, job.UpdatedAt)
+	query := `INSERT INTO generation_jobs (id, org_id, template_id, batch_id, created_by, status, error_message, merge_data, output_options, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.Exec(ctx, query, job.ID, job.OrgID, job.TemplateID, job.BatchID, job.CreatedBy, job.Status, job.ErrorMessage, job.Data, job.Output, job.CreatedAt, job.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create job: %w", err)
 	}
 	return nil
 }
 
-func (r *PostgresRepository) GetJob(ctx context.Context, id uuid.UUID) (*model.GenerationJob, error) {
-	query := `SELECT id, org_id, template_id, status, output_asset_id, error_message, created_at, updated_at FROM generation_jobs WHERE id = $1`
-	row := r.db.QueryRow(ctx, query, id)
+const jobColumns = `id, org_id, template_id, batch_id, COALESCE(batch_row, 0), created_by, status, output_asset_id, error_message, output_options, created_at, updated_at`
 
+func scanJob(row pgx.Row) (*model.GenerationJob, error) {
 	var job model.GenerationJob
-	var outputAssetID *uuid.UUID
 	var errMsg *string
 
-	if err := row.Scan(&job.ID, &job.OrgID, &job.TemplateID, &job.Status, &outputAssetID, &errMsg, &job.CreatedAt, &job.UpdatedAt); err != nil {
-		return nil, fmt.Errorf("failed to get job: %w", err)
+	if err := row.Scan(&job.ID, &job.OrgID, &job.TemplateID, &job.BatchID, &job.BatchRow, &job.CreatedBy, &job.Status, &job.OutputAssetID, &errMsg, &job.Output, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		return nil, err
 	}
-
-	job.OutputAssetID = outputAssetID
 	if errMsg != nil {
 		job.ErrorMessage = *errMsg
 	}
 	return &job, nil
 }
 
-func (r *PostgresRepository) UpdateJobStatus(ctx context.Context, id uuid.UUID, status string, outputAssetID *uuid.UUID, errMsg string) error {
-	query := `UPDATE generation_jobs SET status = $1, output_asset_id = $2, error_message = $3, updated_at = NOW() WHERE id = $4`
-	_, err := r.db.Exec(ctx, query, status, outputAssetID, errMsg, id)
+func (r *PostgresRepository) GetJob(ctx context.Context, orgID, id uuid.UUID) (*model.GenerationJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM generation_jobs WHERE id = $1 AND org_id = $2`
+	job, err := scanJob(r.db.QueryRow(ctx, query, id, orgID))
 	if err != nil {
-		return fmt.Errorf("failed to update job: %w", err)
+		return nil, fmt.Errorf("failed to get job: %w", notFound(err))
 	}
-	return nil
+	return job, nil
 }
 
-func (r *PostgresRepository) GetAsset(ctx context.Context, id uuid.UUID) (*model.Asset, error) {
-	query := `SELECT id, org_id, type, filename, content_type, size_bytes, s3_key, created_at FROM assets WHERE id = $1`
-	row := r.db.QueryRow(ctx, query, id)
+func (r *PostgresRepository) ListJobs(ctx context.Context, f model.JobFilter) ([]model.GenerationJob, int, error) {
+	where := []string{"org_id = $1"}
+	args := []any{f.OrgID}
+	add := func(clause string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.TemplateID != nil {
+		add("template_id = $%d", *f.TemplateID)
+	}
+	if f.BatchID != nil {
+		add("batch_id = $%d", *f.BatchID)
+	}
+	if f.CreatedBy != nil {
+		add("created_by = $%d", *f.CreatedBy)
+	}
+	if f.CreatedAfter != nil {
+		add("created_at >= $%d", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		add("created_at < $%d", *f.CreatedBefore)
+	}
+	cond := strings.Join(where, " AND ")
 
-	var a model.Asset
-	if err := row.Scan(&a.ID, &a.OrgID, &a.Type, &a.Filename, &a.ContentType, &a.SizeBytes, &a.S3Key, &a.CreatedAt); err != nil {
-		return nil, fmt.Errorf("failed to get asset: %w", err)
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM generation_jobs WHERE `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
 	}
-	return &a, nil
+
+	query := fmt.Sprintf(`SELECT %s FROM generation_jobs WHERE %s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`,
+		jobColumns, cond, len(args)+1, len(args)+2)
+	rows, err := r.db.Query(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []model.GenerationJob{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, total, rows.Err()
+}
+
+// UpdateJobStatus also notifies StatusEventsChannel of the transition.
+func (r *PostgresRepository) UpdateJobStatus(ctx context.Context, id uuid.UUID, status string, outputAssetID *uuid.UUID, errMsg string) error {
+	query := `UPDATE generation_jobs SET status = $1, output_asset_id = $2, error_message = $3, updated_at = NOW() WHERE id = $4
+			  RETURNING org_id, batch_id, updated_at`
+	ev := model.StatusEvent{Kind: model.StatusEventJob, ID: id, Status: status, OutputAssetID: outputAssetID, ErrorMessage: errMsg}
+	err := r.db.QueryRow(ctx, query, status, outputAssetID, errMsg, id).Scan(&ev.OrgID, &ev.BatchID, &ev.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", notFound(err))
+	}
+	r.notifyStatus(ctx, ev)
+	return nil
 }
 
 func (r *PostgresRepository) ListMemberships(ctx context.Context, userID uuid.UUID) ([]model.Membership, error) {
-	// We assume there's a memberships table.
-	// If not, we might need to check schema.
-	// Wait, the Summary says "PostgreSQL schema with tables for ... memberships"
-	query := `SELECT id, user_id, org_id, role, created_at FROM memberships WHERE user_id = $1`
+	query := `SELECT id, user_id, org_id, role, created_at FROM memberships WHERE user_id = $1 ORDER BY created_at, id`
 	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
 		return nil, err
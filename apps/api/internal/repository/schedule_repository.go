@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"template-builder-api/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const scheduleColumns = `id, org_id, template_id, name, cron_expr, timezone, version_policy, version, data_source, payload,
+	COALESCE(source_url, ''), source_asset_id, active, next_run_at, last_run_at, created_by, created_at, updated_at`
+
+func scanSchedule(row pgx.Row) (*model.Schedule, error) {
+	var s model.Schedule
+	if err := row.Scan(&s.ID, &s.OrgID, &s.TemplateID, &s.Name, &s.CronExpr, &s.Timezone, &s.VersionPolicy, &s.Version, &s.DataSource, &s.Payload,
+		&s.SourceURL, &s.SourceAssetID, &s.Active, &s.NextRunAt, &s.LastRunAt, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PostgresRepository) CreateSchedule(ctx context.Context, s *model.Schedule) error {
+	query := `INSERT INTO generation_schedules (id, org_id, template_id, name, cron_expr, timezone, version_policy, version, data_source, payload,
+			  source_url, source_asset_id, active, next_run_at, created_by, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13, $14, $15, $16, $17)`
+	_, err := r.db.Exec(ctx, query, s.ID, s.OrgID, s.TemplateID, s.Name, s.CronExpr, s.Timezone, s.VersionPolicy, s.Version, s.DataSource, s.Payload,
+		s.SourceURL, s.SourceAssetID, s.Active, s.NextRunAt, s.CreatedBy, s.CreatedAt, s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetSchedule(ctx context.Context, orgID, id uuid.UUID) (*model.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM generation_schedules WHERE id = $1 AND org_id = $2`
+	s, err := scanSchedule(r.db.QueryRow(ctx, query, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", notFound(err))
+	}
+	return s, nil
+}
+
+func (r *PostgresRepository) ListSchedules(ctx context.Context, orgID uuid.UUID) ([]model.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM generation_schedules WHERE org_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []model.Schedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateSchedule(ctx context.Context, s *model.Schedule) error {
+	query := `UPDATE generation_schedules
+			  SET template_id = $1, name = $2, cron_expr = $3, timezone = $4, version_policy = $5, version = $6, data_source = $7,
+			      payload = $8, source_url = NULLIF($9, ''), source_asset_id = $10, active = $11, next_run_at = $12, updated_at = NOW()
+			  WHERE id = $13 AND org_id = $14`
+	tag, err := r.db.Exec(ctx, query, s.TemplateID, s.Name, s.CronExpr, s.Timezone, s.VersionPolicy, s.Version, s.DataSource,
+		s.Payload, s.SourceURL, s.SourceAssetID, s.Active, s.NextRunAt, s.ID, s.OrgID)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteSchedule(ctx context.Context, orgID, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM generation_schedules WHERE id = $1 AND org_id = $2`, id, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// StartDueScheduleRuns records a pending run for up to limit schedules whose
+// next run is due and advances each to next(schedule), all in one
+// transaction, so a schedule fires at most once per slot even if the caller
+// dies before enqueueing anything. next returning nil deactivates the
+// schedule.
+func (r *PostgresRepository) StartDueScheduleRuns(ctx context.Context, limit int, next func(*model.Schedule) *time.Time) ([]model.Schedule, []model.ScheduleRun, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin schedule tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT ` + scheduleColumns + ` FROM generation_schedules
+			  WHERE active AND next_run_at <= NOW()
+			  ORDER BY next_run_at
+			  LIMIT $1
+			  FOR UPDATE SKIP LOCKED`
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load due schedules: %w", err)
+	}
+	var schedules []model.Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, *s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	runs := make([]model.ScheduleRun, 0, len(schedules))
+	for i := range schedules {
+		s := &schedules[i]
+		run := model.ScheduleRun{
+			ID:           uuid.New(),
+			OrgID:        s.OrgID,
+			ScheduleID:   s.ID,
+			ScheduledFor: *s.NextRunAt,
+			Status:       "pending",
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		s.LastRunAt, s.NextRunAt = &now, next(s)
+		s.Active = s.NextRunAt != nil
+
+		_, err := tx.Exec(ctx, `UPDATE generation_schedules SET next_run_at = $1, last_run_at = $2, active = $3, updated_at = NOW() WHERE id = $4`,
+			s.NextRunAt, s.LastRunAt, s.Active, s.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to advance schedule: %w", err)
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO schedule_runs (id, org_id, schedule_id, scheduled_for, status, created_at, updated_at)
+							   VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			run.ID, run.OrgID, run.ScheduleID, run.ScheduledFor, run.Status, run.CreatedAt, run.UpdatedAt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to record schedule run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit schedule runs: %w", err)
+	}
+	return schedules, runs, nil
+}
+
+func (r *PostgresRepository) FinishScheduleRun(ctx context.Context, run *model.ScheduleRun) error {
+	query := `UPDATE schedule_runs SET status = $1, job_id = $2, batch_id = $3, error_message = NULLIF($4, ''), updated_at = NOW()
+			  WHERE id = $5`
+	_, err := r.db.Exec(ctx, query, run.Status, run.JobID, run.BatchID, run.ErrorMessage, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule run: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListScheduleRuns(ctx context.Context, orgID, scheduleID uuid.UUID, limit, offset int) ([]model.ScheduleRun, error) {
+	query := `SELECT id, org_id, schedule_id, scheduled_for, status, job_id, batch_id, COALESCE(error_message, ''), created_at, updated_at
+			  FROM schedule_runs WHERE schedule_id = $1 AND org_id = $2
+			  ORDER BY scheduled_for DESC, id DESC LIMIT $3 OFFSET $4`
+	rows, err := r.db.Query(ctx, query, scheduleID, orgID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []model.ScheduleRun{}
+	for rows.Next() {
+		var run model.ScheduleRun
+		if err := rows.Scan(&run.ID, &run.OrgID, &run.ScheduleID, &run.ScheduledFor, &run.Status, &run.JobID, &run.BatchID,
+			&run.ErrorMessage, &run.CreatedAt, &run.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetLatestPublishedVersion returns the highest published version of a
+// template, or 0 if none is published.
+func (r *PostgresRepository) GetLatestPublishedVersion(ctx context.Context, orgID, templateID uuid.UUID) (int, error) {
+	query := `SELECT COALESCE(MAX(v.version), 0) FROM template_versions v JOIN templates t ON t.id = v.template_id
+			  WHERE v.template_id = $1 AND t.org_id = $2 AND v.status = 'published'`
+	var version int
+	if err := r.db.QueryRow(ctx, query, templateID, orgID).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get published version: %w", err)
+	}
+	return version, nil
+}
+
+// LeaderLock is a session-level advisory lock taken on a dedicated
+// connection, outside the pool, which is kept between attempts so trying
+// for the lock costs one query rather than a connection. Postgres releases
+// the lock if the connection drops, so holders must Check it before acting
+// as leader. The connection only runs lock queries. A LeaderLock is not
+// safe for concurrent use.
+type LeaderLock struct {
+	config *pgx.ConnConfig
+	key    int64
+	conn   *pgx.Conn
+}
+
+// LeaderLock returns advisory lock key, not yet taken.
+func (r *PostgresRepository) LeaderLock(key int64) *LeaderLock {
+	return &LeaderLock{config: r.db.Config().ConnConfig.Copy(), key: key}
+}
+
+// TryAcquire takes the lock without waiting, connecting first if need be.
+// It reports false when another session holds it.
+func (l *LeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	if l.conn == nil || l.conn.IsClosed() {
+		conn, err := pgx.ConnectConfig(ctx, l.config)
+		if err != nil {
+			return false, fmt.Errorf("failed to open lock connection: %w", err)
+		}
+		l.conn = conn
+	}
+	var locked bool
+	if err := l.conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&locked); err != nil {
+		l.Close()
+		return false, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	return locked, nil
+}
+
+// Check verifies the session holding the lock is still alive.
+func (l *LeaderLock) Check(ctx context.Context) error {
+	if l.conn == nil {
+		return errors.New("lock connection closed")
+	}
+	if err := l.conn.Ping(ctx); err != nil {
+		l.Close()
+		return err
+	}
+	return nil
+}
+
+// Release gives the lock up, keeping the connection for the next attempt.
+func (l *LeaderLock) Release(ctx context.Context) {
+	if l.conn == nil {
+		return
+	}
+	if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		// Closing the session drops the lock as well
+		l.Close()
+	}
+}
+
+// Close closes the connection, dropping the lock if it is held.
+func (l *LeaderLock) Close() {
+	if l.conn != nil {
+		l.conn.Close(context.Background())
+		l.conn = nil
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"template-builder-api/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const signingCertificateColumns = `id, org_id, name, subject, issuer, serial_number, fingerprint, not_before, not_after,
+	created_by, created_at`
+
+func scanSigningCertificate(row pgx.Row, extra ...any) (*model.SigningCertificate, error) {
+	var c model.SigningCertificate
+	dest := []any{&c.ID, &c.OrgID, &c.Name, &c.Subject, &c.Issuer, &c.SerialNumber, &c.Fingerprint, &c.NotBefore, &c.NotAfter,
+		&c.CreatedBy, &c.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *PostgresRepository) CreateSigningCertificate(ctx context.Context, c *model.SigningCertificate) error {
+	query := `INSERT INTO signing_certificates (id, org_id, name, subject, issuer, serial_number, fingerprint,
+			  not_before, not_after, chain, sealed_key, created_by, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := r.db.Exec(ctx, query, c.ID, c.OrgID, c.Name, c.Subject, c.Issuer, c.SerialNumber, c.Fingerprint,
+		c.NotBefore, c.NotAfter, c.Chain, c.SealedKey, c.CreatedBy, c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create signing certificate: %w", err)
+	}
+	return nil
+}
+
+// GetSigningCertificate returns the certificate with its chain and sealed
+// key.
+func (r *PostgresRepository) GetSigningCertificate(ctx context.Context, orgID, id uuid.UUID) (*model.SigningCertificate, error) {
+	query := `SELECT ` + signingCertificateColumns + `, chain, sealed_key FROM signing_certificates WHERE id = $1 AND org_id = $2`
+	var chain [][]byte
+	var sealed []byte
+	c, err := scanSigningCertificate(r.db.QueryRow(ctx, query, id, orgID), &chain, &sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing certificate: %w", notFound(err))
+	}
+	c.Chain, c.SealedKey = chain, sealed
+	return c, nil
+}
+
+func (r *PostgresRepository) ListSigningCertificates(ctx context.Context, orgID uuid.UUID) ([]model.SigningCertificate, error) {
+	query := `SELECT ` + signingCertificateColumns + ` FROM signing_certificates WHERE org_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing certificates: %w", err)
+	}
+	defer rows.Close()
+
+	certs := []model.SigningCertificate{}
+	for rows.Next() {
+		c, err := scanSigningCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan signing certificate: %w", err)
+		}
+		certs = append(certs, *c)
+	}
+	return certs, rows.Err()
+}
+
+// FindSigningCertificates returns the org's certificates with the given
+// fingerprint, without keys.
+func (r *PostgresRepository) FindSigningCertificates(ctx context.Context, orgID uuid.UUID, fingerprint string) ([]model.SigningCertificate, error) {
+	query := `SELECT ` + signingCertificateColumns + ` FROM signing_certificates WHERE org_id = $1 AND fingerprint = $2
+			  ORDER BY created_at`
+	rows, err := r.db.Query(ctx, query, orgID, fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find signing certificates: %w", err)
+	}
+	defer rows.Close()
+
+	var certs []model.SigningCertificate
+	for rows.Next() {
+		c, err := scanSigningCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan signing certificate: %w", err)
+		}
+		certs = append(certs, *c)
+	}
+	return certs, rows.Err()
+}
+
+func (r *PostgresRepository) DeleteSigningCertificate(ctx context.Context, orgID, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM signing_certificates WHERE id = $1 AND org_id = $2`, id, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete signing certificate: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
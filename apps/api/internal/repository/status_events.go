@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"template-builder-api/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StatusEventsChannel is the NOTIFY channel carrying model.StatusEvent JSON.
+const StatusEventsChannel = "status_events"
+
+// NOTIFY payloads are capped at 8000 bytes by Postgres.
+const maxEventErrorLen = 1000
+
+// notifyStatus announces a state change to listening API instances. It is
+// best effort: the status row is already written, and subscribers re-read
+// it periodically, so a lost notification only delays the update.
+func (r *PostgresRepository) notifyStatus(ctx context.Context, ev model.StatusEvent) {
+	if len(ev.ErrorMessage) > maxEventErrorLen {
+		ev.ErrorMessage = ev.ErrorMessage[:maxEventErrorLen]
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	r.db.Exec(ctx, `SELECT pg_notify($1, $2)`, StatusEventsChannel, string(payload))
+}
+
+// Listen holds a dedicated connection LISTENing on channel and calls handle
+// for every notification until ctx is cancelled or the connection fails.
+// ready is called once the LISTEN is in place.
+func (r *PostgresRepository) Listen(ctx context.Context, channel string, ready func(), handle func(payload string)) error {
+	pc, err := r.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listen connection: %w", err)
+	}
+	// A listening connection must not go back to the pool.
+	conn := pc.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+	ready()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle(n.Payload)
+	}
+}
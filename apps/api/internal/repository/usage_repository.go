@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"template-builder-api/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AddUsage adds every delta to its daily counter.
+func (r *PostgresRepository) AddUsage(ctx context.Context, deltas []model.UsageDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	orgIDs := make([]uuid.UUID, len(deltas))
+	days := make([]time.Time, len(deltas))
+	metrics := make([]string, len(deltas))
+	values := make([]int64, len(deltas))
+	for i, d := range deltas {
+		orgIDs[i], days[i], metrics[i], values[i] = d.OrgID, d.Day, d.Metric, d.Value
+	}
+
+	query := `INSERT INTO usage_daily (org_id, day, metric, value)
+			  SELECT * FROM unnest($1::uuid[], $2::date[], $3::varchar[], $4::bigint[])
+			  ON CONFLICT (org_id, day, metric) DO UPDATE
+			  SET value = usage_daily.value + EXCLUDED.value, updated_at = NOW()`
+	if _, err := r.db.Exec(ctx, query, orgIDs, days, metrics, values); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// ListDailyUsage returns the rollup rows for days in [from, to].
+func (r *PostgresRepository) ListDailyUsage(ctx context.Context, orgID uuid.UUID, from, to time.Time) ([]model.UsageDay, error) {
+	query := `SELECT day, metric, value FROM usage_daily
+			  WHERE org_id = $1 AND day BETWEEN $2::date AND $3::date
+			  ORDER BY day, metric`
+	rows, err := r.db.Query(ctx, query, orgID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	defer rows.Close()
+
+	var days []model.UsageDay
+	for rows.Next() {
+		var d model.UsageDay
+		if err := rows.Scan(&d.Day, &d.Metric, &d.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// SumUsage totals each metric over days in [from, to].
+func (r *PostgresRepository) SumUsage(ctx context.Context, orgID uuid.UUID, from, to time.Time) (map[string]int64, error) {
+	query := `SELECT metric, SUM(value)::bigint FROM usage_daily
+			  WHERE org_id = $1 AND day BETWEEN $2::date AND $3::date
+			  GROUP BY metric`
+	rows, err := r.db.Query(ctx, query, orgID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum usage: %w", err)
+	}
+	defer rows.Close()
+
+	totals := map[string]int64{}
+	for rows.Next() {
+		var metric string
+		var value int64
+		if err := rows.Scan(&metric, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		totals[metric] = value
+	}
+	return totals, rows.Err()
+}
+
+func (r *PostgresRepository) GetOrgPlan(ctx context.Context, orgID uuid.UUID) (*model.Plan, error) {
+	query := `SELECT p.id, p.name, p.max_documents, p.max_pages, p.max_render_seconds, p.max_storage_bytes, p.max_api_calls_per_day, p.max_upload_bytes
+			  FROM orgs o JOIN plans p ON p.id = o.plan_id WHERE o.id = $1`
+	var p model.Plan
+	err := r.db.QueryRow(ctx, query, orgID).Scan(&p.ID, &p.Name, &p.MaxDocuments, &p.MaxPages, &p.MaxRenderSeconds, &p.MaxStorageBytes, &p.MaxAPICallsPerDay, &p.MaxUploadBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get org plan: %w", notFound(err))
+	}
+	return &p, nil
+}
+
+// GetStorageBytes returns the total size of the org's assets.
+func (r *PostgresRepository) GetStorageBytes(ctx context.Context, orgID uuid.UUID) (int64, error) {
+	var total int64
+	err := r.db.QueryRow(ctx, `SELECT COALESCE(SUM(size_bytes), 0)::bigint FROM assets WHERE org_id = $1`, orgID).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+	return total, nil
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"template-builder-api/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const webhookEndpointColumns = `id, org_id, url, description, events, active, created_at, updated_at`
+
+func scanWebhookEndpoint(row pgx.Row) (*model.WebhookEndpoint, error) {
+	var e model.WebhookEndpoint
+	if err := row.Scan(&e.ID, &e.OrgID, &e.URL, &e.Description, &e.Events, &e.Active, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *PostgresRepository) CreateWebhookEndpoint(ctx context.Context, e *model.WebhookEndpoint) error {
+	query := `INSERT INTO webhook_endpoints (id, org_id, url, description, secret, events, active, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := r.db.Exec(ctx, query, e.ID, e.OrgID, e.URL, e.Description, e.Secret, e.Events, e.Active, e.CreatedAt, e.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetWebhookEndpoint(ctx context.Context, orgID, id uuid.UUID) (*model.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE id = $1 AND org_id = $2`
+	e, err := scanWebhookEndpoint(r.db.QueryRow(ctx, query, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", notFound(err))
+	}
+	return e, nil
+}
+
+func (r *PostgresRepository) ListWebhookEndpoints(ctx context.Context, orgID uuid.UUID) ([]model.WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE org_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := []model.WebhookEndpoint{}
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, *e)
+	}
+	return endpoints, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateWebhookEndpoint(ctx context.Context, e *model.WebhookEndpoint) error {
+	query := `UPDATE webhook_endpoints SET url = $1, description = $2, events = $3, active = $4, updated_at = NOW()
+			  WHERE id = $5 AND org_id = $6`
+	tag, err := r.db.Exec(ctx, query, e.URL, e.Description, e.Events, e.Active, e.ID, e.OrgID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook endpoint: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteWebhookEndpoint(ctx context.Context, orgID, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 AND org_id = $2`, id, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListWebhookEndpointsForEvent returns the active endpoints, with secrets,
+// that subscribe to eventType.
+func (r *PostgresRepository) ListWebhookEndpointsForEvent(ctx context.Context, orgID uuid.UUID, eventType string) ([]model.WebhookEndpoint, error) {
+	query := `SELECT id, org_id, url, secret FROM webhook_endpoints
+			  WHERE org_id = $1 AND active AND $2 = ANY(events)`
+	rows, err := r.db.Query(ctx, query, orgID, eventType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []model.WebhookEndpoint
+	for rows.Next() {
+		var e model.WebhookEndpoint
+		if err := rows.Scan(&e.ID, &e.OrgID, &e.URL, &e.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, rows.Err()
+}
+
+const webhookDeliveryColumns = `id, org_id, endpoint_id, event_id, event_type, payload, status, attempts,
+	response_code, COALESCE(response_body, ''), COALESCE(error_message, ''), next_attempt_at, delivered_at, created_at, updated_at`
+
+func scanWebhookDelivery(row pgx.Row, extra ...any) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	dest := []any{&d.ID, &d.OrgID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.ResponseCode, &d.ResponseBody, &d.ErrorMessage, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *PostgresRepository) CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	_, err := r.db.CopyFrom(ctx,
+		pgx.Identifier{"webhook_deliveries"},
+		[]string{"id", "org_id", "endpoint_id", "event_id", "event_type", "payload", "status", "attempts", "next_attempt_at", "created_at", "updated_at"},
+		pgx.CopyFromSlice(len(deliveries), func(i int) ([]any, error) {
+			d := deliveries[i]
+			return []any{d.ID, d.OrgID, d.EndpointID, d.EventID, d.EventType, d.Payload, d.Status, d.Attempts, d.NextAttemptAt, d.CreatedAt, d.UpdatedAt}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetWebhookDelivery(ctx context.Context, orgID, id uuid.UUID) (*model.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND org_id = $2`
+	d, err := scanWebhookDelivery(r.db.QueryRow(ctx, query, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", notFound(err))
+	}
+	return d, nil
+}
+
+func (r *PostgresRepository) ListWebhookDeliveries(ctx context.Context, orgID, endpointID uuid.UUID, limit, offset int) ([]model.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+			  WHERE endpoint_id = $1 AND org_id = $2 ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4`
+	rows, err := r.db.Query(ctx, query, endpointID, orgID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ClaimDueWebhookDeliveries leases up to limit pending deliveries whose
+// attempt is due by pushing their next attempt lease into the future and
+// counting the attempt. SKIP LOCKED lets several workers dispatch at once.
+// Due deliveries to endpoints deactivated since they were queued fail
+// instead of being claimed.
+func (r *PostgresRepository) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	query := `WITH due AS (
+				SELECT d.id, e.active FROM webhook_deliveries d
+				JOIN webhook_endpoints e ON e.id = d.endpoint_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= NOW()
+				ORDER BY d.next_attempt_at
+				LIMIT $1
+				FOR UPDATE OF d SKIP LOCKED
+			  ), inactive AS (
+				UPDATE webhook_deliveries d
+				SET status = 'failed', error_message = 'endpoint is inactive', next_attempt_at = NULL, updated_at = NOW()
+				FROM due WHERE d.id = due.id AND NOT due.active
+			  ), claimed AS (
+				UPDATE webhook_deliveries d
+				SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2), updated_at = NOW()
+				FROM due WHERE d.id = due.id AND due.active
+				RETURNING d.*
+			  )
+			  SELECT ` + webhookDeliveryColumns + `,
+				(SELECT e.url FROM webhook_endpoints e WHERE e.id = claimed.endpoint_id),
+				(SELECT e.secret FROM webhook_endpoints e WHERE e.id = claimed.endpoint_id)
+			  FROM claimed`
+	rows, err := r.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var url, secret string
+		d, err := scanWebhookDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.URL, d.Secret = url, secret
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt stores the outcome of one delivery attempt.
+// nextAttemptAt is nil once the delivery is final.
+func (r *PostgresRepository) RecordWebhookAttempt(ctx context.Context, d *model.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries
+			  SET status = $1, response_code = $2, response_body = $3, error_message = $4,
+			      next_attempt_at = $5, delivered_at = $6, updated_at = NOW()
+			  WHERE id = $7`
+	_, err := r.db.Exec(ctx, query, d.Status, d.ResponseCode, d.ResponseBody, d.ErrorMessage, d.NextAttemptAt, d.DeliveredAt, d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
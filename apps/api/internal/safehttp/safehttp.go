@@ -0,0 +1,129 @@
+// Package safehttp makes requests to URLs tenants supply (webhook
+// endpoints, scheduled data sources) without letting them reach the
+// service's own network: loopback, private, link-local and similar
+// addresses are refused when connecting, which also covers redirects and
+// names that resolve differently from one lookup to the next.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrInvalidURL is returned by CheckURL.
+	ErrInvalidURL = errors.New("safehttp: invalid URL")
+	// ErrForbiddenAddress is returned when connecting to an address that
+	// isn't public.
+	ErrForbiddenAddress = errors.New("safehttp: address not allowed")
+)
+
+// maxRedirects is how many redirects Client follows; each is checked like
+// the first request.
+const maxRedirects = 5
+
+// Client is the shared client for tenant-supplied URLs. It has no overall
+// timeout; callers bound each request with its context.
+var Client = NewClient()
+
+// NewClient returns a client that only connects to public addresses over
+// https and ignores proxy settings, which would otherwise be dialled in
+// place of the target.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if _, err := CheckURL(req.URL.String()); err != nil {
+				return fmt.Errorf("redirect refused: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// control runs once the address has been resolved, just before connecting.
+func control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if !Allowed(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ap.Addr())
+	}
+	return nil
+}
+
+// reserved are ranges outside the standard library's classifications that
+// are still not the public internet.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which reaches IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2002::/16"),       // 6to4, likewise
+	netip.MustParsePrefix("2001::/32"),       // Teredo
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+}
+
+// Allowed reports whether ip is a public unicast address.
+func Allowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		// IsGlobalUnicast excludes loopback, link-local, multicast and
+		// unspecified addresses
+		return false
+	}
+	for _, p := range reserved {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL parses an absolute https URL, refusing hosts that are obviously
+// internal. Names are only checked when connecting.
+func CheckURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%w: must be an absolute https URL", ErrInvalidURL)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return nil, fmt.Errorf("%w: %s is not a public host", ErrInvalidURL, host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !Allowed(ip) {
+		return nil, fmt.Errorf("%w: %s is not a public address", ErrInvalidURL, host)
+	}
+	return u, nil
+}
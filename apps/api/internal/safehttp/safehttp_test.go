@@ -0,0 +1,97 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+	for _, tt := range tests {
+		if got := Allowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://hooks.example.com/path?q=1", true},
+		{"https://93.184.216.34:8443/", true},
+		{"http://hooks.example.com/", false},
+		{"ftp://hooks.example.com/", false},
+		{"/relative", false},
+		{"https://localhost/", false},
+		{"https://api.localhost./", false},
+		{"https://127.0.0.1/", false},
+		{"https://[::1]:8080/", false},
+		{"https://169.254.169.254/latest/meta-data/", false},
+		{"https://10.0.0.5/", false},
+	}
+	for _, tt := range tests {
+		_, err := CheckURL(tt.url)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%q) error = %v, want ok %v", tt.url, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("CheckURL(%q) error = %v, want ErrInvalidURL", tt.url, err)
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+
+	// The check is made when connecting, so it holds whatever the URL
+	// says; trust the test server's certificate to get that far.
+	client := NewClient()
+	client.Transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Get(%s) error = %v, want ErrForbiddenAddress", srv.URL, err)
+	}
+}
+
+func TestClientRechecksRedirects(t *testing.T) {
+	client := NewClient()
+	req, _ := http.NewRequest(http.MethodGet, "https://hooks.example.com/", nil)
+	for _, target := range []string{"http://hooks.example.com/", "https://169.254.169.254/"} {
+		next, _ := http.NewRequest(http.MethodGet, target, nil)
+		err := client.CheckRedirect(next, []*http.Request{req})
+		if err == nil || !strings.Contains(err.Error(), "redirect refused") {
+			t.Errorf("redirect to %s: error = %v, want refused", target, err)
+		}
+	}
+}
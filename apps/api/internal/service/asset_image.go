@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"strings"
+	"template-builder-api/internal/imaging"
+	"template-builder-api/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RenditionAuto picks the smallest rendition that prints an image element
+// at autoRenditionScale device pixels per CSS pixel.
+const RenditionAuto = "auto"
+
+// autoRenditionScale is 2x, about 190 DPI on paper.
+const autoRenditionScale = 2
+
+// decodedImage is an uploaded raster image, decoded to make renditions.
+type decodedImage struct {
+	img  image.Image
+	info imaging.Info
+}
+
+// isRaster reports whether renditions are made for a content type.
+func isRaster(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") && contentType != "image/svg+xml"
+}
+
+// decodeImage refuses images that can't be decoded or are too large to.
+func decodeImage(data []byte) (*decodedImage, error) {
+	img, info, err := imaging.Decode(data)
+	if errors.Is(err, imaging.ErrTooLarge) {
+		return nil, fmt.Errorf("%w: images are limited to %d pixels", ErrAssetTooLarge, imaging.MaxPixels)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: image could not be decoded", ErrInvalidAsset)
+	}
+	return &decodedImage{img: img, info: info}, nil
+}
+
+// addRenditions records an image's dimensions on its asset, and stores
+// its renditions beside it.
+func (s *AssetService) addRenditions(ctx context.Context, asset *model.Asset, d *decodedImage) error {
+	asset.Width, asset.Height, asset.DPI = d.info.Width, d.info.Height, d.info.DPI
+
+	renditions, err := imaging.Renditions(d.img)
+	if err != nil {
+		return err
+	}
+	asset.Renditions = make([]model.AssetRendition, 0, len(renditions))
+	for _, r := range renditions {
+		rendition := model.AssetRendition{
+			Name:        r.Name,
+			Width:       r.Width,
+			Height:      r.Height,
+			ContentType: r.ContentType,
+			SizeBytes:   int64(len(r.Data)),
+		}
+		key := renditionKey(asset, &rendition)
+		if _, err := s.store.Put(ctx, key, bytes.NewReader(r.Data), rendition.SizeBytes, r.ContentType); err != nil {
+			s.removeRenditions(ctx, asset)
+			return fmt.Errorf("failed to store %s rendition: %w", r.Name, err)
+		}
+		asset.Renditions = append(asset.Renditions, rendition)
+	}
+	return nil
+}
+
+// renditionKey is where a rendition is stored: beside its asset, under the
+// asset's ID.
+func renditionKey(asset *model.Asset, r *model.AssetRendition) string {
+	ext := ".png"
+	if r.ContentType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	return fmt.Sprintf("%s/%s/%s%s", asset.OrgID, asset.ID, r.Name, ext)
+}
+
+func (s *AssetService) removeRenditions(ctx context.Context, asset *model.Asset) {
+	for i := range asset.Renditions {
+		key := renditionKey(asset, &asset.Renditions[i])
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete object %s of asset %s: %v", key, asset.ID, err)
+		}
+	}
+}
+
+// ImageURL returns a URL the renderer can fetch an org's image asset from,
+// valid for expiry. rendition names one of the asset's renditions, or is
+// RenditionAuto to pick the smallest at least autoRenditionScale times
+// widthPx wide; the original is used when it's empty, or no rendition is
+// large enough.
+func (s *AssetService) ImageURL(ctx context.Context, orgID, assetID uuid.UUID, rendition string, widthPx float64, expiry time.Duration) (string, error) {
+	asset, err := s.repo.GetAsset(ctx, orgID, assetID)
+	if err != nil {
+		return "", err
+	}
+	if asset.Type != model.AssetTypeImage {
+		return "", fmt.Errorf("%w: asset %s is not an image", ErrInvalidAsset, assetID)
+	}
+
+	var r *model.AssetRendition
+	switch rendition {
+	case "":
+	case RenditionAuto:
+		// Renditions are stored largest first
+		for i := len(asset.Renditions) - 1; i >= 0; i-- {
+			if float64(asset.Renditions[i].Width) >= widthPx*autoRenditionScale {
+				r = &asset.Renditions[i]
+				break
+			}
+		}
+	default:
+		if r = asset.Rendition(rendition); r == nil && !knownRendition(rendition) {
+			return "", fmt.Errorf("%w: unknown rendition %q", ErrInvalidAsset, rendition)
+		}
+		// Images smaller than a size have no rendition of it; the original
+		// is no larger.
+	}
+	if r == nil {
+		return s.store.PresignGet(ctx, asset.S3Key, expiry)
+	}
+	return s.store.PresignGet(ctx, renditionKey(asset, r), expiry)
+}
+
+func knownRendition(name string) bool {
+	for _, size := range imaging.Sizes {
+		if size.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/svg"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var (
+	// ErrUnsupportedAsset means an upload's content isn't an allowed type.
+	ErrUnsupportedAsset = errors.New("unsupported file type")
+	// ErrAssetTooLarge means an upload is over its type's or the plan's limit.
+	ErrAssetTooLarge = errors.New("file too large")
+)
+
+// sniffBytes is how much of an upload is read to detect its type. DOCX is
+// recognised from the names of the entries at the start of the zip, which
+// don't always fit in mimetype's default 3KB.
+const sniffBytes = 16 << 10
+
+func init() {
+	mimetype.SetLimit(sniffBytes)
+}
+
+// assetRule allows one content type, stored as an asset type, from files
+// with one of the extensions, up to a size.
+type assetRule struct {
+	ContentType string
+	Type        string
+	Extensions  []string
+	MaxBytes    int64
+}
+
+// assetRules is the upload allowlist.
+var assetRules = []assetRule{
+	{"image/png", model.AssetTypeImage, []string{".png"}, 25 << 20},
+	{"image/jpeg", model.AssetTypeImage, []string{".jpg", ".jpeg"}, 25 << 20},
+	{"image/gif", model.AssetTypeImage, []string{".gif"}, 10 << 20},
+	{"image/webp", model.AssetTypeImage, []string{".webp"}, 25 << 20},
+	// SVGs are read whole to be sanitised.
+	{"image/svg+xml", model.AssetTypeImage, []string{".svg"}, 2 << 20},
+	{"font/ttf", model.AssetTypeFont, []string{".ttf"}, 10 << 20},
+	{"font/otf", model.AssetTypeFont, []string{".otf"}, 10 << 20},
+	{"font/woff", model.AssetTypeFont, []string{".woff"}, 10 << 20},
+	{"font/woff2", model.AssetTypeFont, []string{".woff2"}, 10 << 20},
+	{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", model.AssetTypeDocx, []string{".docx"}, 50 << 20},
+	{"application/pdf", model.AssetTypePDF, []string{".pdf"}, 100 << 20},
+	{"text/csv", model.AssetTypeCSV, []string{".csv"}, 50 << 20},
+}
+
+// allows reports whether filename's extension suits the rule's type.
+func (r *assetRule) allows(filename string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, e := range r.Extensions {
+		if e == ext {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s files must have the extension %s, not %q",
+		ErrInvalidAsset, r.ContentType, strings.Join(r.Extensions, " or "), ext)
+}
+
+func findAssetRule(contentType string) *assetRule {
+	for i := range assetRules {
+		if assetRules[i].ContentType == contentType {
+			return &assetRules[i]
+		}
+	}
+	return nil
+}
+
+// assetTypeFor maps a content type to its asset type, for documents the
+// service generates itself.
+func assetTypeFor(contentType string) string {
+	if rule := findAssetRule(contentType); rule != nil {
+		return rule.Type
+	}
+	return model.AssetTypeDocument
+}
+
+// sniffedAsset is an upload whose type has been detected from its content.
+type sniffedAsset struct {
+	rule *assetRule
+	body io.Reader
+	size int64
+	// rewritten is set if body differs from what was uploaded.
+	rewritten bool
+}
+
+// sniffAsset detects an upload's type from its first bytes, whatever the
+// client claimed, and checks it against the allowlist, the filename's
+// extension and the type's size limit. size is -1 if unknown; the body is
+// then cut off past the limit. SVGs are sanitised, which changes their
+// size.
+func sniffAsset(r io.Reader, filename string, size int64) (*sniffedAsset, error) {
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	head = head[:n]
+	if n == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidAsset)
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	detected := mimetype.Detect(head)
+	contentType := detected.String()
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	// CSV detection needs rows of equal width; a .csv that is otherwise
+	// plain text is taken as one.
+	if ext == ".csv" && detected.Is("text/plain") {
+		contentType = "text/csv"
+	}
+
+	rule := findAssetRule(contentType)
+	if rule == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAsset, contentType)
+	}
+	if err := rule.allows(filename); err != nil {
+		return nil, err
+	}
+	if size > rule.MaxBytes {
+		return nil, fmt.Errorf("%w: %s files are limited to %d bytes", ErrAssetTooLarge, rule.Type, rule.MaxBytes)
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), r)
+	if contentType == "image/svg+xml" {
+		data, err := io.ReadAll(&limitReader{r: body, n: rule.MaxBytes, limit: rule.MaxBytes})
+		if err != nil {
+			return nil, err
+		}
+		if data, err = svg.Sanitize(data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAsset, err)
+		}
+		return &sniffedAsset{rule: rule, body: bytes.NewReader(data), size: int64(len(data)), rewritten: true}, nil
+	}
+	if size < 0 {
+		body = &limitReader{r: body, n: rule.MaxBytes, limit: rule.MaxBytes}
+	}
+	return &sniffedAsset{rule: rule, body: body, size: size}, nil
+}
+
+// limitReader fails with ErrAssetTooLarge once more than limit bytes are
+// read, rather than stopping quietly as io.LimitReader does.
+type limitReader struct {
+	r     io.Reader
+	n     int64 // bytes left
+	limit int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, fmt.Errorf("%w: over %d bytes", ErrAssetTooLarge, l.limit)
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, fmt.Errorf("%w: over %d bytes", ErrAssetTooLarge, l.limit)
+	}
+	return n, err
+}
@@ -1,101 +1,313 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"path"
 	"path/filepath"
+	"strings"
 	"template-builder-api/internal/model"
 	"template-builder-api/internal/repository"
+	"template-builder-api/internal/storage"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// AssetBucket is the bucket assets and generated documents are stored in.
+const AssetBucket = "assets"
+
+// Library limits
+const (
+	maxAssetFilename = 255
+	maxAssetFolder   = 512
+	maxAssetTags     = 20
+	maxAssetTag      = 50
+)
+
+// ErrInvalidAsset wraps problems with a rename, folder or tags.
+var ErrInvalidAsset = errors.New("invalid asset")
+
 type AssetService struct {
-	repo        repository.Repository
-	minioClient *minio.Client
-	bucketName  string
+	repo  repository.Repository
+	store storage.Blob
 }
 
-func NewAssetService(repo repository.Repository, endpoint, accessKey, secretKey string) (*AssetService, error) {
-	minioClient, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: false, // For MVP local minio
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to init minio: %w", err)
-	}
-
-	return &AssetService{
-		repo:        repo,
-		minioClient: minioClient,
-		bucketName:  "assets",
-	}, nil
+func NewAssetService(repo repository.Repository, store storage.Blob) *AssetService {
+	return &AssetService{repo: repo, store: store}
 }
 
 func (s *AssetService) EnsureBucket(ctx context.Context) error {
-	exists, err := s.minioClient.BucketExists(ctx, s.bucketName)
+	return storage.Ensure(ctx, s.store)
+}
+
+// UploadFile stores a file a user uploaded, typed by its content rather
+// than by what the client claimed. It fails with ErrUnsupportedAsset,
+// ErrInvalidAsset or ErrAssetTooLarge for files the upload policy refuses.
+func (s *AssetService) UploadFile(ctx context.Context, orgID uuid.UUID, file io.Reader, filename string, size int64) (*model.Asset, error) {
+	sniffed, err := sniffAsset(file, filename, size)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !exists {
-		return s.minioClient.MakeBucket(ctx, s.bucketName, minio.MakeBucketOptions{})
+	body, size := sniffed.body, sniffed.size
+	var img *decodedImage
+	if isRaster(sniffed.rule.ContentType) {
+		// Images are decoded for their renditions, so held whole
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upload: %w", err)
+		}
+		if img, err = decodeImage(data); err != nil {
+			return nil, err
+		}
+		body, size = bytes.NewReader(data), int64(len(data))
 	}
-	return nil
+	return s.save(ctx, orgID, body, filename, size, sniffed.rule.Type, sniffed.rule.ContentType, img)
 }
 
+// UploadAsset streams a document the service generated to storage and
+// records it. A size of -1 means unknown, e.g. a document still being
+// rendered; it is uploaded in parts.
 func (s *AssetService) UploadAsset(ctx context.Context, orgID uuid.UUID, file io.Reader, filename string, size int64, contentType string) (*model.Asset, error) {
+	return s.save(ctx, orgID, file, filename, size, assetTypeFor(contentType), contentType, nil)
+}
+
+// assetKey is where the file of an asset is stored.
+func assetKey(orgID, assetID uuid.UUID, filename string) string {
+	return fmt.Sprintf("%s/%s%s", orgID, assetID, filepath.Ext(filename))
+}
+
+// save uploads and records an asset, and img's renditions if it is an
+// image. The stored size and checksum are computed from what was read.
+func (s *AssetService) save(ctx context.Context, orgID uuid.UUID, file io.Reader, filename string, size int64, assetType, contentType string, img *decodedImage) (*model.Asset, error) {
 	// 1. Generate unique key
-	ext := filepath.Ext(filename)
 	assetID := uuid.New()
-	s3Key := fmt.Sprintf("%s/%s%s", orgID.String(), assetID.String(), ext)
+	s3Key := assetKey(orgID, assetID, filename)
 
-	// 2. Upload to MinIO
-	_, err := s.minioClient.PutObject(ctx, s.bucketName, s3Key, file, size, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload to minio: %w", err)
+	// 2. Upload, hashing and counting on the way
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if _, err := s.store.Put(ctx, s3Key, io.TeeReader(file, io.MultiWriter(hash, counter)), size, contentType); err != nil {
+		return nil, err
 	}
 
 	// 3. Store Metadata
 	asset := &model.Asset{
-		ID:          assetID,
-		OrgID:       orgID,
-		Type:        contentType, // Simplify Type mapping
-		Filename:    filename,
-		ContentType: contentType,
-		SizeBytes:   size,
-		S3Key:       s3Key,
-		CreatedAt:   time.Now(),
+		ID:             assetID,
+		OrgID:          orgID,
+		Type:           assetType,
+		Filename:       filename,
+		ContentType:    contentType,
+		SizeBytes:      counter.n,
+		ChecksumSHA256: hex.EncodeToString(hash.Sum(nil)),
+		S3Key:          s3Key,
+		CreatedAt:      time.Now(),
+	}
+	if img != nil {
+		if err := s.addRenditions(ctx, asset, img); err != nil {
+			s.store.Delete(ctx, s3Key)
+			return nil, err
+		}
 	}
 
 	if err := s.repo.CreateAsset(ctx, asset); err != nil {
+		s.store.Delete(ctx, s3Key)
+		s.removeRenditions(ctx, asset)
 		return nil, err
 	}
 
 	// 4. Generate Presigned URL for response
-	url, err := s.minioClient.PresignedGetObject(ctx, s.bucketName, s3Key, 24*time.Hour, nil)
-	if err == nil {
-		asset.URL = url.String()
+	s.presign(ctx, asset, 24*time.Hour)
+
+	return asset, nil
+}
+
+// OpenAsset streams an org's asset from storage. The caller must close it.
+func (s *AssetService) OpenAsset(ctx context.Context, orgID, assetID uuid.UUID) (io.ReadCloser, *model.Asset, error) {
+	asset, err := s.repo.GetAsset(ctx, orgID, assetID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, _, err := s.store.Get(ctx, asset.S3Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, asset, nil
+}
+
+// openObject streams an object by storage key, for callers that already
+// hold an org-scoped asset row.
+func (s *AssetService) openObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, _, err := s.store.Get(ctx, key)
+	return obj, err
+}
+
+// ListAssets returns a page of an org's assets, each with a fresh download
+// URL, and how many match in total.
+func (s *AssetService) ListAssets(ctx context.Context, filter model.AssetFilter) ([]model.Asset, int, error) {
+	if filter.Folder != nil {
+		folder, err := normalizeFolder(*filter.Folder)
+		if err != nil {
+			return nil, 0, err
+		}
+		filter.Folder = &folder
+	}
+	filter.Tag = strings.ToLower(strings.TrimSpace(filter.Tag))
+
+	assets, total, err := s.repo.ListAssets(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range assets {
+		s.presign(ctx, &assets[i], 1*time.Hour)
+	}
+	return assets, total, nil
+}
+
+// GetAsset returns an org's asset with a fresh download URL.
+func (s *AssetService) GetAsset(ctx context.Context, orgID, assetID uuid.UUID) (*model.Asset, error) {
+	asset, err := s.repo.GetAsset(ctx, orgID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	s.presign(ctx, asset, 1*time.Hour)
+	return asset, nil
+}
+
+func (s *AssetService) ListFolders(ctx context.Context, orgID uuid.UUID) ([]model.AssetFolder, error) {
+	return s.repo.ListAssetFolders(ctx, orgID)
+}
+
+// UpdateAsset renames an asset or moves and retags it. Only the record
+// changes; the stored object keeps its key.
+func (s *AssetService) UpdateAsset(ctx context.Context, orgID, assetID uuid.UUID, u model.AssetUpdate) (*model.Asset, error) {
+	if u.Filename != nil {
+		name, err := normalizeFilename(*u.Filename)
+		if err != nil {
+			return nil, err
+		}
+		u.Filename = &name
+	}
+	if u.Folder != nil {
+		folder, err := normalizeFolder(*u.Folder)
+		if err != nil {
+			return nil, err
+		}
+		u.Folder = &folder
+	}
+	if u.Tags != nil {
+		tags, err := normalizeTags(*u.Tags)
+		if err != nil {
+			return nil, err
+		}
+		u.Tags = &tags
 	}
 
+	asset, err := s.repo.UpdateAsset(ctx, orgID, assetID, u)
+	if err != nil {
+		return nil, err
+	}
+	s.presign(ctx, asset, 1*time.Hour)
 	return asset, nil
 }
 
-func (s *AssetService) GetDownloadURL(ctx context.Context, assetID uuid.UUID) (string, error) {
-	asset, err := s.repo.GetAsset(ctx, assetID)
+// DeleteAsset deletes an asset nothing published uses, then its object.
+// It fails with repository.ErrAssetInUse otherwise.
+func (s *AssetService) DeleteAsset(ctx context.Context, orgID, assetID uuid.UUID) error {
+	asset, err := s.repo.DeleteAsset(ctx, orgID, assetID)
 	if err != nil {
-		return "", err
+		return err
 	}
+	// The record is gone either way; an orphaned object only costs space.
+	if err := s.store.Delete(ctx, asset.S3Key); err != nil {
+		log.Printf("failed to delete object %s of asset %s: %v", asset.S3Key, asset.ID, err)
+	}
+	s.removeRenditions(ctx, asset)
+	return nil
+}
 
-	url, err := s.minioClient.PresignedGetObject(ctx, s.bucketName, asset.S3Key, 1*time.Hour, nil)
+// presign sets the URLs of an asset and its renditions.
+func (s *AssetService) presign(ctx context.Context, asset *model.Asset, expiry time.Duration) {
+	if url, err := s.store.PresignGet(ctx, asset.S3Key, expiry); err == nil {
+		asset.URL = url
+	}
+	for i := range asset.Renditions {
+		r := &asset.Renditions[i]
+		if url, err := s.store.PresignGet(ctx, renditionKey(asset, r), expiry); err == nil {
+			r.URL = url
+		}
+	}
+}
+
+func normalizeFilename(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAssetFilename || strings.ContainsAny(name, "/\\\x00") {
+		return "", fmt.Errorf("%w: filename must be 1-%d characters without slashes", ErrInvalidAsset, maxAssetFilename)
+	}
+	return name, nil
+}
+
+// normalizeFolder cleans a slash-separated folder path: "/Logos//2024/"
+// becomes "Logos/2024", and "" or "/" the top level.
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if folder == "" || folder == "/" {
+		return "", nil
+	}
+	clean := strings.Trim(path.Clean("/"+folder), "/")
+	for _, part := range strings.Split(clean, "/") {
+		if part == ".." || strings.TrimSpace(part) != part {
+			return "", fmt.Errorf("%w: invalid folder %q", ErrInvalidAsset, folder)
+		}
+	}
+	if len(clean) > maxAssetFolder || strings.ContainsAny(clean, "\\\x00") {
+		return "", fmt.Errorf("%w: invalid folder %q", ErrInvalidAsset, folder)
+	}
+	return clean, nil
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags, keeping order.
+func normalizeTags(tags []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxAssetTag {
+			return nil, fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidAsset, tag, maxAssetTag)
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxAssetTags {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrInvalidAsset, maxAssetTags)
+	}
+	return out, nil
+}
+
+func (s *AssetService) GetDownloadURL(ctx context.Context, orgID, assetID uuid.UUID) (string, error) {
+	asset, err := s.repo.GetAsset(ctx, orgID, assetID)
 	if err != nil {
-		return "", fmt.Errorf("failed to presign url: %w", err)
+		return "", err
 	}
 
-	return url.String(), nil
+	return s.store.PresignGet(ctx, asset.S3Key, 1*time.Hour)
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
 }
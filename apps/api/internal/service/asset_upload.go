@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path/filepath"
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// uploadExpiry is how long a presigned upload works.
+	uploadExpiry = 15 * time.Minute
+	// uploadGrace is how long after that an upload can still be completed
+	// before PurgeUploads removes it.
+	uploadGrace = time.Hour
+)
+
+// ErrUploadNotReceived is returned when completing an upload whose object
+// isn't in storage.
+var ErrUploadNotReceived = errors.New("upload not received")
+
+// CreateUpload checks a declared upload (OrgID, CreatedBy, Filename,
+// ContentType, SizeBytes, Folder and Tags) against the upload policy,
+// records it, and returns how the client can send the file straight to
+// storage. The file can be no larger than declared.
+func (s *AssetService) CreateUpload(ctx context.Context, u *model.AssetUpload) (*storage.PresignedUpload, error) {
+	contentType, _, err := mime.ParseMediaType(u.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid content type %q", ErrInvalidAsset, u.ContentType)
+	}
+	rule := findAssetRule(contentType)
+	if rule == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAsset, contentType)
+	}
+	if u.Filename, err = normalizeFilename(u.Filename); err != nil {
+		return nil, err
+	}
+	if err := rule.allows(u.Filename); err != nil {
+		return nil, err
+	}
+	if u.SizeBytes < 1 {
+		return nil, fmt.Errorf("%w: size must be positive", ErrInvalidAsset)
+	}
+	if u.SizeBytes > rule.MaxBytes {
+		return nil, fmt.Errorf("%w: %s files are limited to %d bytes", ErrAssetTooLarge, rule.Type, rule.MaxBytes)
+	}
+	if u.Folder, err = normalizeFolder(u.Folder); err != nil {
+		return nil, err
+	}
+	if u.Tags, err = normalizeTags(u.Tags); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	u.ID = uuid.New()
+	u.ContentType = rule.ContentType
+	// The client can write here until the upload expires, so the asset
+	// is stored under its own key once checked
+	u.S3Key = fmt.Sprintf("%s/uploads/%s%s", u.OrgID, u.ID, filepath.Ext(u.Filename))
+	u.ExpiresAt = now.Add(uploadExpiry)
+	u.CreatedAt = now
+
+	presigned, err := s.store.PresignUpload(ctx, storage.UploadPolicy{
+		Key:         u.S3Key,
+		ContentType: u.ContentType,
+		MaxSize:     u.SizeBytes,
+		Expiry:      uploadExpiry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateAssetUpload(ctx, u); err != nil {
+		return nil, err
+	}
+	return presigned, nil
+}
+
+// CompleteUpload creates the asset for an upload the client has sent,
+// after sniffing the object as UploadFile would. What was checked is
+// stored under the asset's own key and the uploaded object deleted, so
+// writing to the upload URL again changes nothing. An object the policy
+// refuses is deleted, so the client can upload again while the upload
+// hasn't expired. Completing again returns the same asset.
+func (s *AssetService) CompleteUpload(ctx context.Context, orgID, uploadID uuid.UUID) (*model.Asset, error) {
+	u, err := s.repo.GetAssetUpload(ctx, orgID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if u.CompletedAt != nil {
+		return s.GetAsset(ctx, orgID, u.ID)
+	}
+
+	obj, info, err := s.store.Get(ctx, u.S3Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrUploadNotReceived
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	asset, err := s.ingest(ctx, u, obj, info)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAsset) || errors.Is(err, ErrUnsupportedAsset) || errors.Is(err, ErrAssetTooLarge) {
+			if derr := s.store.Delete(ctx, u.S3Key); derr != nil {
+				log.Printf("failed to delete refused upload %s: %v", u.ID, derr)
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.repo.CompleteAssetUpload(ctx, asset); errors.Is(err, repository.ErrUploadCompleted) {
+		// Completed concurrently, storing the same renditions
+		return s.GetAsset(ctx, orgID, u.ID)
+	} else if err != nil {
+		s.removeRenditions(ctx, asset)
+		s.store.Delete(ctx, asset.S3Key)
+		return nil, err
+	}
+	s.deleteUploadObject(ctx, u)
+	s.presign(ctx, asset, 1*time.Hour)
+	return asset, nil
+}
+
+// ingest checks an uploaded object and stores it as the asset it becomes,
+// reading it through once to hash it. SVGs are stored sanitised; images
+// are decoded and their renditions stored.
+func (s *AssetService) ingest(ctx context.Context, u *model.AssetUpload, obj io.Reader, info storage.ObjectInfo) (*model.Asset, error) {
+	if info.Size > u.SizeBytes {
+		return nil, fmt.Errorf("%w: %d bytes uploaded, %d declared", ErrAssetTooLarge, info.Size, u.SizeBytes)
+	}
+	sniffed, err := sniffAsset(obj, u.Filename, info.Size)
+	if err != nil {
+		return nil, err
+	}
+	if sniffed.rule.ContentType != u.ContentType {
+		return nil, fmt.Errorf("%w: file content is %s, not the declared %s", ErrInvalidAsset, sniffed.rule.ContentType, u.ContentType)
+	}
+
+	key := assetKey(u.OrgID, u.ID, u.Filename)
+	hash := sha256.New()
+	counter := &countingWriter{}
+	body := io.TeeReader(sniffed.body, io.MultiWriter(hash, counter))
+	var img *decodedImage
+	if isRaster(u.ContentType) {
+		var data []byte
+		if data, err = io.ReadAll(body); err == nil {
+			if img, err = decodeImage(data); err == nil {
+				_, err = s.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), u.ContentType)
+			}
+		}
+	} else {
+		_, err = s.store.Put(ctx, key, body, sniffed.size, u.ContentType)
+	}
+	if errors.Is(err, ErrInvalidAsset) || errors.Is(err, ErrAssetTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	asset := &model.Asset{
+		ID:             u.ID,
+		OrgID:          u.OrgID,
+		Type:           sniffed.rule.Type,
+		Filename:       u.Filename,
+		ContentType:    u.ContentType,
+		SizeBytes:      counter.n,
+		ChecksumSHA256: hex.EncodeToString(hash.Sum(nil)),
+		Folder:         u.Folder,
+		Tags:           u.Tags,
+		S3Key:          key,
+		CreatedAt:      time.Now(),
+	}
+	if img != nil {
+		if err := s.addRenditions(ctx, asset, img); err != nil {
+			s.store.Delete(ctx, key)
+			return nil, err
+		}
+	}
+	return asset, nil
+}
+
+// deleteUploadObject deletes what the client uploaded once the asset is
+// stored. Uploads made before they had keys of their own share the
+// asset's.
+func (s *AssetService) deleteUploadObject(ctx context.Context, u *model.AssetUpload) {
+	if u.S3Key == assetKey(u.OrgID, u.ID, u.Filename) {
+		return
+	}
+	if err := s.store.Delete(ctx, u.S3Key); err != nil {
+		log.Printf("failed to delete upload object %s: %v", u.ID, err)
+	}
+}
+
+// PurgeUploads forgets uploads more than uploadGrace past expiry, deleting
+// the objects of those never completed. It returns how many it deleted.
+// Anything written to a completed upload's URL after completion goes too.
+func (s *AssetService) PurgeUploads(ctx context.Context) (int, error) {
+	uploads, err := s.repo.PurgeAssetUploads(ctx, time.Now().Add(-uploadGrace))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, u := range uploads {
+		if u.CompletedAt != nil {
+			s.deleteUploadObject(ctx, &u)
+			continue
+		}
+		if err := s.store.Delete(ctx, u.S3Key); err != nil {
+			log.Printf("failed to delete abandoned upload %s: %v", u.ID, err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
@@ -52,39 +52,41 @@ func (s *AuthService) Register(ctx context.Context, email, password, name string
 		return "", fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// TODO: Create Membership (skipping formal DB generic link for MVP if not strictly enforced by RLS yet,
-	// but ideally we should have it. Assuming repo doesn't have CreateMembership yet, we rely on knowing ID)
+	if err := s.repo.CreateMembership(ctx, user.ID, org.ID, "owner"); err != nil {
+		return "", fmt.Errorf("failed to create membership: %w", err)
+	}
 
 	// 5. Generate Token
 	return s.GenerateToken(user.ID, org.ID)
 }
 
+// ErrInvalidCredentials is returned by Login for an unknown email or a wrong
+// password alike.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrNoOrg is returned by Login for a user who belongs to no org.
+var ErrNoOrg = errors.New("user belongs to no org")
+
 func (s *AuthService) Login(ctx context.Context, email, password string) (string, error) {
 	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return "", ErrInvalidCredentials
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return "", ErrInvalidCredentials
 	}
 
-	// For MVP, checking memberships or just picking headers?
-	// Let's assume we find their primary org.
-	// Since we don't have ListMemberships yet, we might need to HACK this for the MVP
-	// or just require the client to switch orgs later.
-	// HACK: For now, we need an OrgID to put in the token.
-	// If we don't have a lookup, we'll encounter issues.
-	// Let's add `GetOrgForUser` to repo or just query for now.
-
-	// Simplification: We just registered them, so we know they have an org.
-	// But Login needs to find it.
-	// Let's add ListOrgsForUser to repo in next step.
-	// For now, returning a token WITHOUT org, or defaulting to 0000 if not found (bad).
-	// Let's rely on repo update in a moment.
-
-	return s.GenerateToken(user.ID, uuid.Nil) // Placeholder OrgID, Middleware will complain or we fetch later?
+	// Tokens carry the user's first org; there is no org switching yet
+	memberships, err := s.repo.ListMemberships(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(memberships) == 0 {
+		return "", ErrNoOrg
+	}
+	return s.GenerateToken(user.ID, memberships[0].OrgID)
 }
 
 func (s *AuthService) GenerateToken(userID, orgID uuid.UUID) (string, error) {
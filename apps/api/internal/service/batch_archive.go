@@ -0,0 +1,202 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+
+	"github.com/google/uuid"
+)
+
+// DefaultArchiveFilename names archive entries when no pattern is given.
+const DefaultArchiveFilename = "{{_row}}.pdf"
+
+var (
+	// ErrBatchNotReady is returned when downloading a batch that is still running.
+	ErrBatchNotReady = errors.New("batch is still processing")
+	// ErrBatchEmpty is returned when a batch produced no documents.
+	ErrBatchEmpty = errors.New("batch has no completed documents")
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// batchOutputs loads the completed outputs of a finished batch.
+func (s *BatchService) batchOutputs(ctx context.Context, orgID, batchID uuid.UUID) (*model.Batch, []model.JobOutput, error) {
+	batch, err := s.repo.GetBatch(ctx, orgID, batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if batch.Status != "completed" {
+		return nil, nil, ErrBatchNotReady
+	}
+	outputs, err := s.repo.ListBatchOutputs(ctx, orgID, batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(outputs) == 0 {
+		return nil, nil, ErrBatchEmpty
+	}
+	return batch, outputs, nil
+}
+
+// PrepareArchive checks that a batch can be downloaded and returns a
+// function that streams it. Splitting the two lets the handler report
+// errors as JSON before any bytes have been written.
+func (s *BatchService) PrepareArchive(ctx context.Context, orgID, batchID uuid.UUID, pattern string) (func(io.Writer) error, error) {
+	_, outputs, err := s.batchOutputs(ctx, orgID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		pattern = DefaultArchiveFilename
+	}
+
+	return func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		used := map[string]int{}
+		for _, o := range outputs {
+			name := uniqueName(archiveFilename(pattern, o), used)
+
+			// PDFs are already compressed; storing avoids burning CPU for nothing
+			entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+			if err != nil {
+				return err
+			}
+			if err := s.copyObject(ctx, entry, o.S3Key); err != nil {
+				return fmt.Errorf("failed to add %s: %w", name, err)
+			}
+		}
+		return zw.Close()
+	}, nil
+}
+
+// PrepareMergedPDF is PrepareArchive for a single concatenated PDF.
+//
+// The merge doesn't stream: pdfcpu merges every output into one document
+// in memory before it is written, so peak memory grows with the size of
+// the batch.
+func (s *BatchService) PrepareMergedPDF(ctx context.Context, orgID, batchID uuid.UUID) (func(io.Writer) error, error) {
+	_, outputs, err := s.batchOutputs(ctx, orgID, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w io.Writer) error {
+		merger, err := pdf.NewMerger(w)
+		if err != nil {
+			return err
+		}
+		for _, o := range outputs {
+			obj, err := s.assets.openObject(ctx, o.S3Key)
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(obj)
+			obj.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read output of job %s: %w", o.JobID, err)
+			}
+			if err := merger.Add(data); err != nil {
+				return fmt.Errorf("failed to merge output of job %s: %w", o.JobID, err)
+			}
+		}
+		return merger.Close()
+	}, nil
+}
+
+func (s *BatchService) copyObject(ctx context.Context, w io.Writer, key string) error {
+	obj, err := s.assets.openObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	_, err = io.Copy(w, obj)
+	return err
+}
+
+// archiveFilename expands {{ path }} placeholders in pattern from the job's
+// merge data. {{_row}} and {{_jobId}} are always available.
+func archiveFilename(pattern string, o model.JobOutput) string {
+	var data map[string]any
+	json.Unmarshal(o.Data, &data)
+
+	name := placeholder.ReplaceAllStringFunc(pattern, func(m string) string {
+		key := placeholder.FindStringSubmatch(m)[1]
+		switch key {
+		case "_row":
+			return strconv.Itoa(o.BatchRow)
+		case "_jobId":
+			return o.JobID.String()
+		}
+		var cur any = data
+		for _, part := range strings.Split(key, ".") {
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return ""
+			}
+			cur = obj[part]
+		}
+		if cur == nil {
+			return ""
+		}
+		return fmt.Sprint(cur)
+	})
+
+	if strings.EqualFold(path.Ext(name), ".pdf") {
+		name = name[:len(name)-len(".pdf")]
+	}
+	name = sanitizeFilename(name)
+	if name == "" {
+		name = o.JobID.String()
+	}
+	return name + ".pdf"
+}
+
+// maxFilenameBytes leaves room for ".pdf" and a uniqueness suffix within
+// the 255 bytes most file systems allow.
+const maxFilenameBytes = 200
+
+// sanitizeFilename keeps entries flat and portable: no directories, no
+// control characters and nothing Windows refuses to extract.
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if len(name) > maxFilenameBytes {
+		// Cut on a rune boundary so the entry name stays valid UTF-8
+		end := maxFilenameBytes
+		for end > 0 && !utf8.RuneStart(name[end]) {
+			end--
+		}
+		name = name[:end]
+	}
+	return name
+}
+
+func uniqueName(name string, used map[string]int) string {
+	key := strings.ToLower(name)
+	used[key]++
+	if used[key] == 1 {
+		return name
+	}
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), used[key], ext)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"template-builder-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "invoice-42", "invoice-42"},
+		{"directories", "../../etc/passwd", "_.._etc_passwd"},
+		{"windows reserved", `a\b:c*d?e"f<g>h|i`, "a_b_c_d_e_f_g_h_i"},
+		{"control characters", "a\x00b\nc\x7fd", "abcd"},
+		{"surrounding dots and spaces", " ..name.. ", "name"},
+		{"non-ASCII", "Иванов 山田", "Иванов 山田"},
+		{"long ASCII", strings.Repeat("a", 250), strings.Repeat("a", 200)},
+		// 2-byte runes: byte 200 starts a rune, so all 100 fit
+		{"long Cyrillic", strings.Repeat("ж", 150), strings.Repeat("ж", 100)},
+		// 3-byte runes: byte 200 is mid-rune, so the cut steps back to 198
+		{"long CJK", strings.Repeat("山", 100), strings.Repeat("山", 66)},
+		{"CJK after ASCII", "a" + strings.Repeat("山", 100), "a" + strings.Repeat("山", 66)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeFilename(tt.in)
+			if got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("sanitizeFilename(%q) is not valid UTF-8", tt.in)
+			}
+			if len(got) > maxFilenameBytes {
+				t.Errorf("sanitizeFilename(%q) is %d bytes", tt.in, len(got))
+			}
+		})
+	}
+}
+
+func TestArchiveFilename(t *testing.T) {
+	jobID := uuid.MustParse("11111111-2222-3333-4444-555555555555")
+	data := `{"customer":{"name":"山田 太郎","id":7},"invoice":{"number":"INV/001"}}`
+
+	tests := []struct {
+		name, pattern, want string
+	}{
+		{"default", DefaultArchiveFilename, "3.pdf"},
+		{"job id", "{{_jobId}}", jobID.String() + ".pdf"},
+		{"nested fields", "{{customer.name}}-{{ invoice.number }}.pdf", "山田 太郎-INV_001.pdf"},
+		{"numbers", "{{customer.id}}", "7.pdf"},
+		{"extension case", "{{customer.id}}.PDF", "7.pdf"},
+		{"missing field", "{{customer.email}}", jobID.String() + ".pdf"},
+		{"path through a value", "{{customer.name.first}}", jobID.String() + ".pdf"},
+		{"long non-ASCII", "{{customer.name}}" + strings.Repeat("山", 100), "山田 太郎" + strings.Repeat("山", 62) + ".pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := model.JobOutput{JobID: jobID, BatchRow: 3, Data: []byte(data)}
+			got := archiveFilename(tt.pattern, o)
+			if got != tt.want {
+				t.Errorf("archiveFilename(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("archiveFilename(%q) is not valid UTF-8", tt.pattern)
+			}
+		})
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	used := map[string]int{}
+	for _, tt := range []struct{ in, want string }{
+		{"a.pdf", "a.pdf"},
+		{"A.pdf", "A-2.pdf"},
+		{"a.pdf", "a-3.pdf"},
+		{"b.pdf", "b.pdf"},
+	} {
+		if got := uniqueName(tt.in, used); got != tt.want {
+			t.Errorf("uniqueName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
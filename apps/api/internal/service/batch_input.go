@@ -0,0 +1,177 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	BatchFormatCSV   = "csv"
+	BatchFormatJSONL = "jsonl"
+
+	// MaxBatchRows caps a single batch; larger inputs should be split.
+	MaxBatchRows = 50000
+
+	maxJSONLLineBytes = 1 << 20
+)
+
+// DetectBatchFormat picks csv or jsonl from a filename or content type.
+// It returns "" when neither matches.
+func DetectBatchFormat(filename, contentType string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return BatchFormatCSV
+	case ".jsonl", ".ndjson":
+		return BatchFormatJSONL
+	}
+	switch strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+	case "text/csv", "application/csv":
+		return BatchFormatCSV
+	case "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		return BatchFormatJSONL
+	}
+	return ""
+}
+
+// batchRow is one parsed input record. Err is set when the row itself could
+// not be read; such rows are reported but do not abort the batch.
+type batchRow struct {
+	Num  int
+	Data map[string]any
+	Err  error
+}
+
+// readBatchRows parses every record of r, applying mapping (source column or
+// key -> dotted merge-data path) as it goes.
+func readBatchRows(format string, r io.Reader, mapping map[string]string) ([]batchRow, error) {
+	switch format {
+	case BatchFormatCSV:
+		return readCSVRows(r, mapping)
+	case BatchFormatJSONL:
+		return readJSONLRows(r, mapping)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidBatchInput, format)
+	}
+}
+
+func readCSVRows(r io.Reader, mapping map[string]string) ([]batchRow, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: csv is empty", ErrInvalidBatchInput)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read csv header: %v", ErrInvalidBatchInput, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+	for col := range mapping {
+		if !slices.Contains(header, col) {
+			return nil, fmt.Errorf("%w: mapped column %q is not in the csv header", ErrInvalidBatchInput, col)
+		}
+	}
+
+	var rows []batchRow
+	for num := 1; ; num++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) >= MaxBatchRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidBatchInput, MaxBatchRows)
+		}
+		if err != nil {
+			if errors.Is(err, csv.ErrFieldCount) {
+				rows = append(rows, batchRow{Num: num, Err: fmt.Errorf("expected %d columns, got %d", len(header), len(record))})
+				continue
+			}
+			return nil, fmt.Errorf("%w: malformed csv at row %d: %v", ErrInvalidBatchInput, num, err)
+		}
+
+		data := map[string]any{}
+		for i, col := range header {
+			if record[i] == "" {
+				continue
+			}
+			setPath(data, targetPath(col, mapping), record[i])
+		}
+		rows = append(rows, batchRow{Num: num, Data: data})
+	}
+	return rows, nil
+}
+
+func readJSONLRows(r io.Reader, mapping map[string]string) ([]batchRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLLineBytes)
+
+	var rows []batchRow
+	num := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		num++
+		if len(rows) >= MaxBatchRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidBatchInput, MaxBatchRows)
+		}
+
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			rows = append(rows, batchRow{Num: num, Err: fmt.Errorf("invalid json object: %v", err)})
+			continue
+		}
+
+		data := record
+		if len(mapping) > 0 {
+			data = map[string]any{}
+			for key, v := range record {
+				if path, ok := mapping[key]; ok {
+					setPath(data, path, v)
+				} else {
+					data[key] = v
+				}
+			}
+		}
+		rows = append(rows, batchRow{Num: num, Data: data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: failed to read jsonl: %v", ErrInvalidBatchInput, err)
+	}
+	if num == 0 {
+		return nil, fmt.Errorf("%w: jsonl is empty", ErrInvalidBatchInput)
+	}
+	return rows, nil
+}
+
+func targetPath(col string, mapping map[string]string) string {
+	if path, ok := mapping[col]; ok && path != "" {
+		return path
+	}
+	return col
+}
+
+// setPath stores v at a dotted path such as "customer.name", creating
+// intermediate objects as needed.
+func setPath(data map[string]any, path string, v any) {
+	parts := strings.Split(path, ".")
+	cur := data
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := cur[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			cur[p] = next
+		}
+		cur = next
+	}
+	cur[parts[len(parts)-1]] = v
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/queue"
+	"template-builder-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidBatchInput wraps problems with the uploaded file as a whole
+// (unknown format, bad header, too many rows), as opposed to per-row errors.
+var ErrInvalidBatchInput = errors.New("invalid batch input")
+
+type BatchService struct {
+	repo   repository.Repository
+	queue  *queue.Queue
+	assets *AssetService
+	events EventPublisher
+	usage  *UsageService
+}
+
+func NewBatchService(repo repository.Repository, q *queue.Queue, assets *AssetService, events EventPublisher, usage *UsageService) *BatchService {
+	return &BatchService{repo: repo, queue: q, assets: assets, events: events, usage: usage}
+}
+
+// BatchInput describes where batch rows come from. Exactly one of Reader or
+// SourceAssetID is set; Format may be empty to detect it from the filename.
+type BatchInput struct {
+	Reader        io.Reader
+	Filename      string
+	ContentType   string
+	SourceAssetID *uuid.UUID
+	Format        string
+	Mapping       map[string]string
+	Version       int // 0 means the latest version
+}
+
+func (s *BatchService) CreateBatch(ctx context.Context, orgID, userID, templateID uuid.UUID, in BatchInput) (*model.Batch, error) {
+	// 1. Resolve template version (and its schema)
+	if _, err := s.repo.GetTemplate(ctx, orgID, templateID); err != nil {
+		return nil, err
+	}
+	version := in.Version
+	if version == 0 {
+		maxVersion, err := s.repo.GetMaxVersion(ctx, templateID)
+		if err != nil {
+			return nil, err
+		}
+		if maxVersion == 0 {
+			return nil, fmt.Errorf("%w: template has no versions", ErrInvalidBatchInput)
+		}
+		version = maxVersion
+	}
+	tmplVersion, err := s.repo.GetTemplateVersion(ctx, orgID, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Open the source
+	reader := in.Reader
+	if in.SourceAssetID != nil {
+		obj, asset, err := s.assets.OpenAsset(ctx, orgID, *in.SourceAssetID)
+		if err != nil {
+			return nil, err
+		}
+		defer obj.Close()
+		reader = obj
+		in.Filename, in.ContentType = asset.Filename, asset.ContentType
+	}
+	format := in.Format
+	if format == "" {
+		format = DetectBatchFormat(in.Filename, in.ContentType)
+	}
+	if format == "" {
+		return nil, fmt.Errorf("%w: cannot detect format, pass format=csv or format=jsonl", ErrInvalidBatchInput)
+	}
+
+	// 3. Parse and validate every row
+	rows, err := readBatchRows(format, reader, in.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	batch := &model.Batch{
+		ID:            uuid.New(),
+		OrgID:         orgID,
+		TemplateID:    templateID,
+		Version:       version,
+		Status:        "processing",
+		SourceFormat:  format,
+		SourceAssetID: in.SourceAssetID,
+		ColumnMapping: in.Mapping,
+		Total:         len(rows),
+		RowErrors:     []model.BatchRowError{},
+		CreatedBy:     &userID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	var jobs []model.GenerationJob
+	var payloads []queue.JobPayload
+	for _, row := range rows {
+		if row.Err == nil {
+			row.Err = validateMergeData(tmplVersion.SchemaJSON, row.Data, format == BatchFormatCSV)
+		}
+		if row.Err != nil {
+			batch.RowErrors = append(batch.RowErrors, model.BatchRowError{Row: row.Num, Message: row.Err.Error()})
+			batch.Failed++
+			continue
+		}
+
+		data, err := json.Marshal(row.Data)
+		if err != nil {
+			batch.RowErrors = append(batch.RowErrors, model.BatchRowError{Row: row.Num, Message: err.Error()})
+			batch.Failed++
+			continue
+		}
+
+		job := model.GenerationJob{
+			ID:         uuid.New(),
+			OrgID:      orgID,
+			TemplateID: templateID,
+			BatchID:    &batch.ID,
+			BatchRow:   row.Num,
+			CreatedBy:  &userID,
+			Status:     "pending",
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			Data:       data,
+		}
+		jobs = append(jobs, job)
+		payloads = append(payloads, queue.JobPayload{
+			JobID:      job.ID,
+			OrgID:      orgID,
+			TemplateID: templateID,
+			Version:    version,
+			BatchID:    &batch.ID,
+			Priority:   queue.PriorityBatch,
+			Data:       data,
+		})
+	}
+	if len(jobs) == 0 {
+		batch.Status = "completed"
+	}
+	// The whole batch must fit in the plan; a partial batch is rarely useful.
+	if err := s.usage.CheckDocuments(ctx, orgID, int64(len(jobs))); err != nil {
+		return nil, err
+	}
+
+	// 4. Persist, then enqueue
+	if err := s.repo.CreateBatch(ctx, batch, jobs); err != nil {
+		return nil, err
+	}
+
+	for _, p := range payloads {
+		if err := s.queue.EnqueueJob(ctx, p); err != nil {
+			log.Printf("Batch %s: failed to enqueue job %s: %v", batch.ID, p.JobID, err)
+			s.repo.UpdateJobStatus(ctx, p.JobID, "failed", nil, err.Error())
+			if updated, err := s.repo.RecordBatchJobResult(ctx, batch.ID, false); err == nil {
+				batch = updated
+			}
+		}
+	}
+
+	// Normally the worker finishing the last job completes the batch; here
+	// no job ever reached the worker.
+	if batch.Status == "completed" {
+		s.events.Publish(ctx, orgID, model.EventBatchCompleted, batch)
+	}
+
+	return batch, nil
+}
+
+func (s *BatchService) GetBatch(ctx context.Context, orgID, id uuid.UUID) (*model.Batch, error) {
+	return s.repo.GetBatch(ctx, orgID, id)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/queue"
+	"template-builder-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidMergeData is returned when merge data does not match the
+// template version's schema.
+var ErrInvalidMergeData = errors.New("invalid merge data")
+
+// Notifications can be lost; waiters re-read the job this often as well.
+const jobWaitPoll = 2 * time.Second
+
+type GenerationService struct {
+	repo   repository.Repository
+	queue  *queue.Queue
+	status *StatusEventHub
+	usage  *UsageService
+}
+
+func NewGenerationService(repo repository.Repository, q *queue.Queue, status *StatusEventHub, usage *UsageService) *GenerationService {
+	return &GenerationService{repo: repo, queue: q, status: status, usage: usage}
+}
+
+// CreateJob validates data against the template version and queues a
+// single generation job at the given queue priority. version 0 means the
+// latest version; nil output means a PDF.
+func (s *GenerationService) CreateJob(ctx context.Context, orgID, userID, templateID uuid.UUID, version int, data map[string]any, output *model.OutputOptions, priority string) (*model.GenerationJob, error) {
+	if err := normalizeOutput(output); err != nil {
+		return nil, err
+	}
+	tmpl, err := s.repo.GetTemplate(ctx, orgID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if output != nil && output.PostProcessing != nil {
+		if err := checkPostProcessing(ctx, s.repo, orgID, tmpl.PostProcessing.Merge(output.PostProcessing)); err != nil {
+			return nil, err
+		}
+	}
+	if version == 0 {
+		maxVersion, err := s.repo.GetMaxVersion(ctx, templateID)
+		if err != nil {
+			return nil, err
+		}
+		if maxVersion == 0 {
+			return nil, fmt.Errorf("template has no versions: %w", repository.ErrNotFound)
+		}
+		version = maxVersion
+	}
+	tmplVersion, err := s.repo.GetTemplateVersion(ctx, orgID, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.usage.CheckDocuments(ctx, orgID, 1); err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if data != nil {
+		if err := validateMergeData(tmplVersion.SchemaJSON, data, false); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMergeData, err)
+		}
+		if raw, err = json.Marshal(data); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	job := &model.GenerationJob{
+		ID:         uuid.New(),
+		OrgID:      orgID,
+		TemplateID: templateID,
+		CreatedBy:  &userID,
+		Status:     "pending",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Output:     output,
+		Data:       raw,
+	}
+	if output != nil && output.PostProcessing != nil {
+		// Passwords travel with the queued job only, never into the jobs table.
+		stored := *output
+		stored.PostProcessing = output.PostProcessing.Redacted()
+		job.Output = &stored
+	}
+	if err := s.repo.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	err = s.queue.EnqueueJob(ctx, queue.JobPayload{
+		JobID:      job.ID,
+		OrgID:      orgID,
+		TemplateID: templateID,
+		Version:    version,
+		Priority:   priority,
+		Output:     output,
+		Data:       raw,
+	})
+	if err != nil {
+		s.repo.UpdateJobStatus(ctx, job.ID, "failed", nil, err.Error())
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return job, nil
+}
+
+// WaitForJob blocks until the job completes or fails, or timeout elapses,
+// and returns the job as last seen. A job still pending or processing after
+// the timeout is not an error; ctx being cancelled is.
+func (s *GenerationService) WaitForJob(ctx context.Context, orgID, jobID uuid.UUID, timeout time.Duration) (*model.GenerationJob, error) {
+	events, unsubscribe := s.status.Subscribe(model.StatusEventJob, jobID)
+	defer unsubscribe()
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	poll := time.NewTicker(jobWaitPoll)
+	defer poll.Stop()
+
+	var job *model.GenerationJob
+	for {
+		// Always re-read rather than trusting the event, so the caller
+		// gets the full row.
+		latest, err := s.repo.GetJob(ctx, orgID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		job = latest
+		if model.JobStatusEvent(job).Final() {
+			return job, nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return job, ctx.Err()
+		case <-events:
+		case <-poll.C:
+		}
+	}
+}
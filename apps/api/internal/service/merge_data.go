@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validateMergeData checks data against the subset of JSON Schema that
+// template versions use for their schema_json: nested "properties",
+// "required" and scalar "type". When coerce is set, string values are
+// converted in place to the declared number/integer/boolean type, which is
+// what CSV input needs since every cell arrives as a string.
+func validateMergeData(schema map[string]any, data map[string]any, coerce bool) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	var problems []string
+	validateObject(schema, data, "", coerce, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validateObject(schema map[string]any, data map[string]any, prefix string, coerce bool, problems *[]string) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if v, present := data[name]; !present || v == nil {
+				*problems = append(*problems, fmt.Sprintf("%s%s is required", prefix, name))
+			}
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]any)
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propSchema, _ := props[name].(map[string]any)
+		v, present := data[name]
+		if !present || v == nil || propSchema == nil {
+			continue
+		}
+		path := prefix + name
+
+		switch want, _ := propSchema["type"].(string); want {
+		case "object":
+			obj, ok := v.(map[string]any)
+			if !ok {
+				*problems = append(*problems, fmt.Sprintf("%s must be an object", path))
+				continue
+			}
+			validateObject(propSchema, obj, path+".", coerce, problems)
+		case "array":
+			if _, ok := v.([]any); !ok {
+				*problems = append(*problems, fmt.Sprintf("%s must be an array", path))
+			}
+		case "string":
+			if _, ok := v.(string); !ok {
+				*problems = append(*problems, fmt.Sprintf("%s must be a string", path))
+			}
+		case "number", "integer", "boolean":
+			converted, err := checkScalar(want, v, coerce)
+			if err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s %s", path, err))
+				continue
+			}
+			data[name] = converted
+		}
+	}
+}
+
+func checkScalar(want string, v any, coerce bool) (any, error) {
+	if s, ok := v.(string); ok && coerce {
+		switch want {
+		case "boolean":
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("must be a boolean")
+			}
+			return b, nil
+		case "integer":
+			n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be an integer")
+			}
+			return n, nil
+		default:
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a number")
+			}
+			return f, nil
+		}
+	}
+
+	switch want {
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+	case "integer":
+		f, ok := v.(float64)
+		if !ok || f != float64(int64(f)) {
+			return nil, fmt.Errorf("must be an integer")
+		}
+	default:
+		if _, ok := v.(float64); !ok {
+			return nil, fmt.Errorf("must be a number")
+		}
+	}
+	return v, nil
+}
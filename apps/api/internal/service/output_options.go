@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"template-builder-api/internal/model"
+)
+
+// ErrInvalidOutput wraps output option validation failures.
+var ErrInvalidOutput = errors.New("invalid output options")
+
+// ErrInvalidPageSetup wraps template page setup validation failures.
+var ErrInvalidPageSetup = errors.New("invalid page setup")
+
+const (
+	minOutputDPI = 36
+	maxOutputDPI = 600
+	// Margins beyond this are certainly a unit mistake.
+	maxMarginMM = 100
+	maxBleedMM  = 20
+	// Custom pages are limited to roughly A0.
+	maxPageMM = 1200
+)
+
+var pageRangesPattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+
+// normalizeOutput validates o and fills in the default format. nil stays
+// nil, meaning a PDF with the template's own layout.
+func normalizeOutput(o *model.OutputOptions) error {
+	if o == nil {
+		return nil
+	}
+	if o.Format == "" {
+		o.Format = model.OutputPDF
+	}
+	if !slices.Contains(model.OutputFormats, o.Format) {
+		return fmt.Errorf("%w: format must be one of %s", ErrInvalidOutput, strings.Join(model.OutputFormats, ", "))
+	}
+	if err := validatePageSetup(&o.PageSetup); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+
+	isImage := o.Format == model.OutputPNG || o.Format == model.OutputJPEG
+	if o.DPI != 0 && (!isImage || o.DPI < minOutputDPI || o.DPI > maxOutputDPI) {
+		return fmt.Errorf("%w: dpi applies to png and jpeg and must be between %d and %d", ErrInvalidOutput, minOutputDPI, maxOutputDPI)
+	}
+	if o.Quality != 0 && (o.Format != model.OutputJPEG || o.Quality < 1 || o.Quality > 100) {
+		return fmt.Errorf("%w: quality applies to jpeg and must be between 1 and 100", ErrInvalidOutput)
+	}
+
+	if o.PostProcessing != nil {
+		if o.Format != model.OutputPDF {
+			return fmt.Errorf("%w: postProcessing only applies to pdf", ErrInvalidOutput)
+		}
+		if err := validatePostProcessing(o.PostProcessing); err != nil {
+			return err
+		}
+	}
+
+	if o.Pages != "" {
+		if o.Format == model.OutputHTML || o.Format == model.OutputDOCX {
+			return fmt.Errorf("%w: pages does not apply to %s", ErrInvalidOutput, o.Format)
+		}
+		if err := validatePageRanges(o.Pages); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+		}
+	}
+	return nil
+}
+
+// validatePageSetup checks a page setup, whether a template's or an
+// override. Custom dimensions go with, and only with, the custom size.
+func validatePageSetup(p *model.PageSetup) error {
+	if p.PageSize != "" && !slices.Contains(model.PageSizes, p.PageSize) {
+		return fmt.Errorf("pageSize must be one of %s", strings.Join(model.PageSizes, ", "))
+	}
+	if p.PageSize == model.PageSizeCustom {
+		if p.Width <= 0 || p.Height <= 0 || p.Width > maxPageMM || p.Height > maxPageMM {
+			return fmt.Errorf("a custom page needs pageWidth and pageHeight between 0 and %dmm", maxPageMM)
+		}
+	} else if p.Width != 0 || p.Height != 0 {
+		return fmt.Errorf("pageWidth and pageHeight require pageSize custom")
+	}
+	if p.Orientation != "" && !slices.Contains(model.Orientations, p.Orientation) {
+		return fmt.Errorf("orientation must be portrait or landscape")
+	}
+	if m := p.Margins; m != nil {
+		for _, v := range []float64{m.Top, m.Right, m.Bottom, m.Left} {
+			if v < 0 || v > maxMarginMM {
+				return fmt.Errorf("margins must be between 0 and %dmm", maxMarginMM)
+			}
+		}
+	}
+	if p.Bleed != nil && (*p.Bleed < 0 || *p.Bleed > maxBleedMM) {
+		return fmt.Errorf("bleed must be between 0 and %dmm", maxBleedMM)
+	}
+	return nil
+}
+
+// validatePageRanges checks a selection such as "1-3,5": 1-based page
+// numbers and ranges that don't run backwards.
+func validatePageRanges(s string) error {
+	if !pageRangesPattern.MatchString(s) {
+		return fmt.Errorf("pages must look like 1-3,5")
+	}
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		start, _ := strconv.Atoi(from)
+		end := start
+		if isRange {
+			end, _ = strconv.Atoi(to)
+		}
+		if start < 1 || end < start {
+			return fmt.Errorf("invalid page range %q", part)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidPostProcessing wraps post-processing validation failures.
+var ErrInvalidPostProcessing = errors.New("invalid post-processing")
+
+const maxStampFontSize = 400
+
+func hexColor(s string) bool {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return false
+	}
+	return strings.Trim(strings.ToLower(s), "0123456789abcdef") == ""
+}
+
+// validatePostProcessing checks settings from a template or a request.
+func validatePostProcessing(pp *model.PostProcessing) error {
+	if pp == nil {
+		return nil
+	}
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidPostProcessing, fmt.Sprintf(format, args...))
+	}
+
+	if wm := pp.Watermark; wm != nil {
+		if (wm.Text == "") == (wm.ImageAssetID == nil) {
+			return invalid("watermark needs either text or imageAssetId")
+		}
+		if wm.Opacity < 0 || wm.Opacity > 1 {
+			return invalid("watermark opacity must be between 0 and 1")
+		}
+		if wm.FontSize < 0 || wm.FontSize > maxStampFontSize {
+			return invalid("watermark fontSize must be between 0 and %d", maxStampFontSize)
+		}
+		if wm.Scale < 0 || wm.Scale > 1 {
+			return invalid("watermark scale must be between 0 and 1")
+		}
+		if wm.Color != "" && !hexColor(wm.Color) {
+			return invalid("watermark color must look like #rrggbb")
+		}
+	}
+
+	if pn := pp.PageNumbers; pn != nil {
+		if pn.Position != "" && !slices.Contains(model.PageNumberPositions, pn.Position) {
+			return invalid("pageNumbers position must be one of %s", strings.Join(model.PageNumberPositions, ", "))
+		}
+		if pn.FontSize < 0 || pn.FontSize > maxStampFontSize {
+			return invalid("pageNumbers fontSize must be between 0 and %d", maxStampFontSize)
+		}
+		if pn.Margin < 0 || pn.Margin > maxMarginMM {
+			return invalid("pageNumbers margin must be between 0 and %dmm", maxMarginMM)
+		}
+	}
+
+	if p := pp.Protection; p != nil {
+		for _, name := range p.Permissions {
+			if !slices.Contains(model.Permissions, name) {
+				return invalid("unknown permission %q", name)
+			}
+		}
+		if len(p.UserPassword) > 127 || len(p.OwnerPassword) > 127 {
+			return invalid("passwords must be at most 127 bytes")
+		}
+	}
+
+	if pp.Profile != "" {
+		if !slices.Contains(model.Profiles, pp.Profile) {
+			return invalid("profile must be one of %s", strings.Join(model.Profiles, ", "))
+		}
+		if pp.Protection != nil {
+			return invalid("profile %s can't be combined with protection", pp.Profile)
+		}
+	}
+
+	if sig := pp.Signature; sig != nil {
+		if sig.CertificateID == uuid.Nil {
+			return invalid("signature needs a certificateId")
+		}
+		if pp.Protection != nil {
+			return invalid("signature can't be combined with protection")
+		}
+		if a := sig.Appearance; a != nil {
+			if a.Page < 0 {
+				return invalid("signature appearance page must be positive")
+			}
+			for _, v := range []float64{a.X, a.Y, a.Width, a.Height} {
+				if v < 0 || v > maxPageMM {
+					return invalid("signature appearance position and size must be between 0 and %dmm", maxPageMM)
+				}
+			}
+			if a.FontSize < 0 || a.FontSize > maxStampFontSize {
+				return invalid("signature appearance fontSize must be between 0 and %d", maxStampFontSize)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPostProcessing validates settings as they will be applied, after a
+// template's defaults and a request's overrides are merged: sections that
+// conflict can come from different sides, and the signing certificate
+// must be the org's and currently valid.
+func checkPostProcessing(ctx context.Context, repo repository.Repository, orgID uuid.UUID, pp *model.PostProcessing) error {
+	if pp == nil {
+		return nil
+	}
+	if pp.Profile != "" && pp.Protection != nil {
+		return fmt.Errorf("%w: profile %s can't be combined with protection", ErrInvalidPostProcessing, pp.Profile)
+	}
+	if pp.Signature == nil {
+		return nil
+	}
+	if pp.Protection != nil {
+		return fmt.Errorf("%w: signature can't be combined with protection", ErrInvalidPostProcessing)
+	}
+	cert, err := repo.GetSigningCertificate(ctx, orgID, pp.Signature.CertificateID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: signing certificate not found", ErrInvalidPostProcessing)
+	}
+	if err != nil {
+		return err
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("%w: signing certificate is not valid now", ErrInvalidPostProcessing)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"template-builder-api/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// imageURLExpiry is how long the renderer has to fetch a render's images.
+const imageURLExpiry = 15 * time.Minute
+
+// resolveImages returns templateJSON with a src for each image element
+// that names an assetId, at the element's rendition (see
+// AssetService.ImageURL). templateJSON isn't modified.
+func (s *RenderService) resolveImages(ctx context.Context, orgID uuid.UUID, templateJSON map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(templateJSON))
+	for k, v := range templateJSON {
+		out[k] = v
+	}
+
+	// Templates from before pages were introduced have top-level elements
+	if elements, ok := templateJSON["elements"].([]any); ok {
+		resolved, err := s.resolveElements(ctx, orgID, elements)
+		if err != nil {
+			return nil, err
+		}
+		out["elements"] = resolved
+	}
+	pages, ok := templateJSON["pages"].([]any)
+	if !ok {
+		return out, nil
+	}
+	outPages := make([]any, len(pages))
+	for i, p := range pages {
+		page, ok := p.(map[string]any)
+		elements, hasElements := page["elements"].([]any)
+		if !ok || !hasElements {
+			outPages[i] = p
+			continue
+		}
+		resolved, err := s.resolveElements(ctx, orgID, elements)
+		if err != nil {
+			return nil, err
+		}
+		outPage := make(map[string]any, len(page))
+		for k, v := range page {
+			outPage[k] = v
+		}
+		outPage["elements"] = resolved
+		outPages[i] = outPage
+	}
+	out["pages"] = outPages
+	return out, nil
+}
+
+func (s *RenderService) resolveElements(ctx context.Context, orgID uuid.UUID, elements []any) ([]any, error) {
+	out := make([]any, len(elements))
+	for i, e := range elements {
+		out[i] = e
+		el, ok := e.(map[string]any)
+		if !ok || el["type"] != "image" {
+			continue
+		}
+		ref, ok := el["assetId"].(string)
+		if !ok || ref == "" {
+			continue
+		}
+		assetID, err := uuid.Parse(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%w: image element %v: invalid assetId %q", ErrInvalidAsset, el["id"], ref)
+		}
+		rendition, _ := el["rendition"].(string)
+		width, _ := el["width"].(float64)
+
+		src, err := s.assets.ImageURL(ctx, orgID, assetID, rendition, width, imageURLExpiry)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: image element %v: asset %s not found", ErrInvalidAsset, el["id"], assetID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("image element %v: %w", el["id"], err)
+		}
+		resolved := make(map[string]any, len(el)+1)
+		for k, v := range el {
+			resolved[k] = v
+		}
+		resolved["src"] = src
+		out[i] = resolved
+	}
+	return out, nil
+}
@@ -1,73 +1,143 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/rendercache"
+	"template-builder-api/internal/renderer"
 	"template-builder-api/internal/repository"
 
 	"github.com/google/uuid"
 )
 
 type RenderService struct {
-	repo        repository.Repository
-	rendererURL string
-	client      *http.Client
+	repo     repository.Repository
+	renderer *renderer.Client
+	cache    *rendercache.Cache
+	usage    *UsageService
+	assets   *AssetService
 }
 
-func NewRenderService(repo repository.Repository, rendererURL string) *RenderService {
+// NewRenderService renders through the renderer pool. Renders are cached
+// in cache, which may be nil; hits and misses, and the renderer's time for
+// misses, are metered in usage.
+// Image elements that reference assets are fetched from assets.
+func NewRenderService(repo repository.Repository, renderer *renderer.Client, cache *rendercache.Cache, usage *UsageService, assets *AssetService) *RenderService {
 	return &RenderService{
-		repo:        repo,
-		rendererURL: rendererURL,
-		client:      &http.Client{},
+		repo:     repo,
+		renderer: renderer,
+		cache:    cache,
+		usage:    usage,
+		assets:   assets,
 	}
 }
 
 type RenderRequest struct {
-	TemplateJSON map[string]any `json:"templateJson"`
+	TemplateJSON map[string]any       `json:"templateJson"`
+	Data         json.RawMessage      `json:"data,omitempty"`
+	Output       *model.OutputOptions `json:"output,omitempty"`
+	// Page is the version's page setup with the output's overrides applied.
+	Page *model.PageSetup `json:"page"`
 }
 
-func (s *RenderService) PreviewTemplate(ctx context.Context, templateID uuid.UUID, version int) ([]byte, error) {
+// PreviewTemplate renders a version as a PDF. The caller must close it.
+func (s *RenderService) PreviewTemplate(ctx context.Context, orgID, templateID uuid.UUID, version int) (io.ReadCloser, error) {
+	doc, _, err := s.RenderTemplate(ctx, orgID, templateID, version, nil, nil)
+	return doc, err
+}
+
+// RenderTemplate renders a template version with the given merge data and
+// streams the document with its content type; the caller must close it.
+// nil output renders a PDF.
+func (s *RenderService) RenderTemplate(ctx context.Context, orgID, templateID uuid.UUID, version int, data json.RawMessage, output *model.OutputOptions) (io.ReadCloser, string, error) {
 	// 1. Fetch Template Version
 	// For MVP, if version is 0 (latest), we might need logic to find it.
 	// Assuming handling explicit version for now.
 
-	tmplVersion, err := s.repo.GetTemplateVersion(ctx, templateID, version)
+	tmplVersion, err := s.repo.GetTemplateVersion(ctx, orgID, templateID, version)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get template version: %w", err)
+		return nil, "", fmt.Errorf("failed to get template version: %w", err)
 	}
 
 	if tmplVersion.TemplateJSON == nil {
-		return nil, fmt.Errorf("template has no layout json")
+		return nil, "", fmt.Errorf("template has no layout json")
 	}
 
 	// 2. Prepare Request
 	payload := RenderRequest{
 		TemplateJSON: tmplVersion.TemplateJSON,
+		Data:         data,
+		Output:       output,
 	}
-	bodyBytes, _ := json.Marshal(payload)
+	if output != nil && output.PostProcessing != nil {
+		// Post-processing happens in the worker; the renderer needn't see it.
+		trimmed := *output
+		trimmed.PostProcessing = nil
+		payload.Output = &trimmed
+	}
+	var override *model.PageSetup
+	if output != nil {
+		override = &output.PageSetup
+	}
+	payload.Page = tmplVersion.PageSetup.Merge(override)
 
-	// 3. Call Renderer
-	req, err := http.NewRequestWithContext(ctx, "POST", s.rendererURL+"/render", bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		return nil, err
+	// 3. Serve repeated renders from the cache. The key covers everything
+	// the renderer sees, and the renderer itself; image assets are keyed by
+	// ID rather than by their URLs, which change, as assets never do.
+	var cacheKey string
+	if s.cache != nil {
+		if rendererVersion := s.renderer.Version(); rendererVersion != "" {
+			if cacheKey, err = rendercache.Key(orgID, payload.TemplateJSON, payload.Data, payload.Output, payload.Page, rendererVersion); err != nil {
+				log.Printf("Render cache key failed: %v", err)
+			}
+		}
+	}
+	if cacheKey != "" {
+		entry, err := s.cache.Get(ctx, cacheKey)
+		if err != nil {
+			log.Printf("Render cache lookup failed: %v", err)
+		}
+		if entry != nil {
+			s.usage.Record(orgID, model.MetricRenderCacheHits, 1)
+			return entry.Body, entry.ContentType, nil
+		}
+		s.usage.Record(orgID, model.MetricRenderCacheMisses, 1)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.client.Do(req)
+	if payload.TemplateJSON, err = s.resolveImages(ctx, orgID, payload.TemplateJSON); err != nil {
+		return nil, "", err
+	}
+	doc, contentType, err := s.render(ctx, orgID, &payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call renderer: %w", err)
+		return nil, "", err
 	}
-	defer resp.Body.Close()
+	if cacheKey != "" {
+		v := rendercache.Version{OrgID: orgID, TemplateID: templateID, Version: version, Draft: tmplVersion.Status != "published"}
+		doc = s.cache.Tee(ctx, cacheKey, v, contentType, doc)
+	}
+	return doc, contentType, nil
+}
+
+// render calls the renderer, metering the time it reports rendering for.
+func (s *RenderService) render(ctx context.Context, orgID uuid.UUID, payload *RenderRequest) (io.ReadCloser, string, error) {
+	bodyBytes, _ := json.Marshal(payload)
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("renderer error: %s", string(body))
+	resp, err := s.renderer.Post(ctx, "/render", bodyBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	if d, ok := renderer.RenderTime(resp.Header); ok {
+		s.usage.Record(orgID, model.MetricRenderMS, d.Milliseconds())
 	}
 
-	// 4. Return PDF bytes
-	return io.ReadAll(resp.Body)
+	// Return the document; the renderer labels multi-page images as a ZIP
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = model.OutputContentTypes[model.OutputPDF]
+	}
+	return resp.Body, contentType, nil
 }
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/queue"
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/safehttp"
+	"template-builder-api/pkg/db"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// schedulerLockKey is the advisory lock whose holder fires schedules.
+	schedulerLockKey     = 0x7363686564 // "sched"
+	schedulerTick        = 15 * time.Second
+	schedulerBatchSize   = 50
+	scheduleFetchLimit   = 64 << 20
+	scheduleFetchTimeout = 30 * time.Second
+)
+
+// ErrInvalidSchedule wraps schedule validation failures.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+type ScheduleService struct {
+	repo       repository.Repository
+	generation *GenerationService
+	batches    *BatchService
+	client     *http.Client
+}
+
+func NewScheduleService(repo repository.Repository, generation *GenerationService, batches *BatchService) *ScheduleService {
+	return &ScheduleService{
+		repo:       repo,
+		generation: generation,
+		batches:    batches,
+		client:     safehttp.Client,
+	}
+}
+
+// ScheduleInput is the user-editable part of a schedule.
+type ScheduleInput struct {
+	TemplateID    uuid.UUID
+	Name          string
+	CronExpr      string
+	Timezone      string
+	VersionPolicy string
+	Version       *int
+	DataSource    string
+	Payload       json.RawMessage
+	SourceURL     string
+	SourceAssetID *uuid.UUID
+	Active        bool
+}
+
+// nextRun returns the first slot of cronExpr strictly after t, evaluated in
+// timezone.
+func nextRun(cronExpr, timezone string, t time.Time) (time.Time, error) {
+	sched, err := cron.ParseStandard(cronExpr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: cron: %v", ErrInvalidSchedule, err)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, timezone)
+	}
+	next := sched.Next(t.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: cron expression never fires", ErrInvalidSchedule)
+	}
+	return next, nil
+}
+
+func (s *ScheduleService) validate(ctx context.Context, orgID uuid.UUID, in *ScheduleInput) error {
+	if in.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSchedule)
+	}
+	if in.Timezone == "" {
+		in.Timezone = "UTC"
+	}
+	if _, err := nextRun(in.CronExpr, in.Timezone, time.Now()); err != nil {
+		return err
+	}
+	if _, err := s.repo.GetTemplate(ctx, orgID, in.TemplateID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("%w: template not found", ErrInvalidSchedule)
+		}
+		return err
+	}
+
+	switch in.VersionPolicy {
+	case model.VersionPolicyLatest, model.VersionPolicyPublished:
+		in.Version = nil
+	case model.VersionPolicyPinned:
+		if in.Version == nil || *in.Version < 1 {
+			return fmt.Errorf("%w: a pinned schedule needs a version", ErrInvalidSchedule)
+		}
+		if _, err := s.repo.GetTemplateVersion(ctx, orgID, in.TemplateID, *in.Version); err != nil {
+			return fmt.Errorf("%w: version %d not found", ErrInvalidSchedule, *in.Version)
+		}
+	default:
+		return fmt.Errorf("%w: versionPolicy must be latest, published or pinned", ErrInvalidSchedule)
+	}
+
+	switch in.DataSource {
+	case model.DataSourcePayload:
+		var obj map[string]any
+		if err := json.Unmarshal(in.Payload, &obj); err != nil || obj == nil {
+			return fmt.Errorf("%w: payload must be a JSON object", ErrInvalidSchedule)
+		}
+		in.SourceURL, in.SourceAssetID = "", nil
+	case model.DataSourceURL:
+		if _, err := safehttp.CheckURL(in.SourceURL); err != nil {
+			return fmt.Errorf("%w: sourceUrl: %v", ErrInvalidSchedule, err)
+		}
+		in.Payload, in.SourceAssetID = nil, nil
+	case model.DataSourceAsset:
+		if in.SourceAssetID == nil {
+			return fmt.Errorf("%w: sourceAssetId is required", ErrInvalidSchedule)
+		}
+		if _, err := s.repo.GetAsset(ctx, orgID, *in.SourceAssetID); err != nil {
+			return fmt.Errorf("%w: source asset not found", ErrInvalidSchedule)
+		}
+		in.Payload, in.SourceURL = nil, ""
+	default:
+		return fmt.Errorf("%w: dataSource must be payload, url or asset", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+func (s *ScheduleService) CreateSchedule(ctx context.Context, orgID, userID uuid.UUID, in ScheduleInput) (*model.Schedule, error) {
+	if err := s.validate(ctx, orgID, &in); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sch := &model.Schedule{
+		ID:        uuid.New(),
+		OrgID:     orgID,
+		CreatedBy: userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	applyScheduleInput(sch, in)
+	if err := s.repo.CreateSchedule(ctx, sch); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+func (s *ScheduleService) UpdateSchedule(ctx context.Context, orgID, id uuid.UUID, in ScheduleInput) (*model.Schedule, error) {
+	sch, err := s.repo.GetSchedule(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, orgID, &in); err != nil {
+		return nil, err
+	}
+
+	applyScheduleInput(sch, in)
+	if err := s.repo.UpdateSchedule(ctx, sch); err != nil {
+		return nil, err
+	}
+	sch.UpdatedAt = time.Now()
+	return sch, nil
+}
+
+// applyScheduleInput copies validated input onto sch and recomputes the
+// next run from now.
+func applyScheduleInput(sch *model.Schedule, in ScheduleInput) {
+	sch.TemplateID = in.TemplateID
+	sch.Name = in.Name
+	sch.CronExpr = in.CronExpr
+	sch.Timezone = in.Timezone
+	sch.VersionPolicy = in.VersionPolicy
+	sch.Version = in.Version
+	sch.DataSource = in.DataSource
+	sch.Payload = in.Payload
+	sch.SourceURL = in.SourceURL
+	sch.SourceAssetID = in.SourceAssetID
+	sch.Active = in.Active
+
+	sch.NextRunAt = nil
+	if in.Active {
+		if next, err := nextRun(in.CronExpr, in.Timezone, time.Now()); err == nil {
+			sch.NextRunAt = &next
+		}
+	}
+}
+
+func (s *ScheduleService) GetSchedule(ctx context.Context, orgID, id uuid.UUID) (*model.Schedule, error) {
+	return s.repo.GetSchedule(ctx, orgID, id)
+}
+
+func (s *ScheduleService) ListSchedules(ctx context.Context, orgID uuid.UUID) ([]model.Schedule, error) {
+	return s.repo.ListSchedules(ctx, orgID)
+}
+
+func (s *ScheduleService) DeleteSchedule(ctx context.Context, orgID, id uuid.UUID) error {
+	return s.repo.DeleteSchedule(ctx, orgID, id)
+}
+
+func (s *ScheduleService) ListRuns(ctx context.Context, orgID, scheduleID uuid.UUID, limit, offset int) ([]model.ScheduleRun, error) {
+	if _, err := s.repo.GetSchedule(ctx, orgID, scheduleID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListScheduleRuns(ctx, orgID, scheduleID, limit, offset)
+}
+
+// Run fires due schedules until ctx is cancelled. Every instance may run
+// it; only the holder of the scheduler advisory lock does any work.
+func (s *ScheduleService) Run(ctx context.Context) {
+	lock := s.repo.LeaderLock(schedulerLockKey)
+	defer lock.Close()
+	for {
+		held, err := lock.TryAcquire(ctx)
+		if err != nil {
+			log.Printf("Scheduler: %v", err)
+		}
+		if held {
+			log.Println("Scheduler: acquired leadership")
+			s.lead(ctx, lock)
+			lock.Release(context.WithoutCancel(ctx))
+			log.Println("Scheduler: released leadership")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(schedulerTick):
+		}
+	}
+}
+
+func (s *ScheduleService) lead(ctx context.Context, lock *repository.LeaderLock) {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		// Losing the lock's connection means another instance may already
+		// have taken over.
+		if err := lock.Check(ctx); err != nil {
+			log.Printf("Scheduler: lost leader lock: %v", err)
+			return
+		}
+		s.fireDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ScheduleService) fireDue(ctx context.Context) {
+	for {
+		schedules, runs, err := s.repo.StartDueScheduleRuns(ctx, schedulerBatchSize, func(sch *model.Schedule) *time.Time {
+			// Slots missed while no instance was leader collapse into this run.
+			next, err := nextRun(sch.CronExpr, sch.Timezone, time.Now())
+			if err != nil {
+				log.Printf("Scheduler: disabling schedule %s: %v", sch.ID, err)
+				return nil
+			}
+			return &next
+		})
+		if err != nil {
+			log.Printf("Scheduler: %v", err)
+			return
+		}
+
+		for i := range runs {
+			s.execute(ctx, &schedules[i], &runs[i])
+		}
+		if len(runs) < schedulerBatchSize {
+			return
+		}
+	}
+}
+
+// execute creates the run's job or batch and records the outcome, as the
+// schedule's org.
+func (s *ScheduleService) execute(ctx context.Context, sch *model.Schedule, run *model.ScheduleRun) {
+	ctx = db.WithOrgID(ctx, sch.OrgID)
+	if err := s.enqueue(ctx, sch, run); err != nil {
+		log.Printf("Scheduler: schedule %s run %s failed: %v", sch.ID, run.ID, err)
+		run.Status, run.ErrorMessage = "failed", err.Error()
+	} else {
+		run.Status = "enqueued"
+	}
+	if err := s.repo.FinishScheduleRun(ctx, run); err != nil {
+		log.Printf("Scheduler: %v", err)
+	}
+}
+
+func (s *ScheduleService) enqueue(ctx context.Context, sch *model.Schedule, run *model.ScheduleRun) error {
+	version := 0 // latest
+	switch sch.VersionPolicy {
+	case model.VersionPolicyPublished:
+		v, err := s.repo.GetLatestPublishedVersion(ctx, sch.OrgID, sch.TemplateID)
+		if err != nil {
+			return err
+		}
+		if v == 0 {
+			return errors.New("template has no published version")
+		}
+		version = v
+	case model.VersionPolicyPinned:
+		if sch.Version != nil {
+			version = *sch.Version
+		}
+	}
+
+	batchInput := BatchInput{Version: version}
+	switch sch.DataSource {
+	case model.DataSourcePayload:
+		var data map[string]any
+		if err := json.Unmarshal(sch.Payload, &data); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+		return s.createJob(ctx, sch, run, version, data)
+
+	case model.DataSourceURL:
+		body, contentType, err := s.fetch(ctx, sch.SourceURL)
+		if err != nil {
+			return err
+		}
+		// A JSON object is one document's data; anything else is batch rows.
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/json" {
+			var data map[string]any
+			if err := json.Unmarshal(body, &data); err != nil {
+				return fmt.Errorf("source did not return a JSON object: %w", err)
+			}
+			return s.createJob(ctx, sch, run, version, data)
+		}
+		u, _ := url.Parse(sch.SourceURL)
+		batchInput.Reader = bytes.NewReader(body)
+		batchInput.Filename = path.Base(u.Path)
+		batchInput.ContentType = contentType
+
+	case model.DataSourceAsset:
+		batchInput.SourceAssetID = sch.SourceAssetID
+
+	default:
+		return fmt.Errorf("unknown data source %q", sch.DataSource)
+	}
+
+	batch, err := s.batches.CreateBatch(ctx, sch.OrgID, sch.CreatedBy, sch.TemplateID, batchInput)
+	if err != nil {
+		return err
+	}
+	run.BatchID = &batch.ID
+	return nil
+}
+
+func (s *ScheduleService) createJob(ctx context.Context, sch *model.Schedule, run *model.ScheduleRun, version int, data map[string]any) error {
+	job, err := s.generation.CreateJob(ctx, sch.OrgID, sch.CreatedBy, sch.TemplateID, version, data, nil, queue.PriorityDefault)
+	if err != nil {
+		return err
+	}
+	run.JobID = &job.ID
+	return nil
+}
+
+// fetch GETs a url data source. Sources saved before they had to be https
+// are refused here.
+func (s *ScheduleService) fetch(ctx context.Context, rawURL string) ([]byte, string, error) {
+	if _, err := safehttp.CheckURL(rawURL); err != nil {
+		return nil, "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, scheduleFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch data source: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("data source returned %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, scheduleFetchLimit+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read data source: %w", err)
+	}
+	if len(body) > scheduleFetchLimit {
+		return nil, "", errors.New("data source response is too large")
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/pdf"
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/signing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidCertificate is returned for uploads that aren't a usable
+	// PKCS#12 signing bundle.
+	ErrInvalidCertificate = errors.New("invalid signing certificate")
+	// ErrInvalidPDF is returned when a document to verify can't be read.
+	ErrInvalidPDF = errors.New("invalid PDF")
+)
+
+type SigningService struct {
+	repo repository.Repository
+	keys *signing.KeyBox
+	tsa  signing.Timestamper // nil when no timestamp authority is configured
+}
+
+func NewSigningService(repo repository.Repository, keys *signing.KeyBox, tsa signing.Timestamper) *SigningService {
+	return &SigningService{repo: repo, keys: keys, tsa: tsa}
+}
+
+// keyBinding ties a sealed key to its org and certificate.
+func keyBinding(orgID, id uuid.UUID) []byte {
+	return append(orgID[:], id[:]...)
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateCertificate stores the key and chain from a PKCS#12 bundle. An
+// empty name defaults to the certificate's common name.
+func (s *SigningService) CreateCertificate(ctx context.Context, orgID, userID uuid.UUID, name string, bundle []byte, password string) (*model.SigningCertificate, error) {
+	key, chain, err := signing.ParsePKCS12(bundle, password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+	leaf := chain[0]
+	if time.Now().After(leaf.NotAfter) {
+		return nil, fmt.Errorf("%w: certificate expired on %s", ErrInvalidCertificate, leaf.NotAfter.Format(time.DateOnly))
+	}
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment) == 0 {
+		return nil, fmt.Errorf("%w: certificate key usage does not allow signing", ErrInvalidCertificate)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = leaf.Subject.CommonName
+	}
+	cert := &model.SigningCertificate{
+		ID:           uuid.New(),
+		OrgID:        orgID,
+		Name:         name,
+		Subject:      leaf.Subject.String(),
+		Issuer:       leaf.Issuer.String(),
+		SerialNumber: leaf.SerialNumber.Text(16),
+		Fingerprint:  fingerprint(leaf),
+		NotBefore:    leaf.NotBefore,
+		NotAfter:     leaf.NotAfter,
+		CreatedBy:    &userID,
+		CreatedAt:    time.Now(),
+	}
+	for _, c := range chain {
+		cert.Chain = append(cert.Chain, c.Raw)
+	}
+	if cert.SealedKey, err = s.keys.Seal(pkcs8, keyBinding(orgID, cert.ID)); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateSigningCertificate(ctx, cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+func (s *SigningService) ListCertificates(ctx context.Context, orgID uuid.UUID) ([]model.SigningCertificate, error) {
+	return s.repo.ListSigningCertificates(ctx, orgID)
+}
+
+func (s *SigningService) GetCertificate(ctx context.Context, orgID, id uuid.UUID) (*model.SigningCertificate, error) {
+	return s.repo.GetSigningCertificate(ctx, orgID, id)
+}
+
+func (s *SigningService) DeleteCertificate(ctx context.Context, orgID, id uuid.UUID) error {
+	return s.repo.DeleteSigningCertificate(ctx, orgID, id)
+}
+
+// Signer unseals a certificate's key for the worker. timestamp asks for
+// an RFC 3161 timestamp, which needs a configured authority.
+func (s *SigningService) Signer(ctx context.Context, orgID, certificateID uuid.UUID, timestamp bool) (*signing.Signer, error) {
+	cert, err := s.repo.GetSigningCertificate(ctx, orgID, certificateID)
+	if err != nil {
+		return nil, fmt.Errorf("signing certificate %s: %w", certificateID, err)
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("signing certificate %s is not valid now", certificateID)
+	}
+	pkcs8, err := s.keys.Open(cert.SealedKey, keyBinding(orgID, cert.ID))
+	if err != nil {
+		return nil, fmt.Errorf("signing certificate %s: key can't be unsealed: %w", certificateID, err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(pkcs8)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing certificate %s: unsupported key", certificateID)
+	}
+	signer := &signing.Signer{Key: key}
+	for _, der := range cert.Chain {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		signer.Chain = append(signer.Chain, c)
+	}
+	if timestamp {
+		if s.tsa == nil {
+			return nil, errors.New("a timestamp was requested but no timestamp authority is configured")
+		}
+		signer.TSA = s.tsa
+	}
+	return signer, nil
+}
+
+// Verify checks every signature in a PDF against the org's certificates.
+func (s *SigningService) Verify(ctx context.Context, orgID uuid.UUID, data []byte) (*model.VerificationReport, error) {
+	sigs, err := pdf.Signatures(data)
+	if errors.Is(err, pdf.ErrEncrypted) {
+		return nil, fmt.Errorf("%w: encrypted documents can't be verified", ErrInvalidPDF)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+
+	report := &model.VerificationReport{Signatures: []model.SignatureVerification{}}
+	for _, info := range sigs {
+		v := model.SignatureVerification{
+			Field:    info.Field,
+			Signer:   info.Signer,
+			Reason:   info.Reason,
+			Location: info.Location,
+			SignedAt: info.Time,
+			Errors:   []string{},
+		}
+		if err := s.verifySignature(ctx, orgID, data, &info, &v); err != nil {
+			return nil, err
+		}
+		report.Signatures = append(report.Signatures, v)
+	}
+
+	report.Valid = len(report.Signatures) > 0
+	for _, v := range report.Signatures {
+		if len(v.Errors) > 0 {
+			report.Valid = false
+		}
+	}
+	return report, nil
+}
+
+// verifySignature fills in v. Problems with the signature are recorded in
+// v.Errors; only lookup failures are returned.
+func (s *SigningService) verifySignature(ctx context.Context, orgID uuid.UUID, data []byte, info *pdf.SignatureInfo, v *model.SignatureVerification) error {
+	signed, whole, err := info.SignedBytes(data)
+	if err != nil {
+		v.Errors = append(v.Errors, err.Error())
+		return nil
+	}
+	v.CoversWholeDocument = whole
+	if !whole {
+		v.Errors = append(v.Errors, "the document was changed after this signature")
+	}
+
+	result, err := signing.Verify(info.Contents, signed)
+	if err != nil {
+		v.Errors = append(v.Errors, err.Error())
+		return nil
+	}
+	v.Intact = true
+	v.Timestamp = result.Timestamp
+	if v.Signer == "" {
+		v.Signer = result.Signer.Subject.CommonName
+	}
+
+	certs, err := s.repo.FindSigningCertificates(ctx, orgID, fingerprint(result.Signer))
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		v.Errors = append(v.Errors, "not signed with one of this organization's certificates")
+		return nil
+	}
+	v.Certificate = &certs[0]
+
+	signedAt := v.SignedAt
+	if v.Timestamp != nil {
+		signedAt = v.Timestamp
+	}
+	if signedAt != nil && (signedAt.Before(result.Signer.NotBefore) || signedAt.After(result.Signer.NotAfter)) {
+		v.Errors = append(v.Errors, "signed outside the certificate's validity period")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const statusListenRetry = 2 * time.Second
+
+type statusKey struct {
+	kind string
+	id   uuid.UUID
+}
+
+// StatusEventHub fans job and batch status notifications out to in-process
+// subscribers. One Postgres LISTEN connection serves every subscriber.
+type StatusEventHub struct {
+	repo repository.Repository
+
+	mu   sync.Mutex
+	subs map[statusKey]map[chan model.StatusEvent]struct{}
+}
+
+func NewStatusEventHub(repo repository.Repository) *StatusEventHub {
+	return &StatusEventHub{repo: repo, subs: map[statusKey]map[chan model.StatusEvent]struct{}{}}
+}
+
+// Run listens for notifications until ctx is cancelled, reconnecting after
+// connection failures.
+func (h *StatusEventHub) Run(ctx context.Context) {
+	for {
+		err := h.repo.Listen(ctx, repository.StatusEventsChannel, func() {
+			log.Println("Status events: listening")
+		}, h.dispatch)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Status events: listener stopped: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(statusListenRetry):
+		}
+	}
+}
+
+func (h *StatusEventHub) dispatch(payload string) {
+	var ev model.StatusEvent
+	if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+		log.Printf("Status events: bad payload: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[statusKey{ev.Kind, ev.ID}] {
+		// Events are snapshots: a slow subscriber only needs the latest.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- ev
+	}
+}
+
+// Subscribe returns a channel of events for one job or batch and a function
+// that ends the subscription.
+func (h *StatusEventHub) Subscribe(kind string, id uuid.UUID) (<-chan model.StatusEvent, func()) {
+	key := statusKey{kind, id}
+	ch := make(chan model.StatusEvent, 1)
+
+	h.mu.Lock()
+	if h.subs[key] == nil {
+		h.subs[key] = map[chan model.StatusEvent]struct{}{}
+	}
+	h.subs[key][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[key], ch)
+		if len(h.subs[key]) == 0 {
+			delete(h.subs, key)
+		}
+	}
+}
@@ -2,20 +2,30 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"template-builder-api/internal/model"
+	"template-builder-api/internal/rendercache"
 	"template-builder-api/internal/repository"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrVersionPublished is returned for edits to a published version, which
+// is immutable.
+var ErrVersionPublished = errors.New("published versions cannot be edited")
+
 type TemplateService struct {
-	repo repository.Repository
+	repo   repository.Repository
+	events EventPublisher
+	// renders is told when a draft changes, so its cached renders go.
+	renders *rendercache.Cache
 }
 
-func NewTemplateService(repo repository.Repository) *TemplateService {
-	return &TemplateService{repo: repo}
+func NewTemplateService(repo repository.Repository, events EventPublisher, renders *rendercache.Cache) *TemplateService {
+	return &TemplateService{repo: repo, events: events, renders: renders}
 }
 
 func (s *TemplateService) CreateTemplate(ctx context.Context, orgID uuid.UUID, name string, tType string) (*model.Template, error) {
@@ -43,15 +53,61 @@ func (s *TemplateService) ListTemplates(ctx context.Context, orgID uuid.UUID) ([
 	return s.repo.ListTemplates(ctx, orgID)
 }
 
-func (s *TemplateService) GetTemplate(ctx context.Context, id uuid.UUID) (*model.Template, error) {
-	return s.repo.GetTemplate(ctx, id)
+func (s *TemplateService) GetTemplate(ctx context.Context, orgID, id uuid.UUID) (*model.Template, error) {
+	t, err := s.repo.GetTemplate(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	t.PostProcessing = t.PostProcessing.Redacted()
+	return t, nil
+}
+
+// UpdatePostProcessing replaces the post-processing applied to the
+// template's PDFs. Passwords are not returned; settings sent back with
+// passwordSet and no passwords keep the stored ones.
+func (s *TemplateService) UpdatePostProcessing(ctx context.Context, orgID, id uuid.UUID, pp *model.PostProcessing) (*model.Template, error) {
+	if err := validatePostProcessing(pp); err != nil {
+		return nil, err
+	}
+	current, err := s.repo.GetTemplate(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPostProcessing(ctx, s.repo, orgID, pp); err != nil {
+		return nil, err
+	}
+	if pp != nil && pp.Protection != nil {
+		p := pp.Protection
+		if p.PasswordSet && p.UserPassword == "" && p.OwnerPassword == "" &&
+			current.PostProcessing != nil && current.PostProcessing.Protection != nil {
+			p.UserPassword = current.PostProcessing.Protection.UserPassword
+			p.OwnerPassword = current.PostProcessing.Protection.OwnerPassword
+		}
+		p.PasswordSet = false
+	}
+
+	t, err := s.repo.UpdateTemplatePostProcessing(ctx, orgID, id, pp)
+	if err != nil {
+		return nil, err
+	}
+	t.PostProcessing = t.PostProcessing.Redacted()
+	return t, nil
 }
 
-func (s *TemplateService) ListVersions(ctx context.Context, templateID uuid.UUID) ([]model.TemplateVersion, error) {
-	return s.repo.ListTemplateVersions(ctx, templateID)
+func (s *TemplateService) ListVersions(ctx context.Context, orgID, templateID uuid.UUID) ([]model.TemplateVersion, error) {
+	return s.repo.ListTemplateVersions(ctx, orgID, templateID)
 }
 
-func (s *TemplateService) CreateVersion(ctx context.Context, templateID uuid.UUID, userID uuid.UUID, templateJSON map[string]any, schemaJSON map[string]any) (*model.TemplateVersion, error) {
+func (s *TemplateService) CreateVersion(ctx context.Context, orgID, templateID uuid.UUID, userID uuid.UUID, templateJSON map[string]any, schemaJSON map[string]any, pageSetup *model.PageSetup) (*model.TemplateVersion, error) {
+	if pageSetup != nil {
+		if err := validatePageSetup(pageSetup); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPageSetup, err)
+		}
+	}
+	if _, err := s.repo.GetTemplate(ctx, orgID, templateID); err != nil {
+		return nil, err
+	}
+
 	maxVersion, err := s.repo.GetMaxVersion(ctx, templateID)
 	if err != nil {
 		return nil, err
@@ -65,6 +121,7 @@ func (s *TemplateService) CreateVersion(ctx context.Context, templateID uuid.UUI
 		Status:       "draft",
 		TemplateJSON: templateJSON,
 		SchemaJSON:   schemaJSON,
+		PageSetup:    pageSetup,
 		CreatedBy:    &userID,
 		CreatedAt:    time.Now(),
 	}
@@ -74,3 +131,55 @@ func (s *TemplateService) CreateVersion(ctx context.Context, templateID uuid.UUI
 	}
 	return version, nil
 }
+
+// UpdateVersion replaces a draft version's layout, schema and page setup
+// and drops its cached renders.
+func (s *TemplateService) UpdateVersion(ctx context.Context, orgID, templateID uuid.UUID, version int, templateJSON map[string]any, schemaJSON map[string]any, pageSetup *model.PageSetup) (*model.TemplateVersion, error) {
+	if pageSetup != nil {
+		if err := validatePageSetup(pageSetup); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPageSetup, err)
+		}
+	}
+	current, err := s.repo.GetTemplateVersion(ctx, orgID, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == "published" {
+		return nil, ErrVersionPublished
+	}
+
+	current.TemplateJSON, current.SchemaJSON, current.PageSetup = templateJSON, schemaJSON, pageSetup
+	if err := s.repo.UpdateTemplateVersion(ctx, orgID, current); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Published since it was read
+			return nil, ErrVersionPublished
+		}
+		return nil, err
+	}
+
+	v := rendercache.Version{OrgID: orgID, TemplateID: templateID, Version: version, Draft: true}
+	if err := s.renders.InvalidateVersion(ctx, v); err != nil {
+		log.Printf("Failed to invalidate cached renders of template %s version %d: %v", templateID, version, err)
+	}
+	return current, nil
+}
+
+// PublishVersion marks a version as published. Publishing an already
+// published version is a no-op and does not emit another event.
+func (s *TemplateService) PublishVersion(ctx context.Context, orgID, templateID uuid.UUID, version int) (*model.TemplateVersion, error) {
+	current, err := s.repo.GetTemplateVersion(ctx, orgID, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == "published" {
+		current.TemplateJSON, current.SchemaJSON = nil, nil
+		return current, nil
+	}
+
+	published, err := s.repo.PublishTemplateVersion(ctx, orgID, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish(ctx, orgID, model.EventTemplateVersionPublished, published)
+	return published, nil
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Counters are buffered in memory and added to usage_daily this often.
+	usageFlushInterval = 10 * time.Second
+	// Plans and flushed totals used for quota checks are cached this long.
+	usageCacheTTL = 10 * time.Second
+)
+
+var (
+	// ErrQuotaExceeded means a monthly plan limit has been reached.
+	ErrQuotaExceeded = errors.New("plan quota exceeded")
+	// ErrDailyQuotaExceeded means the daily API call allowance is used up.
+	ErrDailyQuotaExceeded = errors.New("daily quota exceeded")
+)
+
+type usageKey struct {
+	orgID  uuid.UUID
+	day    time.Time
+	metric string
+}
+
+type cachedUsage struct {
+	plan    *model.Plan
+	month   map[string]int64
+	today   map[string]int64
+	expires time.Time
+}
+
+// UsageService meters per-org usage and enforces plan quotas.
+//
+// Quotas are soft by up to usageFlushInterval per process: each API and
+// worker instance buffers its own counters and only sees the others' once
+// they are flushed and its cache (usageCacheTTL) expires. With N instances
+// an org can exceed a limit by about N x 10s of usage before being
+// rejected. That is accepted for billing; a limit that must never be
+// exceeded would need a shared counter instead.
+type UsageService struct {
+	repo repository.Repository
+
+	mu      sync.Mutex
+	pending map[usageKey]int64
+	cache   map[uuid.UUID]*cachedUsage
+}
+
+func NewUsageService(repo repository.Repository) *UsageService {
+	return &UsageService{
+		repo:    repo,
+		pending: map[usageKey]int64{},
+		cache:   map[uuid.UUID]*cachedUsage{},
+	}
+}
+
+func utcDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func monthStart(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Record adds n to today's counter for metric. It never blocks on the
+// database; Run writes the counters out.
+func (s *UsageService) Record(orgID uuid.UUID, metric string, n int64) {
+	if n == 0 {
+		return
+	}
+	s.mu.Lock()
+	s.pending[usageKey{orgID, utcDay(time.Now()), metric}] += n
+	s.mu.Unlock()
+}
+
+// Run flushes recorded usage until ctx is cancelled, then flushes once more.
+func (s *UsageService) Run(ctx context.Context) {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.Flush(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			s.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes buffered counters to the daily rollup. Counters that fail
+// to write are kept for the next flush.
+func (s *UsageService) Flush(ctx context.Context) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[usageKey]int64{}
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	deltas := make([]model.UsageDelta, 0, len(pending))
+	for k, v := range pending {
+		deltas = append(deltas, model.UsageDelta{OrgID: k.orgID, Day: k.day, Metric: k.metric, Value: v})
+	}
+	if err := s.repo.AddUsage(ctx, deltas); err != nil {
+		log.Printf("Usage: flush failed: %v", err)
+		s.mu.Lock()
+		for k, v := range pending {
+			s.pending[k] += v
+		}
+		s.mu.Unlock()
+	}
+}
+
+// current returns the org's plan and its usage this month and today,
+// including counters not yet flushed.
+func (s *UsageService) current(ctx context.Context, orgID uuid.UUID) (*model.Plan, map[string]int64, map[string]int64, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	c := s.cache[orgID]
+	s.mu.Unlock()
+
+	if c == nil || now.After(c.expires) {
+		plan, err := s.repo.GetOrgPlan(ctx, orgID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		month, err := s.repo.SumUsage(ctx, orgID, monthStart(now), utcDay(now))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		today, err := s.repo.SumUsage(ctx, orgID, utcDay(now), utcDay(now))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		c = &cachedUsage{plan: plan, month: month, today: today, expires: now.Add(usageCacheTTL)}
+		s.mu.Lock()
+		s.cache[orgID] = c
+		s.mu.Unlock()
+	}
+
+	month := map[string]int64{}
+	today := map[string]int64{}
+	for k, v := range c.month {
+		month[k] = v
+	}
+	for k, v := range c.today {
+		today[k] = v
+	}
+
+	s.mu.Lock()
+	for k, v := range s.pending {
+		if k.orgID != orgID {
+			continue
+		}
+		if !k.day.Before(monthStart(now)) {
+			month[k.metric] += v
+		}
+		if k.day.Equal(utcDay(now)) {
+			today[k.metric] += v
+		}
+	}
+	s.mu.Unlock()
+
+	return c.plan, month, today, nil
+}
+
+// CheckDocuments reports whether the org may generate n more documents this
+// month. Pages and render time are checked as already-used allowances,
+// since a document's cost is only known once it is rendered.
+func (s *UsageService) CheckDocuments(ctx context.Context, orgID uuid.UUID, n int64) error {
+	plan, month, _, err := s.current(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxDocuments != nil && month[model.MetricDocuments]+n > *plan.MaxDocuments {
+		return fmt.Errorf("%w: %d of %d documents this month used", ErrQuotaExceeded, month[model.MetricDocuments], *plan.MaxDocuments)
+	}
+	if plan.MaxPages != nil && month[model.MetricPages] >= *plan.MaxPages {
+		return fmt.Errorf("%w: %d pages this month used", ErrQuotaExceeded, *plan.MaxPages)
+	}
+	if plan.MaxRenderSeconds != nil && month[model.MetricRenderMS] >= *plan.MaxRenderSeconds*1000 {
+		return fmt.Errorf("%w: %d render seconds this month used", ErrQuotaExceeded, *plan.MaxRenderSeconds)
+	}
+	return nil
+}
+
+// CheckStorage reports whether the org may store size more bytes.
+func (s *UsageService) CheckStorage(ctx context.Context, orgID uuid.UUID, size int64) error {
+	plan, _, _, err := s.current(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxStorageBytes == nil {
+		return nil
+	}
+	used, err := s.repo.GetStorageBytes(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if used+size > *plan.MaxStorageBytes {
+		return fmt.Errorf("%w: storage limit of %d bytes reached", ErrQuotaExceeded, *plan.MaxStorageBytes)
+	}
+	return nil
+}
+
+// CheckUpload reports whether the org's plan allows a single upload of size
+// bytes.
+func (s *UsageService) CheckUpload(ctx context.Context, orgID uuid.UUID, size int64) error {
+	plan, _, _, err := s.current(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxUploadBytes != nil && size > *plan.MaxUploadBytes {
+		return fmt.Errorf("%w: uploads are limited to %d bytes on the %s plan", ErrAssetTooLarge, *plan.MaxUploadBytes, plan.Name)
+	}
+	return nil
+}
+
+// CheckAPICall reports whether the org is still within its daily API call
+// allowance.
+func (s *UsageService) CheckAPICall(ctx context.Context, orgID uuid.UUID) error {
+	plan, _, today, err := s.current(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxAPICallsPerDay != nil && today[model.MetricAPICalls] >= *plan.MaxAPICallsPerDay {
+		return fmt.Errorf("%w: %d API calls per day", ErrDailyQuotaExceeded, *plan.MaxAPICallsPerDay)
+	}
+	return nil
+}
+
+// GetUsage reports usage for days in [from, to] along with the org's plan
+// and current storage.
+func (s *UsageService) GetUsage(ctx context.Context, orgID uuid.UUID, from, to time.Time) (*model.UsageReport, error) {
+	plan, err := s.repo.GetOrgPlan(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.ListDailyUsage(ctx, orgID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := s.repo.GetStorageBytes(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.UsageReport{
+		OrgID:        orgID,
+		Plan:         plan,
+		From:         from.Format(time.DateOnly),
+		To:           to.Format(time.DateOnly),
+		StorageBytes: storage,
+		Daily:        []model.DailyUsage{},
+	}
+	for _, row := range rows {
+		day := row.Day.Format(time.DateOnly)
+		if n := len(report.Daily); n == 0 || report.Daily[n-1].Day != day {
+			report.Daily = append(report.Daily, model.DailyUsage{Day: day})
+		}
+		addUsage(&report.Daily[len(report.Daily)-1].UsageTotals, row.Metric, row.Value)
+		addUsage(&report.Totals, row.Metric, row.Value)
+	}
+	return report, nil
+}
+
+func addUsage(t *model.UsageTotals, metric string, value int64) {
+	switch metric {
+	case model.MetricDocuments:
+		t.Documents += value
+	case model.MetricPages:
+		t.Pages += value
+	case model.MetricRenderMS:
+		t.RenderSeconds += float64(value) / 1000
+	case model.MetricAPICalls:
+		t.APICalls += value
+	case model.MetricRenderCacheHits:
+		t.RenderCacheHits += value
+	case model.MetricRenderCacheMisses:
+		t.RenderCacheMisses += value
+	}
+}
+
+// PlanID returns the org's plan ID, from the same short-lived cache as the
+// quota checks.
+func (s *UsageService) PlanID(ctx context.Context, orgID uuid.UUID) (string, error) {
+	plan, _, _, err := s.current(ctx, orgID)
+	if err != nil {
+		return "", err
+	}
+	return plan.ID, nil
+}
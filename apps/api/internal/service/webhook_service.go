@@ -0,0 +1,322 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	mrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"template-builder-api/internal/model"
+	"template-builder-api/internal/repository"
+	"template-builder-api/internal/safehttp"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookMaxAttempts  = 10
+	webhookBaseDelay    = 30 * time.Second
+	webhookMaxDelay     = 6 * time.Hour
+	webhookTimeout      = 10 * time.Second
+	webhookLease        = time.Minute
+	webhookBatchSize    = 20
+	webhookPollInterval = 2 * time.Second
+	webhookMaxBodyLog   = 1024
+)
+
+// ErrInvalidWebhook wraps endpoint validation failures.
+var ErrInvalidWebhook = errors.New("invalid webhook endpoint")
+
+// EventPublisher records domain events for delivery to subscribers.
+// Publishing never fails the caller's operation; errors are logged.
+type EventPublisher interface {
+	Publish(ctx context.Context, orgID uuid.UUID, eventType string, data any)
+}
+
+type WebhookService struct {
+	repo   repository.Repository
+	client *http.Client
+}
+
+func NewWebhookService(repo repository.Repository) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		client: safehttp.Client,
+	}
+}
+
+func validateWebhook(rawURL string, events []string) error {
+	if _, err := safehttp.CheckURL(rawURL); err != nil {
+		return fmt.Errorf("%w: url: %v", ErrInvalidWebhook, err)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	for _, e := range events {
+		if !slices.Contains(model.WebhookEvents, e) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+	return nil
+}
+
+// CreateEndpoint registers an endpoint. The returned endpoint carries the
+// signing secret; it is not retrievable afterwards.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, orgID uuid.UUID, rawURL, description string, events []string) (*model.WebhookEndpoint, error) {
+	if err := validateWebhook(rawURL, events); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	endpoint := &model.WebhookEndpoint{
+		ID:          uuid.New(),
+		OrgID:       orgID,
+		URL:         rawURL,
+		Description: description,
+		Secret:      "whsec_" + hex.EncodeToString(secret),
+		Events:      slices.Compact(slices.Sorted(slices.Values(events))),
+		Active:      true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.CreateWebhookEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context, orgID uuid.UUID) ([]model.WebhookEndpoint, error) {
+	return s.repo.ListWebhookEndpoints(ctx, orgID)
+}
+
+func (s *WebhookService) GetEndpoint(ctx context.Context, orgID, id uuid.UUID) (*model.WebhookEndpoint, error) {
+	return s.repo.GetWebhookEndpoint(ctx, orgID, id)
+}
+
+func (s *WebhookService) UpdateEndpoint(ctx context.Context, orgID, id uuid.UUID, rawURL, description string, events []string, active bool) (*model.WebhookEndpoint, error) {
+	if err := validateWebhook(rawURL, events); err != nil {
+		return nil, err
+	}
+	endpoint, err := s.repo.GetWebhookEndpoint(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.URL = rawURL
+	endpoint.Description = description
+	endpoint.Events = slices.Compact(slices.Sorted(slices.Values(events)))
+	endpoint.Active = active
+	if err := s.repo.UpdateWebhookEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	endpoint.UpdatedAt = time.Now()
+	return endpoint, nil
+}
+
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, orgID, id uuid.UUID) error {
+	return s.repo.DeleteWebhookEndpoint(ctx, orgID, id)
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, orgID, endpointID uuid.UUID, limit, offset int) ([]model.WebhookDelivery, error) {
+	if _, err := s.repo.GetWebhookEndpoint(ctx, orgID, endpointID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListWebhookDeliveries(ctx, orgID, endpointID, limit, offset)
+}
+
+// Redeliver queues a fresh delivery of a previous event to the same
+// endpoint. The event ID is kept so receivers can deduplicate.
+func (s *WebhookService) Redeliver(ctx context.Context, orgID, endpointID, deliveryID uuid.UUID) (*model.WebhookDelivery, error) {
+	prev, err := s.repo.GetWebhookDelivery(ctx, orgID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if prev.EndpointID != endpointID {
+		return nil, repository.ErrNotFound
+	}
+
+	now := time.Now()
+	d := model.WebhookDelivery{
+		ID:            uuid.New(),
+		OrgID:         orgID,
+		EndpointID:    prev.EndpointID,
+		EventID:       prev.EventID,
+		EventType:     prev.EventType,
+		Payload:       prev.Payload,
+		Status:        "pending",
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.repo.CreateWebhookDeliveries(ctx, []model.WebhookDelivery{d}); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Publish fans an event out to every subscribed endpoint of the org as
+// pending deliveries; the dispatcher sends them.
+func (s *WebhookService) Publish(ctx context.Context, orgID uuid.UUID, eventType string, data any) {
+	endpoints, err := s.repo.ListWebhookEndpointsForEvent(ctx, orgID, eventType)
+	if err != nil {
+		log.Printf("Webhooks: failed to load endpoints for %s: %v", eventType, err)
+		return
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+
+	now := time.Now()
+	eventID := uuid.New()
+	payload, err := json.Marshal(map[string]any{
+		"id":        eventID,
+		"type":      eventType,
+		"createdAt": now.UTC(),
+		"data":      data,
+	})
+	if err != nil {
+		log.Printf("Webhooks: failed to encode %s: %v", eventType, err)
+		return
+	}
+
+	deliveries := make([]model.WebhookDelivery, 0, len(endpoints))
+	for _, e := range endpoints {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			ID:            uuid.New(),
+			OrgID:         orgID,
+			EndpointID:    e.ID,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       payload,
+			Status:        "pending",
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if err := s.repo.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		log.Printf("Webhooks: failed to queue %s: %v", eventType, err)
+	}
+}
+
+// RunDispatcher sends due deliveries until ctx is cancelled.
+func (s *WebhookService) RunDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		deliveries, err := s.repo.ClaimDueWebhookDeliveries(ctx, webhookBatchSize, webhookLease)
+		if err != nil {
+			log.Printf("Webhooks: claim failed: %v", err)
+		}
+		for i := range deliveries {
+			s.deliver(ctx, &deliveries[i])
+		}
+		if len(deliveries) == webhookBatchSize {
+			continue // more may be due; don't wait
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WebhookService) deliver(ctx context.Context, d *model.WebhookDelivery) {
+	code, body, err := s.send(ctx, d)
+
+	d.ResponseCode, d.ResponseBody, d.ErrorMessage = nil, body, ""
+	if code != 0 {
+		d.ResponseCode = &code
+	}
+	if err != nil {
+		d.ErrorMessage = err.Error()
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil && code >= 200 && code < 300:
+		d.Status, d.DeliveredAt, d.NextAttemptAt = "succeeded", &now, nil
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status, d.NextAttemptAt = "failed", nil
+	default:
+		next := now.Add(webhookBackoff(d.Attempts))
+		d.Status, d.NextAttemptAt = "pending", &next
+	}
+
+	if err := s.repo.RecordWebhookAttempt(ctx, d); err != nil {
+		log.Printf("Webhooks: %v", err)
+	}
+}
+
+// send POSTs the payload with a signature over "timestamp.body", so a
+// captured request cannot be replayed once the receiver's tolerance for
+// the timestamp has passed. Endpoints registered before URLs had to be
+// https are refused here.
+func (s *WebhookService) send(ctx context.Context, d *model.WebhookDelivery) (int, string, error) {
+	if _, err := safehttp.CheckURL(d.URL); err != nil {
+		return 0, "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "template-builder-webhooks/1")
+	req.Header.Set("Webhook-Id", d.EventID.String())
+	req.Header.Set("Webhook-Event", d.EventType)
+	req.Header.Set("Webhook-Timestamp", ts)
+	req.Header.Set("Webhook-Signature", "t="+ts+",v1="+SignWebhook(d.Secret, ts, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBodyLog))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "timestamp.payload".
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is exponential from webhookBaseDelay with +/-20% jitter.
+func webhookBackoff(attempt int) time.Duration {
+	d := float64(webhookBaseDelay) * math.Pow(2, float64(attempt-1))
+	d = math.Min(d, float64(webhookMaxDelay))
+	jitter := 0.8 + 0.4*mrand.Float64()
+	return time.Duration(d * jitter)
+}
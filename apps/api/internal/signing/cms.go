@@ -0,0 +1,159 @@
+package signing
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+)
+
+// ErrInvalidSignature is returned when a CMS signature is malformed or
+// does not match the signed content.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+var (
+	oidAttrSigningCertV2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidAttrTimestampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+)
+
+// essCertIDv2 identifies the signing certificate (RFC 5035). The hash
+// algorithm defaults to SHA-256 and is left out.
+type essCertIDv2 struct {
+	Hash         []byte
+	IssuerSerial issuerSerial
+}
+
+type issuerSerial struct {
+	Issuer []asn1.RawValue
+	Serial *big.Int
+}
+
+// Signer produces detached CAdES signatures over PDF byte ranges: PAdES
+// baseline B-B, or B-T when a timestamp authority is configured.
+type Signer struct {
+	Key crypto.Signer
+	// Chain is the signing certificate followed by its issuers.
+	Chain []*x509.Certificate
+	// TSA, when set, timestamps the signature value.
+	TSA Timestamper
+}
+
+// Sign returns a DER ContentInfo holding the SignedData for content.
+func (s *Signer) Sign(ctx context.Context, content []byte) ([]byte, error) {
+	if len(s.Chain) == 0 {
+		return nil, errors.New("signing: no certificate")
+	}
+	cert := s.Chain[0]
+	certHash := sha256.Sum256(cert.Raw)
+	ess, err := asn1.Marshal(struct{ Certs []essCertIDv2 }{[]essCertIDv2{{
+		Hash: certHash[:],
+		IssuerSerial: issuerSerial{
+			// GeneralNames with a single directoryName [4]
+			Issuer: []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: cert.RawIssuer}},
+			Serial: cert.SerialNumber,
+		},
+	}}})
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	err = sd.AddSignerChain(cert, s.Key, s.Chain[1:], pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{{Type: oidAttrSigningCertV2, Value: asn1.RawValue{FullBytes: ess}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+
+	if s.TSA != nil {
+		si := &sd.GetSignedData().SignerInfos[0]
+		sigDigest := sha256.Sum256(si.EncryptedDigest)
+		token, err := s.TSA.Timestamp(ctx, sigDigest[:], crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("signing: timestamp: %w", err)
+		}
+		err = si.SetUnauthenticatedAttributes([]pkcs7.Attribute{{Type: oidAttrTimestampToken, Value: asn1.RawValue{FullBytes: token}}})
+		if err != nil {
+			return nil, fmt.Errorf("signing: timestamp: %w", err)
+		}
+	}
+
+	sd.Detach()
+	return sd.Finish()
+}
+
+// Verification describes a signature that checked out.
+type Verification struct {
+	Signer *x509.Certificate
+	// Certificates are all certificates embedded in the signature.
+	Certificates []*x509.Certificate
+	// Timestamp is the time asserted by an embedded RFC 3161 token, whose
+	// signature and message imprint have been checked. The TSA's
+	// certificate chain is not validated.
+	Timestamp *time.Time
+	TSA       *x509.Certificate
+}
+
+// Verify checks a detached CMS signature over content: the message digest,
+// the signature value and, if present, the signature timestamp. It says
+// nothing about whether the signer is trusted.
+func Verify(der, content []byte) (*Verification, error) {
+	p7, err := parseSignedData(der)
+	if err != nil {
+		return nil, err
+	}
+	p7.Content = content
+	if err := p7.Verify(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	v := &Verification{Signer: p7.GetOnlySigner(), Certificates: p7.Certificates}
+
+	si := p7.Signers[0]
+	for _, attr := range si.UnauthenticatedAttributes {
+		if !attr.Type.Equal(oidAttrTimestampToken) {
+			continue
+		}
+		info, tsa, err := verifyTimestampToken(attr.Value.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: timestamp: %v", ErrInvalidSignature, err)
+		}
+		if err := info.MessageImprint.check(si.EncryptedDigest); err != nil {
+			return nil, fmt.Errorf("%w: timestamp does not cover the signature", ErrInvalidSignature)
+		}
+		v.Timestamp, v.TSA = &info.GenTime, tsa
+		break
+	}
+	return v, nil
+}
+
+// parseSignedData parses a SignedData with exactly one signer whose
+// certificate is embedded.
+func parseSignedData(der []byte) (*pkcs7.PKCS7, error) {
+	// PDF signature placeholders are zero padded after the DER
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	p7, err := pkcs7.Parse(raw.FullBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if len(p7.Signers) != 1 {
+		return nil, fmt.Errorf("%w: expected one signer, found %d", ErrInvalidSignature, len(p7.Signers))
+	}
+	if p7.GetOnlySigner() == nil {
+		return nil, fmt.Errorf("%w: signing certificate not embedded", ErrInvalidSignature)
+	}
+	return p7, nil
+}
@@ -0,0 +1,195 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testContent = []byte("%PDF-1.7 byte ranges to be signed")
+
+// verifyWithOpenSSL checks a detached signature over content with
+// openssl cms, against ca as the only trust anchor.
+func verifyWithOpenSSL(t *testing.T, sig, content []byte, ca *testCert) error {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "sig.der", sig)
+	writeFile(t, dir, "content.bin", content)
+	writePEM(t, dir, "ca", ca)
+	cmd := exec.Command(openssl(t), "cms", "-verify", "-binary", "-inform", "DER", "-in", "sig.der",
+		"-content", "content.bin", "-CAfile", "ca.pem", "-purpose", "any", "-out", os.DevNull)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.New(string(out))
+	}
+	return nil
+}
+
+func TestSignVerifiedByOpenSSL(t *testing.T) {
+	for name, key := range map[string]crypto.Signer{"rsa": rsaKey(t), "ecdsa": ecdsaKey(t)} {
+		t.Run(name, func(t *testing.T) {
+			pki := newTestPKI(t, key)
+			signer := &Signer{Key: pki.leaf.key, Chain: []*x509.Certificate{pki.leaf.cert, pki.ca.cert}}
+			sig, err := signer.Sign(context.Background(), testContent)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := verifyWithOpenSSL(t, sig, testContent, pki.ca); err != nil {
+				t.Fatalf("openssl rejected the signature: %v", err)
+			}
+			tampered := append([]byte("x"), testContent...)
+			if err := verifyWithOpenSSL(t, sig, tampered, pki.ca); err == nil {
+				t.Error("openssl accepted the signature over other content")
+			}
+
+			// As stored in a PDF, padded to the placeholder's size
+			padded := append(sig, make([]byte, 512)...)
+			v, err := Verify(padded, testContent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !v.Signer.Equal(pki.leaf.cert) || len(v.Certificates) != 2 || v.Timestamp != nil {
+				t.Errorf("Verify = %+v", v)
+			}
+			if _, err := Verify(padded, tampered); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("tampered content: error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestVerifyOpenSSLSignature(t *testing.T) {
+	pki := newTestPKI(t, rsaKey(t))
+	dir := t.TempDir()
+	writePEM(t, dir, "leaf", pki.leaf)
+	writePEM(t, dir, "ca", pki.ca)
+	writeFile(t, dir, "content.bin", testContent)
+	runOpenSSL(t, dir, "cms", "-sign", "-binary", "-md", "sha256", "-in", "content.bin",
+		"-signer", "leaf.pem", "-inkey", "leaf.key", "-certfile", "ca.pem", "-outform", "DER", "-out", "sig.der")
+	sig, err := os.ReadFile(filepath.Join(dir, "sig.der"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Verify(sig, testContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Signer.Equal(pki.leaf.cert) {
+		t.Errorf("signer = %s, want %s", v.Signer.Subject, pki.leaf.cert.Subject)
+	}
+	if _, err := Verify(sig, testContent[1:]); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("other content: error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+// opensslTSA serves RFC 3161 requests with openssl ts.
+func opensslTSA(t *testing.T) (*httptest.Server, *testCert) {
+	t.Helper()
+	openssl(t)
+	ca := newTestCert(t, "Test TSA CA", ecdsaKey(t), nil, false)
+	tsa := newTestCert(t, "Test TSA", ecdsaKey(t), ca, true)
+	dir := t.TempDir()
+	writePEM(t, dir, "tsa", tsa)
+	writeFile(t, dir, "serial", []byte("01\n"))
+	writeFile(t, dir, "tsa.cnf", []byte(`[ tsa ]
+default_tsa = tsa_config
+[ tsa_config ]
+serial = ./serial
+signer_digest = sha256
+default_policy = 1.2.3.4.1
+digests = sha256
+ess_cert_id_alg = sha256
+`))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		writeFile(t, dir, "query.tsq", query)
+		cmd := exec.Command(openssl(t), "ts", "-reply", "-config", "tsa.cnf", "-queryfile", "query.tsq",
+			"-signer", "tsa.pem", "-inkey", "tsa.key", "-out", "reply.tsr")
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("openssl ts: %v\n%s", err, out)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		reply, _ := os.ReadFile(filepath.Join(dir, "reply.tsr"))
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, tsa
+}
+
+func TestSignWithTimestamp(t *testing.T) {
+	srv, tsa := opensslTSA(t)
+	pki := newTestPKI(t, ecdsaKey(t))
+	signer := &Signer{
+		Key:   pki.leaf.key,
+		Chain: []*x509.Certificate{pki.leaf.cert, pki.ca.cert},
+		TSA:   NewHTTPTimestamper(srv.URL),
+	}
+	before := time.Now().Add(-time.Minute)
+	sig, err := signer.Sign(context.Background(), testContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyWithOpenSSL(t, sig, testContent, pki.ca); err != nil {
+		t.Fatalf("openssl rejected the signature: %v", err)
+	}
+	v, err := Verify(sig, testContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Timestamp == nil || v.Timestamp.Before(before) || v.Timestamp.After(time.Now().Add(time.Minute)) {
+		t.Errorf("timestamp = %v, want about now", v.Timestamp)
+	}
+	if v.TSA == nil || !v.TSA.Equal(tsa.cert) {
+		t.Errorf("TSA = %v, want %s", v.TSA, tsa.cert.Subject)
+	}
+}
+
+func TestParseTimestampResponseChecksRequest(t *testing.T) {
+	srv, _ := opensslTSA(t)
+	tsa := NewHTTPTimestamper(srv.URL)
+	digest := bytes.Repeat([]byte{1}, 32)
+	token, err := tsa.Timestamp(context.Background(), digest, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _, err := verifyTimestampToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(info.MessageImprint.HashedMessage, digest) || info.Nonce == nil {
+		t.Errorf("token info = %+v", info)
+	}
+
+	// A reply to another request is refused
+	resp, err := asn1.Marshal(timeStampResp{
+		Status: asn1.RawValue{FullBytes: []byte{0x30, 0x03, 0x02, 0x01, 0x00}},
+		Token:  asn1.RawValue{FullBytes: token},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseTimestampResponse(resp, bytes.Repeat([]byte{2}, 32), info.Nonce); err == nil {
+		t.Error("accepted a token for another digest")
+	}
+	if _, err := parseTimestampResponse(resp, digest, info.Nonce); err != nil {
+		t.Errorf("same request: %v", err)
+	}
+}
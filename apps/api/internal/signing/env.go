@@ -0,0 +1,34 @@
+package signing
+
+import (
+	"log"
+	"os"
+)
+
+// devKeySecret seals keys when SIGNING_KEY_SECRET isn't set. Keys sealed
+// under it can't be opened once a real secret is configured.
+const devKeySecret = "dev-signing-secret-change-me"
+
+// KeyBoxFromEnv builds the KeyBox for stored signing keys from
+// SIGNING_KEY_SECRET. The API and the worker must use the same secret.
+func KeyBoxFromEnv() (*KeyBox, error) {
+	secret := os.Getenv("SIGNING_KEY_SECRET")
+	if secret == "" {
+		log.Printf("Warning: SIGNING_KEY_SECRET not set, using a development secret")
+		secret = devKeySecret
+	}
+	return NewKeyBox(secret)
+}
+
+// TimestamperFromEnv returns the timestamp authority configured by TSA_URL
+// (with optional TSA_USERNAME and TSA_PASSWORD), or nil when there is none.
+func TimestamperFromEnv() Timestamper {
+	url := os.Getenv("TSA_URL")
+	if url == "" {
+		return nil
+	}
+	tsa := NewHTTPTimestamper(url)
+	tsa.Username = os.Getenv("TSA_USERNAME")
+	tsa.Password = os.Getenv("TSA_PASSWORD")
+	return tsa
+}
@@ -0,0 +1,51 @@
+package signing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// KeyBox seals signing keys at rest with AES-256-GCM under a server-side
+// secret. The additional data binds a sealed key to the row it belongs to,
+// so a key copied onto another org's certificate won't open.
+type KeyBox struct {
+	aead cipher.AEAD
+}
+
+// NewKeyBox derives the sealing key from secret.
+func NewKeyBox(secret string) (*KeyBox, error) {
+	if secret == "" {
+		return nil, errors.New("signing: empty key secret")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyBox{aead: aead}, nil
+}
+
+// Seal encrypts plaintext; the nonce is prepended to the result.
+func (b *KeyBox) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open reverses Seal.
+func (b *KeyBox) Open(sealed, additionalData []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("signing: sealed key too short")
+	}
+	return b.aead.Open(nil, sealed[:n], sealed[n:], additionalData)
+}
@@ -0,0 +1,84 @@
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	// ErrInvalidPKCS12 is returned for bundles that cannot be read or do
+	// not hold exactly one usable key with its certificate.
+	ErrInvalidPKCS12 = errors.New("invalid PKCS#12 bundle")
+	// ErrPKCS12Password is returned when the bundle's password is wrong.
+	ErrPKCS12Password = errors.New("wrong PKCS#12 password")
+)
+
+// ParsePKCS12 reads a .p12/.pfx bundle holding one private key and its
+// certificate chain, in the current OpenSSL format (PBES2 with AES) or the
+// legacy ones. The chain starts with the key's certificate.
+func ParsePKCS12(data []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	parsed, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) || errors.Is(err, pkcs12.ErrDecryption) {
+		return nil, nil, ErrPKCS12Password
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidPKCS12, err)
+	}
+
+	var key crypto.Signer
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key = k
+	case *ecdsa.PrivateKey:
+		key = k
+	default:
+		return nil, nil, fmt.Errorf("%w: only RSA and ECDSA keys are supported", ErrInvalidPKCS12)
+	}
+
+	chain, err := orderChain(key, append([]*x509.Certificate{cert}, caCerts...))
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, chain, nil
+}
+
+// orderChain puts the certificate matching key first, followed by its
+// issuers as far as the bundle has them. Bundles don't always list the
+// key's certificate first.
+func orderChain(key crypto.Signer, certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	type publicKey interface{ Equal(crypto.PublicKey) bool }
+	var leaf *x509.Certificate
+	for _, c := range certs {
+		if pub, ok := key.Public().(publicKey); ok && pub.Equal(c.PublicKey) {
+			leaf = c
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("%w: no certificate matches the private key", ErrInvalidPKCS12)
+	}
+
+	chain := []*x509.Certificate{leaf}
+	for cur := leaf; len(chain) <= len(certs); {
+		var next *x509.Certificate
+		for _, c := range certs {
+			if c != cur && bytes.Equal(c.RawSubject, cur.RawIssuer) && cur.CheckSignatureFrom(c) == nil {
+				next = c
+				break
+			}
+		}
+		if next == nil || next == leaf {
+			break
+		}
+		chain = append(chain, next)
+		cur = next
+	}
+	return chain, nil
+}
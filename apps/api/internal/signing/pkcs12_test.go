@@ -0,0 +1,89 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestParsePKCS12FromOpenSSL(t *testing.T) {
+	pki := newTestPKI(t, rsaKey(t))
+	dir := t.TempDir()
+	writePEM(t, dir, "leaf", pki.leaf)
+	writePEM(t, dir, "ca", pki.ca)
+
+	for name, args := range map[string][]string{
+		// OpenSSL 3 defaults: PBES2 with AES-256 and a SHA-256 MAC
+		"default": nil,
+		"legacy":  {"-certpbe", "PBE-SHA1-3DES", "-keypbe", "PBE-SHA1-3DES", "-macalg", "sha1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(dir, name+".p12")
+			runOpenSSL(t, dir, append([]string{"pkcs12", "-export",
+				"-in", "leaf.pem", "-inkey", "leaf.key", "-certfile", "ca.pem",
+				"-passout", "pass:bundle secret", "-out", out}, args...)...)
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			key, chain, err := ParsePKCS12(data, "bundle secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(pki.leaf.key.Public()) {
+				t.Error("private key does not match the signing certificate")
+			}
+			if len(chain) != 2 || !chain[0].Equal(pki.leaf.cert) || !chain[1].Equal(pki.ca.cert) {
+				t.Errorf("chain = %v, want leaf then CA", subjects(chain))
+			}
+
+			if _, _, err := ParsePKCS12(data, "wrong"); !errors.Is(err, ErrPKCS12Password) {
+				t.Errorf("wrong password: error = %v, want ErrPKCS12Password", err)
+			}
+		})
+	}
+}
+
+func TestParsePKCS12OrdersChain(t *testing.T) {
+	pki := newTestPKI(t, ecdsaKey(t))
+	// The CA comes first in the bundle, before the key's certificate
+	data, err := pkcs12.Modern.Encode(pki.leaf.key, pki.ca.cert, []*x509.Certificate{pki.leaf.cert}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, chain, err := ParsePKCS12(data, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || !chain[0].Equal(pki.leaf.cert) || !chain[1].Equal(pki.ca.cert) {
+		t.Errorf("chain = %v, want leaf then CA", subjects(chain))
+	}
+}
+
+func TestParsePKCS12Mismatch(t *testing.T) {
+	pki := newTestPKI(t, ecdsaKey(t))
+	data, err := pkcs12.Modern.Encode(ecdsaKey(t), pki.leaf.cert, nil, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParsePKCS12(data, "secret"); !errors.Is(err, ErrInvalidPKCS12) {
+		t.Errorf("error = %v, want ErrInvalidPKCS12", err)
+	}
+	if _, _, err := ParsePKCS12([]byte("not a bundle"), "secret"); !errors.Is(err, ErrInvalidPKCS12) {
+		t.Errorf("garbage: error = %v, want ErrInvalidPKCS12", err)
+	}
+}
+
+func subjects(certs []*x509.Certificate) []string {
+	var out []string
+	for _, c := range certs {
+		out = append(out, c.Subject.CommonName)
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate with its key.
+type testCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+var testSerial int64
+
+// oidTimeStamping is the extended key usage of timestamp authorities.
+var oidTimeStamping = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+
+// newTestCert issues a certificate for key, signed by parent or, when
+// parent is nil, self-signed as a CA. A TSA certificate carries the
+// critical timestamping usage RFC 3161 requires.
+func newTestCert(t *testing.T, name string, key crypto.Signer, parent *testCert, tsa bool) *testCert {
+	t.Helper()
+	testSerial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"Template Builder Test"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if tsa {
+		eku, _ := asn1.Marshal([]asn1.ObjectIdentifier{oidTimeStamping})
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 37}, Critical: true, Value: eku}}
+	}
+	issuer, signer := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func rsaKey(t *testing.T) crypto.Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func ecdsaKey(t *testing.T) crypto.Signer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// testPKI is a CA with a signing certificate it issued.
+type testPKI struct {
+	ca, leaf *testCert
+}
+
+func newTestPKI(t *testing.T, leafKey crypto.Signer) testPKI {
+	t.Helper()
+	ca := newTestCert(t, "Test CA", ecdsaKey(t), nil, false)
+	return testPKI{ca: ca, leaf: newTestCert(t, "Test Signer", leafKey, ca, false)}
+}
+
+// openssl returns the path of the openssl binary, skipping the test when
+// there is none.
+func openssl(t *testing.T) string {
+	t.Helper()
+	path, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+	return path
+}
+
+// runOpenSSL runs openssl with args in dir and returns its output.
+func runOpenSSL(t *testing.T, dir string, args ...string) []byte {
+	t.Helper()
+	cmd := exec.Command(openssl(t), args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("openssl %v: %v\n%s", args, err, out)
+	}
+	return out
+}
+
+// writeFile writes data to name in dir and returns its path.
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writePEM writes c's certificate and key as name.pem and name.key.
+func writePEM(t *testing.T, dir, name string, c *testCert) {
+	t.Helper()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, name+".pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}))
+	writeFile(t, dir, name+".key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
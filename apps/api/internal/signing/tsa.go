@@ -0,0 +1,223 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// Timestamper obtains RFC 3161 timestamp tokens. HTTPTimestamper talks to
+// a TSA over HTTP; other transports or test doubles can stand in.
+type Timestamper interface {
+	// Timestamp returns a DER TimeStampToken (a CMS ContentInfo) for digest.
+	Timestamp(ctx context.Context, digest []byte, h crypto.Hash) ([]byte, error)
+}
+
+// HTTPTimestamper is an RFC 3161 client for TSAs reachable over HTTP.
+type HTTPTimestamper struct {
+	URL string
+	// Username and Password, when set, are sent as basic auth.
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+func NewHTTPTimestamper(url string) *HTTPTimestamper {
+	return &HTTPTimestamper{URL: url, Client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// check reports whether the imprint is a hash of data.
+func (m messageImprint) check(data []byte) error {
+	h, ok := hashForOID(m.HashAlgorithm.Algorithm)
+	if !ok {
+		return fmt.Errorf("unsupported imprint hash %v", m.HashAlgorithm.Algorithm)
+	}
+	hh := h.New()
+	hh.Write(data)
+	if !bytes.Equal(hh.Sum(nil), m.HashedMessage) {
+		return errors.New("message imprint mismatch")
+	}
+	return nil
+}
+
+type timeStampResp struct {
+	Status asn1.RawValue
+	Token  asn1.RawValue `asn1:"optional"`
+}
+
+// tstInfo is the signed content of a timestamp token, up to genTime.
+// Accuracy, ordering and the TSA name are not needed, and the nonce is
+// picked out by hand: the optional fields before it can't be told apart
+// by encoding/asn1.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+// tokenInfo is what a verified timestamp token asserts.
+type tokenInfo struct {
+	tstInfo
+	Nonce *big.Int
+}
+
+func (t *HTTPTimestamper) Timestamp(ctx context.Context, digest []byte, h crypto.Hash) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+	body, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: hashOID(h)}, HashedMessage: digest},
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	if t.Username != "" {
+		req.SetBasicAuth(t.Username, t.Password)
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tsa returned %d", resp.StatusCode)
+	}
+	return parseTimestampResponse(raw, digest, nonce)
+}
+
+// parseTimestampResponse extracts the token from a TimeStampResp and checks
+// it answers this request.
+func parseTimestampResponse(raw, digest []byte, nonce *big.Int) ([]byte, error) {
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("tsa response: %w", err)
+	}
+	var status int
+	if _, err := asn1.Unmarshal(resp.Status.Bytes, &status); err != nil {
+		return nil, fmt.Errorf("tsa response: %w", err)
+	}
+	// 0 granted, 1 granted with modifications
+	if status > 1 || len(resp.Token.FullBytes) == 0 {
+		return nil, fmt.Errorf("tsa rejected the request (status %d)", status)
+	}
+
+	info, _, err := verifyTimestampToken(resp.Token.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, errors.New("tsa token is for a different digest")
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("tsa token nonce mismatch")
+	}
+	return resp.Token.FullBytes, nil
+}
+
+// verifyTimestampToken checks a token's own signature and returns what it
+// asserts together with the TSA's certificate.
+func verifyTimestampToken(token []byte) (*tokenInfo, *x509.Certificate, error) {
+	p7, err := parseSignedData(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, nil, err
+	}
+	content := p7.Content
+	var info tokenInfo
+	if _, err := asn1.Unmarshal(content, &info.tstInfo); err != nil {
+		return nil, nil, errors.New("not a timestamp token")
+	}
+
+	// The nonce is the only INTEGER after genTime
+	var seq asn1.RawValue
+	asn1.Unmarshal(content, &seq)
+	for rest, i := seq.Bytes, 0; len(rest) > 0; i++ {
+		var field asn1.RawValue
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			break
+		}
+		if i > 4 && field.Class == asn1.ClassUniversal && field.Tag == asn1.TagInteger {
+			info.Nonce = new(big.Int)
+			asn1.Unmarshal(field.FullBytes, &info.Nonce)
+			break
+		}
+	}
+	return &info, p7.GetOnlySigner(), nil
+}
+
+var (
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+func hashForOID(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, true
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, true
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, true
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+func hashOID(h crypto.Hash) asn1.ObjectIdentifier {
+	switch h {
+	case crypto.SHA1:
+		return oidSHA1
+	case crypto.SHA384:
+		return oidSHA384
+	case crypto.SHA512:
+		return oidSHA512
+	}
+	return oidSHA256
+}
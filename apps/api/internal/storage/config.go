@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Backends
+const (
+	BackendMinIO  = "minio"
+	BackendS3     = "s3"
+	BackendFS     = "fs"
+	BackendMemory = "memory"
+)
+
+// devURLSecret signs download URLs when STORAGE_URL_SECRET isn't set.
+const devURLSecret = "dev-storage-url-secret-change-me"
+
+type Config struct {
+	Backend string
+	// MinIO and S3
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Region    string
+	UseSSL    bool
+	// Dir is the fs backend's root directory.
+	Dir string
+	// URLSecret signs the fs and memory backends' download URLs, which
+	// the API at PublicURL serves.
+	URLSecret string
+	PublicURL string
+}
+
+// ConfigFromEnv reads STORAGE_BACKEND (minio, s3, fs or memory; default
+// minio) and the backend's settings: STORAGE_ENDPOINT, STORAGE_ACCESS_KEY,
+// STORAGE_SECRET_KEY, STORAGE_REGION and STORAGE_USE_SSL for MinIO and S3;
+// STORAGE_DIR for fs; STORAGE_URL_SECRET and PUBLIC_URL for the URLs fs
+// and memory objects are downloaded from. The API and the worker must
+// agree.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Backend:   os.Getenv("STORAGE_BACKEND"),
+		Endpoint:  os.Getenv("STORAGE_ENDPOINT"),
+		AccessKey: os.Getenv("STORAGE_ACCESS_KEY"),
+		SecretKey: os.Getenv("STORAGE_SECRET_KEY"),
+		Region:    os.Getenv("STORAGE_REGION"),
+		Dir:       os.Getenv("STORAGE_DIR"),
+		URLSecret: os.Getenv("STORAGE_URL_SECRET"),
+		PublicURL: os.Getenv("PUBLIC_URL"),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = BackendMinIO
+	}
+	if v := os.Getenv("STORAGE_USE_SSL"); v != "" {
+		ssl, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid STORAGE_USE_SSL: %q", v)
+		}
+		cfg.UseSSL = ssl
+	} else {
+		cfg.UseSSL = cfg.Backend == BackendS3
+	}
+
+	switch cfg.Backend {
+	case BackendMinIO:
+		// Defaults from docker-compose
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "localhost:9000"
+		}
+		if cfg.AccessKey == "" && cfg.SecretKey == "" {
+			log.Printf("Warning: STORAGE_ACCESS_KEY not set, using MinIO's default credentials")
+			cfg.AccessKey, cfg.SecretKey = "minioadmin", "minioadmin"
+		}
+	case BackendS3:
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "s3.amazonaws.com"
+		}
+	case BackendFS:
+		if cfg.Dir == "" {
+			cfg.Dir = "data/storage"
+		}
+	case BackendMemory:
+	default:
+		return cfg, fmt.Errorf("invalid STORAGE_BACKEND: %q", cfg.Backend)
+	}
+
+	if cfg.Backend == BackendFS || cfg.Backend == BackendMemory {
+		if cfg.URLSecret == "" {
+			log.Printf("Warning: STORAGE_URL_SECRET not set, using a development secret")
+			cfg.URLSecret = devURLSecret
+		}
+		if cfg.PublicURL == "" {
+			cfg.PublicURL = "http://localhost:8080"
+		}
+	}
+	return cfg, nil
+}
+
+// Provider opens the configured backend's buckets, once each.
+type Provider struct {
+	cfg    Config
+	client *minio.Client
+	signer *URLSigner
+
+	mu      sync.Mutex
+	buckets map[string]Blob
+}
+
+func NewProvider(cfg Config) (*Provider, error) {
+	p := &Provider{cfg: cfg, buckets: map[string]Blob{}}
+	switch cfg.Backend {
+	case BackendMinIO, BackendS3:
+		var creds *credentials.Credentials
+		if cfg.AccessKey != "" {
+			creds = credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, "")
+		} else {
+			// Instance roles and the usual AWS environment
+			creds = credentials.NewChainCredentials([]credentials.Provider{&credentials.EnvAWS{}, &credentials.IAM{}})
+		}
+		client, err := minio.New(cfg.Endpoint, &minio.Options{Creds: creds, Secure: cfg.UseSSL, Region: cfg.Region})
+		if err != nil {
+			return nil, fmt.Errorf("failed to init minio: %w", err)
+		}
+		p.client = client
+	default:
+		p.signer = NewURLSigner(cfg.URLSecret, cfg.PublicURL)
+	}
+	return p, nil
+}
+
+// ProviderFromEnv is NewProvider with ConfigFromEnv.
+func ProviderFromEnv() (*Provider, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(cfg)
+}
+
+// Bucket returns the store for a bucket.
+func (p *Provider) Bucket(name string) (Blob, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if b, ok := p.buckets[name]; ok {
+		return b, nil
+	}
+	var b Blob
+	switch p.cfg.Backend {
+	case BackendMinIO, BackendS3:
+		b = NewMinIO(p.client, name)
+	case BackendFS:
+		fs, err := NewFS(p.cfg.Dir, name, p.signer)
+		if err != nil {
+			return nil, err
+		}
+		b = fs
+	case BackendMemory:
+		b = NewMemory(name, p.signer)
+	}
+	p.buckets[name] = b
+	return b, nil
+}
+
+// Signer returns the signer for URLs the API serves, or nil if the
+// backend has URLs of its own.
+func (p *Provider) Signer() *URLSigner {
+	return p.signer
+}
+
+// Opened returns a bucket that has been opened, for serving signed URLs.
+func (p *Provider) Opened(name string) (Blob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, ok := p.buckets[name]
+	return b, ok
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// metaDir holds each object's content type, beside the objects.
+const metaDir = ".meta"
+
+// FS stores objects as files under a directory, for single-node installs.
+// Its download URLs are signed and served by the API.
+type FS struct {
+	root   string
+	bucket string
+	signer *URLSigner
+}
+
+// NewFS stores bucket's objects under root/bucket, creating it if needed.
+func NewFS(root, bucket string, signer *URLSigner) (*FS, error) {
+	dir := filepath.Join(root, bucket)
+	if err := os.MkdirAll(filepath.Join(dir, metaDir), 0o750); err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	return &FS{root: dir, bucket: bucket, signer: signer}, nil
+}
+
+type fsMeta struct {
+	ContentType string `json:"contentType"`
+}
+
+// path maps a key to its file, refusing keys that would leave the root.
+func (f *FS) path(dir, key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key || strings.HasPrefix(clean, metaDir+"/") || clean == metaDir {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(f.root, dir, filepath.FromSlash(clean)), nil
+}
+
+func (f *FS) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	name, err := f.path("", key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	meta, _ := f.path(metaDir, key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(meta), 0o750); err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+
+	// Write beside the target and rename, so readers never see a partial
+	// object.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: failed to write %s: %w", key, err)
+	}
+	if size >= 0 && n != size {
+		return ObjectInfo{}, fmt.Errorf("storage: %s: read %d bytes, expected %d", key, n, size)
+	}
+
+	m, _ := json.Marshal(fsMeta{ContentType: contentType})
+	if err := os.WriteFile(meta, m, 0o640); err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+	return f.Stat(ctx, key)
+}
+
+func (f *FS) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	name, err := f.path("", key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+	st, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+	return file, f.info(key, st), nil
+}
+
+func (f *FS) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	name, err := f.path("", key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	st, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: %w", err)
+	}
+	return f.info(key, st), nil
+}
+
+func (f *FS) info(key string, st fs.FileInfo) ObjectInfo {
+	info := ObjectInfo{Key: key, Size: st.Size(), LastModified: st.ModTime(), ContentType: "application/octet-stream"}
+	if meta, err := f.path(metaDir, key); err == nil {
+		var m fsMeta
+		if data, err := os.ReadFile(meta); err == nil && json.Unmarshal(data, &m) == nil && m.ContentType != "" {
+			info.ContentType = m.ContentType
+		}
+	}
+	return info
+}
+
+func (f *FS) Delete(ctx context.Context, key string) error {
+	name, err := f.path("", key)
+	if err != nil {
+		return err
+	}
+	meta, _ := f.path(metaDir, key)
+	for _, p := range []string{name, meta} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("storage: %w", err)
+		}
+	}
+	return nil
+}
+
+func (f *FS) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := f.path("", key); err != nil {
+		return "", err
+	}
+	return f.signer.URL(f.bucket, key, expiry), nil
+}
+
+func (f *FS) PresignUpload(ctx context.Context, p UploadPolicy) (*PresignedUpload, error) {
+	if _, err := f.path("", p.Key); err != nil {
+		return nil, err
+	}
+	return f.signer.UploadURL(f.bucket, p)
+}
+
+func (f *FS) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return filepath.WalkDir(f.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if name == metaDir && filepath.Dir(p) == f.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(f.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		st, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(f.info(key, st))
+	})
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFSPath(t *testing.T) {
+	root := t.TempDir()
+	f, err := NewFS(root, "assets", NewURLSigner("secret", "https://api.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "assets")
+
+	tests := []struct {
+		key  string
+		want string // relative to the bucket directory; "" for an invalid key
+	}{
+		{"file.pdf", "file.pdf"},
+		{"org/uploads/x.png", filepath.Join("org", "uploads", "x.png")},
+		{"..", ""},
+		{"../x", ""},
+		{"../assets/x", ""},
+		{"a/../../x", ""},
+		{"a/../x", ""},
+		{"./x", ""},
+		{"/x", ""},
+		{"a//b", ""},
+		{"a/", ""},
+		{"", ""},
+		{".", ""},
+		{".meta", ""},
+		{".meta/x", ""},
+		{"a/.meta/x", filepath.Join("a", ".meta", "x")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := f.path("", tt.key)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("path(%q) = %q, want an error", tt.key, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("path(%q): %v", tt.key, err)
+			}
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("path(%q) = %q, want %q", tt.key, got, want)
+			}
+		})
+	}
+}
+
+func TestFSRefusesInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	f, err := NewFS(root, "assets", NewURLSigner("secret", "https://api.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../secret.txt", ".meta/x", "a//b"} {
+		if _, err := f.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, _, err := f.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want an invalid key error", key, err)
+		}
+		if err := f.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+		if _, err := f.PresignGet(ctx, key, time.Minute); err == nil {
+			t.Errorf("PresignGet(%q) succeeded", key)
+		}
+		if _, err := f.PresignUpload(ctx, UploadPolicy{Key: key, ContentType: "text/plain", MaxSize: 1, Expiry: time.Minute}); err == nil {
+			t.Errorf("PresignUpload(%q) succeeded", key)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "secret.txt")); err != nil || string(data) != "secret" {
+		t.Errorf("file outside the bucket changed: %q, %v", data, err)
+	}
+}
+
+func TestFSRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	f, err := NewFS(t.TempDir(), "assets", NewURLSigner("secret", "https://api.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Put(ctx, "org/a.txt", strings.NewReader("hello"), 6, "text/plain"); err == nil {
+		t.Error("Put with the wrong size succeeded")
+	}
+	if _, _, err := f.Get(ctx, "org/a.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("short Put left an object: %v", err)
+	}
+
+	info, err := f.Put(ctx, "org/a.txt", strings.NewReader("hello"), 5, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 5 || info.ContentType != "text/plain" {
+		t.Errorf("Put() = %+v", info)
+	}
+	r, info, err := f.Get(ctx, "org/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello" || info.ContentType != "text/plain" {
+		t.Errorf("Get() = %q, %+v", data, info)
+	}
+
+	var keys []string
+	if err := f.List(ctx, "", func(o ObjectInfo) error {
+		keys = append(keys, o.Key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "org/a.txt" {
+		t.Errorf("List() = %v, want only org/a.txt", keys)
+	}
+
+	if err := f.Delete(ctx, "org/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Stat(ctx, "org/a.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() after Delete = %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps objects in memory, for tests and trying the API out. Its
+// objects are private to the process, so the API and the worker don't
+// share them; its download URLs are signed and served by the API.
+type Memory struct {
+	bucket string
+	signer *URLSigner
+
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+func NewMemory(bucket string, signer *URLSigner) *Memory {
+	return &Memory{bucket: bucket, signer: signer, objects: map[string]memoryObject{}}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("storage: failed to read %s: %w", key, err)
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return ObjectInfo{}, fmt.Errorf("storage: %s: read %d bytes, expected %d", key, len(data), size)
+	}
+	info := ObjectInfo{Key: key, Size: int64(len(data)), ContentType: contentType, LastModified: time.Now()}
+
+	m.mu.Lock()
+	m.objects[key] = memoryObject{data: data, info: info}
+	m.mu.Unlock()
+	return info, nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	// Objects are replaced, never modified, so readers can share data.
+	return io.NopCloser(bytes.NewReader(obj.data)), obj.info, nil
+}
+
+func (m *Memory) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return obj.info, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return m.signer.URL(m.bucket, key, expiry), nil
+}
+
+func (m *Memory) PresignUpload(ctx context.Context, p UploadPolicy) (*PresignedUpload, error) {
+	return m.signer.UploadURL(m.bucket, p)
+}
+
+func (m *Memory) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	m.mu.RLock()
+	var infos []ObjectInfo
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, obj.info)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// partSize is the part size for uploads of unknown length. Each upload
+// buffers one part, so this bounds its memory.
+const partSize = 16 << 20
+
+// MinIO stores objects in a bucket of MinIO or any S3-compatible service.
+type MinIO struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewMinIO(client *minio.Client, bucket string) *MinIO {
+	return &MinIO{client: client, bucket: bucket}
+}
+
+func (m *MinIO) Ensure(ctx context.Context) error {
+	exists, err := m.client.BucketExists(ctx, m.bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return m.client.MakeBucket(ctx, m.bucket, minio.MakeBucketOptions{})
+	}
+	return nil
+}
+
+func (m *MinIO) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	info, err := m.client.PutObject(ctx, m.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    partSize,
+	})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to upload to minio: %w", err)
+	}
+	return ObjectInfo{Key: key, Size: info.Size, ContentType: contentType, LastModified: time.Now()}, nil
+}
+
+func (m *MinIO) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	obj, err := m.client.GetObject(ctx, m.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("failed to read from minio: %w", err)
+	}
+	// GetObject is lazy; Stat makes the request and reports missing keys.
+	stat, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, ObjectInfo{}, minioError(err)
+	}
+	return obj, objectInfo(stat), nil
+}
+
+func (m *MinIO) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	stat, err := m.client.StatObject(ctx, m.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, minioError(err)
+	}
+	return objectInfo(stat), nil
+}
+
+func (m *MinIO) Delete(ctx context.Context, key string) error {
+	if err := m.client.RemoveObject(ctx, m.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete from minio: %w", err)
+	}
+	return nil
+}
+
+func (m *MinIO) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := m.client.PresignedGetObject(ctx, m.bucket, key, expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign url: %w", err)
+	}
+	return u.String(), nil
+}
+
+// PresignUpload returns a POST policy, which unlike a presigned PUT can
+// limit the size.
+func (m *MinIO) PresignUpload(ctx context.Context, p UploadPolicy) (*PresignedUpload, error) {
+	expires := time.Now().Add(p.Expiry)
+	policy := minio.NewPostPolicy()
+	if err := errors.Join(
+		policy.SetBucket(m.bucket),
+		policy.SetKey(p.Key),
+		policy.SetExpires(expires),
+		policy.SetContentType(p.ContentType),
+		policy.SetContentLengthRange(1, p.MaxSize),
+	); err != nil {
+		return nil, fmt.Errorf("invalid upload policy: %w", err)
+	}
+	u, fields, err := m.client.PresignedPostPolicy(ctx, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload: %w", err)
+	}
+	return &PresignedUpload{Method: "POST", URL: u.String(), Fields: fields, ExpiresAt: expires}, nil
+}
+
+func (m *MinIO) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for obj := range m.client.ListObjects(ctx, m.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return fmt.Errorf("failed to list minio objects: %w", obj.Err)
+		}
+		if err := fn(objectInfo(obj)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func objectInfo(o minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{Key: o.Key, Size: o.Size, ContentType: o.ContentType, LastModified: o.LastModified}
+}
+
+func minioError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return fmt.Errorf("failed to read from minio: %w", err)
+}
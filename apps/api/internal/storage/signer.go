@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBadSignature is returned for signed URLs that were altered or have
+// expired.
+var ErrBadSignature = errors.New("storage: invalid or expired signature")
+
+// URLSigner makes expiring download and upload URLs for stores with no URLs
+// of their own. The API serves them at BaseURL + "/v1/storage/{bucket}/{key}".
+type URLSigner struct {
+	secret  []byte
+	baseURL string
+}
+
+func NewURLSigner(secret, baseURL string) *URLSigner {
+	return &URLSigner{secret: []byte(secret), baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// URL returns a download URL for key in bucket, valid until expiry.
+func (s *URLSigner) URL(bucket, key string, expiry time.Duration) string {
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	q := url.Values{"expires": {expires}, "signature": {s.sign(bucket, key, expires)}}
+	return s.objectURL(bucket, key, q)
+}
+
+// UploadURL returns a URL a client can PUT one object of contentType and
+// at most maxSize bytes to, until expiry.
+func (s *URLSigner) UploadURL(bucket string, p UploadPolicy) (*PresignedUpload, error) {
+	at := time.Now().Add(p.Expiry)
+	expires := strconv.FormatInt(at.Unix(), 10)
+	maxSize := strconv.FormatInt(p.MaxSize, 10)
+	q := url.Values{
+		"expires":   {expires},
+		"maxSize":   {maxSize},
+		"signature": {s.sign("upload", bucket, p.Key, expires, p.ContentType, maxSize)},
+	}
+	return &PresignedUpload{
+		Method:    "PUT",
+		URL:       s.objectURL(bucket, p.Key, q),
+		Headers:   map[string]string{"Content-Type": p.ContentType},
+		ExpiresAt: at,
+	}, nil
+}
+
+func (s *URLSigner) objectURL(bucket, key string, q url.Values) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return fmt.Sprintf("%s/v1/storage/%s/%s?%s", s.baseURL, url.PathEscape(bucket), strings.Join(segments, "/"), q.Encode())
+}
+
+// Verify checks a URL's expires and signature parameters.
+func (s *URLSigner) Verify(bucket, key, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(bucket, key, expires))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// VerifyUpload checks an upload URL's parameters against the request's
+// content type, and returns the most it may store.
+func (s *URLSigner) VerifyUpload(bucket, key, contentType, expires, maxSize, signature string) (int64, error) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return 0, ErrBadSignature
+	}
+	limit, err := strconv.ParseInt(maxSize, 10, 64)
+	if err != nil {
+		return 0, ErrBadSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign("upload", bucket, key, expires, contentType, maxSize))) {
+		return 0, ErrBadSignature
+	}
+	return limit, nil
+}
+
+// sign MACs its parts joined by newlines. Upload URLs sign a leading
+// "upload" part, so a download signature can't pass for one.
+func (s *URLSigner) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
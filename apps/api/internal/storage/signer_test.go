@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedParams splits a signed URL into the key and query the storage
+// handler would see.
+func signedParams(t *testing.T, raw, bucket string) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, ok := strings.CutPrefix(u.Path, "/v1/storage/"+bucket+"/")
+	if !ok {
+		t.Fatalf("unexpected path %q", u.Path)
+	}
+	return key, u.Query()
+}
+
+// tamper changes the last hex digit of a signature.
+func tamper(sig string) string {
+	last := sig[len(sig)-1]
+	if last == '0' {
+		return sig[:len(sig)-1] + "1"
+	}
+	return sig[:len(sig)-1] + "0"
+}
+
+func TestURLSignerVerify(t *testing.T) {
+	s := NewURLSigner("secret", "https://api.example.com/")
+	key, q := signedParams(t, s.URL("assets", "org/a b/file.pdf", time.Hour), "assets")
+	if key != "org/a b/file.pdf" {
+		t.Fatalf("key = %q", key)
+	}
+	expiredKey, expired := signedParams(t, s.URL("assets", "org/file.pdf", -time.Minute), "assets")
+	later := strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name                      string
+		signer                    *URLSigner
+		bucket, key, expires, sig string
+		wantErr                   bool
+	}{
+		{"valid", s, "assets", key, q.Get("expires"), q.Get("signature"), false},
+		{"expired", s, "assets", expiredKey, expired.Get("expires"), expired.Get("signature"), true},
+		{"extended expiry", s, "assets", key, later, q.Get("signature"), true},
+		{"tampered signature", s, "assets", key, q.Get("expires"), tamper(q.Get("signature")), true},
+		{"missing signature", s, "assets", key, q.Get("expires"), "", true},
+		{"malformed expiry", s, "assets", key, "soon", q.Get("signature"), true},
+		{"other key", s, "assets", "org/other.pdf", q.Get("expires"), q.Get("signature"), true},
+		{"other bucket", s, "render-cache", key, q.Get("expires"), q.Get("signature"), true},
+		{"other secret", NewURLSigner("other", "https://api.example.com"), "assets", key, q.Get("expires"), q.Get("signature"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.Verify(tt.bucket, tt.key, tt.expires, tt.sig)
+			if tt.wantErr && !errors.Is(err, ErrBadSignature) {
+				t.Errorf("Verify() = %v, want ErrBadSignature", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Verify() = %v", err)
+			}
+		})
+	}
+}
+
+func TestURLSignerVerifyUpload(t *testing.T) {
+	s := NewURLSigner("secret", "https://api.example.com")
+	up, err := s.UploadURL("assets", UploadPolicy{Key: "org/uploads/x.png", ContentType: "image/png", MaxSize: 1 << 20, Expiry: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up.Method != "PUT" || up.Headers["Content-Type"] != "image/png" {
+		t.Fatalf("unexpected upload %+v", up)
+	}
+	key, q := signedParams(t, up.URL, "assets")
+
+	expiredUp, _ := s.UploadURL("assets", UploadPolicy{Key: key, ContentType: "image/png", MaxSize: 1 << 20, Expiry: -time.Minute})
+	_, expired := signedParams(t, expiredUp.URL, "assets")
+
+	// A download URL for the same key must not authorise a PUT
+	_, download := signedParams(t, s.URL("assets", key, time.Hour), "assets")
+
+	tests := []struct {
+		name                                       string
+		bucket, key, contentType, expires, maxSize string
+		sig                                        string
+		wantErr                                    bool
+	}{
+		{"valid", "assets", key, "image/png", q.Get("expires"), q.Get("maxSize"), q.Get("signature"), false},
+		{"expired", "assets", key, "image/png", expired.Get("expires"), expired.Get("maxSize"), expired.Get("signature"), true},
+		{"tampered signature", "assets", key, "image/png", q.Get("expires"), q.Get("maxSize"), tamper(q.Get("signature")), true},
+		{"raised maxSize", "assets", key, "image/png", q.Get("expires"), "1073741824", q.Get("signature"), true},
+		{"malformed maxSize", "assets", key, "image/png", q.Get("expires"), "lots", q.Get("signature"), true},
+		{"other content type", "assets", key, "image/svg+xml", q.Get("expires"), q.Get("maxSize"), q.Get("signature"), true},
+		{"other key", "assets", "org/uploads/y.png", "image/png", q.Get("expires"), q.Get("maxSize"), q.Get("signature"), true},
+		{"other bucket", "render-cache", key, "image/png", q.Get("expires"), q.Get("maxSize"), q.Get("signature"), true},
+		{"download signature", "assets", key, "image/png", download.Get("expires"), q.Get("maxSize"), download.Get("signature"), true},
+		{"download signature without maxSize", "assets", key, "", download.Get("expires"), "", download.Get("signature"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := s.VerifyUpload(tt.bucket, tt.key, tt.contentType, tt.expires, tt.maxSize, tt.sig)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBadSignature) {
+					t.Errorf("VerifyUpload() = %d, %v, want ErrBadSignature", limit, err)
+				}
+				return
+			}
+			if err != nil || limit != 1<<20 {
+				t.Errorf("VerifyUpload() = %d, %v, want %d", limit, err, 1<<20)
+			}
+		})
+	}
+
+	// Nor the other way around
+	if err := s.Verify("assets", key, q.Get("expires"), q.Get("signature")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("upload signature verified as a download: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package storage stores objects (assets, generated documents, cached
+// renders) in MinIO or S3, a local directory, or memory, behind one
+// interface. Each Blob is one bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned for keys with no object.
+var ErrNotFound = errors.New("storage: object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+type Blob interface {
+	// Put stores r under key, replacing any object there. A size of -1
+	// means unknown; r is read to the end.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error)
+	// Get streams an object; the caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL anyone can download the object from until
+	// expiry.
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// PresignUpload returns how a client can upload one object straight
+	// to the store, within the policy's limits.
+	PresignUpload(ctx context.Context, policy UploadPolicy) (*PresignedUpload, error)
+	// List calls fn for each object whose key starts with prefix, until fn
+	// returns an error.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// UploadPolicy limits a presigned upload to one key, content type and
+// size, until expiry.
+type UploadPolicy struct {
+	Key         string
+	ContentType string
+	MaxSize     int64
+	Expiry      time.Duration
+}
+
+// PresignedUpload tells a client how to upload an object. For POST, send a
+// multipart form of Fields followed by the file as a field named "file";
+// for PUT, send the file as the body with Headers.
+type PresignedUpload struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Ensurer is implemented by stores whose bucket must be created before
+// use.
+type Ensurer interface {
+	Ensure(ctx context.Context) error
+}
+
+// Ensure creates b's bucket if it needs creating.
+func Ensure(ctx context.Context, b Blob) error {
+	if e, ok := b.(Ensurer); ok {
+		return e.Ensure(ctx)
+	}
+	return nil
+}
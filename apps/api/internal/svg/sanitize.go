@@ -0,0 +1,248 @@
+// Package svg makes uploaded SVG images safe to serve and render.
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalid is returned for documents that aren't well-formed SVG.
+var ErrInvalid = errors.New("invalid svg")
+
+// droppedElements are removed with everything inside them.
+var droppedElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// Sanitize rewrites an SVG document without scripts: script and
+// foreignObject elements (and other embedders), on* event attributes,
+// javascript: links, DTDs (and so entities), comments and processing
+// instructions other than the XML declaration. Nothing may load another
+// document: references other than to the document's own elements are
+// dropped, except raster data: images and the targets of plain links.
+// Styles that could run script or fetch anything are emptied.
+// Self-closing elements come out as open and close tag pairs.
+func Sanitize(data []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+
+	var out bytes.Buffer
+	root := true
+	// depth counts open elements: RawToken doesn't check they are closed
+	depth := 0
+	skip := 0
+	// style collects the text of a style element, which is checked as a
+	// whole: CDATA sections and entities split it into several tokens.
+	var style *strings.Builder
+	for {
+		// RawToken keeps namespace prefixes as written, so the output
+		// declares and uses the same ones as the input.
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root {
+				if !strings.EqualFold(t.Name.Local, "svg") {
+					return nil, fmt.Errorf("%w: root element is %s", ErrInvalid, qname(t.Name))
+				}
+				root = false
+			}
+			depth++
+			element := strings.ToLower(t.Name.Local)
+			if skip > 0 || style != nil || droppedElements[element] {
+				skip++
+				continue
+			}
+			if element == "style" {
+				style = &strings.Builder{}
+			}
+			out.WriteByte('<')
+			out.WriteString(qname(t.Name))
+			for _, a := range t.Attr {
+				if !safeAttr(element, a) {
+					continue
+				}
+				fmt.Fprintf(&out, ` %s="`, qname(a.Name))
+				xml.EscapeText(&out, []byte(a.Value))
+				out.WriteByte('"')
+			}
+			out.WriteByte('>')
+		case xml.EndElement:
+			depth--
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if style != nil {
+				if css := style.String(); !unsafeStyle(css) {
+					xml.EscapeText(&out, []byte(css))
+				}
+				style = nil
+			}
+			fmt.Fprintf(&out, "</%s>", qname(t.Name))
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if style != nil {
+				style.Write(t)
+				continue
+			}
+			xml.EscapeText(&out, t)
+		case xml.ProcInst:
+			if t.Target == "xml" && out.Len() == 0 {
+				fmt.Fprintf(&out, "<?xml %s?>", t.Inst)
+			}
+		case xml.Comment, xml.Directive:
+		}
+	}
+	if root {
+		return nil, fmt.Errorf("%w: no svg element", ErrInvalid)
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("%w: unexpected end of document", ErrInvalid)
+	}
+	return out.Bytes(), nil
+}
+
+func qname(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+func safeAttr(element string, a xml.Attr) bool {
+	name := strings.ToLower(a.Name.Local)
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+	v := normalize(a.Value)
+	if strings.Contains(v, "javascript:") || strings.Contains(v, "vbscript:") {
+		return false
+	}
+	// Presentation attributes are CSS values too (fill="url(...)").
+	if unsafeStyle(a.Value) {
+		return false
+	}
+	if name == "href" {
+		return safeHref(element, v)
+	}
+	return true
+}
+
+// safeHref reports whether element may keep an href of v (normalized).
+// Elements only refer to others in the same document; images may also be
+// raster data: URLs, and links may go anywhere but data:.
+func safeHref(element, v string) bool {
+	switch {
+	case strings.HasPrefix(v, "#"):
+		return true
+	case strings.HasPrefix(v, "data:"):
+		return (element == "image" || element == "feimage") && rasterData(v)
+	}
+	return element == "a"
+}
+
+func rasterData(v string) bool {
+	return strings.HasPrefix(v, "data:image/") && !strings.HasPrefix(v, "data:image/svg")
+}
+
+// unsafeStyle reports whether css could run script or load anything once
+// its escapes and comments are resolved.
+func unsafeStyle(css string) bool {
+	v := normalize(unescapeCSS(css))
+	if strings.Contains(v, "javascript:") || strings.Contains(v, "expression(") ||
+		strings.Contains(v, "@import") || strings.Contains(v, "-moz-binding") ||
+		strings.Contains(v, "image-set(") {
+		return true
+	}
+	for rest := v; ; {
+		i := strings.Index(rest, "url(")
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len("url("):]
+		target := strings.TrimLeft(rest, `"'`)
+		if !strings.HasPrefix(target, "#") && !rasterData(target) {
+			return true
+		}
+	}
+}
+
+// unescapeCSS resolves CSS escapes ("\69" and "\i" are both "i") and drops
+// comments, which can otherwise hide keywords from a plain text search.
+func unescapeCSS(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			i += 2 + end + 1
+		case s[i] == '\\' && i+1 < len(s):
+			j := i + 1
+			for j < len(s) && j < i+7 && isHex(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				// Any other character stands for itself
+				i++
+				b.WriteByte(s[i])
+				continue
+			}
+			n, _ := strconv.ParseUint(s[i+1:j], 16, 32)
+			r := rune(n)
+			if r == 0 || !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			b.WriteRune(r)
+			// A single whitespace ends the escape
+			if j < len(s) && strings.IndexByte(" \t\n\r\f", s[j]) >= 0 {
+				j++
+			}
+			i = j - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// normalize lowercases s and drops whitespace and control characters, which
+// browsers ignore inside URL schemes ("java\tscript:").
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,76 @@
+package svg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeRemovesScript(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		// gone must not appear in the output
+		gone string
+	}{
+		{"script element", `<svg><script>alert(1)</script></svg>`, "alert"},
+		{"event attribute", `<svg onload="alert(1)"><rect/></svg>`, "alert"},
+		{"javascript link", `<svg><a href="java&#9;script:alert(1)"><rect/></a></svg>`, "alert"},
+		{"svg data image", `<svg><image href="data:image/svg+xml;base64,PHN2Zz4="/></svg>`, "data:"},
+		{"foreign object", `<svg><foreignObject><p>hi</p></foreignObject></svg>`, "hi"},
+		{"style split by CDATA", `<svg><style>@imp<![CDATA[ort "//evil/x.css";]]></style></svg>`, "evil"},
+		{"escaped at-rule", `<svg><style>@\69mport "http://evil/x.css";</style></svg>`, "evil"},
+		{"escape ending in a space", `<svg><style>@\000069 mport "http://evil/x.css";</style></svg>`, "evil"},
+		{"comment inside a keyword", `<svg><style>a{background:u/**/rl(http://evil/x.png)}</style></svg>`, "evil"},
+		{"external url in style", `<svg><style>@font-face{src:url("https://evil/f.woff")}</style></svg>`, "evil"},
+		{"external url in attribute", `<svg><rect fill="url(http://evil/p.svg#a)"/></svg>`, "evil"},
+		{"escaped url in style attribute", `<svg><rect style="fill:\75rl(//evil/p.svg#a)"/></svg>`, "evil"},
+		{"external use", `<svg><use href="http://evil/sprite.svg#icon"/></svg>`, "evil"},
+		{"external xlink use", `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="//evil/sprite.svg#icon"/></svg>`, "evil"},
+		{"external image", `<svg><image href="https://evil/track.png"/></svg>`, "evil"},
+		{"external filter image", `<svg><filter><feImage href="https://evil/x.png"/></filter></svg>`, "evil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Sanitize([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(out), tt.gone) {
+				t.Errorf("Sanitize(%s) = %s, still contains %q", tt.in, out, tt.gone)
+			}
+		})
+	}
+}
+
+func TestSanitizeKeepsSafeContent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		kept string
+	}{
+		{"local use", `<svg><use href="#icon"/></svg>`, `href="#icon"`},
+		{"gradient fill", `<svg><rect fill="url(#grad)"/></svg>`, `fill="url(#grad)"`},
+		{"raster data image", `<svg><image href="data:image/png;base64,iVBORw0KGgo="/></svg>`, "data:image/png"},
+		{"link", `<svg><a href="https://example.com/"><rect/></a></svg>`, "https://example.com/"},
+		{"style", `<svg><style><![CDATA[.a{fill:url(#g)}]]></style></svg>`, ".a{fill:url(#g)}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Sanitize([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(out), tt.kept) {
+				t.Errorf("Sanitize(%s) = %s, want %q kept", tt.in, out, tt.kept)
+			}
+		})
+	}
+}
+
+func TestSanitizeRejectsNonSVG(t *testing.T) {
+	for _, in := range []string{`<html><body/></html>`, `<svg>`, ``} {
+		if _, err := Sanitize([]byte(in)); err == nil {
+			t.Errorf("Sanitize(%q) accepted", in)
+		}
+	}
+}
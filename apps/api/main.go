@@ -5,15 +5,23 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"template-builder-api/internal/handler"
 	"template-builder-api/internal/middleware"
 	"template-builder-api/internal/queue"
+	"template-builder-api/internal/ratelimit"
+	"template-builder-api/internal/rendercache"
+	"template-builder-api/internal/renderer"
 	"template-builder-api/internal/repository"
 	"template-builder-api/internal/service"
+	"template-builder-api/internal/signing"
+	"template-builder-api/internal/storage"
 	"template-builder-api/pkg/db"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -31,38 +39,141 @@ func main() {
 
 	// 2. Init Layers
 	repo := repository.NewPostgresRepository(pool)
-	templateService := service.NewTemplateService(repo)
+	// Background work across orgs bypasses row-level security explicitly
+	system := db.AsSystem(context.Background())
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6380"})
 
-	// MinIO credentials (default from docker-compose)
-	assetService, err := service.NewAssetService(repo, "localhost:9000", "minioadmin", "minioadmin")
+	// Object storage: MinIO, S3, a local directory or memory (STORAGE_BACKEND)
+	stores, err := storage.ProviderFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to init asset service: %v", err)
+		log.Fatalf("Failed to init storage: %v", err)
 	}
+	assetStore, err := stores.Bucket(service.AssetBucket)
+	if err != nil {
+		log.Fatalf("Failed to init asset storage: %v", err)
+	}
+	cacheStore, err := stores.Bucket(rendercache.Bucket)
+	if err != nil {
+		log.Fatalf("Failed to init render cache storage: %v", err)
+	}
+
+	// Render cache: small documents in Redis, large ones in object storage
+	renderCache, err := rendercache.FromEnv(rdb, cacheStore)
+	if err != nil {
+		log.Fatalf("Failed to init render cache: %v", err)
+	}
+	if err := renderCache.EnsureBucket(context.Background()); err != nil {
+		log.Printf("Warning: Failed to ensure render cache bucket: %v", err)
+	}
+
+	webhookService := service.NewWebhookService(repo)
+	templateService := service.NewTemplateService(repo, webhookService, renderCache)
+
+	assetService := service.NewAssetService(repo, assetStore)
 	// Ensure bucket exists on startup
 	if err := assetService.EnsureBucket(context.Background()); err != nil {
 		log.Printf("Warning: Failed to ensure bucket: %v", err)
 	}
 
-	renderService := service.NewRenderService(repo, "http://localhost:3001")
 	authService := service.NewAuthService(repo)
 
+	// Usage metering, flushed to usage_daily in the background
+	usageService := service.NewUsageService(repo)
+	go usageService.Run(system)
+
+	// Renderer pool: RENDERER_URLS, with health checks in the background
+	rendererConfig, err := renderer.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid renderer config: %v", err)
+	}
+	rendererClient := renderer.New(rendererConfig)
+	go rendererClient.Run(context.Background())
+	renderService := service.NewRenderService(repo, rendererClient, renderCache, usageService, assetService)
+
 	// Queue
 	q := queue.NewQueue("localhost:6380", "")
+	batchService := service.NewBatchService(repo, q, assetService, webhookService, usageService)
+
+	// Status events for SSE subscribers
+	statusHub := service.NewStatusEventHub(repo)
+	go statusHub.Run(context.Background())
+
+	// Synchronous renders wait at most this long before falling back to 202
+	syncTimeout := 30 * time.Second
+	if v := os.Getenv("RENDER_SYNC_TIMEOUT"); v != "" {
+		if syncTimeout, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("Invalid RENDER_SYNC_TIMEOUT: %v", err)
+		}
+	}
+	generationService := service.NewGenerationService(repo, q, statusHub, usageService)
+	scheduleService := service.NewScheduleService(repo, generationService, batchService)
+
+	// Signing certificates: private keys are sealed under SIGNING_KEY_SECRET
+	signingKeys, err := signing.KeyBoxFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to init signing keys: %v", err)
+	}
+	signingService := service.NewSigningService(repo, signingKeys, signing.TimestamperFromEnv())
+
+	// Rate limits: defaults per route group and plan, overridable with RATE_LIMITS (JSON)
+	limiter := ratelimit.NewLimiter(rdb)
+	limits := ratelimit.DefaultConfig()
+	if v := os.Getenv("RATE_LIMITS"); v != "" {
+		if limits, err = ratelimit.ParseConfig(limits, []byte(v)); err != nil {
+			log.Fatalf("Invalid RATE_LIMITS: %v", err)
+		}
+	}
+	authLimit := middleware.RateLimit(limiter, limits, "auth", nil)
+	apiLimit := middleware.RateLimit(limiter, limits, "api", usageService.PlanID)
+	generateLimit := middleware.RateLimit(limiter, limits, "generate", usageService.PlanID)
 
 	// 2.1 Init Handlers
-	generationHandler := handler.NewGenerationHandler(repo, q, assetService)
-	authHandler := handler.NewAuthHandler(authService)
+	generationHandler := handler.NewGenerationHandler(repo, generationService, assetService, syncTimeout)
+	authHandler := handler.NewAuthHandler(authService, ratelimit.NewLockout(rdb))
+
+	// Stored idempotent responses are only replayed for 24h; drop them after
+	go func() {
+		for range time.Tick(time.Hour) {
+			if n, err := repo.PurgeExpiredIdempotencyKeys(system); err != nil {
+				log.Printf("Failed to purge idempotency keys: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d expired idempotency keys", n)
+			}
+		}
+	}()
+
+	// Presigned uploads that were never completed leave objects behind
+	go func() {
+		for range time.Tick(time.Hour) {
+			if n, err := assetService.PurgeUploads(system); err != nil {
+				log.Printf("Failed to purge asset uploads: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d abandoned asset uploads", n)
+			}
+		}
+	}()
 
 	// 3. Init Router
 	r := gin.Default()
+	// Client IPs (used by the per-IP rate limits) come from X-Forwarded-For
+	// only behind these proxies: TRUSTED_PROXIES, comma-separated IPs or
+	// CIDRs. None by default, so the header is ignored.
+	var trustedProxies []string
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			trustedProxies = append(trustedProxies, strings.TrimSpace(p))
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
-	// Middleware (Simple CORS)
 	// Middleware (Simple CORS)
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Origin, X-Requested-With, Accept")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Origin, X-Requested-With, Accept, Last-Event-ID, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -74,35 +185,94 @@ func main() {
 	})
 
 	// Auth Routes
-	r.POST("/v1/register", authHandler.Register)
-	r.POST("/v1/login", authHandler.Login)
+	r.POST("/v1/register", authLimit, authHandler.Register)
+	r.POST("/v1/login", authLimit, authHandler.Login)
+	// Signed download links for local filesystem and in-memory storage
+	storageHandler := handler.NewStorageHandler(stores)
+	r.GET("/v1/storage/:bucket/*key", storageHandler.Download)
+	r.PUT("/v1/storage/:bucket/*key", storageHandler.Upload)
 	r.GET("/v1/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "renderers": rendererClient.Statuses()})
 	})
 
 	// Protected Routes
 	api := r.Group("/v1")
-	api.Use(middleware.AuthMiddleware(authService))
+	api.Use(middleware.AuthMiddleware(authService), apiLimit, middleware.UsageMiddleware(usageService))
+	idempotent := middleware.Idempotency(repo)
 	{
 		// Template Handlers
 		templateHandler := handler.NewTemplateHandler(templateService)
 		api.POST("/templates", templateHandler.CreateTemplate)
 		api.GET("/templates", templateHandler.ListTemplates)
 		api.GET("/templates/:id", templateHandler.GetTemplate)
+		api.PUT("/templates/:id/post-processing", templateHandler.UpdatePostProcessing)
 		api.GET("/templates/:id/versions", templateHandler.ListVersions)
 		api.POST("/templates/:id/versions", templateHandler.CreateVersion)
+		api.PUT("/templates/:id/versions/:version", templateHandler.UpdateVersion)
+		api.POST("/templates/:id/versions/:version/publish", templateHandler.PublishVersion)
 
 		// Assets
-		assetHandler := handler.NewAssetHandler(assetService)
-		api.POST("/assets", assetHandler.UploadAsset)
+		assetHandler := handler.NewAssetHandler(assetService, usageService)
+		api.POST("/assets", idempotent, assetHandler.UploadAsset)
+		api.GET("/assets", assetHandler.ListAssets)
+		api.GET("/assets/folders", assetHandler.ListFolders)
+		api.POST("/assets/uploads", assetHandler.CreateUpload)
+		api.POST("/assets/uploads/:id/complete", assetHandler.CompleteUpload)
+		api.GET("/assets/:id", assetHandler.GetAsset)
+		api.PUT("/assets/:id", assetHandler.UpdateAsset)
+		api.DELETE("/assets/:id", assetHandler.DeleteAsset)
 
 		// Preview
 		previewHandler := handler.NewPreviewHandler(renderService)
 		api.POST("/templates/:id/preview", previewHandler.PreviewTemplate)
 
 		// Generation
-		api.POST("/templates/:id/generate", generationHandler.GeneratePDF)
+		api.POST("/templates/:id/generate", generateLimit, idempotent, generationHandler.GeneratePDF)
+		api.POST("/templates/:id/render", generateLimit, generationHandler.RenderSync)
+		api.GET("/jobs", generationHandler.ListJobs)
 		api.GET("/jobs/:id", generationHandler.GetJobStatus)
+
+		// Status streams
+		eventsHandler := handler.NewEventsHandler(repo, statusHub)
+		api.GET("/jobs/:id/events", eventsHandler.JobEvents)
+		api.GET("/batches/:id/events", eventsHandler.BatchEvents)
+
+		// Batches
+		batchHandler := handler.NewBatchHandler(batchService)
+		api.POST("/templates/:id/batches", generateLimit, idempotent, batchHandler.CreateBatch)
+		api.GET("/batches/:id", batchHandler.GetBatch)
+		api.GET("/batches/:id/archive", batchHandler.DownloadArchive)
+
+		// Schedules
+		scheduleHandler := handler.NewScheduleHandler(scheduleService)
+		api.POST("/schedules", scheduleHandler.CreateSchedule)
+		api.GET("/schedules", scheduleHandler.ListSchedules)
+		api.GET("/schedules/:id", scheduleHandler.GetSchedule)
+		api.PUT("/schedules/:id", scheduleHandler.UpdateSchedule)
+		api.DELETE("/schedules/:id", scheduleHandler.DeleteSchedule)
+		api.GET("/schedules/:id/runs", scheduleHandler.ListRuns)
+
+		// Usage
+		usageHandler := handler.NewUsageHandler(usageService)
+		api.GET("/orgs/:id/usage", usageHandler.GetUsage)
+
+		// Signing certificates and signature verification
+		signingHandler := handler.NewSigningHandler(signingService)
+		api.POST("/signing-certificates", signingHandler.CreateCertificate)
+		api.GET("/signing-certificates", signingHandler.ListCertificates)
+		api.GET("/signing-certificates/:id", signingHandler.GetCertificate)
+		api.DELETE("/signing-certificates/:id", signingHandler.DeleteCertificate)
+		api.POST("/verify", signingHandler.Verify)
+
+		// Webhooks
+		webhookHandler := handler.NewWebhookHandler(webhookService)
+		api.POST("/webhooks", webhookHandler.CreateEndpoint)
+		api.GET("/webhooks", webhookHandler.ListEndpoints)
+		api.GET("/webhooks/:id", webhookHandler.GetEndpoint)
+		api.PUT("/webhooks/:id", webhookHandler.UpdateEndpoint)
+		api.DELETE("/webhooks/:id", webhookHandler.DeleteEndpoint)
+		api.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+		api.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
 	}
 
 	log.Println("Server starting on :8080")
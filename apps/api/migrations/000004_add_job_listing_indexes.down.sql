@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_jobs_created_by;
+DROP INDEX IF EXISTS idx_jobs_batch;
+DROP INDEX IF EXISTS idx_jobs_org_template_created;
+DROP INDEX IF EXISTS idx_jobs_org_status_created;
+DROP INDEX IF EXISTS idx_jobs_org_created;
+ALTER TABLE generation_jobs DROP COLUMN IF EXISTS batch_id;
+ALTER TABLE generation_jobs DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,9 @@
+ALTER TABLE generation_jobs ADD COLUMN created_by UUID REFERENCES users(id);
+ALTER TABLE generation_jobs ADD COLUMN batch_id UUID;
+
+-- Listing is always org-scoped and newest-first
+CREATE INDEX idx_jobs_org_created ON generation_jobs(org_id, created_at DESC);
+CREATE INDEX idx_jobs_org_status_created ON generation_jobs(org_id, status, created_at DESC);
+CREATE INDEX idx_jobs_org_template_created ON generation_jobs(org_id, template_id, created_at DESC);
+CREATE INDEX idx_jobs_batch ON generation_jobs(batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX idx_jobs_created_by ON generation_jobs(created_by) WHERE created_by IS NOT NULL;
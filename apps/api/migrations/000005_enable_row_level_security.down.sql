@@ -0,0 +1,15 @@
+DROP POLICY IF EXISTS tenant_isolation ON generation_jobs;
+ALTER TABLE generation_jobs NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE generation_jobs DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON assets;
+ALTER TABLE assets NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE assets DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON template_versions;
+ALTER TABLE template_versions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE template_versions DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON templates;
+ALTER TABLE templates NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE templates DISABLE ROW LEVEL SECURITY;
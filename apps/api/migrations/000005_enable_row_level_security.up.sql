@@ -0,0 +1,30 @@
+-- Tenant isolation, failing closed. The API sets app.org_id on every pooled
+-- connection from the authenticated request, and a connection sees a
+-- tenant's rows only when it names that tenant. Background work across
+-- tenants sets app.system instead (db.AsSystem); a connection with neither
+-- sees none. FORCE makes the policies apply to the table owner, which is
+-- the role the API connects as.
+
+ALTER TABLE templates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE templates FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON templates
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
+
+-- Versions inherit visibility from their (already filtered) template
+ALTER TABLE template_versions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE template_versions FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON template_versions
+    USING (EXISTS (SELECT 1 FROM templates t WHERE t.id = template_versions.template_id));
+
+ALTER TABLE assets ENABLE ROW LEVEL SECURITY;
+ALTER TABLE assets FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON assets
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
+
+ALTER TABLE generation_jobs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE generation_jobs FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON generation_jobs
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
@@ -0,0 +1,2 @@
+ALTER TABLE generation_jobs DROP CONSTRAINT IF EXISTS fk_jobs_batch;
+DROP TABLE IF EXISTS generation_batches;
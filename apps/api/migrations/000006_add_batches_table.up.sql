@@ -0,0 +1,28 @@
+CREATE TABLE generation_batches (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    template_id UUID NOT NULL REFERENCES templates(id),
+    version INT NOT NULL,
+    status VARCHAR(50) NOT NULL, -- processing, completed
+    source_format VARCHAR(20) NOT NULL, -- csv, jsonl
+    source_asset_id UUID REFERENCES assets(id),
+    column_mapping JSONB,
+    total INT NOT NULL DEFAULT 0,
+    completed INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    row_errors JSONB NOT NULL DEFAULT '[]',
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_batches_org_created ON generation_batches(org_id, created_at DESC);
+
+ALTER TABLE generation_jobs
+    ADD CONSTRAINT fk_jobs_batch FOREIGN KEY (batch_id) REFERENCES generation_batches(id);
+
+ALTER TABLE generation_batches ENABLE ROW LEVEL SECURITY;
+ALTER TABLE generation_batches FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON generation_batches
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
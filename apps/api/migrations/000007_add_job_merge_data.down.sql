@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_jobs_batch_row;
+CREATE INDEX idx_jobs_batch ON generation_jobs(batch_id) WHERE batch_id IS NOT NULL;
+
+ALTER TABLE generation_jobs DROP COLUMN IF EXISTS batch_row;
+ALTER TABLE generation_jobs DROP COLUMN IF EXISTS merge_data;
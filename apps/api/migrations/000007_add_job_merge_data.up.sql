@@ -0,0 +1,7 @@
+-- Merge data is kept so batch outputs can be named from it and jobs can be
+-- re-run; batch_row preserves the input order within a batch.
+ALTER TABLE generation_jobs ADD COLUMN merge_data JSONB;
+ALTER TABLE generation_jobs ADD COLUMN batch_row INT;
+
+DROP INDEX IF EXISTS idx_jobs_batch;
+CREATE INDEX idx_jobs_batch_row ON generation_jobs(batch_id, batch_row) WHERE batch_id IS NOT NULL;
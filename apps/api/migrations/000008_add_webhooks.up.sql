@@ -0,0 +1,46 @@
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    url TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_endpoints_org ON webhook_endpoints(org_id);
+
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(50) NOT NULL, -- pending, succeeded, failed
+    attempts INT NOT NULL DEFAULT 0,
+    response_code INT,
+    response_body TEXT,
+    error_message TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at DESC);
+
+ALTER TABLE webhook_endpoints ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_endpoints FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON webhook_endpoints
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON webhook_deliveries
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
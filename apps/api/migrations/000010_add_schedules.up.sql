@@ -0,0 +1,50 @@
+CREATE TABLE generation_schedules (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    template_id UUID NOT NULL REFERENCES templates(id),
+    name VARCHAR(255) NOT NULL,
+    cron_expr VARCHAR(255) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    version_policy VARCHAR(20) NOT NULL, -- latest, published, pinned
+    version INT, -- set when version_policy = 'pinned'
+    data_source VARCHAR(20) NOT NULL, -- payload, url, asset
+    payload JSONB,
+    source_url TEXT,
+    source_asset_id UUID REFERENCES assets(id),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_schedules_org ON generation_schedules(org_id, created_at DESC);
+CREATE INDEX idx_schedules_due ON generation_schedules(next_run_at) WHERE active;
+
+CREATE TABLE schedule_runs (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    schedule_id UUID NOT NULL REFERENCES generation_schedules(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL, -- pending, enqueued, failed
+    job_id UUID REFERENCES generation_jobs(id),
+    batch_id UUID REFERENCES generation_batches(id),
+    error_message TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_schedule_runs_schedule ON schedule_runs(schedule_id, scheduled_for DESC);
+
+ALTER TABLE generation_schedules ENABLE ROW LEVEL SECURITY;
+ALTER TABLE generation_schedules FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON generation_schedules
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
+
+ALTER TABLE schedule_runs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE schedule_runs FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON schedule_runs
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
@@ -0,0 +1 @@
+ALTER TABLE orgs DROP COLUMN IF EXISTS max_concurrent_jobs;
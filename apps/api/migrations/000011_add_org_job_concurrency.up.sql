@@ -0,0 +1,2 @@
+-- NULL means the worker's default limit applies.
+ALTER TABLE orgs ADD COLUMN max_concurrent_jobs INT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS usage_daily;
+ALTER TABLE orgs DROP COLUMN IF EXISTS plan_id;
+DROP TABLE IF EXISTS plans;
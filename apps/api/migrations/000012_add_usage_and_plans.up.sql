@@ -0,0 +1,37 @@
+CREATE TABLE plans (
+    id VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    -- Monthly limits; NULL means unlimited.
+    max_documents BIGINT,
+    max_pages BIGINT,
+    max_render_seconds BIGINT,
+    -- Current total of stored assets.
+    max_storage_bytes BIGINT,
+    -- Per UTC day.
+    max_api_calls_per_day BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO plans (id, name, max_documents, max_pages, max_render_seconds, max_storage_bytes, max_api_calls_per_day) VALUES
+    ('free', 'Free', 100, 1000, 600, 104857600, 1000),
+    ('pro', 'Pro', 10000, 100000, 36000, 10737418240, 100000),
+    ('enterprise', 'Enterprise', NULL, NULL, NULL, NULL, NULL);
+
+ALTER TABLE orgs ADD COLUMN plan_id VARCHAR(50) NOT NULL DEFAULT 'free' REFERENCES plans(id);
+
+-- One row per org, UTC day and metric. Counters are added to in place, so
+-- this is the daily rollup.
+CREATE TABLE usage_daily (
+    org_id UUID NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    metric VARCHAR(50) NOT NULL, -- documents, pages, render_ms, api_calls
+    value BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (org_id, day, metric)
+);
+
+ALTER TABLE usage_daily ENABLE ROW LEVEL SECURITY;
+ALTER TABLE usage_daily FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON usage_daily
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
@@ -0,0 +1,3 @@
+-- Format and layout options a job was requested with; NULL means a PDF with
+-- the template's own layout.
+ALTER TABLE generation_jobs ADD COLUMN output_options JSONB;
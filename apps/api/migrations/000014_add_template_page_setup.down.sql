@@ -0,0 +1 @@
+ALTER TABLE template_versions DROP COLUMN IF EXISTS page_setup;
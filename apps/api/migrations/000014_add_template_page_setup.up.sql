@@ -0,0 +1,3 @@
+-- Page size, orientation, margins and bleed of a version; NULL is A4
+-- portrait with the renderer's default margins.
+ALTER TABLE template_versions ADD COLUMN page_setup JSONB;
@@ -0,0 +1,3 @@
+-- Watermark, metadata, page number and protection settings applied to
+-- every PDF generated from the template.
+ALTER TABLE templates ADD COLUMN post_processing JSONB;
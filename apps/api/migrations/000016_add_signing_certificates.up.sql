@@ -0,0 +1,24 @@
+CREATE TABLE signing_certificates (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id),
+    name TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    issuer TEXT NOT NULL,
+    serial_number TEXT NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL, -- SHA-256 of the certificate, hex
+    not_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    not_after TIMESTAMP WITH TIME ZONE NOT NULL,
+    chain BYTEA[] NOT NULL,           -- DER, signing certificate first
+    sealed_key BYTEA NOT NULL,        -- AES-GCM sealed PKCS#8 private key
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_signing_certificates_org ON signing_certificates(org_id);
+CREATE INDEX idx_signing_certificates_fingerprint ON signing_certificates(org_id, fingerprint);
+
+ALTER TABLE signing_certificates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE signing_certificates FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON signing_certificates
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
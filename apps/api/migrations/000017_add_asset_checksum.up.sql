@@ -0,0 +1,2 @@
+-- SHA-256 of the stored object, hex encoded; NULL for assets uploaded before it was recorded
+ALTER TABLE assets ADD COLUMN checksum_sha256 TEXT;
@@ -0,0 +1,13 @@
+ALTER TABLE generation_batches DROP CONSTRAINT generation_batches_source_asset_id_fkey,
+    ADD CONSTRAINT generation_batches_source_asset_id_fkey
+        FOREIGN KEY (source_asset_id) REFERENCES assets(id);
+ALTER TABLE generation_jobs DROP CONSTRAINT generation_jobs_output_asset_id_fkey,
+    ADD CONSTRAINT generation_jobs_output_asset_id_fkey
+        FOREIGN KEY (output_asset_id) REFERENCES assets(id);
+
+DROP INDEX IF EXISTS idx_assets_tags;
+DROP INDEX IF EXISTS idx_assets_org_created;
+DROP INDEX IF EXISTS idx_assets_org_folder;
+
+ALTER TABLE assets DROP COLUMN IF EXISTS tags;
+ALTER TABLE assets DROP COLUMN IF EXISTS folder;
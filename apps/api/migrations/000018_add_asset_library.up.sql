@@ -0,0 +1,15 @@
+-- Asset library: folders ('' is the top level, otherwise a/b/c) and tags
+ALTER TABLE assets ADD COLUMN folder TEXT NOT NULL DEFAULT '';
+ALTER TABLE assets ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_assets_org_folder ON assets(org_id, folder);
+CREATE INDEX idx_assets_org_created ON assets(org_id, created_at DESC, id DESC);
+CREATE INDEX idx_assets_tags ON assets USING GIN (tags);
+
+-- Deleting an asset keeps the jobs and batches that produced or read it
+ALTER TABLE generation_jobs DROP CONSTRAINT generation_jobs_output_asset_id_fkey,
+    ADD CONSTRAINT generation_jobs_output_asset_id_fkey
+        FOREIGN KEY (output_asset_id) REFERENCES assets(id) ON DELETE SET NULL;
+ALTER TABLE generation_batches DROP CONSTRAINT generation_batches_source_asset_id_fkey,
+    ADD CONSTRAINT generation_batches_source_asset_id_fkey
+        FOREIGN KEY (source_asset_id) REFERENCES assets(id) ON DELETE SET NULL;
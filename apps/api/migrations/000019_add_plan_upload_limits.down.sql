@@ -0,0 +1 @@
+ALTER TABLE plans DROP COLUMN IF EXISTS max_upload_bytes;
@@ -0,0 +1,5 @@
+-- Largest single upload; NULL means only the per-type limits apply.
+ALTER TABLE plans ADD COLUMN max_upload_bytes BIGINT;
+
+UPDATE plans SET max_upload_bytes = 10485760 WHERE id = 'free';
+UPDATE plans SET max_upload_bytes = 104857600 WHERE id = 'pro';
@@ -0,0 +1,24 @@
+-- Uploads a client sends straight to storage. The asset is created with
+-- the upload's id when the client reports the upload complete.
+CREATE TABLE asset_uploads (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id),
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    s3_key TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_asset_uploads_expires ON asset_uploads(expires_at);
+
+ALTER TABLE asset_uploads ENABLE ROW LEVEL SECURITY;
+ALTER TABLE asset_uploads FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON asset_uploads
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid
+           OR current_setting('app.system', true) = 'on');
@@ -0,0 +1,4 @@
+ALTER TABLE assets DROP COLUMN IF EXISTS renditions;
+ALTER TABLE assets DROP COLUMN IF EXISTS dpi;
+ALTER TABLE assets DROP COLUMN IF EXISTS height;
+ALTER TABLE assets DROP COLUMN IF EXISTS width;
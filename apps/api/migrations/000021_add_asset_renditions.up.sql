@@ -0,0 +1,6 @@
+-- Decoded image dimensions (as displayed) and resolution, and the smaller
+-- copies made on upload: [{name, width, height, content_type, size_bytes}]
+ALTER TABLE assets ADD COLUMN width INT;
+ALTER TABLE assets ADD COLUMN height INT;
+ALTER TABLE assets ADD COLUMN dpi REAL;
+ALTER TABLE assets ADD COLUMN renditions JSONB NOT NULL DEFAULT '[]';
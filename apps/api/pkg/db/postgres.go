@@ -17,6 +17,7 @@ func NewPostgresDB(connString string) (*pgxpool.Pool, error) {
 	config.MaxConns = 10
 	config.MinConns = 2
 	config.MaxConnLifetime = time.Hour
+	config.PrepareConn = setTenant
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
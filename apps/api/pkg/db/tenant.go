@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type orgIDKey struct{}
+
+type systemKey struct{}
+
+// WithOrgID tags ctx with the tenant whose rows the row-level security
+// policies should expose to queries run with it.
+func WithOrgID(ctx context.Context, orgID uuid.UUID) context.Context {
+	return context.WithValue(ctx, orgIDKey{}, orgID)
+}
+
+// OrgIDFromContext returns the tenant set by WithOrgID, if any.
+func OrgIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	orgID, ok := ctx.Value(orgIDKey{}).(uuid.UUID)
+	return orgID, ok && orgID != uuid.Nil
+}
+
+// AsSystem tags ctx as a system context, for background work across
+// tenants (webhook dispatch, scheduling, usage flushes, purges). Queries
+// run with it see every tenant's rows. A tenant set by WithOrgID on ctx
+// or a derived context takes precedence.
+func AsSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// setTenant runs on every pool acquire so a connection never carries a
+// previous caller's app.org_id or app.system. The policies fail closed: a
+// context with neither a tenant nor AsSystem sees no tenant rows at all.
+func setTenant(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	orgID, system := "", ""
+	if id, ok := ctx.Value(orgIDKey{}).(uuid.UUID); ok {
+		// uuid.Nil matches no rows, but isn't mistaken for a system context
+		orgID = id.String()
+	} else if ctx.Value(systemKey{}) == true {
+		system = "on"
+	}
+	_, err := conn.Exec(ctx, `SELECT set_config('app.org_id', $1, false), set_config('app.system', $2, false)`, orgID, system)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
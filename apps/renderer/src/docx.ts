@@ -0,0 +1,147 @@
+import { interpolate, lookup } from './merge'
+import { PageSetup, pageSizeMm } from './output'
+import { zip } from './zip'
+
+const TWIPS_PER_MM = 1440 / 25.4
+
+function escapeXml(text: string): string {
+    return text.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;')
+}
+
+// A run of text; newlines become line breaks within the paragraph.
+function run(text: string, marks: { bold?: boolean; italic?: boolean } = {}): string {
+    const props = (marks.bold ? '<w:b/>' : '') + (marks.italic ? '<w:i/>' : '')
+    const body = text
+        .split('\n')
+        .map((line) => `<w:t xml:space="preserve">${escapeXml(line)}</w:t>`)
+        .join('<w:br/>')
+    return `<w:r>${props ? `<w:rPr>${props}</w:rPr>` : ''}${body}</w:r>`
+}
+
+function paragraph(runs: string, props = ''): string {
+    return `<w:p>${props ? `<w:pPr>${props}</w:pPr>` : ''}${runs}</w:p>`
+}
+
+const PAGE_BREAK = '<w:p><w:r><w:br w:type="page"/></w:r></w:p>'
+
+function runsFromTipTap(nodes: any[] | undefined, data?: Record<string, any>): string {
+    return (nodes || [])
+        .map((node: any) => {
+            if (node.type === 'text') {
+                const marks = (node.marks || []).map((m: any) => m.type)
+                return run(interpolate(node.text, data), { bold: marks.includes('bold'), italic: marks.includes('italic') })
+            }
+            if (node.type === 'variable') {
+                const label = node.attrs?.label || 'var'
+                const value = data ? lookup(data, label) : undefined
+                return run(value != null ? String(value) : `{{ ${label} }}`)
+            }
+            return ''
+        })
+        .join('')
+}
+
+// Lists are written as indented paragraphs with a literal bullet or number,
+// which keeps the document free of a numbering part.
+function blocksFromTipTap(node: any, data?: Record<string, any>, prefix = '', depth = 0): string {
+    switch (node.type) {
+        case 'doc':
+            return (node.content || []).map((c: any) => blocksFromTipTap(c, data)).join('')
+        case 'paragraph': {
+            const indent = depth > 0 ? `<w:ind w:left="${720 * depth}" w:hanging="360"/>` : ''
+            return paragraph((prefix ? run(prefix) : '') + runsFromTipTap(node.content, data), indent)
+        }
+        case 'heading': {
+            const level = Math.min(Math.max(node.attrs?.level || 1, 1), 3)
+            return paragraph(runsFromTipTap(node.content, data), `<w:pStyle w:val="Heading${level}"/>`)
+        }
+        case 'bulletList':
+        case 'orderedList':
+            return (node.content || [])
+                .map((item: any, i: number) => {
+                    const marker = node.type === 'bulletList' ? '•\t' : `${i + 1}.\t`
+                    return (item.content || [])
+                        .map((c: any, j: number) => blocksFromTipTap(c, data, j === 0 ? marker : '', depth + 1))
+                        .join('')
+                })
+                .join('')
+        default:
+            return ''
+    }
+}
+
+// Layout templates are positioned absolutely, which Word can't express
+// faithfully; their text is written top-to-bottom, one page per page.
+function blocksFromLayout(templateJson: any, data?: Record<string, any>): string {
+    const pages = templateJson.pages || [{ elements: templateJson.elements || [] }]
+    return pages
+        .map((page: any) =>
+            [...(page.elements || [])]
+                .filter((el: any) => el.type === 'text' || el.type === 'field')
+                .sort((a: any, b: any) => a.y - b.y || a.x - b.x)
+                .map((el: any) => paragraph(run(interpolate(el.text || '', data))))
+                .join(''),
+        )
+        .join(PAGE_BREAK)
+}
+
+// Word has no notion of bleed, so the document uses the trim size.
+function sectionProperties(page?: PageSetup): string {
+    const [w, h] = pageSizeMm(page)
+    const m = page?.margins || { top: 20, right: 20, bottom: 20, left: 20 }
+    const tw = (mm: number) => Math.round(mm * TWIPS_PER_MM)
+    const orient = page?.orientation === 'landscape' ? ' w:orient="landscape"' : ''
+    return `<w:sectPr><w:pgSz w:w="${tw(w)}" w:h="${tw(h)}"${orient}/>` +
+        `<w:pgMar w:top="${tw(m.top)}" w:right="${tw(m.right)}" w:bottom="${tw(m.bottom)}" w:left="${tw(m.left)}" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr>`
+}
+
+const W_NS = 'http://schemas.openxmlformats.org/wordprocessingml/2006/main'
+
+const CONTENT_TYPES = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+</Types>`
+
+const ROOT_RELS = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+
+const DOCUMENT_RELS = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+function headingStyle(level: number, halfPoints: number): string {
+    return `<w:style w:type="paragraph" w:styleId="Heading${level}"><w:name w:val="heading ${level}"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/>` +
+        `<w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:outlineLvl w:val="${level - 1}"/></w:pPr>` +
+        `<w:rPr><w:b/><w:sz w:val="${halfPoints}"/></w:rPr></w:style>`
+}
+
+// Matches the fonts and sizes of the HTML rendering (12pt body, 24/18pt headings).
+const STYLES = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="${W_NS}">
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Arial" w:hAnsi="Arial" w:cs="Arial"/><w:sz w:val="24"/></w:rPr></w:rPrDefault>
+<w:pPrDefault><w:pPr><w:spacing w:after="200" w:line="360" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+${headingStyle(1, 48)}
+${headingStyle(2, 36)}
+${headingStyle(3, 28)}
+</w:styles>`
+
+export function generateDocx(templateJson: any, data?: Record<string, any>, page?: PageSetup): Buffer {
+    const body = templateJson.type === 'doc' ? blocksFromTipTap(templateJson, data) : blocksFromLayout(templateJson, data)
+    const document = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="${W_NS}"><w:body>${body}${sectionProperties(page)}</w:body></w:document>`
+
+    return zip([
+        { name: '[Content_Types].xml', data: Buffer.from(CONTENT_TYPES) },
+        { name: '_rels/.rels', data: Buffer.from(ROOT_RELS) },
+        { name: 'word/_rels/document.xml.rels', data: Buffer.from(DOCUMENT_RELS) },
+        { name: 'word/document.xml', data: Buffer.from(document) },
+        { name: 'word/styles.xml', data: Buffer.from(STYLES) },
+    ])
+}
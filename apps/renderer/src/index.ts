@@ -1,5 +1,11 @@
 import Fastify from 'fastify'
+import { readFileSync } from 'fs'
+import { join } from 'path'
 import { chromium } from 'playwright'
+import { generateDocx } from './docx'
+import { interpolate, lookup } from './merge'
+import { OutputOptions, PageSetup, pageSizeMm, selectPages, sheetMarginsMm } from './output'
+import { zip } from './zip'
 
 const fastify = Fastify({ logger: true })
 
@@ -14,28 +20,58 @@ interface RenderRequest {
                 width: number
                 height: number
                 text?: string
+                // Image elements that reference an asset (assetId, and
+                // optionally a rendition) arrive with src resolved by the API.
                 src?: string
+                assetId?: string
+                rendition?: 'large' | 'medium' | 'small' | 'thumbnail' | 'auto'
                 style?: Record<string, any>
             }>
         }>
     }
+    data?: Record<string, any>
+    output?: OutputOptions
+    page?: PageSetup
+}
+
+const CONTENT_TYPES = {
+    pdf: 'application/pdf',
+    png: 'image/png',
+    jpeg: 'image/jpeg',
+    html: 'text/html; charset=utf-8',
+    docx: 'application/vnd.openxmlformats-officedocument.wordprocessingml.document',
+    zip: 'application/zip',
+}
+
+const PX_PER_MM = 96 / 25.4
+
+function marginCss(page: PageSetup | undefined, fallback: number): string {
+    const m = sheetMarginsMm(page, fallback)
+    return `${m.top}mm ${m.right}mm ${m.bottom}mm ${m.left}mm`
+}
+
+// The printed sheet: the trim size plus bleed on every side.
+function sheetSizeMm(page?: PageSetup): [number, number] {
+    const [w, h] = pageSizeMm(page)
+    const b = page?.bleed || 0
+    return [w + 2 * b, h + 2 * b]
 }
 
 // Helper to convert template JSON to HTML
 // Helper to separate Layout vs Document logic
-function htmlFromTipTap(node: any): string {
+function htmlFromTipTap(node: any, data?: Record<string, any>): string {
     if (!node) return ''
 
     if (node.type === 'doc') {
-        return node.content.map((c: any) => htmlFromTipTap(c)).join('')
+        return node.content.map((c: any) => htmlFromTipTap(c, data)).join('')
     }
 
     if (node.type === 'paragraph') {
-        return `<p>${node.content ? node.content.map((c: any) => htmlFromTipTap(c)).join('') : '<br>'}</p>`
+        return `<p>${node.content ? node.content.map((c: any) => htmlFromTipTap(c, data)).join('') : '<br>'}</p>`
     }
 
     if (node.type === 'text') {
-        let text = node.text
+        let text = interpolate(node.text, data)
         if (node.marks) {
             node.marks.forEach((mark: any) => {
                 if (mark.type === 'bold') text = `<b>${text}</b>`
@@ -47,38 +83,43 @@ function htmlFromTipTap(node: any): string {
 
     if (node.type === 'heading') {
         const level = node.attrs?.level || 1
-        return `<h${level}>${node.content ? node.content.map((c: any) => htmlFromTipTap(c)).join('') : ''}</h${level}>`
+        return `<h${level}>${node.content ? node.content.map((c: any) => htmlFromTipTap(c, data)).join('') : ''}</h${level}>`
     }
 
     if (node.type === 'bulletList') {
-        return `<ul>${node.content ? node.content.map((c: any) => htmlFromTipTap(c)).join('') : ''}</ul>`
+        return `<ul>${node.content ? node.content.map((c: any) => htmlFromTipTap(c, data)).join('') : ''}</ul>`
     }
 
     if (node.type === 'orderedList') {
-        return `<ol>${node.content ? node.content.map((c: any) => htmlFromTipTap(c)).join('') : ''}</ol>`
+        return `<ol>${node.content ? node.content.map((c: any) => htmlFromTipTap(c, data)).join('') : ''}</ol>`
     }
 
     if (node.type === 'listItem') {
-        return `<li>${node.content ? node.content.map((c: any) => htmlFromTipTap(c)).join('') : ''}</li>`
+        return `<li>${node.content ? node.content.map((c: any) => htmlFromTipTap(c, data)).join('') : ''}</li>`
     }
 
     if (node.type === 'variable') {
+        const value = data ? lookup(data, node.attrs?.label || '') : undefined
+        if (value != null) return String(value)
         return `<span style="background: #ede9fe; color: #5b21b6; padding: 2px 4px; border-radius: 4px; font-weight: 500;">{{ ${node.attrs?.label || 'var'} }}</span>`
     }
 
     return ''
 }
 
-function generateHTML(templateJson: any) {
+function generateHTML(templateJson: any, data?: Record<string, any>, page?: PageSetup) {
+    const [pageWidth, pageHeight] = sheetSizeMm(page)
+
     // Check if it's TipTap JSON (has type: 'doc')
     if (templateJson.type === 'doc') {
-        const contentHtml = htmlFromTipTap(templateJson)
+        const contentHtml = htmlFromTipTap(templateJson, data)
         return `
     <!DOCTYPE html>
     <html>
       <head>
         <style>
-          @page { size: A4; margin: 2cm; }
+          @page { size: ${pageWidth}mm ${pageHeight}mm; margin: ${marginCss(page, 20)}; }
+          @media screen { body { box-sizing: border-box; width: ${pageWidth}mm; margin: 0; padding: ${marginCss(page, 20)}; } }
           body { font-family: sans-serif; font-size: 12pt; line-height: 1.5; color: #333; }
           p { margin-bottom: 1em; }
           h1 { font-size: 24pt; margin-bottom: 0.5em; }
@@ -91,18 +132,21 @@ function generateHTML(templateJson: any) {
     `
     }
 
-    // Default to Layout Editor (existing logic)
+    // Default to Layout Editor (existing logic). Elements are positioned
+    // relative to the page's content box, inset by the margins and bleed.
+    const m = sheetMarginsMm(page, 0)
     const pageStyle = `
-    @page { size: A4; margin: 0; }
+    @page { size: ${pageWidth}mm ${pageHeight}mm; margin: 0; }
     body { margin: 0; padding: 0; font-family: sans-serif; }
     .page { 
-      width: 595pt; 
-      height: 842pt; 
+      width: ${pageWidth}mm; 
+      height: ${pageHeight}mm; 
       position: relative; 
       page-break-after: always; 
       overflow: hidden;
       background: white;
     }
+    .content { position: absolute; top: ${m.top}mm; right: ${m.right}mm; bottom: ${m.bottom}mm; left: ${m.left}mm; }
     .element { position: absolute; }
   `
 
@@ -125,15 +169,15 @@ function generateHTML(templateJson: any) {
            `
 
                 if (el.type === 'text') {
-                    elementsHtml += `<div class="element" style="${style}">${el.text || ''}</div>`
+                    elementsHtml += `<div class="element" style="${style}">${interpolate(el.text || '', data)}</div>`
                 } else if (el.type === 'image') {
                     elementsHtml += `<img class="element" src="${el.src}" style="${style}; object-fit: contain;" />`
                 } else if (el.type === 'field') {
-                    elementsHtml += `<div class="element" style="${style}; color: blue;">${el.text || '{{field}}'}</div>`
+                    elementsHtml += `<div class="element" style="${style}; color: blue;">${interpolate(el.text || '{{field}}', data)}</div>`
                 }
             })
         }
-        pagesHtml += `<div class="page">${elementsHtml}</div>`
+        pagesHtml += `<div class="page"><div class="content">${elementsHtml}</div></div>`
     })
 
     return `
@@ -145,32 +189,124 @@ function generateHTML(templateJson: any) {
   `
 }
 
+// Screenshot the selected pages: one image per layout page, or the whole
+// flowing document as a single page.
+async function renderImages(html: string, format: 'png' | 'jpeg', output: OutputOptions, page?: PageSetup) {
+    const [pageWidth] = sheetSizeMm(page)
+    const browser = await chromium.launch()
+    try {
+        const tab = await browser.newPage({
+            deviceScaleFactor: (output.dpi || 96) / 96,
+            viewport: { width: Math.ceil(pageWidth * PX_PER_MM), height: 800 },
+        })
+        await tab.setContent(html)
+
+        const options = { type: format, quality: format === 'jpeg' ? output.quality || 90 : undefined }
+        const pageElements = await tab.$$('.page')
+        if (pageElements.length === 0) {
+            return [{ number: 1, data: await tab.screenshot({ ...options, fullPage: true }) }]
+        }
+
+        const images: Array<{ number: number; data: Buffer }> = []
+        for (const index of selectPages(output.pages, pageElements.length)) {
+            images.push({ number: index + 1, data: await pageElements[index].screenshot(options) })
+        }
+        return images
+    } finally {
+        await browser.close()
+    }
+}
+
+async function renderPdf(html: string, output?: OutputOptions) {
+    const browser = await chromium.launch()
+    try {
+        const page = await browser.newPage()
+        await page.setContent(html)
+
+        // The page size and margins come from the @page rule
+        return await page.pdf({
+            preferCSSPageSize: true,
+            printBackground: true,
+            pageRanges: output?.pages || '',
+        })
+    } finally {
+        await browser.close()
+    }
+}
+
+// The API meters render time from this, so time spent queueing for or
+// talking to the renderer isn't billed.
+fastify.addHook('onSend', async (request, reply) => {
+    if (request.routeOptions.url === '/render') {
+        reply.header('Server-Timing', `render;dur=${reply.elapsedTime.toFixed(1)}`)
+    }
+})
+
 fastify.post('/render', async (request, reply) => {
     const body = request.body as RenderRequest
     if (!body.templateJson) {
         return reply.code(400).send({ error: "templateJson required" })
     }
 
-    const html = generateHTML(body.templateJson)
+    const output: OutputOptions = body.output || {}
+    const format = output.format || 'pdf'
 
-    // Launch Playwright
-    const browser = await chromium.launch()
-    const page = await browser.newPage()
+    if (format === 'docx') {
+        reply.header('Content-Type', CONTENT_TYPES.docx)
+        return reply.send(generateDocx(body.templateJson, body.data, body.page))
+    }
 
-    await page.setContent(html)
+    const html = generateHTML(body.templateJson, body.data, body.page)
 
-    // PDF Generation
-    const pdfBuffer = await page.pdf({
-        format: 'A4',
-        printBackground: true
-    })
+    // HTML export is the document itself, e.g. for an email body
+    if (format === 'html') {
+        reply.header('Content-Type', CONTENT_TYPES.html)
+        return reply.send(html)
+    }
 
-    await browser.close()
+    if (format === 'png' || format === 'jpeg') {
+        const images = await renderImages(html, format, output, body.page)
+        if (images.length === 0) {
+            return reply.code(400).send({ error: "pages selects no pages" })
+        }
+        if (images.length === 1) {
+            reply.header('Content-Type', CONTENT_TYPES[format])
+            return reply.send(images[0].data)
+        }
+        const ext = format === 'jpeg' ? 'jpg' : 'png'
+        reply.header('Content-Type', CONTENT_TYPES.zip)
+        return reply.send(zip(images.map(({ number, data }) => ({ name: `page-${number}.${ext}`, data }))))
+    }
 
-    reply.header('Content-Type', 'application/pdf')
+    const pdfBuffer = await renderPdf(html, output)
+    reply.header('Content-Type', CONTENT_TYPES.pdf)
     reply.send(pdfBuffer)
 })
 
+// The API keys its render cache on this, so anything that changes output
+// must change it: the package version, RENDERER_BUILD for unreleased
+// builds, and the bundled Chromium.
+const packageVersion: string = JSON.parse(readFileSync(join(__dirname, '..', 'package.json'), 'utf8')).version
+let chromiumVersion: Promise<string> | undefined
+
+fastify.get('/version', async () => {
+    if (!chromiumVersion) {
+        chromiumVersion = chromium.launch().then(async (browser) => {
+            try {
+                return browser.version()
+            } finally {
+                await browser.close()
+            }
+        })
+        chromiumVersion.catch(() => { chromiumVersion = undefined })
+    }
+    return {
+        version: packageVersion,
+        build: process.env.RENDERER_BUILD || '',
+        chromium: await chromiumVersion,
+    }
+})
+
 const start = async () => {
     try {
         await fastify.listen({ port: 3001, host: '0.0.0.0' })
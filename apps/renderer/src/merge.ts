@@ -0,0 +1,14 @@
+// Resolve a dotted path such as "customer.name" against merge data
+export function lookup(data: Record<string, any> | undefined, path: string): any {
+    return path.split('.').reduce((acc: any, key) => (acc == null ? undefined : acc[key]), data)
+}
+
+// Replace {{ path }} placeholders when merge data is supplied; without data
+// the placeholders are left visible, which is what previews want.
+export function interpolate(text: string, data?: Record<string, any>): string {
+    if (!data) return text
+    return text.replace(/\{\{\s*([\w.]+)\s*\}\}/g, (_match, path: string) => {
+        const value = lookup(data, path)
+        return value == null ? '' : String(value)
+    })
+}
@@ -0,0 +1,59 @@
+export interface Margins {
+    top: number
+    right: number
+    bottom: number
+    left: number
+}
+
+// Mirrors model.PageSetup in the API, already merged with any overrides.
+// All dimensions are in millimetres.
+export interface PageSetup {
+    pageSize?: string
+    pageWidth?: number
+    pageHeight?: number
+    orientation?: 'portrait' | 'landscape'
+    margins?: Margins
+    bleed?: number
+}
+
+// Mirrors model.OutputOptions in the API; page geometry comes in PageSetup.
+export interface OutputOptions {
+    format?: 'pdf' | 'png' | 'jpeg' | 'html' | 'docx'
+    dpi?: number
+    quality?: number
+    pages?: string
+}
+
+const PAGE_SIZES_MM: Record<string, [number, number]> = {
+    A3: [297, 420],
+    A4: [210, 297],
+    A5: [148, 210],
+    Letter: [215.9, 279.4],
+    Legal: [215.9, 355.6],
+}
+
+// Trim width and height of the page in millimetres.
+export function pageSizeMm(page?: PageSetup): [number, number] {
+    const [w, h] = page?.pageSize === 'custom' && page.pageWidth && page.pageHeight
+        ? [page.pageWidth, page.pageHeight]
+        : PAGE_SIZES_MM[page?.pageSize || 'A4'] || PAGE_SIZES_MM.A4
+    return page?.orientation === 'landscape' ? [Math.max(w, h), Math.min(w, h)] : [w, h]
+}
+
+// Margins measured from the edge of the sheet, i.e. including the bleed.
+export function sheetMarginsMm(page: PageSetup | undefined, fallback: number): Margins {
+    const b = page?.bleed || 0
+    const m = page?.margins || { top: fallback, right: fallback, bottom: fallback, left: fallback }
+    return { top: m.top + b, right: m.right + b, bottom: m.bottom + b, left: m.left + b }
+}
+
+// Expand "1-3,5" into zero-based page indexes below count.
+export function selectPages(ranges: string | undefined, count: number): number[] {
+    if (!ranges) return Array.from({ length: count }, (_v, i) => i)
+    const selected = new Set<number>()
+    for (const part of ranges.split(',')) {
+        const [from, to] = part.split('-').map(Number)
+        for (let n = from; n <= (to ?? from) && n <= count; n++) selected.add(n - 1)
+    }
+    return [...selected].sort((a, b) => a - b)
+}
@@ -0,0 +1,59 @@
+import { crc32, deflateRawSync } from 'zlib'
+
+export interface ZipEntry {
+    name: string
+    data: Buffer
+}
+
+// Minimal ZIP writer (deflate, no ZIP64), enough for packaging rendered
+// pages and DOCX parts without pulling in an archive library.
+export function zip(entries: ZipEntry[]): Buffer {
+    const chunks: Buffer[] = []
+    const central: Buffer[] = []
+    let offset = 0
+
+    for (const entry of entries) {
+        const name = Buffer.from(entry.name, 'utf8')
+        const compressed = deflateRawSync(entry.data)
+        const crc = crc32(entry.data)
+
+        const local = Buffer.alloc(30)
+        local.writeUInt32LE(0x04034b50, 0)
+        local.writeUInt16LE(20, 4) // version needed
+        local.writeUInt16LE(0x0800, 6) // UTF-8 names
+        local.writeUInt16LE(8, 8) // deflate
+        local.writeUInt32LE(0, 10) // mod time/date
+        local.writeUInt32LE(crc, 14)
+        local.writeUInt32LE(compressed.length, 18)
+        local.writeUInt32LE(entry.data.length, 22)
+        local.writeUInt16LE(name.length, 26)
+        local.writeUInt16LE(0, 28)
+        chunks.push(local, name, compressed)
+
+        const header = Buffer.alloc(46)
+        header.writeUInt32LE(0x02014b50, 0)
+        header.writeUInt16LE(20, 4) // version made by
+        header.writeUInt16LE(20, 6)
+        header.writeUInt16LE(0x0800, 8)
+        header.writeUInt16LE(8, 10)
+        header.writeUInt32LE(0, 12)
+        header.writeUInt32LE(crc, 16)
+        header.writeUInt32LE(compressed.length, 20)
+        header.writeUInt32LE(entry.data.length, 24)
+        header.writeUInt16LE(name.length, 28)
+        header.writeUInt32LE(offset, 42)
+        central.push(header, name)
+
+        offset += local.length + name.length + compressed.length
+    }
+
+    const centralSize = central.reduce((n, b) => n + b.length, 0)
+    const end = Buffer.alloc(22)
+    end.writeUInt32LE(0x06054b50, 0)
+    end.writeUInt16LE(entries.length, 8)
+    end.writeUInt16LE(entries.length, 10)
+    end.writeUInt32LE(centralSize, 12)
+    end.writeUInt32LE(offset, 16)
+
+    return Buffer.concat([...chunks, ...central, end])
+}